@@ -0,0 +1,43 @@
+package daemon
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/SafetyCulture/safetyculture-exporter/cmd/safetyculture-exporter/cmd/export"
+	util "github.com/SafetyCulture/safetyculture-exporter/cmd/safetyculture-exporter/cmd/utils"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// Cmd is used to run the exports on a schedule
+func Cmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "daemon",
+		Short: "Run exports on a schedule",
+		Long: `Runs an export on the cron schedule set in daemon.schedule. Runs never overlap, failed runs are retried
+and a running export is cancelled when the daemon receives SIGTERM or SIGINT.`,
+		Example: `// Export to CSV every day at 2am
+safetyculture-exporter daemon --schedule "0 2 * * *" --export-type csv
+
+// Export to a SQL database every hour
+safetyculture-exporter daemon --schedule "@hourly" --export-type sql --db-dialect postgres --db-connection-string "..."`,
+		RunE: runDaemon,
+	}
+}
+
+func runDaemon(*cobra.Command, []string) error {
+	exp := export.NewSafetyCultureExporter(viper.GetViper())
+	d, err := exp.NewDaemon()
+	util.Check(err, "failed to initialize the daemon")
+	defer export.ServeMetrics(exp)()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	err = d.Run(ctx)
+	util.Check(err, "error while running the daemon")
+	return nil
+}
@@ -1,6 +1,8 @@
 package export
 
 import (
+	"os"
+
 	util "github.com/SafetyCulture/safetyculture-exporter/cmd/safetyculture-exporter/cmd/utils"
 	"github.com/SafetyCulture/safetyculture-exporter/internal/app/version"
 	exporterAPI "github.com/SafetyCulture/safetyculture-exporter/pkg/api"
@@ -31,11 +33,42 @@ func CSVCmd() *cobra.Command {
 safetyculture-exporter csv --template-ids template_F492E54D87F2419E9398F7BDCA0FA5D9,template_d54e06808d2f11e2893e83a731dba0ca
 
 // Customise export location
-safetyculture-exporter csv --export-path /path/to/export/to`,
+safetyculture-exporter csv --export-path /path/to/export/to
+
+// Write rows to the csv files as they are downloaded
+safetyculture-exporter csv --streaming`,
 		RunE: runCSV,
 	}
 }
 
+// ParquetCmd is used to export data in parquet format
+func ParquetCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "parquet",
+		Short: "Export SafetyCulture data to Parquet files",
+		Example: `// Limit inspections and schedules to these templates
+safetyculture-exporter parquet --template-ids template_F492E54D87F2419E9398F7BDCA0FA5D9,template_d54e06808d2f11e2893e83a731dba0ca
+
+// Customise export location
+safetyculture-exporter parquet --export-path /path/to/export/to`,
+		RunE: runParquet,
+	}
+}
+
+// JSONLCmd is used to export data in newline delimited json format
+func JSONLCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "jsonl",
+		Short: "Export SafetyCulture data to newline delimited JSON files",
+		Example: `// Limit inspections and schedules to these templates
+safetyculture-exporter jsonl --template-ids template_F492E54D87F2419E9398F7BDCA0FA5D9,template_d54e06808d2f11e2893e83a731dba0ca
+
+// Compress the exported files
+safetyculture-exporter jsonl --gzip --export-path /path/to/export/to`,
+		RunE: runJSONL,
+	}
+}
+
 // SQLiteCmd is used to export data into SQLite db
 func SQLiteCmd() *cobra.Command {
 	return &cobra.Command{
@@ -67,28 +100,41 @@ safetyculture-exporter inspection-json --export-path /path/to/export/to`,
 // PrintSchemaCmd is used to print table schemas
 func PrintSchemaCmd() *cobra.Command {
 	return &cobra.Command{
-		Use:     "schema",
-		Short:   "Print SafetyCulture table schemas",
-		Example: `safetyculture-exporter schema`,
-		RunE:    printSchema,
+		Use:   "schema",
+		Short: "Print SafetyCulture table schemas",
+		Example: `// Print the columns of each table
+safetyculture-exporter schema
+
+// Print the statements creating the tables in a Postgres database
+safetyculture-exporter schema --format ddl --dialect postgres
+
+// Generate the dbt sources of the tables
+safetyculture-exporter schema --format dbt > models/sources.yml`,
+		RunE: printSchema,
 	}
 }
 
 // ReportCmd is used to download inspection reports
 func ReportCmd() *cobra.Command {
-	return &cobra.Command{
+	cmd := &cobra.Command{
 		Use:   "report",
 		Short: "Export inspection report",
 		Example: `// Export PDF and Word inspection reports
 		safetyculture-exporter report --export-path /path/to/export/to --format PDF,WORD
 		// Export PDF inspection reports with a custom report layout
-		safetyculture-exporter report --export-path /path/to/export/to --format PDF --preference-id abc`,
+		safetyculture-exporter report --export-path /path/to/export/to --format PDF --preference-id abc
+		// Generate again the reports that failed during the previous exports
+		safetyculture-exporter report --export-path /path/to/export/to --retry-failed`,
 		RunE: runInspectionReports,
 	}
+	cmd.AddCommand(reportStatusCmd())
+	return cmd
 }
 
 func runSQL(*cobra.Command, []string) error {
 	exp := NewSafetyCultureExporter(viper.GetViper())
+	defer ServeMetrics(exp)()
+
 	err := exp.RunSQL()
 	util.Check(err, "error while exporting SQL")
 	return nil
@@ -103,13 +149,35 @@ func runInspectionJSON(*cobra.Command, []string) error {
 
 func runCSV(*cobra.Command, []string) error {
 	exp := NewSafetyCultureExporter(viper.GetViper())
+	defer ServeMetrics(exp)()
+
 	err := exp.RunCSV()
 	util.Check(err, "error while exporting CSV")
 	return nil
 }
 
+func runParquet(*cobra.Command, []string) error {
+	exp := NewSafetyCultureExporter(viper.GetViper())
+	defer ServeMetrics(exp)()
+
+	err := exp.RunParquet()
+	util.Check(err, "error while exporting Parquet")
+	return nil
+}
+
+func runJSONL(*cobra.Command, []string) error {
+	exp := NewSafetyCultureExporter(viper.GetViper())
+	defer ServeMetrics(exp)()
+
+	err := exp.RunJSONL()
+	util.Check(err, "error while exporting JSONL")
+	return nil
+}
+
 func runSQLite(*cobra.Command, []string) error {
 	exp := NewSafetyCultureExporter(viper.GetViper())
+	defer ServeMetrics(exp)()
+
 	err := exp.RunSQLite()
 	util.Check(err, "error while exporting SQLITE")
 	return nil
@@ -117,18 +185,27 @@ func runSQLite(*cobra.Command, []string) error {
 
 func printSchema(*cobra.Command, []string) error {
 	exp := NewSafetyCultureExporter(viper.GetViper())
-	err := exp.RunPrintSchema()
+	err := exp.WriteSchemas(os.Stdout, viper.GetString("schema.format"), viper.GetString("schema.dialect"))
 	util.Check(err, "error while printing schema")
 	return nil
 }
 
 func runInspectionReports(*cobra.Command, []string) error {
 	exp := NewSafetyCultureExporter(viper.GetViper())
+	defer ServeMetrics(exp)()
+
 	err := exp.RunInspectionReports()
 	util.Check(err, "failed to generate reports")
 	return nil
 }
 
+// ServeMetrics starts the metrics endpoint when enabled, the returned func stops it
+func ServeMetrics(exp *exporterAPI.SafetyCultureExporter) func() {
+	stop, err := exp.StartMetricsServer()
+	util.Check(err, "failed to start the metrics endpoint")
+	return stop
+}
+
 // NewSafetyCultureExporter create a new SafetyCultureExporter with configuration from Viper
 func NewSafetyCultureExporter(v *viper.Viper) *exporterAPI.SafetyCultureExporter {
 	cm, err := exporterAPI.NewConfigurationManagerFromFile("", v.ConfigFileUsed())
@@ -151,6 +228,12 @@ func NewSafetyCultureExporter(v *viper.Viper) *exporterAPI.SafetyCultureExporter
 // MapViperConfigToExporterConfiguration maps Viper config to ExporterConfiguration structure
 func MapViperConfigToExporterConfiguration(v *viper.Viper, cfg *exporterAPI.ExporterConfiguration) {
 	cfg.AccessToken = v.GetString("access_token")
+	if apiURL := v.GetString("api.url"); apiURL != "" {
+		cfg.API.URL = apiURL
+	}
+	if sheqsyURL := v.GetString("api.sheqsy_url"); sheqsyURL != "" {
+		cfg.API.SheqsyURL = sheqsyURL
+	}
 	cfg.API.MaxConcurrency = v.GetInt("api.max_concurrency")
 	cfg.SheqsyUsername = v.GetString("sheqsy_username")
 	cfg.SheqsyCompanyID = v.GetString("sheqsy_company_id")
@@ -158,8 +241,23 @@ func MapViperConfigToExporterConfiguration(v *viper.Viper, cfg *exporterAPI.Expo
 	cfg.Db.ConnectionString = v.GetString("db.connection_string")
 	cfg.Db.AutoMigrateDisabled = v.GetBool("db.auto_migrate_disabled")
 	cfg.Csv.MaxRowsPerFile = v.GetInt("csv.max_rows_per_file")
+	cfg.Csv.Streaming = v.GetBool("csv.streaming")
+	cfg.Parquet.MaxRowsPerFile = v.GetInt("parquet.max_rows_per_file")
+	cfg.Jsonl.MaxRowsPerFile = v.GetInt("jsonl.max_rows_per_file")
+	cfg.Jsonl.Gzip = v.GetBool("jsonl.gzip")
 	cfg.Export.Path = v.GetString("export.path")
 	cfg.Export.Incremental = v.GetBool("export.incremental")
+	cfg.Export.Resume = v.GetBool("export.resume")
+	cfg.Export.AtomicRefresh = v.GetBool("export.atomic_refresh")
+	cfg.Export.HistoryTables = v.GetStringSlice("export.history_tables")
+	cfg.Export.PivotTemplateIds = v.GetStringSlice("export.pivot_template_ids")
+	if deletionMode := v.GetString("export.deletion_mode"); deletionMode != "" {
+		cfg.Export.DeletionMode = deletionMode
+	}
+	if timeZone := v.GetString("export.time_zone"); timeZone != "" {
+		cfg.Export.TimeZone = timeZone
+	}
+	cfg.Export.KeepUTCTime = v.GetBool("export.time_zone_keep_utc")
 	cfg.Export.ModifiedAfter.Time = v.GetTime("export.modified_after")
 	cfg.Export.TemplateIds = v.GetStringSlice("export.template_ids")
 	cfg.Export.Tables = v.GetStringSlice("export.tables")
@@ -174,10 +272,31 @@ func MapViperConfigToExporterConfiguration(v *viper.Viper, cfg *exporterAPI.Expo
 	cfg.Export.Site.IncludeFullHierarchy = v.GetBool("export.site.include_full_hierarchy")
 	cfg.Export.Media = v.GetBool("export.media")
 	cfg.Export.MediaPath = v.GetString("export.media_path")
+	cfg.Export.MediaStore = v.GetString("export.media_store")
+	cfg.Export.MediaDownload.Concurrency = v.GetInt("export.media_download.concurrency")
+	cfg.Export.MediaDownload.Retries = v.GetInt("export.media_download.retries")
+	cfg.Export.ObjectStorage.URL = v.GetString("export.object_storage.url")
 	cfg.Export.Action.Limit = v.GetInt("export.action.limit")
 	cfg.Export.Issue.Limit = v.GetInt("export.issue.limit")
 	cfg.Report.Format = v.GetStringSlice("report.format")
 	cfg.Report.PreferenceID = v.GetString("report.preference_id")
 	cfg.Report.FilenameConvention = v.GetString("report.filename_convention")
+	cfg.Report.PathTemplate = v.GetString("report.path_template")
 	cfg.Report.RetryTimeout = v.GetInt("report.retry_timeout")
+	cfg.Report.Concurrency = v.GetInt("report.concurrency")
+	cfg.Report.RetryFailed = v.GetBool("report.retry_failed")
+	cfg.Report.Source = v.GetString("report.source")
+	cfg.Report.Filter.SiteIDs = v.GetStringSlice("report.filter.site_ids")
+	cfg.Report.Filter.TemplateIDs = v.GetStringSlice("report.filter.template_ids")
+	cfg.Report.Filter.DateFrom.Time = v.GetTime("report.filter.date_from")
+	cfg.Report.Filter.DateTo.Time = v.GetTime("report.filter.date_to")
+	cfg.Report.Filter.ScoreMin = v.GetFloat64("report.filter.score_min")
+	cfg.Report.Filter.ScoreMax = v.GetFloat64("report.filter.score_max")
+	cfg.Report.Filter.AuditIDsFile = v.GetString("report.filter.audit_ids_file")
+	cfg.Report.BundleBy = v.GetStringSlice("report.bundle_by")
+	cfg.Metrics.Enabled = v.GetBool("metrics.enabled")
+	cfg.Metrics.Address = v.GetString("metrics.address")
+	cfg.Daemon.Schedule = v.GetString("daemon.schedule")
+	cfg.Daemon.ExportType = v.GetString("daemon.export_type")
+	cfg.Daemon.LockFile = v.GetString("daemon.lock_file")
 }
@@ -16,7 +16,8 @@ func TestPrintSchemaCmd(t *testing.T) {
 	require.NotNil(t, res)
 	assert.EqualValues(t, "schema", res.Use)
 	assert.EqualValues(t, "Print SafetyCulture table schemas", res.Short)
-	assert.EqualValues(t, "safetyculture-exporter schema", res.Example)
+	assert.Contains(t, res.Example, "safetyculture-exporter schema\n")
+	assert.Contains(t, res.Example, "safetyculture-exporter schema --format dbt")
 }
 
 func TestReportCmd(t *testing.T) {
@@ -0,0 +1,63 @@
+package export
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	util "github.com/SafetyCulture/safetyculture-exporter/cmd/safetyculture-exporter/cmd/utils"
+	exporterAPI "github.com/SafetyCulture/safetyculture-exporter/pkg/api"
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// reportStatusCmd is used to list the state of the reports of the previous exports
+func reportStatusCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "status",
+		Short: "List the pending, failed and succeeded inspection reports",
+		Example: `// List the state of the PDF reports exported to a folder
+safetyculture-exporter report status --export-path /path/to/export/to --format PDF`,
+		Args: cobra.NoArgs,
+		RunE: runReportStatus,
+	}
+}
+
+func runReportStatus(*cobra.Command, []string) error {
+	exp := NewSafetyCultureExporter(viper.GetViper())
+	statuses, err := exp.ListReportStatuses()
+	util.Check(err, "while listing report statuses")
+	printReportStatuses(statuses)
+	return nil
+}
+
+func printReportStatuses(statuses []exporterAPI.ReportStatusResponseItem) {
+	counts := map[string]int{}
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"Audit ID", "Name", "Status", "Attempts", "Last Attempt", "Path", "Error"})
+	for _, s := range statuses {
+		counts[s.Status]++
+
+		lastAttempt := "-"
+		if s.LastAttemptAt != nil {
+			lastAttempt = s.LastAttemptAt.Local().Format(time.RFC3339)
+		}
+
+		table.Append([]string{
+			s.AuditID,
+			s.Name,
+			s.Status,
+			strconv.Itoa(s.Attempts),
+			lastAttempt,
+			strings.Join(s.Paths, "\n"),
+			strings.Join(s.Errors, "\n"),
+		})
+	}
+	table.Render()
+
+	fmt.Printf("\n%d pending, %d failed, %d succeeded\n", counts["PENDING"], counts["FAILED"], counts["SUCCEEDED"])
+}
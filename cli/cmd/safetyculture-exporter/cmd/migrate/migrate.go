@@ -0,0 +1,116 @@
+package migrate
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/SafetyCulture/safetyculture-exporter/cmd/safetyculture-exporter/cmd/export"
+	util "github.com/SafetyCulture/safetyculture-exporter/cmd/safetyculture-exporter/cmd/utils"
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// Cmd is used to manage the schema of the SQL database with versioned migrations
+func Cmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "migrate",
+		Short: "Manage the schema of the SQL database",
+		Long: `Manages the schema of the SQL database with versioned migrations, for databases where the account running the
+exports isn't granted the rights to create or alter tables. Once a migration is applied, exports run with
+--db-auto-migrate-disabled refuse to run against an out-of-date schema.`,
+		Example: `// Review the DDL bringing the database up to date
+safetyculture-exporter migrate plan --db-dialect postgres --db-connection-string "..." --output migration.sql
+
+// Apply it with an account allowed to alter the schema
+safetyculture-exporter migrate apply --db-dialect postgres --db-connection-string "..."
+
+// Check the applied migrations
+safetyculture-exporter migrate status --db-dialect postgres --db-connection-string "..."`,
+	}
+
+	cmd.AddCommand(
+		&cobra.Command{
+			Use:   "plan",
+			Short: "Print the DDL bringing the schema of the database up to date",
+			Args:  cobra.NoArgs,
+			RunE:  runPlan,
+		},
+		&cobra.Command{
+			Use:   "apply",
+			Short: "Bring the schema of the database up to date",
+			Args:  cobra.NoArgs,
+			RunE:  runApply,
+		},
+		&cobra.Command{
+			Use:   "status",
+			Short: "List the migrations applied to the database",
+			Args:  cobra.NoArgs,
+			RunE:  runStatus,
+		},
+	)
+	return cmd
+}
+
+func runPlan(*cobra.Command, []string) error {
+	exp := export.NewSafetyCultureExporter(viper.GetViper())
+	plan, err := exp.PlanSchemaMigration()
+	util.Check(err, "while planning the schema migration")
+
+	if output := viper.GetString("migrate.output"); output != "" {
+		util.Check(os.WriteFile(output, []byte(plan.Script), 0o644), "while writing the schema migration")
+		fmt.Printf("Schema migration %s written to %s\n", plan.Version, output)
+		return nil
+	}
+
+	if plan.UpToDate {
+		fmt.Printf("-- the database schema is up to date (version %s)\n", plan.Version)
+		return nil
+	}
+	fmt.Print(plan.Script)
+	return nil
+}
+
+func runApply(*cobra.Command, []string) error {
+	exp := export.NewSafetyCultureExporter(viper.GetViper())
+	plan, err := exp.ApplySchemaMigration()
+	util.Check(err, "while applying the schema migration")
+
+	if plan.UpToDate {
+		fmt.Printf("The database schema is up to date (version %s)\n", plan.Version)
+		return nil
+	}
+	fmt.Printf("Schema migration %s applied, %d statements run (%d destructive)\n", plan.Version, len(plan.Statements), len(plan.Destructive))
+	return nil
+}
+
+func runStatus(*cobra.Command, []string) error {
+	exp := export.NewSafetyCultureExporter(viper.GetViper())
+	plan, err := exp.PlanSchemaMigration()
+	util.Check(err, "while planning the schema migration")
+	migrations, err := exp.ListSchemaMigrations()
+	util.Check(err, "while listing the schema migrations")
+
+	status := "up to date"
+	if !plan.UpToDate {
+		status = fmt.Sprintf("out of date, %d statements pending (%d destructive)", len(plan.Statements), len(plan.Destructive))
+	}
+	fmt.Printf("Dialect:          %s\n", plan.Dialect)
+	fmt.Printf("Current Version:  %s\n", plan.CurrentVersion)
+	fmt.Printf("Expected Version: %s\n", plan.Version)
+	fmt.Printf("Status:           %s\n\n", status)
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"Version", "Applied At", "Statements"})
+	for _, m := range migrations {
+		table.Append([]string{
+			m.Version,
+			m.AppliedAt.Local().Format(time.RFC3339),
+			strconv.Itoa(m.Statements),
+		})
+	}
+	table.Render()
+	return nil
+}
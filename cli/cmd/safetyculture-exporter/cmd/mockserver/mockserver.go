@@ -0,0 +1,72 @@
+package mockserver
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	util "github.com/SafetyCulture/safetyculture-exporter/cmd/safetyculture-exporter/cmd/utils"
+	"github.com/SafetyCulture/safetyculture-exporter/pkg/logger"
+	"github.com/SafetyCulture/safetyculture-exporter/pkg/mockapi"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// Cmd is used to serve a mock of the SafetyCulture API
+func Cmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "mock-server",
+		Short: "Serve a mock of the SafetyCulture API",
+		Long: `Serves the feeds, inspections, media, reports and SHEQSY endpoints used by the exporter from a fixture directory,
+so that exports can be tried out without access to an organisation. The fixtures shipped with the exporter are served
+when no directory is given.`,
+		Example: `// Serve the fixtures shipped with the exporter
+safetyculture-exporter mock-server --address localhost:8080
+
+// Export to CSV from the mock server
+safetyculture-exporter csv --api-url http://localhost:8080 --access-token mock`,
+		RunE: runMockServer,
+	}
+}
+
+func runMockServer(*cobra.Command, []string) error {
+	fixtures := mockapi.Seed()
+	if dir := viper.GetString("mock_server.fixtures"); dir != "" {
+		_, err := os.Stat(dir)
+		util.Check(err, "while opening the fixture directory")
+		fixtures = os.DirFS(dir)
+	}
+
+	return serve(viper.GetString("mock_server.address"), viper.GetInt("mock_server.page_size"), fixtures)
+}
+
+func serve(address string, pageSize int, fixtures fs.FS) error {
+	mock := mockapi.NewServer(fixtures)
+	if pageSize > 0 {
+		mock.PageSize = pageSize
+	}
+
+	srv := &http.Server{
+		Addr:              address,
+		Handler:           mock,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		_ = srv.Shutdown(context.Background())
+	}()
+
+	logger.GetLogger().Infof("serving the mock API on %s", address)
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		util.Check(err, "while serving the mock API")
+	}
+	return nil
+}
@@ -6,7 +6,11 @@ import (
 	"strings"
 
 	"github.com/SafetyCulture/safetyculture-exporter/cmd/safetyculture-exporter/cmd/configure"
+	"github.com/SafetyCulture/safetyculture-exporter/cmd/safetyculture-exporter/cmd/daemon"
 	"github.com/SafetyCulture/safetyculture-exporter/cmd/safetyculture-exporter/cmd/export"
+	"github.com/SafetyCulture/safetyculture-exporter/cmd/safetyculture-exporter/cmd/migrate"
+	"github.com/SafetyCulture/safetyculture-exporter/cmd/safetyculture-exporter/cmd/mockserver"
+	"github.com/SafetyCulture/safetyculture-exporter/cmd/safetyculture-exporter/cmd/runs"
 	util "github.com/SafetyCulture/safetyculture-exporter/cmd/safetyculture-exporter/cmd/utils"
 	"github.com/SafetyCulture/safetyculture-exporter/internal/app/version"
 	"github.com/SafetyCulture/safetyculture-exporter/pkg/update"
@@ -19,8 +23,8 @@ import (
 )
 
 var cfgFile string
-var connectionFlags, dbFlags, sqliteFlags, csvFlags, exportFlags, mediaFlags, inspectionFlags, actionFlags,
-	templatesFlag, tablesFlag, schemasFlag, reportFlags, sitesFlags *flag.FlagSet
+var connectionFlags, dbFlags, sqliteFlags, csvFlags, parquetFlags, jsonlFlags, exportFlags, mediaFlags, inspectionFlags, actionFlags,
+	templatesFlag, tablesFlag, schemasFlag, reportFlags, sitesFlags, runsFlags, daemonFlags, metricsFlags, mockServerFlags, migrateFlags, printSchemaFlags *flag.FlagSet
 
 // RootCmd represents the base command when called without any subcommands.
 var RootCmd = &cobra.Command{
@@ -33,7 +37,7 @@ var RootCmd = &cobra.Command{
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
-	fmt.Printf("SafetyCulture Exporter CLI version %s\n", version.GetVersion())
+	fmt.Fprintf(os.Stderr, "SafetyCulture Exporter CLI version %s\n", version.GetVersion())
 	updateMsgChan := make(chan *update.ReleaseInfo)
 
 	go func() {
@@ -73,12 +77,18 @@ func init() {
 	bindFlags()
 
 	// Add sub-commands
-	addCmd(export.SQLCmd(), connectionFlags, exportFlags, dbFlags, inspectionFlags, actionFlags, templatesFlag, tablesFlag, schemasFlag, mediaFlags, sitesFlags)
-	addCmd(export.CSVCmd(), connectionFlags, exportFlags, csvFlags, inspectionFlags, actionFlags, templatesFlag, tablesFlag, schemasFlag, mediaFlags, sitesFlags)
-	addCmd(export.SQLiteCmd(), connectionFlags, exportFlags, sqliteFlags, inspectionFlags, actionFlags, templatesFlag, tablesFlag, schemasFlag, mediaFlags, sitesFlags)
+	addCmd(export.SQLCmd(), connectionFlags, exportFlags, dbFlags, inspectionFlags, actionFlags, templatesFlag, tablesFlag, schemasFlag, mediaFlags, sitesFlags, metricsFlags)
+	addCmd(export.CSVCmd(), connectionFlags, exportFlags, csvFlags, inspectionFlags, actionFlags, templatesFlag, tablesFlag, schemasFlag, mediaFlags, sitesFlags, metricsFlags)
+	addCmd(export.ParquetCmd(), connectionFlags, exportFlags, parquetFlags, inspectionFlags, actionFlags, templatesFlag, tablesFlag, schemasFlag, mediaFlags, sitesFlags, metricsFlags)
+	addCmd(export.JSONLCmd(), connectionFlags, exportFlags, jsonlFlags, inspectionFlags, actionFlags, templatesFlag, tablesFlag, schemasFlag, mediaFlags, sitesFlags, metricsFlags)
+	addCmd(export.SQLiteCmd(), connectionFlags, exportFlags, sqliteFlags, inspectionFlags, actionFlags, templatesFlag, tablesFlag, schemasFlag, mediaFlags, sitesFlags, metricsFlags)
 	addCmd(export.InspectionJSONCmd(), exportFlags, connectionFlags, inspectionFlags, actionFlags, templatesFlag)
-	addCmd(export.ReportCmd(), connectionFlags, exportFlags, inspectionFlags, actionFlags, templatesFlag, reportFlags)
-	addCmd(export.PrintSchemaCmd())
+	addCmd(export.ReportCmd(), connectionFlags, exportFlags, inspectionFlags, actionFlags, templatesFlag, reportFlags, metricsFlags)
+	addCmd(export.PrintSchemaCmd(), exportFlags, dbFlags, printSchemaFlags)
+	addCmd(daemon.Cmd(), connectionFlags, exportFlags, dbFlags, csvFlags, jsonlFlags, inspectionFlags, actionFlags, templatesFlag, tablesFlag, mediaFlags, sitesFlags, reportFlags, daemonFlags, metricsFlags)
+	addCmd(runs.Cmd(), exportFlags, dbFlags, csvFlags, runsFlags)
+	addCmd(mockserver.Cmd(), mockServerFlags)
+	addCmd(migrate.Cmd(), exportFlags, dbFlags, migrateFlags)
 	addCmd(configure.Cmd(), connectionFlags, dbFlags, exportFlags, inspectionFlags, actionFlags, templatesFlag, tablesFlag)
 	RootCmd.AddCommand(&cobra.Command{
 		Hidden: true,
@@ -110,6 +120,14 @@ func configFlags() {
 
 	csvFlags = flag.NewFlagSet("csv", flag.ContinueOnError)
 	csvFlags.Int("max-rows-per-file", 1000000, "Maximum number of rows in a csv file. New files will be created when reaching this limit.")
+	csvFlags.Bool("streaming", false, "Write rows to the csv files as they are downloaded instead of staging them in a SQLite database")
+
+	parquetFlags = flag.NewFlagSet("parquet", flag.ContinueOnError)
+	parquetFlags.Int("max-rows-per-file", 1000000, "Maximum number of rows in a parquet file. New files will be created when reaching this limit.")
+
+	jsonlFlags = flag.NewFlagSet("jsonl", flag.ContinueOnError)
+	jsonlFlags.Int("max-rows-per-file", 1000000, "Maximum number of rows in a jsonl file. New files will be created when reaching this limit.")
+	jsonlFlags.Bool("gzip", false, "Compress jsonl files with gzip")
 
 	exportFlags = flag.NewFlagSet("export", flag.ContinueOnError)
 	exportFlags.String("export-path", "./export/", "File Export Path")
@@ -117,10 +135,21 @@ func configFlags() {
 	exportFlags.String("modified-after", "", "Return inspections modified after this date (see readme for supported formats)")
 	exportFlags.String("modified-before", "", "Return inspections modified before this date (see readme for supported formats)")
 	exportFlags.String("block-size", "", "Split export into time blocks (e.g., \"1d\", \"1w\", \"1m\")")
+	exportFlags.Bool("resume", false, "Resume an interrupted export from the last page downloaded for each table")
+	exportFlags.String("deletion-mode", "soft", "How the rows deleted in SafetyCulture are reflected in the export. soft flags them as deleted, hard deletes them")
+	exportFlags.Bool("atomic-refresh", false, "Load tables refreshed in full into <table>__staging tables, swapped in once exported (SQL and SQLite only)")
+	exportFlags.StringSlice("history-tables", []string{}, "Tables whose changed rows are appended to a <table>_history table with valid_from and valid_to columns (SQL and SQLite only)")
+	exportFlags.StringSlice("pivot-template-ids", []string{}, "Template IDs whose inspection items are pivoted into an inspection_pivot_<template> table with a column per question")
+	exportFlags.String("time-zone", "UTC", "IANA time zone the timestamps are written in (e.g., \"Australia/Sydney\")")
+	exportFlags.Bool("time-zone-keep-utc", false, "Keep the UTC value of each timestamp in an additional <column>_utc column")
+	exportFlags.String("object-storage-url", "", "S3-compatible bucket the exported files are copied to (e.g., \"s3://bucket/prefix\", add \"?endpoint=http://localhost:9000\" for MinIO). Credentials are read from the AWS environment variables")
 
 	mediaFlags = flag.NewFlagSet("media", flag.ContinueOnError)
 	mediaFlags.Bool("export-media", false, "Export media")
 	mediaFlags.String("export-media-path", "./export/media/", "Media Export Path")
+	mediaFlags.String("media-store", "per_audit", "How the media are stored. per_audit writes <audit_id>/<media_id> files, content names them by their SHA-256 and records them in a media table")
+	mediaFlags.Int("media-concurrency", 10, "Number of media downloaded at the same time, across all the feeds")
+	mediaFlags.Int("media-retries", 2, "Number of times a media failing to download is attempted again before it is reported and skipped")
 
 	inspectionFlags = flag.NewFlagSet("inspection", flag.ContinueOnError)
 	inspectionFlags.StringSlice("inspection-skip-ids", []string{}, "Skip storing these inspection IDs")
@@ -145,12 +174,49 @@ func configFlags() {
 	reportFlags = flag.NewFlagSet("report", flag.ContinueOnError)
 	reportFlags.StringSlice("format", []string{"PDF"}, "Export format (PDF,WORD)")
 	reportFlags.String("filename-convention", "INSPECTION_TITLE", "The name of the report exported, either INSPECTION_TITLE or INSPECTION_ID")
+	reportFlags.String("path-template", "", "The path of the reports relative to the export path, overriding the filename convention (e.g., \"{template_name}/{site_name}/{date:2006-01}/{title}_{audit_id}.{ext}\")")
 	reportFlags.String("preference-id", "", "The report layout to apply to the document")
 	reportFlags.Int("retry-timeout", 15, "Specify the time in seconds spent retrieving each report. Values greater than 60 seconds will be treated as 60 seconds.")
+	reportFlags.Int("report-concurrency", 10, "Number of reports generated at the same time")
+	reportFlags.Bool("retry-failed", false, "Only generate again the reports that failed during the previous runs, without downloading the inspections")
+	reportFlags.String("report-source", "api", "Where the inspections are read from. api downloads them, sql reads the ones exported to the database set with --db-dialect and --db-connection-string")
+	reportFlags.StringSlice("filter-site-ids", []string{}, "Only generate the reports of the inspections of these sites")
+	reportFlags.StringSlice("filter-template-ids", []string{}, "Only generate the reports of the inspections of these templates")
+	reportFlags.String("filter-date-from", "", "Only generate the reports of the inspections started from this date (see readme for supported formats)")
+	reportFlags.String("filter-date-to", "", "Only generate the reports of the inspections started before this date (see readme for supported formats)")
+	reportFlags.Float64("filter-score-min", 0, "Only generate the reports of the inspections scoring at least this percentage")
+	reportFlags.Float64("filter-score-max", 0, "Only generate the reports of the inspections scoring at most this percentage")
+	reportFlags.String("filter-audit-ids-file", "", "Only generate the reports of the inspections listed in this file, one audit ID per line")
+	reportFlags.StringSlice("bundle-by", []string{}, "Group the reports into ZIP archives with an index.csv, written to <export-path>/bundles. template, site and month are the only valid options (e.g., \"site,month\")")
 
 	sitesFlags = flag.NewFlagSet("sites", flag.ContinueOnError)
 	sitesFlags.Bool("site-include-deleted", false, "Include deleted sites in the sites table (default false)")
 	sitesFlags.Bool("site-include-full-hierarchy", true, "Include full sites hierarchy in table e.g. areas, regions, etc (default true)")
+
+	metricsFlags = flag.NewFlagSet("metrics", flag.ContinueOnError)
+	metricsFlags.Bool("metrics-enabled", false, "Serve Prometheus metrics on /metrics while exporting")
+	metricsFlags.String("metrics-address", ":2112", "Address the metrics endpoint listens on")
+
+	daemonFlags = flag.NewFlagSet("daemon", flag.ContinueOnError)
+	daemonFlags.String("schedule", "", "Cron expression of the export schedule (e.g., \"0 2 * * *\" or \"@hourly\")")
+	daemonFlags.String("export-type", "csv", "Export run on schedule. sql, sqlite, csv, parquet, jsonl and report are the only valid options.")
+	daemonFlags.String("lock-file", "", "Lock file preventing overlapping runs (default <export-path>/safetyculture-exporter.lock)")
+
+	runsFlags = flag.NewFlagSet("runs", flag.ContinueOnError)
+	runsFlags.String("format", "csv", "Export format the history is read from. sql, sqlite, csv, parquet and jsonl are the only valid options.")
+	runsFlags.Int("limit", 20, "Maximum number of runs to list, 0 lists all of them")
+
+	mockServerFlags = flag.NewFlagSet("mock-server", flag.ContinueOnError)
+	mockServerFlags.String("address", "localhost:8080", "Address the mock API listens on")
+	mockServerFlags.String("fixtures", "", "Directory of the fixtures to serve (default the fixtures shipped with the exporter)")
+	mockServerFlags.Int("page-size", 100, "Number of records per page when the request doesn't set a limit")
+
+	printSchemaFlags = flag.NewFlagSet("schema", flag.ContinueOnError)
+	printSchemaFlags.String("format", "table", "Format of the schemas. table, ddl, jsonschema and dbt are the only valid options.")
+	printSchemaFlags.String("dialect", "", "Database dialect of the ddl statements and dbt data types. mysql, postgres, sqlserver and sqlite are the only valid options (default the db-dialect)")
+
+	migrateFlags = flag.NewFlagSet("migrate", flag.ContinueOnError)
+	migrateFlags.String("output", "", "File the planned migration is written to (default stdout)")
 }
 
 func bindFlags() {
@@ -171,15 +237,30 @@ func bindFlags() {
 	util.Check(viper.BindPFlag("db.auto_migrate_disabled", dbFlags.Lookup("db-auto-migrate-disabled")), "while binding flag")
 
 	util.Check(viper.BindPFlag("csv.max_rows_per_file", csvFlags.Lookup("max-rows-per-file")), "while binding flag")
+	util.Check(viper.BindPFlag("csv.streaming", csvFlags.Lookup("streaming")), "while binding flag")
+	util.Check(viper.BindPFlag("parquet.max_rows_per_file", parquetFlags.Lookup("max-rows-per-file")), "while binding flag")
+	util.Check(viper.BindPFlag("jsonl.max_rows_per_file", jsonlFlags.Lookup("max-rows-per-file")), "while binding flag")
+	util.Check(viper.BindPFlag("jsonl.gzip", jsonlFlags.Lookup("gzip")), "while binding flag")
 
 	util.Check(viper.BindPFlag("export.path", exportFlags.Lookup("export-path")), "while binding flag")
 	util.Check(viper.BindPFlag("export.incremental", exportFlags.Lookup("incremental")), "while binding flag")
 	util.Check(viper.BindPFlag("export.modified_after", exportFlags.Lookup("modified-after")), "while binding flag")
 	util.Check(viper.BindPFlag("export.inspection.modified_before", exportFlags.Lookup("modified-before")), "while binding flag")
 	util.Check(viper.BindPFlag("export.inspection.block_size", exportFlags.Lookup("block-size")), "while binding flag")
+	util.Check(viper.BindPFlag("export.resume", exportFlags.Lookup("resume")), "while binding flag")
+	util.Check(viper.BindPFlag("export.deletion_mode", exportFlags.Lookup("deletion-mode")), "while binding flag")
+	util.Check(viper.BindPFlag("export.atomic_refresh", exportFlags.Lookup("atomic-refresh")), "while binding flag")
+	util.Check(viper.BindPFlag("export.history_tables", exportFlags.Lookup("history-tables")), "while binding flag")
+	util.Check(viper.BindPFlag("export.pivot_template_ids", exportFlags.Lookup("pivot-template-ids")), "while binding flag")
+	util.Check(viper.BindPFlag("export.time_zone", exportFlags.Lookup("time-zone")), "while binding flag")
+	util.Check(viper.BindPFlag("export.time_zone_keep_utc", exportFlags.Lookup("time-zone-keep-utc")), "while binding flag")
+	util.Check(viper.BindPFlag("export.object_storage.url", exportFlags.Lookup("object-storage-url")), "while binding flag")
 
 	util.Check(viper.BindPFlag("export.media", mediaFlags.Lookup("export-media")), "while binding flag")
 	util.Check(viper.BindPFlag("export.media_path", mediaFlags.Lookup("export-media-path")), "while binding flag")
+	util.Check(viper.BindPFlag("export.media_store", mediaFlags.Lookup("media-store")), "while binding flag")
+	util.Check(viper.BindPFlag("export.media_download.concurrency", mediaFlags.Lookup("media-concurrency")), "while binding flag")
+	util.Check(viper.BindPFlag("export.media_download.retries", mediaFlags.Lookup("media-retries")), "while binding flag")
 	util.Check(viper.BindPFlag("export.template_ids", templatesFlag.Lookup("template-ids")), "while binding flag")
 	util.Check(viper.BindPFlag("export.tables", tablesFlag.Lookup("tables")), "while binding flag")
 
@@ -197,8 +278,39 @@ func bindFlags() {
 
 	util.Check(viper.BindPFlag("report.format", reportFlags.Lookup("format")), "while binding flag")
 	util.Check(viper.BindPFlag("report.filename_convention", reportFlags.Lookup("filename-convention")), "while binding flag")
+	util.Check(viper.BindPFlag("report.path_template", reportFlags.Lookup("path-template")), "while binding flag")
 	util.Check(viper.BindPFlag("report.preference_id", reportFlags.Lookup("preference-id")), "while binding flag")
 	util.Check(viper.BindPFlag("report.retry_timeout", reportFlags.Lookup("retry-timeout")), "while binding flag")
+	util.Check(viper.BindPFlag("report.concurrency", reportFlags.Lookup("report-concurrency")), "while binding flag")
+	util.Check(viper.BindPFlag("report.retry_failed", reportFlags.Lookup("retry-failed")), "while binding flag")
+	util.Check(viper.BindPFlag("report.source", reportFlags.Lookup("report-source")), "while binding flag")
+	util.Check(viper.BindPFlag("report.filter.site_ids", reportFlags.Lookup("filter-site-ids")), "while binding flag")
+	util.Check(viper.BindPFlag("report.filter.template_ids", reportFlags.Lookup("filter-template-ids")), "while binding flag")
+	util.Check(viper.BindPFlag("report.filter.date_from", reportFlags.Lookup("filter-date-from")), "while binding flag")
+	util.Check(viper.BindPFlag("report.filter.date_to", reportFlags.Lookup("filter-date-to")), "while binding flag")
+	util.Check(viper.BindPFlag("report.filter.score_min", reportFlags.Lookup("filter-score-min")), "while binding flag")
+	util.Check(viper.BindPFlag("report.filter.score_max", reportFlags.Lookup("filter-score-max")), "while binding flag")
+	util.Check(viper.BindPFlag("report.filter.audit_ids_file", reportFlags.Lookup("filter-audit-ids-file")), "while binding flag")
+	util.Check(viper.BindPFlag("report.bundle_by", reportFlags.Lookup("bundle-by")), "while binding flag")
+
+	util.Check(viper.BindPFlag("metrics.enabled", metricsFlags.Lookup("metrics-enabled")), "while binding flag")
+	util.Check(viper.BindPFlag("metrics.address", metricsFlags.Lookup("metrics-address")), "while binding flag")
+
+	util.Check(viper.BindPFlag("daemon.schedule", daemonFlags.Lookup("schedule")), "while binding flag")
+	util.Check(viper.BindPFlag("daemon.export_type", daemonFlags.Lookup("export-type")), "while binding flag")
+	util.Check(viper.BindPFlag("daemon.lock_file", daemonFlags.Lookup("lock-file")), "while binding flag")
+
+	util.Check(viper.BindPFlag("runs.format", runsFlags.Lookup("format")), "while binding flag")
+	util.Check(viper.BindPFlag("runs.limit", runsFlags.Lookup("limit")), "while binding flag")
+
+	util.Check(viper.BindPFlag("mock_server.address", mockServerFlags.Lookup("address")), "while binding flag")
+	util.Check(viper.BindPFlag("mock_server.fixtures", mockServerFlags.Lookup("fixtures")), "while binding flag")
+	util.Check(viper.BindPFlag("mock_server.page_size", mockServerFlags.Lookup("page-size")), "while binding flag")
+
+	util.Check(viper.BindPFlag("migrate.output", migrateFlags.Lookup("output")), "while binding flag")
+
+	util.Check(viper.BindPFlag("schema.format", printSchemaFlags.Lookup("format")), "while binding flag")
+	util.Check(viper.BindPFlag("schema.dialect", printSchemaFlags.Lookup("dialect")), "while binding flag")
 }
 
 func addCmd(cmd *cobra.Command, flags ...*flag.FlagSet) {
@@ -0,0 +1,107 @@
+package runs
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/SafetyCulture/safetyculture-exporter/cmd/safetyculture-exporter/cmd/export"
+	util "github.com/SafetyCulture/safetyculture-exporter/cmd/safetyculture-exporter/cmd/utils"
+	exporterAPI "github.com/SafetyCulture/safetyculture-exporter/pkg/api"
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// Cmd is used to list and inspect the history of the exports
+func Cmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "runs [run-id]",
+		Short: "List and inspect past exports",
+		Long:  `Lists the past exports recorded by the exporter, or the details of a single export when a run ID is given.`,
+		Example: `// List the last 20 CSV exports
+safetyculture-exporter runs --format csv --export-path /path/to/export/to
+
+// Inspect a single export to a SQL database
+safetyculture-exporter runs 0f6e8dc4-8a34-4c0b-a5a4-86b0e76e3d8a --format sql --db-dialect postgres --db-connection-string "..."`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: runRuns,
+	}
+}
+
+func runRuns(_ *cobra.Command, args []string) error {
+	exp := export.NewSafetyCultureExporter(viper.GetViper())
+	format := viper.GetString("runs.format")
+
+	if len(args) == 1 {
+		run, err := exp.GetExportRun(format, args[0])
+		util.Check(err, "while reading export run")
+		printRun(run)
+		return nil
+	}
+
+	runs, err := exp.ListExportRuns(format, viper.GetInt("runs.limit"))
+	util.Check(err, "while listing export runs")
+	printRuns(runs)
+	return nil
+}
+
+func printRuns(runs []exporterAPI.ExportRunResponseItem) {
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"Run ID", "Started At", "Duration", "Status", "Errors", "User", "Organisation"})
+
+	for _, run := range runs {
+		table.Append([]string{
+			run.ID,
+			formatTime(run.StartedAt),
+			formatDuration(run.StartedAt, run.FinishedAt),
+			run.Status,
+			strconv.Itoa(run.ErrorCount),
+			run.UserName,
+			run.OrganisationID,
+		})
+	}
+	table.Render()
+}
+
+func printRun(run *exporterAPI.ExportRunResponse) {
+	fmt.Printf("Run ID:        %s\n", run.ID)
+	fmt.Printf("Status:        %s\n", run.Status)
+	fmt.Printf("Started At:    %s\n", formatTime(run.StartedAt))
+	fmt.Printf("Duration:      %s\n", formatDuration(run.StartedAt, run.FinishedAt))
+	fmt.Printf("User:          %s (%s)\n", run.UserName, run.UserID)
+	fmt.Printf("Organisation:  %s\n", run.OrganisationID)
+	fmt.Printf("Config Hash:   %s\n\n", run.ConfigHash)
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"Feed", "Status", "Rows Written", "Duration", "Error"})
+	for _, f := range run.Feeds {
+		table.Append([]string{
+			f.FeedName,
+			f.Status,
+			strconv.FormatInt(f.RowsWritten, 10),
+			formatDuration(f.StartedAt, f.FinishedAt),
+			f.Error,
+		})
+	}
+	table.Render()
+
+	if len(run.Errors) != 0 {
+		fmt.Println("\nErrors:")
+		for _, e := range run.Errors {
+			fmt.Printf(" > %s\n", e)
+		}
+	}
+}
+
+func formatTime(t time.Time) string {
+	return t.Local().Format(time.RFC3339)
+}
+
+func formatDuration(start time.Time, end *time.Time) string {
+	if end == nil {
+		return "-"
+	}
+	return end.Sub(start).Round(time.Second).String()
+}
@@ -16,8 +16,9 @@ import (
 
 // ExporterConfiguration is the equivalent struct of YAML
 type ExporterConfiguration struct {
-	AccessToken string `yaml:"access_token"`
-	API         struct {
+	AccessToken   string                      `yaml:"access_token"`
+	Organisations []OrganisationConfiguration `yaml:"organisations,omitempty"`
+	API           struct {
 		ProxyURL       string `yaml:"proxy_url"`
 		SheqsyURL      string `yaml:"sheqsy_url"`
 		TLSCert        string `yaml:"tls_cert"`
@@ -26,8 +27,16 @@ type ExporterConfiguration struct {
 		MaxConcurrency int    `yaml:"max_concurrency"`
 	} `yaml:"api"`
 	Csv struct {
-		MaxRowsPerFile int `yaml:"max_rows_per_file"`
+		MaxRowsPerFile int  `yaml:"max_rows_per_file"`
+		Streaming      bool `yaml:"streaming"`
 	} `yaml:"csv"`
+	Parquet struct {
+		MaxRowsPerFile int `yaml:"max_rows_per_file"`
+	} `yaml:"parquet"`
+	Jsonl struct {
+		MaxRowsPerFile int  `yaml:"max_rows_per_file"`
+		Gzip           bool `yaml:"gzip"`
+	} `yaml:"jsonl"`
 	Db struct {
 		ConnectionString    string `yaml:"connection_string"`
 		Dialect             string `yaml:"dialect"`
@@ -63,13 +72,30 @@ type ExporterConfiguration struct {
 		Issue struct {
 			Limit int `yaml:"limit"`
 		} `yaml:"issue"`
-		Media         bool   `yaml:"media"`
-		MediaPath     string `yaml:"media_path"`
-		ModifiedAfter mTime  `yaml:"modified_after"`
-		TimeZone      string `yaml:"time_zone"`
-		Path          string `yaml:"path"`
-		SchemaOnly    bool   `yaml:"-"`
-		Site          struct {
+		// ObjectStorage is the S3-compatible bucket the exported files are copied to, such as s3://bucket/prefix
+		ObjectStorage struct {
+			URL string `yaml:"url"`
+		} `yaml:"object_storage"`
+		// MediaDownload configures the pool the media of every feed are downloaded through
+		MediaDownload struct {
+			Concurrency int `yaml:"concurrency"`
+			Retries     int `yaml:"retries"`
+		} `yaml:"media_download"`
+		Media         bool     `yaml:"media"`
+		MediaPath     string   `yaml:"media_path"`
+		MediaStore    string   `yaml:"media_store"`
+		ModifiedAfter mTime    `yaml:"modified_after"`
+		TimeZone      string   `yaml:"time_zone"`
+		KeepUTCTime   bool     `yaml:"time_zone_keep_utc"`
+		Path          string   `yaml:"path"`
+		Resume        bool     `yaml:"resume"`
+		AtomicRefresh bool     `yaml:"atomic_refresh"`
+		DeletionMode  string   `yaml:"deletion_mode"`
+		HistoryTables []string `yaml:"history_tables"`
+		// PivotTemplateIds are the templates whose inspection items are pivoted into a table per template
+		PivotTemplateIds []string `yaml:"pivot_template_ids"`
+		SchemaOnly       bool     `yaml:"-"`
+		Site             struct {
 			IncludeDeleted       bool `yaml:"include_deleted"`
 			IncludeFullHierarchy bool `yaml:"include_full_hierarchy"`
 		} `yaml:"site"`
@@ -84,6 +110,27 @@ type ExporterConfiguration struct {
 		Format             []string `yaml:"format"`
 		PreferenceID       string   `yaml:"preference_id"`
 		RetryTimeout       int      `yaml:"retry_timeout"`
+		Concurrency        int      `yaml:"concurrency"`
+		// PathTemplate is the path of the reports relative to the export path, such as
+		// {template_name}/{date:2006-01}/{title}_{audit_id}.{ext}. The filename convention is used when not set
+		PathTemplate string `yaml:"path_template"`
+		// RetryFailed only generates again the reports that failed during the previous runs
+		RetryFailed bool `yaml:"retry_failed"`
+		// Source is where the inspections are read from, api or sql. sql reads the inspections exported to the
+		// database configured in db
+		Source string `yaml:"source"`
+		// Filter limits the inspections the reports are generated for
+		Filter struct {
+			SiteIDs      []string `yaml:"site_ids"`
+			TemplateIDs  []string `yaml:"template_ids"`
+			DateFrom     mTime    `yaml:"date_from"`
+			DateTo       mTime    `yaml:"date_to"`
+			ScoreMin     float64  `yaml:"score_min"`
+			ScoreMax     float64  `yaml:"score_max"`
+			AuditIDsFile string   `yaml:"audit_ids_file"`
+		} `yaml:"filter"`
+		// BundleBy groups the reports into ZIP archives by template, site and/or month, written to <export_path>/bundles
+		BundleBy []string `yaml:"bundle_by"`
 	} `yaml:"report"`
 	SheqsyCompanyID string `yaml:"sheqsy_company_id"`
 	SheqsyPassword  string `yaml:"sheqsy_password"`
@@ -91,6 +138,27 @@ type ExporterConfiguration struct {
 	Session         struct {
 		ExportType string `yaml:"export_type"`
 	} `yaml:"session"`
+	Metrics struct {
+		Enabled bool   `yaml:"enabled"`
+		Address string `yaml:"address"`
+	} `yaml:"metrics"`
+	Daemon struct {
+		Schedule   string `yaml:"schedule"`
+		ExportType string `yaml:"export_type"`
+		LockFile   string `yaml:"lock_file"`
+		Retry      struct {
+			Max     int `yaml:"max"`
+			WaitMin int `yaml:"wait_min"`
+			WaitMax int `yaml:"wait_max"`
+		} `yaml:"retry"`
+	} `yaml:"daemon"`
+}
+
+// OrganisationConfiguration holds the credentials of an organisation exported along the others into the same destination
+type OrganisationConfiguration struct {
+	Name           string `yaml:"name"`
+	AccessToken    string `yaml:"access_token"`
+	MaxConcurrency int    `yaml:"max_concurrency"`
 }
 
 // AppVersion used to store the version and ID
@@ -201,10 +269,32 @@ func (c *ConfigurationManager) ApplySafetyGuards() {
 		c.Configuration.Csv.MaxRowsPerFile = defaultCfg.Csv.MaxRowsPerFile
 	}
 
+	if c.Configuration.Parquet.MaxRowsPerFile == 0 {
+		c.Configuration.Parquet.MaxRowsPerFile = defaultCfg.Parquet.MaxRowsPerFile
+	}
+
+	if c.Configuration.Jsonl.MaxRowsPerFile == 0 {
+		c.Configuration.Jsonl.MaxRowsPerFile = defaultCfg.Jsonl.MaxRowsPerFile
+	}
+
 	if c.Configuration.Export.Tables == nil {
 		c.Configuration.Export.Tables = defaultCfg.Export.Tables
 	}
 
+	if c.Configuration.Export.HistoryTables == nil {
+		c.Configuration.Export.HistoryTables = defaultCfg.Export.HistoryTables
+	}
+
+	if c.Configuration.Export.PivotTemplateIds == nil {
+		c.Configuration.Export.PivotTemplateIds = defaultCfg.Export.PivotTemplateIds
+	}
+
+	for i, org := range c.Configuration.Organisations {
+		if strings.TrimSpace(org.Name) == "" {
+			c.Configuration.Organisations[i].Name = fmt.Sprintf("organisation-%d", i+1)
+		}
+	}
+
 	if c.Configuration.Export.TemplateIds == nil {
 		c.Configuration.Export.TemplateIds = defaultCfg.Export.TemplateIds
 	}
@@ -221,6 +311,14 @@ func (c *ConfigurationManager) ApplySafetyGuards() {
 		c.Configuration.Report.FilenameConvention = defaultCfg.Report.FilenameConvention
 	}
 
+	if c.Configuration.Report.Concurrency <= 0 {
+		c.Configuration.Report.Concurrency = defaultCfg.Report.Concurrency
+	}
+
+	if c.Configuration.Report.Source == "" {
+		c.Configuration.Report.Source = defaultCfg.Report.Source
+	}
+
 	if c.Configuration.Export.Inspection.Completed == "" {
 		c.Configuration.Export.Inspection.Completed = defaultCfg.Export.Inspection.Completed
 	}
@@ -233,10 +331,30 @@ func (c *ConfigurationManager) ApplySafetyGuards() {
 		c.Configuration.Export.TimeZone = defaultCfg.Export.TimeZone
 	}
 
+	if c.Configuration.Export.DeletionMode == "" {
+		c.Configuration.Export.DeletionMode = defaultCfg.Export.DeletionMode
+	}
+
 	if c.Configuration.Session.ExportType == "" {
 		c.Configuration.Session.ExportType = defaultCfg.Session.ExportType
 	}
 
+	if c.Configuration.Metrics.Address == "" {
+		c.Configuration.Metrics.Address = defaultCfg.Metrics.Address
+	}
+
+	if c.Configuration.Daemon.ExportType == "" {
+		c.Configuration.Daemon.ExportType = defaultCfg.Daemon.ExportType
+	}
+
+	if c.Configuration.Daemon.Retry.WaitMin <= 0 {
+		c.Configuration.Daemon.Retry.WaitMin = defaultCfg.Daemon.Retry.WaitMin
+	}
+
+	if c.Configuration.Daemon.Retry.WaitMax < c.Configuration.Daemon.Retry.WaitMin {
+		c.Configuration.Daemon.Retry.WaitMax = c.Configuration.Daemon.Retry.WaitMin
+	}
+
 	if c.Configuration.Db.Dialect == "" {
 		c.Configuration.Db.Dialect = defaultCfg.Db.Dialect
 	}
@@ -249,6 +367,18 @@ func (c *ConfigurationManager) ApplySafetyGuards() {
 		c.Configuration.Export.MediaPath = defaultCfg.Export.MediaPath
 	}
 
+	if c.Configuration.Export.MediaStore == "" {
+		c.Configuration.Export.MediaStore = defaultCfg.Export.MediaStore
+	}
+
+	if c.Configuration.Export.MediaDownload.Concurrency <= 0 {
+		c.Configuration.Export.MediaDownload.Concurrency = defaultCfg.Export.MediaDownload.Concurrency
+	}
+
+	if c.Configuration.Export.MediaDownload.Retries < 0 {
+		c.Configuration.Export.MediaDownload.Retries = defaultCfg.Export.MediaDownload.Retries
+	}
+
 	if c.Configuration.Export.Inspection.BlockSize != "" {
 		if _, err := util.ParseDuration(c.Configuration.Export.Inspection.BlockSize); err != nil {
 			c.Configuration.Export.Inspection.BlockSize = defaultCfg.Export.Inspection.BlockSize
@@ -289,8 +419,12 @@ func BuildConfigurationWithDefaults() *ExporterConfiguration {
 	cfg.API.URL = "https://api.safetyculture.io"
 	cfg.API.MaxConcurrency = 10
 	cfg.Csv.MaxRowsPerFile = 1000000
+	cfg.Parquet.MaxRowsPerFile = 1000000
+	cfg.Jsonl.MaxRowsPerFile = 1000000
 	cfg.Db.Dialect = "mysql"
 	cfg.Export.Tables = []string{}
+	cfg.Export.HistoryTables = []string{}
+	cfg.Export.PivotTemplateIds = []string{}
 	cfg.Export.TemplateIds = []string{}
 	cfg.Export.Action.Limit = 100
 	cfg.Export.Asset.Limit = 100
@@ -308,13 +442,25 @@ func BuildConfigurationWithDefaults() *ExporterConfiguration {
 	cfg.Export.Issue.Limit = 100
 	cfg.Export.Path = exportLocation
 	cfg.Export.MediaPath = mediaPathLocation
+	cfg.Export.MediaStore = "per_audit"
+	cfg.Export.MediaDownload.Concurrency = 10
+	cfg.Export.MediaDownload.Retries = 2
 	cfg.Export.TimeZone = "UTC"
+	cfg.Export.DeletionMode = "soft"
 	cfg.Export.ModifiedAfter = mTime{}
 	cfg.Export.Site.IncludeFullHierarchy = true
 	cfg.Report.FilenameConvention = "INSPECTION_TITLE"
 	cfg.Report.Format = []string{"PDF"}
 	cfg.Report.RetryTimeout = 15
+	cfg.Report.Concurrency = 10
+	cfg.Report.Source = "api"
+	cfg.Report.Filter.DateTo = mTime{}
 	cfg.Session.ExportType = "csv"
+	cfg.Metrics.Address = ":2112"
+	cfg.Daemon.ExportType = "csv"
+	cfg.Daemon.Retry.Max = 3
+	cfg.Daemon.Retry.WaitMin = 30
+	cfg.Daemon.Retry.WaitMax = 600
 
 	err := os.MkdirAll(cfg.Export.Path, os.ModePerm)
 	if err != nil {
@@ -379,9 +525,19 @@ func (ec *ExporterConfiguration) ToExporterConfig() *feed.ExporterFeedCfg {
 		ExportInspectionWebReportLink:         ec.Export.Inspection.WebReportLink,
 		ExportInspectionItemsSkipFields:       ec.Export.InspectionItems.SkipFields,
 		ExportScheduleResumeDownload:          ec.Export.Schedule.ResumeDownload,
+		ExportResume:                          ec.Export.Resume,
+		ExportAtomicRefresh:                   ec.Export.AtomicRefresh,
+		ExportHistoryTables:                   ec.Export.HistoryTables,
+		ExportPivotTemplateIds:                ec.Export.PivotTemplateIds,
+		ExportDeletionMode:                    ec.Export.DeletionMode,
+		ExportTimeZone:                        ec.Export.TimeZone,
+		ExportTimeZoneKeepUTC:                 ec.Export.KeepUTCTime,
 		ExportIncremental:                     ec.Export.Incremental,
 		ExportInspectionLimit:                 ec.Export.Inspection.Limit,
 		ExportMedia:                           ec.Export.Media,
+		ExportMediaStore:                      ec.Export.MediaStore,
+		ExportMediaConcurrency:                ec.Export.MediaDownload.Concurrency,
+		ExportMediaRetries:                    ec.Export.MediaDownload.Retries,
 		ExportSiteIncludeDeleted:              ec.Export.Site.IncludeDeleted,
 		ExportActionLimit:                     ec.Export.Action.Limit,
 		ExportSiteIncludeFullHierarchy:        ec.Export.Site.IncludeFullHierarchy,
@@ -397,7 +553,21 @@ func (ec *ExporterConfiguration) ToReporterConfig() *ReportExporterCfg {
 		Format:       ec.Report.Format,
 		PreferenceID: ec.Report.PreferenceID,
 		Filename:     ec.Report.FilenameConvention,
+		PathTemplate: ec.Report.PathTemplate,
 		RetryTimeout: ec.Report.RetryTimeout,
+		Concurrency:  ec.Report.Concurrency,
+		RetryFailed:  ec.Report.RetryFailed,
+		Source:       ec.Report.Source,
+		BundleBy:     ec.Report.BundleBy,
+		Filter: ReportFilterCfg{
+			SiteIDs:      ec.Report.Filter.SiteIDs,
+			TemplateIDs:  ec.Report.Filter.TemplateIDs,
+			DateFrom:     ec.Report.Filter.DateFrom.Time,
+			DateTo:       ec.Report.Filter.DateTo.Time,
+			ScoreMin:     ec.Report.Filter.ScoreMin,
+			ScoreMax:     ec.Report.Filter.ScoreMax,
+			AuditIDsFile: ec.Report.Filter.AuditIDsFile,
+		},
 	}
 }
 
@@ -412,6 +582,28 @@ func (ec *ExporterConfiguration) ToInspectionConfig() *inspections.InspectionCli
 	}
 }
 
+func (ec *ExporterConfiguration) ToDaemonConfig() *DaemonCfg {
+	lockFile := ec.Daemon.LockFile
+	if lockFile == "" {
+		lockFile = filepath.Join(ec.Export.Path, "safetyculture-exporter.lock")
+	}
+
+	return &DaemonCfg{
+		Schedule:     ec.Daemon.Schedule,
+		LockFile:     lockFile,
+		RetryMax:     ec.Daemon.Retry.Max,
+		RetryWaitMin: time.Duration(ec.Daemon.Retry.WaitMin) * time.Second,
+		RetryWaitMax: time.Duration(ec.Daemon.Retry.WaitMax) * time.Second,
+	}
+}
+
+// ToOrganisationApiConfig returns the configuration of the API client of an organisation
+func (ec *ExporterConfiguration) ToOrganisationApiConfig(org OrganisationConfiguration) *HttpApiCfg {
+	cfg := ec.ToApiConfig()
+	cfg.accessToken = org.AccessToken
+	return cfg
+}
+
 func (ec *ExporterConfiguration) ToApiConfig() *HttpApiCfg {
 	return &HttpApiCfg{
 		tlsSkipVerify:  ec.API.TLSSkipVerify,
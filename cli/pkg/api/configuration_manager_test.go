@@ -59,6 +59,19 @@ func TestNewConfigurationManagerFromFile_when_filename_exists_with_site_hierarch
 	assert.True(t, cfg.Export.Site.IncludeFullHierarchy)
 }
 
+func TestNewConfigurationManagerFromFile_when_filename_exists_with_organisations(t *testing.T) {
+	cm, err := api.NewConfigurationManagerFromFile("", "fixtures/valid_with_organisations.yaml")
+	require.Nil(t, err)
+	require.NotNil(t, cm)
+
+	cfg := cm.Configuration
+	assert.Empty(t, cfg.AccessToken)
+	assert.Equal(t, []api.OrganisationConfiguration{
+		{Name: "head-office", AccessToken: "fake_token_1", MaxConcurrency: 4},
+		{Name: "organisation-2", AccessToken: "fake_token_2"},
+	}, cfg.Organisations)
+}
+
 func TestNewConfigurationManagerFromFile_when_filename_exists_with_time_rfc3339(t *testing.T) {
 	cm, err := api.NewConfigurationManagerFromFile("", "fixtures/valid_with_time_long.yaml")
 	require.Nil(t, err)
@@ -180,6 +193,8 @@ func TestConfigurationManager_SaveConfiguration(t *testing.T) {
 	assert.EqualValues(t, "INSPECTION_TITLE", newCm.Configuration.Report.FilenameConvention)
 	assert.EqualValues(t, []string{"PDF"}, newCm.Configuration.Report.Format)
 	assert.EqualValues(t, 15, newCm.Configuration.Report.RetryTimeout)
+	assert.EqualValues(t, 10, newCm.Configuration.Report.Concurrency)
+	assert.EqualValues(t, "api", newCm.Configuration.Report.Source)
 	assert.EqualValues(t, false, newCm.Configuration.Export.Schedule.ResumeDownload)
 	assert.Empty(t, cm.Configuration.Export.InspectionItems.SkipFields)
 
@@ -0,0 +1,162 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/SafetyCulture/safetyculture-exporter/pkg/logger"
+	"github.com/gofrs/flock"
+	"github.com/pkg/errors"
+	"github.com/robfig/cron/v3"
+	"go.uber.org/zap"
+)
+
+// DaemonCfg is the configuration of the daemon mode
+type DaemonCfg struct {
+	Schedule     string
+	LockFile     string
+	RetryMax     int
+	RetryWaitMin time.Duration
+	RetryWaitMax time.Duration
+}
+
+// Daemon runs an export on a cron schedule. Runs never overlap, even across processes,
+// and failed runs are retried with a jittered exponential backoff
+type Daemon struct {
+	cfg      *DaemonCfg
+	schedule cron.Schedule
+	job      func(ctx context.Context) error
+	logger   *zap.SugaredLogger
+}
+
+// NewDaemon creates a daemon running job on the configured schedule. The context passed to job is done
+// when the daemon is stopped, interrupting the running job
+func NewDaemon(cfg *DaemonCfg, job func(ctx context.Context) error) (*Daemon, error) {
+	if cfg.Schedule == "" {
+		return nil, fmt.Errorf("no schedule configured, set daemon.schedule to a cron expression")
+	}
+
+	schedule, err := cron.ParseStandard(cfg.Schedule)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid schedule %q", cfg.Schedule)
+	}
+
+	return &Daemon{
+		cfg:      cfg,
+		schedule: schedule,
+		job:      job,
+		logger:   logger.GetLogger().With("schedule", cfg.Schedule),
+	}, nil
+}
+
+// NewDaemon creates a daemon running the export type set in the configuration
+func (s *SafetyCultureExporter) NewDaemon() (*Daemon, error) {
+	var job func(ctx context.Context) error
+	switch s.cfg.Daemon.ExportType {
+	case "sql":
+		job = s.runSQL
+	case "sqlite":
+		job = s.runSQLite
+	case "csv":
+		job = s.runCSV
+	case "parquet":
+		job = s.runParquet
+	case "jsonl":
+		job = s.runJSONL
+	case "report":
+		job = s.runInspectionReports
+	default:
+		return nil, fmt.Errorf("unsupported export type %q", s.cfg.Daemon.ExportType)
+	}
+
+	return NewDaemon(s.cfg.ToDaemonConfig(), job)
+}
+
+// Run runs the job on schedule until the context is done
+func (d *Daemon) Run(ctx context.Context) error {
+	d.logger.Info("starting daemon")
+
+	for {
+		next := d.schedule.Next(time.Now())
+		d.logger.Infof("next run at %s", next.Format(time.RFC3339))
+
+		if err := sleep(ctx, time.Until(next)); err != nil {
+			d.logger.Info("stopping daemon")
+			return nil
+		}
+
+		if err := d.RunOnce(ctx); err != nil {
+			d.logger.Errorf("run failed: %v", err)
+		}
+	}
+}
+
+// RunOnce runs the job, retrying it when it fails. The run is skipped when another one holds the lock
+func (d *Daemon) RunOnce(ctx context.Context) error {
+	if err := os.MkdirAll(filepath.Dir(d.cfg.LockFile), os.ModePerm); err != nil {
+		return errors.Wrapf(err, "Failed to create directory %s", filepath.Dir(d.cfg.LockFile))
+	}
+
+	lock := flock.New(d.cfg.LockFile)
+	locked, err := lock.TryLock()
+	if err != nil {
+		return errors.Wrapf(err, "acquire lock %s", d.cfg.LockFile)
+	}
+	if !locked {
+		d.logger.Warnf("skipping run, another export holds the lock %s", d.cfg.LockFile)
+		return nil
+	}
+	defer lock.Unlock()
+
+	for attempt := 0; ; attempt++ {
+		d.logger.With("attempt", attempt+1).Info("starting run")
+		err = d.job(ctx)
+		if err == nil {
+			d.logger.Info("run completed")
+			return nil
+		}
+
+		if ctx.Err() != nil || attempt >= d.cfg.RetryMax {
+			return err
+		}
+
+		wait := d.backoff(attempt)
+		d.logger.With("attempt", attempt+1).Warnf("run failed, retrying in %s: %v", wait, err)
+		if sleep(ctx, wait) != nil {
+			return err
+		}
+	}
+}
+
+// backoff returns the exponential wait before a retry, with half of it randomised so that
+// exporters sharing a schedule don't retry in lockstep
+func (d *Daemon) backoff(attempt int) time.Duration {
+	wait := d.cfg.RetryWaitMax
+	if attempt < 32 {
+		if exp := d.cfg.RetryWaitMin << uint(attempt); exp > 0 && exp < wait {
+			wait = exp
+		}
+	}
+
+	half := wait / 2
+	if half <= 0 {
+		return wait
+	}
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,151 @@
+package api_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/SafetyCulture/safetyculture-exporter/pkg/api"
+	"github.com/gofrs/flock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func getDaemonTestCfg(t *testing.T) *api.DaemonCfg {
+	dir, err := os.MkdirTemp("", "export")
+	require.NoError(t, err)
+
+	return &api.DaemonCfg{
+		Schedule:     "@hourly",
+		LockFile:     filepath.Join(dir, "safetyculture-exporter.lock"),
+		RetryMax:     2,
+		RetryWaitMin: time.Millisecond,
+		RetryWaitMax: 5 * time.Millisecond,
+	}
+}
+
+func TestNewDaemon_should_fail_when_schedule_is_invalid(t *testing.T) {
+	cfg := getDaemonTestCfg(t)
+
+	cfg.Schedule = ""
+	_, err := api.NewDaemon(cfg, func(context.Context) error { return nil })
+	assert.EqualError(t, err, "no schedule configured, set daemon.schedule to a cron expression")
+
+	cfg.Schedule = "every day"
+	_, err = api.NewDaemon(cfg, func(context.Context) error { return nil })
+	assert.ErrorContains(t, err, `invalid schedule "every day"`)
+}
+
+func TestDaemon_RunOnce_should_retry_failed_runs(t *testing.T) {
+	cfg := getDaemonTestCfg(t)
+
+	calls := 0
+	d, err := api.NewDaemon(cfg, func(context.Context) error {
+		calls++
+		if calls < 3 {
+			return fmt.Errorf("export failed")
+		}
+		return nil
+	})
+	require.NoError(t, err)
+
+	assert.NoError(t, d.RunOnce(context.Background()))
+	assert.Equal(t, 3, calls)
+}
+
+func TestDaemon_RunOnce_should_give_up_after_max_retries(t *testing.T) {
+	cfg := getDaemonTestCfg(t)
+
+	calls := 0
+	d, err := api.NewDaemon(cfg, func(context.Context) error {
+		calls++
+		return fmt.Errorf("export failed")
+	})
+	require.NoError(t, err)
+
+	assert.EqualError(t, d.RunOnce(context.Background()), "export failed")
+	assert.Equal(t, 3, calls)
+}
+
+func TestDaemon_RunOnce_should_skip_when_locked(t *testing.T) {
+	cfg := getDaemonTestCfg(t)
+
+	lock := flock.New(cfg.LockFile)
+	locked, err := lock.TryLock()
+	require.NoError(t, err)
+	require.True(t, locked)
+	defer lock.Unlock()
+
+	calls := 0
+	d, err := api.NewDaemon(cfg, func(context.Context) error {
+		calls++
+		return nil
+	})
+	require.NoError(t, err)
+
+	assert.NoError(t, d.RunOnce(context.Background()))
+	assert.Equal(t, 0, calls)
+}
+
+func TestDaemon_RunOnce_should_cancel_running_export_when_stopped(t *testing.T) {
+	cfg := getDaemonTestCfg(t)
+
+	ctx, stop := context.WithCancel(context.Background())
+	calls := 0
+	d, err := api.NewDaemon(cfg, func(ctx context.Context) error {
+		calls++
+		stop()
+		<-ctx.Done()
+		return fmt.Errorf("export cancelled")
+	})
+	require.NoError(t, err)
+
+	assert.EqualError(t, d.RunOnce(ctx), "export cancelled")
+	assert.Equal(t, 1, calls)
+}
+
+func TestDaemon_Run_should_stop_when_context_is_done(t *testing.T) {
+	cfg := getDaemonTestCfg(t)
+
+	d, err := api.NewDaemon(cfg, func(context.Context) error { return nil })
+	require.NoError(t, err)
+
+	ctx, stop := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer stop()
+	assert.NoError(t, d.Run(ctx))
+}
+
+func TestSafetyCultureExporter_NewDaemon_should_fail_for_unknown_export_type(t *testing.T) {
+	cfg := api.ExporterConfiguration{}
+	cfg.Daemon.Schedule = "@daily"
+	cfg.Daemon.ExportType = "xml"
+
+	exporter, err := api.NewSafetyCultureExporter(&cfg, &api.AppVersion{})
+	require.NoError(t, err)
+
+	_, err = exporter.NewDaemon()
+	assert.EqualError(t, err, `unsupported export type "xml"`)
+}
+
+func TestSafetyCultureExporter_NewDaemon_should_not_export_when_stopped_before_the_run(t *testing.T) {
+	exporter, dir := getMockAPIExporter(t, func(cfg *api.ExporterConfiguration) {
+		cfg.Daemon.Schedule = "@hourly"
+		cfg.Daemon.ExportType = "csv"
+		cfg.Daemon.LockFile = filepath.Join(cfg.Export.Path, "safetyculture-exporter.lock")
+		cfg.Export.Tables = []string{"inspections"}
+		cfg.SheqsyUsername = ""
+	})
+	d, err := exporter.NewDaemon()
+	require.NoError(t, err)
+
+	// the daemon is stopped before the job creates its context
+	ctx, stop := context.WithCancel(context.Background())
+	stop()
+
+	assert.Error(t, d.RunOnce(ctx))
+	_, err = os.Stat(filepath.Join(dir, "inspections.csv"))
+	assert.True(t, os.IsNotExist(err))
+}
@@ -0,0 +1,94 @@
+package api_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/SafetyCulture/safetyculture-exporter/pkg/api"
+	"github.com/SafetyCulture/safetyculture-exporter/pkg/mockapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func getAtomicRefreshExporter(t *testing.T, configure func(cfg *api.ExporterConfiguration)) (*api.SafetyCultureExporter, *gorm.DB) {
+	exporter, dir := getMockAPIExporter(t, func(cfg *api.ExporterConfiguration) {
+		cfg.Export.Tables = []string{"inspections", "issues"}
+		cfg.Export.Incremental = false
+		cfg.Export.AtomicRefresh = true
+		cfg.SheqsyUsername = ""
+		if configure != nil {
+			configure(cfg)
+		}
+	})
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(dir, "sqlite_export.db")), &gorm.Config{})
+	require.NoError(t, err)
+	return exporter, db
+}
+
+func countRows(t *testing.T, db *gorm.DB, table string) int64 {
+	var count int64
+	require.NoError(t, db.Table(table).Count(&count).Error)
+	return count
+}
+
+func TestSafetyCultureExporter_RunSQLite_should_swap_in_staging_tables(t *testing.T) {
+	exporter, db := getAtomicRefreshExporter(t, nil)
+
+	require.NoError(t, exporter.RunSQLite())
+	// the staging tables are created again and swapped in over the previous export
+	require.NoError(t, exporter.RunSQLite())
+
+	assert.EqualValues(t, 39, countRows(t, db, "issues"))
+	assert.EqualValues(t, 3, countRows(t, db, "inspections"))
+	assert.False(t, db.Migrator().HasTable("issues__staging"))
+	assert.False(t, db.Migrator().HasTable("inspections__staging"))
+	assert.True(t, db.Migrator().HasIndex("inspections", "idx_ins_modified_at"))
+}
+
+func TestSafetyCultureExporter_RunSQLite_should_keep_previous_data_when_refresh_fails(t *testing.T) {
+	var failing atomic.Bool
+	mock := mockapi.NewServer(mockapi.Seed())
+	mock.PageSize = 2
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failing.Load() && r.URL.Path == "/feed/issues" && r.URL.Query().Get("next_page_token") != "" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		mock.ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+
+	exporter, db := getAtomicRefreshExporter(t, func(cfg *api.ExporterConfiguration) {
+		cfg.API.URL = srv.URL
+	})
+
+	require.NoError(t, exporter.RunSQLite())
+	require.EqualValues(t, 39, countRows(t, db, "issues"))
+
+	failing.Store(true)
+	require.Error(t, exporter.RunSQLite())
+
+	assert.EqualValues(t, 39, countRows(t, db, "issues"))
+	assert.EqualValues(t, 2, countRows(t, db, "issues__staging"))
+	assert.EqualValues(t, 3, countRows(t, db, "inspections"))
+	assert.False(t, db.Migrator().HasTable("inspections__staging"))
+}
+
+func TestSafetyCultureExporter_RunSQLite_should_keep_views_of_the_live_tables(t *testing.T) {
+	exporter, db := getAtomicRefreshExporter(t, nil)
+
+	require.NoError(t, exporter.RunSQLite())
+	require.NoError(t, db.Exec("CREATE VIEW issue_ids AS SELECT id FROM issues").Error)
+
+	require.NoError(t, exporter.RunSQLite())
+
+	// the view reads the rows of the table swapped in
+	assert.EqualValues(t, 39, countRows(t, db, "issue_ids"))
+	assert.False(t, db.Migrator().HasTable("issues__staging"))
+}
@@ -0,0 +1,220 @@
+package api_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/SafetyCulture/safetyculture-exporter/pkg/api"
+	"github.com/SafetyCulture/safetyculture-exporter/pkg/mockapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+const deletionActivityLog = `[
+  {"id": "1", "event_at": "2022-07-05T01:02:10.887Z", "type": "site.deleted", "metadata": {"site_id": "location_1"}},
+  {"id": "2", "event_at": "2022-07-05T01:02:10.887Z", "type": "issue.deleted", "metadata": {"issue_id": "56bc5efa-2420-483d-bad1-27b35922c403"}},
+  {"id": "3", "event_at": "2022-07-05T01:02:10.887Z", "type": "template.deleted", "metadata": {"template_id": "template_1"}},
+  {"id": "4", "event_at": "2022-07-05T01:02:10.887Z", "type": "asset.deleted", "metadata": {"asset_id": "92dac48a-b8f1-4d49-918f-26cae210f16d"}},
+  {"id": "5", "event_at": "2022-07-05T01:02:10.887Z", "type": "inspection.deleted", "metadata": {"inspection_id": "audit_1"}}
+]`
+
+// deletedRows are the rows deleted by the deletion activity log
+var deletedRows = []struct {
+	table      string
+	primaryKey string
+	id         string
+}{
+	{table: "sites", primaryKey: "site_id", id: "location_1"},
+	{table: "issues", primaryKey: "id", id: "56bc5efa-2420-483d-bad1-27b35922c403"},
+	{table: "templates", primaryKey: "template_id", id: "template_1"},
+	{table: "assets", primaryKey: "asset_id", id: "92dac48a-b8f1-4d49-918f-26cae210f16d"},
+}
+
+// activityLogRequest is a request listing the events of the activity log
+type activityLogRequest struct {
+	PageSize int `json:"page_size"`
+	Filters  struct {
+		Timeframe struct {
+			From time.Time `json:"from"`
+		} `json:"timeframe"`
+		EventTypes []string `json:"event_types"`
+	} `json:"filters"`
+}
+
+func getDeletionExporter(t *testing.T, mode string, requests *[]activityLogRequest, incremental bool) (*api.SafetyCultureExporter, *gorm.DB) {
+	mock := mockapi.NewServer(overlayFS{
+		FS:      mockapi.Seed(),
+		overlay: fstest.MapFS{"activity_log.json": {Data: []byte(deletionActivityLog)}},
+	})
+	mock.PageSize = 2
+
+	var mu sync.Mutex
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests != nil && r.URL.Path == "/accounts/history/v1/activity_log/list" {
+			body, err := io.ReadAll(r.Body)
+			require.NoError(t, err)
+			var req activityLogRequest
+			require.NoError(t, json.Unmarshal(body, &req))
+
+			mu.Lock()
+			*requests = append(*requests, req)
+			mu.Unlock()
+			r.Body = io.NopCloser(bytes.NewReader(body))
+		}
+		mock.ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+
+	exporter, dir := getMockAPIExporter(t, func(cfg *api.ExporterConfiguration) {
+		cfg.API.URL = srv.URL
+		cfg.Export.Tables = []string{"sites", "issues", "templates", "assets", "inspections", "inspection_items"}
+		cfg.Export.DeletionMode = mode
+		cfg.Export.Incremental = incremental
+		cfg.SheqsyUsername = ""
+	})
+	require.NoError(t, exporter.RunSQLite())
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(dir, "sqlite_export.db")), &gorm.Config{})
+	require.NoError(t, err)
+	return exporter, db
+}
+
+func TestSafetyCultureExporter_RunSQLite_should_flag_deleted_rows(t *testing.T) {
+	_, db := getDeletionExporter(t, "soft", nil, false)
+
+	for _, r := range deletedRows {
+		var row struct {
+			Deleted   bool
+			DeletedAt string
+		}
+		require.NoError(t, db.Table(r.table).Select("deleted", "deleted_at").Where(r.primaryKey+" = ?", r.id).Take(&row).Error, r.table)
+		assert.True(t, row.Deleted, r.table)
+		assert.Contains(t, row.DeletedAt, "2022-07-05T01:02:10.887", r.table)
+
+		var remaining int64
+		require.NoError(t, db.Table(r.table).Where(r.primaryKey+" <> ? AND deleted_at IS NOT NULL", r.id).Count(&remaining).Error)
+		assert.Zero(t, remaining, r.table)
+	}
+
+	// the items of the inspections soft deleted are kept
+	var items int64
+	require.NoError(t, db.Table("inspection_items").Where("audit_id = ?", "audit_1").Count(&items).Error)
+	assert.EqualValues(t, 5, items)
+}
+
+func TestSafetyCultureExporter_RunSQLite_should_delete_deleted_rows(t *testing.T) {
+	_, db := getDeletionExporter(t, "hard", nil, false)
+
+	for _, r := range deletedRows {
+		var count int64
+		require.NoError(t, db.Table(r.table).Where(r.primaryKey+" = ?", r.id).Count(&count).Error, r.table)
+		assert.Zero(t, count, r.table)
+		assert.NotZero(t, countRows(t, db, r.table), r.table)
+	}
+}
+
+func TestSafetyCultureExporter_RunSQLite_should_delete_items_of_deleted_inspections(t *testing.T) {
+	_, db := getDeletionExporter(t, "hard", nil, false)
+
+	var items int64
+	require.NoError(t, db.Table("inspection_items").Where("audit_id = ?", "audit_1").Count(&items).Error)
+	assert.Zero(t, items)
+}
+
+func TestSafetyCultureExporter_RunSQLite_should_read_deletions_from_the_latest_one_read(t *testing.T) {
+	var requests []activityLogRequest
+	exporter, _ := getDeletionExporter(t, "soft", &requests, true)
+
+	requests = nil
+	require.NoError(t, exporter.RunSQLite())
+
+	deletedAt := time.Date(2022, 7, 5, 1, 2, 10, 887000000, time.UTC)
+	for _, eventType := range []string{"template.deleted", "inspection.deleted"} {
+		var found bool
+		for _, req := range requests {
+			if len(req.Filters.EventTypes) != 1 || req.Filters.EventTypes[0] != eventType {
+				continue
+			}
+			found = true
+			assert.True(t, deletedAt.Equal(req.Filters.Timeframe.From), eventType)
+			assert.Positive(t, req.PageSize, eventType)
+		}
+		assert.True(t, found, eventType)
+	}
+}
+
+func TestSafetyCultureExporter_RunSQLite_should_read_deletions_of_reloaded_feeds_from_the_start(t *testing.T) {
+	for _, mode := range []string{"soft", "hard"} {
+		var requests []activityLogRequest
+		exporter, _ := getDeletionExporter(t, mode, &requests, true)
+
+		requests = nil
+		require.NoError(t, exporter.RunSQLite())
+
+		// the sites, issues and assets are fetched in full by every export, the hard deleted rows of any feed may be
+		// fetched again
+		eventTypes := []string{"site.deleted", "issue.deleted", "asset.deleted"}
+		if mode == "hard" {
+			eventTypes = append(eventTypes, "template.deleted", "inspection.deleted")
+		}
+		for _, eventType := range eventTypes {
+			var found bool
+			for _, req := range requests {
+				if len(req.Filters.EventTypes) != 1 || req.Filters.EventTypes[0] != eventType {
+					continue
+				}
+				found = true
+				assert.True(t, req.Filters.Timeframe.From.Before(time.Date(2022, 7, 5, 0, 0, 0, 0, time.UTC)), eventType)
+			}
+			assert.True(t, found, eventType)
+		}
+	}
+}
+
+func TestSafetyCultureExporter_RunSQLite_should_keep_rows_deleted_when_fetched_again(t *testing.T) {
+	for _, tc := range []struct {
+		mode        string
+		incremental bool
+	}{
+		{mode: "soft", incremental: true},
+		{mode: "soft", incremental: false},
+		{mode: "hard", incremental: true},
+		{mode: "hard", incremental: false},
+	} {
+		exporter, db := getDeletionExporter(t, tc.mode, nil, tc.incremental)
+
+		// the second export fetches the rows again after the deletions were read
+		require.NoError(t, exporter.RunSQLite())
+
+		for _, r := range deletedRows {
+			msg := fmt.Sprintf("%s %s incremental=%v", r.table, tc.mode, tc.incremental)
+			if tc.mode == "hard" {
+				var count int64
+				require.NoError(t, db.Table(r.table).Where(r.primaryKey+" = ?", r.id).Count(&count).Error, msg)
+				assert.Zero(t, count, msg)
+				continue
+			}
+
+			var row struct {
+				Deleted   bool
+				DeletedAt *string
+			}
+			require.NoError(t, db.Table(r.table).Select("deleted", "deleted_at").Where(r.primaryKey+" = ?", r.id).Take(&row).Error, msg)
+			assert.True(t, row.Deleted, msg)
+			if assert.NotNil(t, row.DeletedAt, msg) {
+				assert.Contains(t, *row.DeletedAt, "2022-07-05T01:02:10.887", msg)
+			}
+		}
+	}
+}
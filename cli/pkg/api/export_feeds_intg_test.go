@@ -191,7 +191,7 @@ func TestIntegrationDbExportFeeds_should_handle_lots_of_rows_ok(t *testing.T) {
 
 	gock.New("http://localhost:9999").
 		Post("/accounts/history/v1/activity_log/list").
-		BodyString(`{"org_id":"","page_size":0,"page_token":"","filters":{"timeframe":{"from":"0001-01-01T00:00:00Z"},"event_types":["inspection.deleted"],"limit":0}}`).
+		BodyString(`{"org_id":"","page_size":100,"page_token":"","filters":{"timeframe":{"from":"0001-01-01T00:00:00Z"},"event_types":["inspection.deleted"],"limit":100}}`).
 		Reply(http.StatusOK).
 		BodyString(`{"activites": []}`)
 
@@ -249,13 +249,13 @@ func TestIntegrationDbExportFeeds_should_update_action_assignees_on_second_run(t
 	initMockFeedsSet1(apiClient.HTTPClient())
 	gock.New("http://localhost:9999").
 		Post("/accounts/history/v1/activity_log/list").
-		BodyString(`{"org_id":"","page_size":0,"page_token":"","filters":{"timeframe":{"from":"2014-03-17T11:35:40+11:00"},"event_types":["inspection.deleted"],"limit":0}}`).
+		BodyString(`{"org_id":"","page_size":100,"page_token":"","filters":{"timeframe":{"from":"2014-03-17T11:35:40+11:00"},"event_types":["inspection.deleted"],"limit":100}}`).
 		Reply(http.StatusOK).
 		File(path.Join("mocks", "set_1", "inspections_deleted_single_page.json"))
 
 	gock.New("http://localhost:9999").
 		Post("/accounts/history/v1/activity_log/list").
-		BodyString(`{"org_id":"","page_size":0,"page_token":"","filters":{"timeframe":{"from":"2014-03-17T00:35:40Z"},"event_types":["inspection.deleted"],"limit":0}}`).
+		BodyString(`{"org_id":"","page_size":100,"page_token":"","filters":{"timeframe":{"from":"2014-03-17T00:35:40Z"},"event_types":["inspection.deleted"],"limit":100}}`).
 		Reply(http.StatusOK).
 		File(path.Join("mocks", "set_1", "inspections_deleted_single_page.json"))
 
@@ -320,3 +320,49 @@ func TestGroupUserFeed_Export_should_filter_duplicates(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, 5, lines)
 }
+
+// Views are bound to the table itself on Postgres, the rows of the staging table are copied into the live table
+func TestIntegrationDbExportFeeds_should_keep_views_of_tables_refreshed_atomically(t *testing.T) {
+	sqlExporter, err := getTestingSQLExporter()
+	require.NoError(t, err)
+
+	apiClient := GetTestClient()
+
+	gock.New("http://localhost:9999").
+		Get("/accounts/user/v1/user:WhoAmI").
+		Times(2).
+		Reply(200).
+		BodyString(`
+		{
+			"user_id": "user_123",
+			"organisation_id": "role_ada3042f16a44249915ddc088adef92a",
+			"firstname": "Test",
+			"lastname": "Test"
+		  }
+		`)
+
+	cfg := &feed.ExporterFeedCfg{
+		AccessToken:         "token-123",
+		ExportTables:        []string{"users"},
+		ExportAtomicRefresh: true,
+	}
+
+	initMockFeedsSet1(apiClient.HTTPClient())
+	exporterApp := feed.NewExporterApp(apiClient, apiClient, cfg)
+	require.NoError(t, exporterApp.ExportFeeds(sqlExporter, context.Background()))
+
+	require.NoError(t, sqlExporter.DB.Exec("CREATE VIEW user_ids AS SELECT user_id FROM users").Error)
+
+	initMockFeedsSet1(apiClient.HTTPClient())
+	exporterApp = feed.NewExporterApp(apiClient, apiClient, cfg)
+	err = exporterApp.ExportFeeds(sqlExporter, context.Background())
+
+	require.NoError(t, err)
+	assert.False(t, sqlExporter.DB.Migrator().HasTable("users__staging"))
+
+	var users, userIDs int64
+	require.NoError(t, sqlExporter.DB.Table("users").Count(&users).Error)
+	require.NoError(t, sqlExporter.DB.Table("user_ids").Count(&userIDs).Error)
+	assert.Positive(t, users)
+	assert.Equal(t, users, userIDs)
+}
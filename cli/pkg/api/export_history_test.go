@@ -0,0 +1,197 @@
+package api_test
+
+import (
+	"encoding/json"
+	"io/fs"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/SafetyCulture/safetyculture-exporter/pkg/api"
+	"github.com/SafetyCulture/safetyculture-exporter/pkg/mockapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestSafetyCultureExporter_RunSQLite_should_keep_history_of_changed_rows(t *testing.T) {
+	issues, err := fs.ReadFile(mockapi.Seed(), "feeds/issues.json")
+	require.NoError(t, err)
+
+	overlay := fstest.MapFS{"feeds/issues.json": {Data: issues}}
+	mock := mockapi.NewServer(overlayFS{FS: mockapi.Seed(), overlay: overlay})
+	mock.PageSize = 2
+	srv := httptest.NewServer(mock)
+	t.Cleanup(srv.Close)
+
+	exporter, dir := getMockAPIExporter(t, func(cfg *api.ExporterConfiguration) {
+		cfg.API.URL = srv.URL
+		cfg.Export.Tables = []string{"issues"}
+		cfg.Export.HistoryTables = []string{"issues"}
+		cfg.SheqsyUsername = ""
+	})
+
+	require.NoError(t, exporter.RunSQLite())
+	// the rows didn't change, no version is added
+	require.NoError(t, exporter.RunSQLite())
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(dir, "sqlite_export.db")), &gorm.Config{})
+	require.NoError(t, err)
+	assert.EqualValues(t, 39, countRows(t, db, "issues_history"))
+
+	var rows []map[string]interface{}
+	require.NoError(t, json.Unmarshal(issues, &rows))
+	rows[0]["status"] = "CLOSED"
+	rows[0]["modified_at"] = "2020-04-15T00:00:00Z"
+	changed, err := json.Marshal(rows)
+	require.NoError(t, err)
+	overlay["feeds/issues.json"] = &fstest.MapFile{Data: changed}
+
+	require.NoError(t, exporter.RunSQLite())
+
+	assert.EqualValues(t, 39, countRows(t, db, "issues"))
+	assert.EqualValues(t, 40, countRows(t, db, "issues_history"))
+
+	var versions []struct {
+		Status    string
+		ValidFrom time.Time
+		ValidTo   *time.Time
+	}
+	require.NoError(t, db.Table("issues_history").
+		Where("id = ?", "56bc5efa-2420-483d-bad1-27b35922c403").
+		Order("history_id").
+		Find(&versions).Error)
+	require.Len(t, versions, 2)
+
+	changedAt := time.Date(2020, 4, 15, 0, 0, 0, 0, time.UTC)
+	assert.Equal(t, "OPEN", versions[0].Status)
+	assert.True(t, versions[0].ValidFrom.Equal(time.Date(2020, 4, 14, 2, 36, 53, 304000000, time.UTC)))
+	require.NotNil(t, versions[0].ValidTo)
+	assert.True(t, versions[0].ValidTo.Equal(changedAt))
+	assert.Equal(t, "CLOSED", versions[1].Status)
+	assert.True(t, versions[1].ValidFrom.Equal(changedAt))
+	assert.Nil(t, versions[1].ValidTo)
+}
+
+func TestSafetyCultureExporter_RunSQLite_should_keep_history_of_deleted_rows(t *testing.T) {
+	deletedAt := time.Date(2022, 7, 5, 1, 2, 10, 887000000, time.UTC)
+
+	for _, mode := range []string{"soft", "hard"} {
+		t.Run(mode, func(t *testing.T) {
+			mock := mockapi.NewServer(overlayFS{
+				FS:      mockapi.Seed(),
+				overlay: fstest.MapFS{"activity_log.json": {Data: []byte(deletionActivityLog)}},
+			})
+			srv := httptest.NewServer(mock)
+			t.Cleanup(srv.Close)
+
+			exporter, dir := getMockAPIExporter(t, func(cfg *api.ExporterConfiguration) {
+				cfg.API.URL = srv.URL
+				cfg.Export.Tables = []string{"issues"}
+				cfg.Export.HistoryTables = []string{"issues"}
+				cfg.Export.DeletionMode = mode
+				cfg.SheqsyUsername = ""
+			})
+			require.NoError(t, exporter.RunSQLite())
+
+			db, err := gorm.Open(sqlite.Open(filepath.Join(dir, "sqlite_export.db")), &gorm.Config{})
+			require.NoError(t, err)
+
+			var versions []struct {
+				Status    string
+				Deleted   bool
+				ValidFrom time.Time
+				ValidTo   *time.Time
+			}
+			require.NoError(t, db.Table("issues_history").
+				Where("id = ?", "56bc5efa-2420-483d-bad1-27b35922c403").
+				Order("history_id").
+				Find(&versions).Error)
+
+			// the version the row had is closed when it is deleted
+			require.NotEmpty(t, versions)
+			assert.False(t, versions[0].Deleted)
+			require.NotNil(t, versions[0].ValidTo)
+
+			switch mode {
+			case "soft":
+				require.Len(t, versions, 2)
+				assert.True(t, versions[0].ValidTo.Equal(deletedAt))
+				assert.Equal(t, versions[0].Status, versions[1].Status)
+				assert.True(t, versions[1].Deleted)
+				assert.True(t, versions[1].ValidFrom.Equal(deletedAt))
+				assert.Nil(t, versions[1].ValidTo)
+			case "hard":
+				require.Len(t, versions, 1)
+				assert.True(t, versions[0].ValidTo.Equal(deletedAt))
+			}
+
+			// the other rows keep their current version
+			var current int64
+			require.NoError(t, db.Table("issues_history").
+				Where("id <> ? AND valid_to IS NULL", "56bc5efa-2420-483d-bad1-27b35922c403").
+				Count(&current).Error)
+			assert.EqualValues(t, 38, current)
+		})
+	}
+}
+
+func TestSafetyCultureExporter_RunSQLite_should_not_close_versions_before_they_are_valid(t *testing.T) {
+	issues, err := fs.ReadFile(mockapi.Seed(), "feeds/issues.json")
+	require.NoError(t, err)
+
+	// the row was modified after the time of its deletion in the activity log
+	var rows []map[string]interface{}
+	require.NoError(t, json.Unmarshal(issues, &rows))
+	rows[0]["modified_at"] = "2023-01-01T00:00:00Z"
+	modified, err := json.Marshal(rows)
+	require.NoError(t, err)
+	modifiedAt := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	for _, mode := range []string{"soft", "hard"} {
+		t.Run(mode, func(t *testing.T) {
+			mock := mockapi.NewServer(overlayFS{
+				FS: mockapi.Seed(),
+				overlay: fstest.MapFS{
+					"activity_log.json": {Data: []byte(deletionActivityLog)},
+					"feeds/issues.json": {Data: modified},
+				},
+			})
+			srv := httptest.NewServer(mock)
+			t.Cleanup(srv.Close)
+
+			exporter, dir := getMockAPIExporter(t, func(cfg *api.ExporterConfiguration) {
+				cfg.API.URL = srv.URL
+				cfg.Export.Tables = []string{"issues"}
+				cfg.Export.HistoryTables = []string{"issues"}
+				cfg.Export.DeletionMode = mode
+				cfg.SheqsyUsername = ""
+			})
+			require.NoError(t, exporter.RunSQLite())
+
+			db, err := gorm.Open(sqlite.Open(filepath.Join(dir, "sqlite_export.db")), &gorm.Config{})
+			require.NoError(t, err)
+
+			var versions []struct {
+				ID        string
+				ValidFrom time.Time
+				ValidTo   *time.Time
+			}
+			require.NoError(t, db.Table("issues_history").Order("history_id").Find(&versions).Error)
+			require.NotEmpty(t, versions)
+			for _, v := range versions {
+				if v.ValidTo != nil {
+					assert.False(t, v.ValidTo.Before(v.ValidFrom), v.ID)
+				}
+			}
+
+			// the deletion is recorded when the version it closes starts
+			require.Equal(t, "56bc5efa-2420-483d-bad1-27b35922c403", versions[0].ID)
+			require.NotNil(t, versions[0].ValidTo)
+			assert.True(t, versions[0].ValidTo.Equal(modifiedAt))
+		})
+	}
+}
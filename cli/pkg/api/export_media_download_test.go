@@ -0,0 +1,94 @@
+package api_test
+
+import (
+	"encoding/json"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"testing/fstest"
+
+	"github.com/SafetyCulture/safetyculture-exporter/pkg/api"
+	"github.com/SafetyCulture/safetyculture-exporter/pkg/mockapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSafetyCultureExporter_RunCSV_should_report_media_failing_to_download(t *testing.T) {
+	items, err := fs.ReadFile(mockapi.Seed(), "feeds/inspection_items.json")
+	require.NoError(t, err)
+
+	// an item references a media failing once, another a media that can't be downloaded
+	var rows []map[string]interface{}
+	require.NoError(t, json.Unmarshal(items, &rows))
+	rows[2]["media_hypertext_reference"] = "{base_url}/audits/audit_1/media/flaky"
+	rows[3]["media_hypertext_reference"] = "{base_url}/audits/audit_1/media/broken"
+	items, err = json.Marshal(rows)
+	require.NoError(t, err)
+
+	var flakyAttempts, brokenAttempts atomic.Int32
+	mock := mockapi.NewServer(overlayFS{FS: mockapi.Seed(), overlay: fstest.MapFS{
+		"feeds/inspection_items.json": {Data: items},
+	}})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/audits/audit_1/media/flaky":
+			if flakyAttempts.Add(1) == 1 {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			w.Header().Set("Content-Type", "image/jpeg")
+			_, _ = w.Write([]byte("flaky"))
+			return
+		case "/audits/audit_1/media/broken":
+			brokenAttempts.Add(1)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		mock.ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+
+	exporter, dir := getMockAPIExporter(t, func(cfg *api.ExporterConfiguration) {
+		cfg.API.URL = srv.URL
+		cfg.SheqsyUsername = ""
+		cfg.Export.Tables = []string{"inspection_items"}
+		cfg.Export.MediaDownload.Concurrency = 1
+		cfg.Export.MediaDownload.Retries = 1
+	})
+
+	err = exporter.RunCSV()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "/audits/audit_1/media/broken")
+	assert.EqualValues(t, 2, flakyAttempts.Load())
+	assert.EqualValues(t, 2, brokenAttempts.Load())
+
+	// the other media and the rows are exported regardless
+	assert.FileExists(t, filepath.Join(dir, "media", "audit_1", "flaky.jpeg"))
+	assert.FileExists(t, filepath.Join(dir, "media", "audit_1", "12345.jpeg"))
+	content, err := os.ReadFile(filepath.Join(dir, "inspection_items.csv"))
+	require.NoError(t, err)
+	assert.Contains(t, string(content), "/audits/audit_1/media/broken")
+
+	runs, err := exporter.ListExportRuns("csv", 0)
+	require.NoError(t, err)
+	require.Len(t, runs, 1)
+	assert.Equal(t, 1, runs[0].ErrorCount)
+	require.Len(t, runs[0].Errors, 1)
+	assert.Contains(t, runs[0].Errors[0], "download media")
+
+	var media *api.ExportStatusResponseItem
+	for _, item := range exporter.GetExportStatus().Feeds {
+		if item.FeedName == "media" {
+			media = &item
+		}
+	}
+	require.NotNil(t, media)
+	assert.True(t, media.Finished)
+	assert.True(t, media.HasError)
+	assert.EqualValues(t, 2, media.Counter)
+	assert.Equal(t, "1 media couldn't be downloaded", media.StatusMessage)
+}
@@ -0,0 +1,122 @@
+package api_test
+
+import (
+	"encoding/json"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"testing/fstest"
+
+	"github.com/SafetyCulture/safetyculture-exporter/pkg/api"
+	"github.com/SafetyCulture/safetyculture-exporter/pkg/mockapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestSafetyCultureExporter_RunCSV_should_store_media_by_content(t *testing.T) {
+	items, err := fs.ReadFile(mockapi.Seed(), "feeds/inspection_items.json")
+	require.NoError(t, err)
+	photo, err := fs.ReadFile(mockapi.Seed(), "media/12345.jpg")
+	require.NoError(t, err)
+
+	// a second item references another media with the same content
+	var rows []map[string]interface{}
+	require.NoError(t, json.Unmarshal(items, &rows))
+	rows[3]["media_hypertext_reference"] = "{base_url}/audits/audit_1/media/67890"
+	items, err = json.Marshal(rows)
+	require.NoError(t, err)
+
+	var downloads atomic.Int32
+	mock := mockapi.NewServer(overlayFS{FS: mockapi.Seed(), overlay: fstest.MapFS{
+		"feeds/inspection_items.json": {Data: items},
+	}})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/audits/audit_1/media/67890":
+			downloads.Add(1)
+			w.Header().Set("Content-Type", "image/jpeg; charset=binary")
+			_, _ = w.Write(photo)
+			return
+		case "/audits/audit_1/media/12345":
+			downloads.Add(1)
+		}
+		mock.ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+
+	exporter, dir := getMockAPIExporter(t, func(cfg *api.ExporterConfiguration) {
+		cfg.API.URL = srv.URL
+		cfg.SheqsyUsername = ""
+		cfg.Export.Tables = []string{"inspection_items"}
+		cfg.Export.MediaStore = "content"
+	})
+
+	require.NoError(t, exporter.RunCSV())
+	// the media already stored aren't downloaded again
+	require.NoError(t, exporter.RunCSV())
+	assert.EqualValues(t, 2, downloads.Load())
+
+	files, err := filepath.Glob(filepath.Join(dir, "media", "*", "*.jpeg"))
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	assert.NoDirExists(t, filepath.Join(dir, "media", "audit_1"))
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(dir, "sqlite.db")), &gorm.Config{})
+	require.NoError(t, err)
+
+	var media []struct {
+		ItemID      string
+		MediaID     string
+		Hash        string
+		Size        int64
+		ContentType string
+		Path        string
+	}
+	require.NoError(t, db.Table("media").Order("media_id").Find(&media).Error)
+	require.Len(t, media, 2)
+	assert.Equal(t, "12345", media[0].MediaID)
+	assert.Equal(t, "67890", media[1].MediaID)
+	assert.Equal(t, media[0].Hash, media[1].Hash)
+	assert.EqualValues(t, len(photo), media[0].Size)
+	assert.Equal(t, "image/jpeg", media[0].ContentType)
+	assert.Equal(t, filepath.Join(dir, "media", filepath.FromSlash(media[0].Path)), files[0])
+
+	content, err := os.ReadFile(filepath.Join(dir, "media.csv"))
+	require.NoError(t, err)
+	assert.Contains(t, string(content), "audit_id,item_id,media_id,hash,size,content_type,path")
+	assert.Contains(t, string(content), media[1].Hash)
+}
+
+func TestSafetyCultureExporter_RunSQLite_should_download_removed_media_again(t *testing.T) {
+	exporter, dir := getMockAPIExporter(t, func(cfg *api.ExporterConfiguration) {
+		cfg.SheqsyUsername = ""
+		cfg.Export.Tables = []string{"inspection_items"}
+		cfg.Export.MediaStore = "content"
+	})
+
+	require.NoError(t, exporter.RunSQLite())
+
+	files, err := filepath.Glob(filepath.Join(dir, "media", "*", "*.jpeg"))
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	require.NoError(t, os.Remove(files[0]))
+
+	require.NoError(t, exporter.RunSQLite())
+	assert.FileExists(t, files[0])
+}
+
+func TestSafetyCultureExporter_RunSQLite_should_reject_unknown_media_store(t *testing.T) {
+	exporter, _ := getMockAPIExporter(t, func(cfg *api.ExporterConfiguration) {
+		cfg.Export.MediaStore = "s3"
+	})
+
+	err := exporter.RunSQLite()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid media store")
+}
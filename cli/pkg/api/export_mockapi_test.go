@@ -0,0 +1,105 @@
+package api_test
+
+import (
+	"io/fs"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/SafetyCulture/safetyculture-exporter/pkg/api"
+	"github.com/SafetyCulture/safetyculture-exporter/pkg/mockapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// overlayFS serves the files of the overlay in place of the files of the fixtures
+type overlayFS struct {
+	fs.FS
+	overlay fstest.MapFS
+}
+
+func (o overlayFS) Open(name string) (fs.File, error) {
+	if _, ok := o.overlay[name]; ok {
+		return o.overlay.Open(name)
+	}
+	return o.FS.Open(name)
+}
+
+// getMockAPIExporter creates an exporter talking to a mock API serving the seed fixtures, two records per page.
+// configure, when set, amends the configuration of the exporter
+func getMockAPIExporter(t *testing.T, configure func(cfg *api.ExporterConfiguration)) (*api.SafetyCultureExporter, string) {
+	mock := mockapi.NewServer(mockapi.Seed())
+	mock.PageSize = 2
+	srv := httptest.NewServer(mock)
+	t.Cleanup(srv.Close)
+
+	dir, err := os.MkdirTemp("", "export")
+	require.NoError(t, err)
+
+	cfg := api.ExporterConfiguration{}
+	cfg.AccessToken = "token-123"
+	cfg.API.URL = srv.URL
+	cfg.API.SheqsyURL = srv.URL
+	cfg.SheqsyUsername = "sheqsy-user"
+	cfg.SheqsyCompanyID = "company_1"
+	cfg.Db.Dialect = "sqlite"
+	cfg.Db.ConnectionString = filepath.Join(dir, "sql.db")
+	cfg.Csv.MaxRowsPerFile = 100000
+	cfg.Parquet.MaxRowsPerFile = 100000
+	cfg.Jsonl.MaxRowsPerFile = 100000
+	cfg.Export.Path = dir
+	cfg.Export.Media = true
+	cfg.Export.MediaPath = filepath.Join(dir, "media")
+	if configure != nil {
+		configure(&cfg)
+	}
+
+	exporter, err := api.NewSafetyCultureExporter(&cfg, &api.AppVersion{})
+	require.NoError(t, err)
+	return exporter, dir
+}
+
+func TestSafetyCultureExporter_should_export_all_feeds_from_mock_api(t *testing.T) {
+	tests := []struct {
+		format string
+		run    func(*api.SafetyCultureExporter) error
+	}{
+		{format: "sql", run: (*api.SafetyCultureExporter).RunSQL},
+		{format: "csv", run: (*api.SafetyCultureExporter).RunCSV},
+		{format: "sqlite", run: (*api.SafetyCultureExporter).RunSQLite},
+		{format: "parquet", run: (*api.SafetyCultureExporter).RunParquet},
+		{format: "jsonl", run: (*api.SafetyCultureExporter).RunJSONL},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			exporter, dir := getMockAPIExporter(t, nil)
+			require.NoError(t, tt.run(exporter))
+
+			runs, err := exporter.ListExportRuns(tt.format, 0)
+			require.NoError(t, err)
+			require.Len(t, runs, 1)
+			assert.Equal(t, "SUCCEEDED", runs[0].Status)
+			assert.Equal(t, "A User", runs[0].UserName)
+
+			run, err := exporter.GetExportRun(tt.format, runs[0].ID)
+			require.NoError(t, err)
+
+			rows := map[string]int64{}
+			for _, f := range run.Feeds {
+				assert.Equal(t, "SUCCEEDED", f.Status, f.FeedName)
+				rows[f.FeedName] = f.RowsWritten
+			}
+			assert.EqualValues(t, 4, rows["users"])
+			assert.EqualValues(t, 39, rows["issues"])
+			assert.EqualValues(t, 5, rows["inspection_items"])
+			assert.EqualValues(t, 18, rows["template_permissions"])
+			assert.EqualValues(t, 37, rows["sheqsy_shifts"])
+			assert.EqualValues(t, 2, rows["sheqsy_activities"])
+
+			assert.FileExists(t, filepath.Join(dir, "media", "audit_1", "12345.jpeg"))
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package api_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/SafetyCulture/safetyculture-exporter/pkg/api"
+	"github.com/SafetyCulture/safetyculture-exporter/pkg/internal/objectstore/objectstoretest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func getObjectStorage(t *testing.T) *objectstoretest.Server {
+	t.Setenv("AWS_ACCESS_KEY_ID", "test")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "test")
+
+	server := objectstoretest.NewServer()
+	t.Cleanup(server.Close)
+	return server
+}
+
+func keysWithSuffix(keys []string, prefix string, suffix string) []string {
+	var res []string
+	for _, key := range keys {
+		if strings.HasPrefix(key, prefix) && strings.HasSuffix(key, suffix) {
+			res = append(res, key)
+		}
+	}
+	return res
+}
+
+func TestSafetyCultureExporter_RunCSV_should_upload_files_to_object_storage(t *testing.T) {
+	storage := getObjectStorage(t)
+	exporter, dir := getMockAPIExporter(t, func(cfg *api.ExporterConfiguration) {
+		cfg.SheqsyUsername = ""
+		cfg.Csv.MaxRowsPerFile = 2
+		cfg.Export.Tables = []string{"users", "inspection_items"}
+		cfg.Export.ObjectStorage.URL = storage.BucketURL("exports", "sc")
+	})
+
+	require.NoError(t, exporter.RunCSV())
+
+	keys := storage.Keys("exports")
+	assert.Contains(t, keys, "sc/users.csv")
+	assert.Contains(t, keys, "sc/inspection_items.csv")
+	assert.Contains(t, keys, "sc/media/audit_1/12345.jpeg")
+	assert.NotContains(t, keys, "sc/sqlite.db")
+
+	// the 4 users are written to a file and a rollover file
+	rollovers, err := filepath.Glob(filepath.Join(dir, "users-*.csv"))
+	require.NoError(t, err)
+	require.Len(t, rollovers, 1)
+	assert.Contains(t, keys, "sc/"+filepath.Base(rollovers[0]))
+
+	local, err := os.ReadFile(filepath.Join(dir, "users.csv"))
+	require.NoError(t, err)
+	remote, ok := storage.Object("exports", "sc/users.csv")
+	require.True(t, ok)
+	assert.Equal(t, local, remote)
+}
+
+func TestSafetyCultureExporter_RunInspectionReports_should_upload_reports_to_object_storage(t *testing.T) {
+	storage := getObjectStorage(t)
+	exporter, _ := getMockAPIExporter(t, func(cfg *api.ExporterConfiguration) {
+		cfg.SheqsyUsername = ""
+		cfg.Report.Format = []string{"PDF"}
+		cfg.Report.FilenameConvention = "INSPECTION_ID"
+		cfg.Report.RetryTimeout = 15
+		cfg.Export.ObjectStorage.URL = storage.BucketURL("exports", "")
+	})
+
+	require.NoError(t, exporter.RunInspectionReports())
+	assert.NotEmpty(t, keysWithSuffix(storage.Keys("exports"), "", ".pdf"))
+}
+
+func TestSafetyCultureExporter_RunInspectionJSON_should_upload_inspections_to_object_storage(t *testing.T) {
+	storage := getObjectStorage(t)
+	exporter, _ := getMockAPIExporter(t, func(cfg *api.ExporterConfiguration) {
+		cfg.SheqsyUsername = ""
+		cfg.Export.ObjectStorage.URL = storage.BucketURL("exports", "sc")
+	})
+
+	require.NoError(t, exporter.RunInspectionJSON())
+	assert.NotEmpty(t, keysWithSuffix(storage.Keys("exports"), "sc/json/", ".json"))
+}
+
+func TestSafetyCultureExporter_RunCSV_should_reject_invalid_object_storage_url(t *testing.T) {
+	exporter, _ := getMockAPIExporter(t, func(cfg *api.ExporterConfiguration) {
+		cfg.Export.ObjectStorage.URL = "bucket/prefix"
+	})
+
+	err := exporter.RunCSV()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "object storage")
+}
@@ -0,0 +1,193 @@
+package api_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/SafetyCulture/safetyculture-exporter/pkg/api"
+	"github.com/SafetyCulture/safetyculture-exporter/pkg/mockapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+const secondOrgWhoAmI = `{"user_id": "user_2", "organisation_id": "role_org_b", "firstname": "B", "lastname": "User"}`
+
+const secondOrgInspections = `[{
+  "id": "audit_org_b",
+  "name": "Audit of the second organisation",
+  "template_id": "template_1",
+  "created_at": "2014-01-28T23:14:23.000Z",
+  "modified_at": "2014-01-28T23:14:23.000Z",
+  "organisation_id": "role_org_b"
+}]`
+
+// getOrganisationsExporter creates an exporter of two organisations, the mock API serves the fixtures of the
+// organisation of the access token. The requests of the second organisation are passed to observe, if set
+func getOrganisationsExporter(t *testing.T, configure func(cfg *api.ExporterConfiguration), observe func(r *http.Request)) (*api.SafetyCultureExporter, string) {
+	first := mockapi.NewServer(mockapi.Seed())
+	second := mockapi.NewServer(overlayFS{FS: mockapi.Seed(), overlay: fstest.MapFS{
+		"whoami.json":            {Data: []byte(secondOrgWhoAmI)},
+		"feeds/inspections.json": {Data: []byte(secondOrgInspections)},
+	}})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "Bearer token-b" {
+			if observe != nil {
+				observe(r)
+			}
+			second.ServeHTTP(w, r)
+			return
+		}
+		first.ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+
+	return getMockAPIExporter(t, func(cfg *api.ExporterConfiguration) {
+		cfg.AccessToken = ""
+		cfg.Organisations = []api.OrganisationConfiguration{
+			{Name: "first", AccessToken: "token-a"},
+			{Name: "second", AccessToken: "token-b", MaxConcurrency: 1},
+		}
+		cfg.API.URL = srv.URL
+		cfg.SheqsyUsername = ""
+		cfg.Export.Tables = []string{"inspections", "users"}
+		cfg.Export.Incremental = false
+		cfg.Export.Media = false
+		if configure != nil {
+			configure(cfg)
+		}
+	})
+}
+
+func TestSafetyCultureExporter_RunCSV_should_export_every_organisation(t *testing.T) {
+	exporter, dir := getOrganisationsExporter(t, nil, nil)
+
+	require.NoError(t, exporter.RunCSV())
+	// the feeds are truncated for the rows of each organisation, the rows of the other one are kept
+	require.NoError(t, exporter.RunCSV())
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(dir, "sqlite.db")), &gorm.Config{})
+	require.NoError(t, err)
+	assert.EqualValues(t, 4, countRows(t, db, "inspections"))
+
+	files, err := filepath.Glob(filepath.Join(dir, "inspections*.csv"))
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	content, err := os.ReadFile(files[0])
+	require.NoError(t, err)
+	assert.Contains(t, string(content), "audit_org_b")
+	assert.Contains(t, string(content), "audit_47ac0dce16f94d73b5178372368af162")
+
+	runs, err := exporter.ListExportRuns("csv", 2)
+	require.NoError(t, err)
+	require.Len(t, runs, 2)
+
+	orgs := map[string]string{}
+	for _, run := range runs {
+		assert.Equal(t, "SUCCEEDED", run.Status)
+		orgs[run.OrganisationID] = run.UserName
+	}
+	assert.Equal(t, map[string]string{
+		"role_a08b6ac08a0511e29951ddd1182f65d8": "A User",
+		"role_org_b":                            "B User",
+	}, orgs)
+}
+
+func TestSafetyCultureExporter_RunSQLite_should_export_every_organisation_incrementally(t *testing.T) {
+	var mu sync.Mutex
+	var modifiedAfter []string
+	exporter, dir := getOrganisationsExporter(t, func(cfg *api.ExporterConfiguration) {
+		cfg.Export.Tables = []string{"inspections", "users", "issues"}
+		cfg.Export.Incremental = true
+	}, func(r *http.Request) {
+		if r.URL.Path != "/feed/inspections" && r.URL.Path != "/audits/search" {
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		modifiedAfter = append(modifiedAfter, r.URL.Query().Get("modified_after"))
+	})
+
+	require.NoError(t, exporter.RunSQLite())
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(dir, "sqlite_export.db")), &gorm.Config{})
+	require.NoError(t, err)
+	// the issues have no organisation_id, the rows no organisation exports are dropped by the next run
+	require.NoError(t, db.Exec("INSERT INTO issues (id, title) VALUES ('issue_stale', 'Stale issue')").Error)
+
+	mu.Lock()
+	modifiedAfter = nil
+	mu.Unlock()
+	require.NoError(t, exporter.RunSQLite())
+
+	assert.EqualValues(t, 4, countRows(t, db, "inspections"))
+	assert.EqualValues(t, 39, countRows(t, db, "issues"))
+
+	// the second organisation resumes from its own inspections, the watermark of the first one isn't shared
+	mu.Lock()
+	defer mu.Unlock()
+	require.NotEmpty(t, modifiedAfter)
+	for _, after := range modifiedAfter {
+		resumed, err := time.Parse(time.RFC3339Nano, after)
+		require.NoError(t, err)
+		assert.True(t, resumed.Equal(time.Date(2014, 1, 28, 23, 14, 23, 0, time.UTC)), after)
+	}
+}
+
+func TestSafetyCultureExporter_RunSQLite_should_report_errors_per_organisation(t *testing.T) {
+	mock := mockapi.NewServer(mockapi.Seed())
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "Bearer token-b" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		mock.ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+
+	exporter, _ := getMockAPIExporter(t, func(cfg *api.ExporterConfiguration) {
+		cfg.AccessToken = ""
+		cfg.Organisations = []api.OrganisationConfiguration{
+			{Name: "first", AccessToken: "token-a"},
+			{Name: "revoked", AccessToken: "token-b"},
+		}
+		cfg.API.URL = srv.URL
+		cfg.SheqsyUsername = ""
+		cfg.Export.Tables = []string{"users"}
+	})
+
+	err := exporter.RunSQLite()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "organisation revoked")
+
+	runs, err := exporter.ListExportRuns("sqlite", 0)
+	require.NoError(t, err)
+	require.Len(t, runs, 2)
+
+	statuses := map[string]string{}
+	for _, run := range runs {
+		statuses[run.UserName] = run.Status
+	}
+	assert.Equal(t, map[string]string{"A User": "SUCCEEDED", "": "FAILED"}, statuses)
+}
+
+func TestSafetyCultureExporter_RunSQLite_should_not_refresh_atomically_several_organisations(t *testing.T) {
+	exporter, _ := getMockAPIExporter(t, func(cfg *api.ExporterConfiguration) {
+		cfg.Organisations = []api.OrganisationConfiguration{
+			{Name: "first", AccessToken: "token-a"},
+			{Name: "second", AccessToken: "token-b"},
+		}
+		cfg.Export.AtomicRefresh = true
+	})
+
+	err := exporter.RunSQLite()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "atomic refresh is not supported")
+}
@@ -0,0 +1,95 @@
+package api_test
+
+import (
+	"encoding/json"
+	"io/fs"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/SafetyCulture/safetyculture-exporter/pkg/api"
+	"github.com/SafetyCulture/safetyculture-exporter/pkg/mockapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// getPivotRows reads the pivot table of template_1 through a new connection, the columns of the table change
+// between the exports
+func getPivotRows(t *testing.T, path string) []map[string]interface{} {
+	db, err := gorm.Open(sqlite.Open(path), &gorm.Config{})
+	require.NoError(t, err)
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	defer sqlDB.Close()
+
+	var rows []map[string]interface{}
+	require.NoError(t, db.Table("inspection_pivot_template_1").Find(&rows).Error)
+	return rows
+}
+
+func TestSafetyCultureExporter_RunCSV_should_pivot_inspection_items(t *testing.T) {
+	items, err := fs.ReadFile(mockapi.Seed(), "feeds/inspection_items.json")
+	require.NoError(t, err)
+
+	overlay := fstest.MapFS{"feeds/inspection_items.json": {Data: items}}
+	mock := mockapi.NewServer(overlayFS{FS: mockapi.Seed(), overlay: overlay})
+	srv := httptest.NewServer(mock)
+	t.Cleanup(srv.Close)
+
+	exporter, dir := getMockAPIExporter(t, func(cfg *api.ExporterConfiguration) {
+		cfg.API.URL = srv.URL
+		cfg.SheqsyUsername = ""
+		cfg.Export.Tables = []string{"inspection_items"}
+		cfg.Export.PivotTemplateIds = []string{"template_1"}
+		cfg.Export.Incremental = false
+		cfg.Export.Media = false
+	})
+
+	require.NoError(t, exporter.RunCSV())
+
+	rows := getPivotRows(t, filepath.Join(dir, "sqlite.db"))
+	require.Len(t, rows, 1)
+	assert.Equal(t, "audit_1", rows[0]["audit_id"])
+	assert.Equal(t, "ABBA", rows[0]["client_site"])
+	assert.Equal(t, "Agnetha Fältskog", rows[0]["prepared_by"])
+	assert.NotContains(t, rows[0], "information")
+
+	content, err := os.ReadFile(filepath.Join(dir, "inspection_pivot_template_1.csv"))
+	require.NoError(t, err)
+	assert.Contains(t, string(content), "audit_id,organisation_id,modified_at,audit_title,client_site,conducted_on,prepared_by")
+	assert.Contains(t, string(content), "ABBA")
+
+	// the question is renamed in a new version of the template, the column is named after the latest label
+	var seed []map[string]interface{}
+	require.NoError(t, json.Unmarshal(items, &seed))
+	seed[2]["label"] = "Customer"
+	seed[2]["modified_at"] = "2014-02-28T23:14:23.000Z"
+	changed, err := json.Marshal(seed)
+	require.NoError(t, err)
+	overlay["feeds/inspection_items.json"] = &fstest.MapFile{Data: changed}
+
+	require.NoError(t, exporter.RunCSV())
+
+	rows = getPivotRows(t, filepath.Join(dir, "sqlite.db"))
+	require.Len(t, rows, 1)
+	assert.Equal(t, "ABBA", rows[0]["customer"])
+	assert.NotContains(t, rows[0], "client_site")
+}
+
+func TestSafetyCultureExporter_RunSQLite_should_skip_pivot_without_inspection_items(t *testing.T) {
+	exporter, dir := getMockAPIExporter(t, func(cfg *api.ExporterConfiguration) {
+		cfg.SheqsyUsername = ""
+		cfg.Export.Tables = []string{"users"}
+		cfg.Export.PivotTemplateIds = []string{"template_1"}
+	})
+
+	require.NoError(t, exporter.RunSQLite())
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(dir, "sqlite_export.db")), &gorm.Config{})
+	require.NoError(t, err)
+	assert.False(t, db.Migrator().HasTable("inspection_pivot_template_1"))
+}
@@ -0,0 +1,136 @@
+package api_test
+
+import (
+	"archive/zip"
+	"encoding/csv"
+	"encoding/json"
+	"io/fs"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/SafetyCulture/safetyculture-exporter/pkg/api"
+	"github.com/SafetyCulture/safetyculture-exporter/pkg/mockapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// readBundle returns the rows of the index of an archive and the names of its files
+func readBundle(t *testing.T, filePath string) ([][]string, []string) {
+	archive, err := zip.OpenReader(filePath)
+	require.NoError(t, err)
+	defer archive.Close()
+
+	var index [][]string
+	var names []string
+	for _, f := range archive.File {
+		names = append(names, f.Name)
+		if f.Name != "index.csv" {
+			continue
+		}
+
+		r, err := f.Open()
+		require.NoError(t, err)
+		index, err = csv.NewReader(r).ReadAll()
+		require.NoError(t, err)
+		r.Close()
+	}
+	sort.Strings(names)
+	return index, names
+}
+
+func bundleModTimes(t *testing.T, dir string) map[string]time.Time {
+	entries, err := os.ReadDir(filepath.Join(dir, "bundles"))
+	require.NoError(t, err)
+
+	res := map[string]time.Time{}
+	for _, entry := range entries {
+		info, err := entry.Info()
+		require.NoError(t, err)
+		res[entry.Name()] = info.ModTime()
+	}
+	return res
+}
+
+func TestSafetyCultureExporter_RunInspectionReports_should_bundle_reports(t *testing.T) {
+	overlay := fstest.MapFS{}
+	mock := mockapi.NewServer(overlayFS{FS: mockapi.Seed(), overlay: overlay})
+	srv := httptest.NewServer(mock)
+	t.Cleanup(srv.Close)
+
+	exporter, dir := getMockAPIExporter(t, func(cfg *api.ExporterConfiguration) {
+		cfg.API.URL = srv.URL
+		cfg.SheqsyUsername = ""
+		cfg.Export.Incremental = true
+		cfg.Report.Format = []string{"PDF"}
+		cfg.Report.FilenameConvention = "INSPECTION_ID"
+		cfg.Report.RetryTimeout = 15
+		cfg.Report.BundleBy = []string{"month"}
+	})
+
+	require.NoError(t, exporter.RunInspectionReports())
+
+	index, names := readBundle(t, filepath.Join(dir, "bundles", "2014-03.zip"))
+	assert.Equal(t, []string{
+		"audit_4d95cb4be1e7488bba5893fecd2379d2.pdf",
+		"audit_4e28ab2cce8c44a781d376d0ac47dc92.pdf",
+		"index.csv",
+	}, names)
+	assert.Equal(t, [][]string{
+		{"audit_id", "title", "site", "date", "score", "file_name"},
+		{"audit_4e28ab2cce8c44a781d376d0ac47dc92", "", "", "2014-03-06", "0", "audit_4e28ab2cce8c44a781d376d0ac47dc92.pdf"},
+		{"audit_4d95cb4be1e7488bba5893fecd2379d2", "", "", "2014-03-17", "0", "audit_4d95cb4be1e7488bba5893fecd2379d2.pdf"},
+	}, index)
+
+	index, names = readBundle(t, filepath.Join(dir, "bundles", "2014-01.zip"))
+	assert.Equal(t, []string{"audit_47ac0dce16f94d73b5178372368af162.pdf", "index.csv"}, names)
+	require.Len(t, index, 2)
+	assert.Equal(t, "My Audit", index[1][1])
+
+	// the archives without new reports are kept as they are
+	before := bundleModTimes(t, dir)
+	require.NoError(t, exporter.RunInspectionReports())
+	assert.Equal(t, before, bundleModTimes(t, dir))
+
+	// the inspection moves to another month, both archives are rebuilt
+	inspections, err := fs.ReadFile(mockapi.Seed(), "feeds/inspections.json")
+	require.NoError(t, err)
+	var rows []map[string]interface{}
+	require.NoError(t, json.Unmarshal(inspections, &rows))
+	for _, row := range rows {
+		if row["id"] == "audit_4d95cb4be1e7488bba5893fecd2379d2" {
+			row["date_started"] = "2014-01-30T00:00:00.000Z"
+			row["modified_at"] = "2014-03-18T00:00:00.000Z"
+		}
+	}
+	inspections, err = json.Marshal(rows)
+	require.NoError(t, err)
+	overlay["feeds/inspections.json"] = &fstest.MapFile{Data: inspections}
+
+	require.NoError(t, exporter.RunInspectionReports())
+
+	_, names = readBundle(t, filepath.Join(dir, "bundles", "2014-01.zip"))
+	assert.Equal(t, []string{
+		"audit_47ac0dce16f94d73b5178372368af162.pdf",
+		"audit_4d95cb4be1e7488bba5893fecd2379d2.pdf",
+		"index.csv",
+	}, names)
+	_, names = readBundle(t, filepath.Join(dir, "bundles", "2014-03.zip"))
+	assert.Equal(t, []string{"audit_4e28ab2cce8c44a781d376d0ac47dc92.pdf", "index.csv"}, names)
+}
+
+func TestSafetyCultureExporter_RunInspectionReports_should_reject_invalid_bundle(t *testing.T) {
+	exporter, _ := getMockAPIExporter(t, func(cfg *api.ExporterConfiguration) {
+		cfg.SheqsyUsername = ""
+		cfg.Report.Format = []string{"PDF"}
+		cfg.Report.FilenameConvention = "INSPECTION_ID"
+		cfg.Report.BundleBy = []string{"week"}
+	})
+
+	err := exporter.RunInspectionReports()
+	assert.ErrorContains(t, err, `reports can't be bundled by "week"`)
+}
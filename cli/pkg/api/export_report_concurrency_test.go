@@ -0,0 +1,70 @@
+package api_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/SafetyCulture/safetyculture-exporter/pkg/api"
+	"github.com/SafetyCulture/safetyculture-exporter/pkg/mockapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// pendingReports counts the reports initiated and not downloaded yet
+type pendingReports struct {
+	handler http.Handler
+	mu      sync.Mutex
+	pending int
+	max     int
+}
+
+func (p *pendingReports) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p.mu.Lock()
+	switch {
+	case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/report"):
+		p.pending++
+		p.max = max(p.max, p.pending)
+	case strings.HasPrefix(r.URL.Path, "/reports/"):
+		p.pending--
+	}
+	p.mu.Unlock()
+
+	p.handler.ServeHTTP(w, r)
+}
+
+func TestSafetyCultureExporter_RunInspectionReports_should_generate_reports_concurrently(t *testing.T) {
+	tests := map[string]struct {
+		concurrency int
+		expected    int
+	}{
+		"sequential": {concurrency: 1, expected: 1},
+		"concurrent": {concurrency: 3, expected: 3},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			mock := mockapi.NewServer(mockapi.Seed())
+			mock.ReportPendingPolls = 1
+			reports := &pendingReports{handler: mock}
+			srv := httptest.NewServer(reports)
+			t.Cleanup(srv.Close)
+
+			exporter, dir := getMockAPIExporter(t, func(cfg *api.ExporterConfiguration) {
+				cfg.API.URL = srv.URL
+				cfg.SheqsyUsername = ""
+				cfg.Report.Format = []string{"PDF"}
+				cfg.Report.FilenameConvention = "INSPECTION_ID"
+				cfg.Report.RetryTimeout = 15
+				cfg.Report.Concurrency = tt.concurrency
+			})
+
+			require.NoError(t, exporter.RunInspectionReports())
+			assert.Len(t, listReports(t, dir), 3)
+			assert.Equal(t, 0, reports.pending)
+			assert.Equal(t, tt.expected, reports.max)
+		})
+	}
+}
@@ -0,0 +1,182 @@
+package api_test
+
+import (
+	"encoding/json"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/SafetyCulture/safetyculture-exporter/pkg/api"
+	"github.com/SafetyCulture/safetyculture-exporter/pkg/mockapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// feedRequests counts the requests to the inspections feed
+type feedRequests struct {
+	handler     http.Handler
+	inspections atomic.Int32
+}
+
+func (f *feedRequests) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/feed/inspections" {
+		f.inspections.Add(1)
+	}
+	f.handler.ServeHTTP(w, r)
+}
+
+func TestSafetyCultureExporter_RunInspectionReports_should_read_inspections_from_sql(t *testing.T) {
+	requests := &feedRequests{handler: mockapi.NewServer(mockapi.Seed())}
+	srv := httptest.NewServer(requests)
+	t.Cleanup(srv.Close)
+
+	var cfg *api.ExporterConfiguration
+	exporter, dir := getMockAPIExporter(t, func(c *api.ExporterConfiguration) {
+		c.API.URL = srv.URL
+		c.SheqsyUsername = ""
+		c.Export.Media = false
+		c.Export.Tables = []string{"inspections"}
+		c.Report.Format = []string{"PDF"}
+		c.Report.FilenameConvention = "INSPECTION_ID"
+		c.Report.RetryTimeout = 15
+		cfg = c
+	})
+
+	require.NoError(t, exporter.RunSQL())
+	require.NotZero(t, requests.inspections.Load())
+	requests.inspections.Store(0)
+
+	cfg.Report.Source = "sql"
+	cfg.Report.Filter.TemplateIDs = []string{"template_2", "template_3"}
+	cfg.Report.Filter.DateFrom.Time = time.Date(2014, 3, 10, 0, 0, 0, 0, time.UTC)
+	exporter.SetConfiguration(cfg)
+
+	require.NoError(t, exporter.RunInspectionReports())
+	assert.EqualValues(t, 0, requests.inspections.Load())
+	assert.Equal(t, []string{"audit_4d95cb4be1e7488bba5893fecd2379d2.pdf"}, listReports(t, dir))
+
+	// only the inspections matching the filter are read from the SQL database
+	db, err := gorm.Open(sqlite.Open(filepath.Join(dir, "reports.db")), &gorm.Config{})
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, countRows(t, db, "inspections"))
+
+	auditIDsFile := filepath.Join(t.TempDir(), "audit_ids.txt")
+	require.NoError(t, os.WriteFile(auditIDsFile, []byte("audit_4e28ab2cce8c44a781d376d0ac47dc92\naudit_4d95cb4be1e7488bba5893fecd2379d2"), 0o644))
+	cfg.Report.Filter.TemplateIDs = nil
+	cfg.Report.Filter.DateFrom.Time = time.Time{}
+	cfg.Report.Filter.AuditIDsFile = auditIDsFile
+	exporter.SetConfiguration(cfg)
+
+	require.NoError(t, exporter.RunInspectionReports())
+	assert.EqualValues(t, 2, countRows(t, db, "inspections"))
+}
+
+func TestSafetyCultureExporter_RunInspectionReports_should_reject_unknown_source(t *testing.T) {
+	exporter, _ := getMockAPIExporter(t, func(cfg *api.ExporterConfiguration) {
+		cfg.SheqsyUsername = ""
+		cfg.Report.Format = []string{"PDF"}
+		cfg.Report.Source = "postgres"
+	})
+
+	err := exporter.RunInspectionReports()
+	assert.ErrorContains(t, err, `invalid report source "postgres"`)
+}
+
+func TestSafetyCultureExporter_RunInspectionReports_should_fail_without_sql_inspections(t *testing.T) {
+	exporter, _ := getMockAPIExporter(t, func(cfg *api.ExporterConfiguration) {
+		cfg.SheqsyUsername = ""
+		cfg.Report.Format = []string{"PDF"}
+		cfg.Report.FilenameConvention = "INSPECTION_ID"
+		cfg.Report.Source = "sql"
+	})
+
+	err := exporter.RunInspectionReports()
+	assert.ErrorContains(t, err, "no inspections table in the SQL database")
+}
+
+func TestSafetyCultureExporter_RunInspectionReports_should_filter_inspections(t *testing.T) {
+	inspections, err := fs.ReadFile(mockapi.Seed(), "feeds/inspections.json")
+	require.NoError(t, err)
+
+	var rows []map[string]interface{}
+	require.NoError(t, json.Unmarshal(inspections, &rows))
+	scores := map[string]float64{
+		"audit_47ac0dce16f94d73b5178372368af162": 90,
+		"audit_4e28ab2cce8c44a781d376d0ac47dc92": 40,
+		"audit_4d95cb4be1e7488bba5893fecd2379d2": 60,
+	}
+	for _, row := range rows {
+		row["score_percentage"] = scores[row["id"].(string)]
+	}
+	inspections, err = json.Marshal(rows)
+	require.NoError(t, err)
+
+	mock := mockapi.NewServer(overlayFS{FS: mockapi.Seed(), overlay: fstest.MapFS{
+		"feeds/inspections.json": {Data: inspections},
+	}})
+	srv := httptest.NewServer(mock)
+	t.Cleanup(srv.Close)
+
+	auditIDsFile := filepath.Join(t.TempDir(), "audit_ids.txt")
+	require.NoError(t, os.WriteFile(auditIDsFile, []byte(strings.Join([]string{
+		"# inspections to generate",
+		"audit_47ac0dce16f94d73b5178372368af162",
+		"",
+		"audit_4e28ab2cce8c44a781d376d0ac47dc92",
+	}, "\n")), 0o644))
+
+	exporter, dir := getMockAPIExporter(t, func(cfg *api.ExporterConfiguration) {
+		cfg.API.URL = srv.URL
+		cfg.SheqsyUsername = ""
+		cfg.Report.Format = []string{"PDF"}
+		cfg.Report.FilenameConvention = "INSPECTION_ID"
+		cfg.Report.RetryTimeout = 15
+		cfg.Report.Filter.ScoreMin = 50
+		cfg.Report.Filter.AuditIDsFile = auditIDsFile
+	})
+
+	require.NoError(t, exporter.RunInspectionReports())
+	assert.Equal(t, []string{"audit_47ac0dce16f94d73b5178372368af162.pdf"}, listReports(t, dir))
+
+	statuses, err := exporter.ListReportStatuses()
+	require.NoError(t, err)
+	assert.Equal(t, "SUCCEEDED", statusesByAuditID(statuses)["audit_47ac0dce16f94d73b5178372368af162"].Status)
+	assert.Equal(t, "PENDING", statusesByAuditID(statuses)["audit_4d95cb4be1e7488bba5893fecd2379d2"].Status)
+}
+
+func TestSafetyCultureExporter_RunInspectionReports_should_reject_empty_audit_ids_file(t *testing.T) {
+	auditIDsFile := filepath.Join(t.TempDir(), "audit_ids.txt")
+	require.NoError(t, os.WriteFile(auditIDsFile, []byte("\n# nothing to generate\n"), 0o644))
+
+	exporter, _ := getMockAPIExporter(t, func(cfg *api.ExporterConfiguration) {
+		cfg.SheqsyUsername = ""
+		cfg.Report.Format = []string{"PDF"}
+		cfg.Report.Filter.AuditIDsFile = auditIDsFile
+	})
+
+	err := exporter.RunInspectionReports()
+	assert.ErrorContains(t, err, "no audit IDs found")
+}
+
+func TestSafetyCultureExporter_RunInspectionReports_should_reject_inverted_date_range(t *testing.T) {
+	exporter, dir := getMockAPIExporter(t, func(cfg *api.ExporterConfiguration) {
+		cfg.SheqsyUsername = ""
+		cfg.Report.Format = []string{"PDF"}
+		cfg.Report.FilenameConvention = "INSPECTION_ID"
+		cfg.Report.Filter.DateFrom.Time = time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+		cfg.Report.Filter.DateTo.Time = time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	})
+
+	err := exporter.RunInspectionReports()
+	assert.ErrorContains(t, err, "invalid report date range")
+	assert.Empty(t, listReports(t, dir))
+}
@@ -0,0 +1,105 @@
+package api_test
+
+import (
+	"encoding/json"
+	"io/fs"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/SafetyCulture/safetyculture-exporter/pkg/api"
+	"github.com/SafetyCulture/safetyculture-exporter/pkg/mockapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// listReports returns the reports of an export, relative to the export path
+func listReports(t *testing.T, dir string) []string {
+	var reports []string
+	require.NoError(t, filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err == nil && strings.HasSuffix(path, ".pdf") {
+			rel, _ := filepath.Rel(dir, path)
+			reports = append(reports, filepath.ToSlash(rel))
+		}
+		return err
+	}))
+	sort.Strings(reports)
+	return reports
+}
+
+func TestSafetyCultureExporter_RunInspectionReports_should_use_path_template(t *testing.T) {
+	inspections, err := fs.ReadFile(mockapi.Seed(), "feeds/inspections.json")
+	require.NoError(t, err)
+
+	var rows []map[string]interface{}
+	require.NoError(t, json.Unmarshal(inspections, &rows))
+	rows[0]["site_id"] = "location_2"
+	inspections, err = json.Marshal(rows)
+	require.NoError(t, err)
+
+	mock := mockapi.NewServer(overlayFS{FS: mockapi.Seed(), overlay: fstest.MapFS{
+		"feeds/inspections.json": {Data: inspections},
+	}})
+	srv := httptest.NewServer(mock)
+	t.Cleanup(srv.Close)
+
+	exporter, dir := getMockAPIExporter(t, func(cfg *api.ExporterConfiguration) {
+		cfg.API.URL = srv.URL
+		cfg.SheqsyUsername = ""
+		cfg.Report.Format = []string{"PDF"}
+		cfg.Report.PathTemplate = "{template_name}/{site_name}/{date:2006-01}/{title}_{audit_id}.{ext}"
+		cfg.Report.RetryTimeout = 15
+	})
+
+	expected := []string{
+		"General-Workplace-Inspection/Schrute-Farms/2014-01/My-Audit_audit_47ac0dce16f94d73b5178372368af162.pdf",
+		"Group-9---Townsville-Tourism-Walking-Tour/unknown/2014-03/audit_4e28ab2cce8c44a781d376d0ac47dc92_audit_4e28ab2cce8c44a781d376d0ac47dc92.pdf",
+		"unknown/unknown/2014-03/audit_4d95cb4be1e7488bba5893fecd2379d2_audit_4d95cb4be1e7488bba5893fecd2379d2.pdf",
+	}
+
+	require.NoError(t, exporter.RunInspectionReports())
+	assert.Equal(t, expected, listReports(t, dir))
+}
+
+func TestSafetyCultureExporter_RunInspectionReports_should_overwrite_reports_of_same_inspection(t *testing.T) {
+	exporter, dir := getMockAPIExporter(t, func(cfg *api.ExporterConfiguration) {
+		cfg.SheqsyUsername = ""
+		cfg.Report.Format = []string{"PDF"}
+		cfg.Report.PathTemplate = "{date:2006}.{ext}"
+		cfg.Report.RetryTimeout = 15
+	})
+
+	// the inspections share the same path, the one created first keeps it
+	expected := []string{
+		"2014 (audit_4d95cb4be1e7488bba5893fecd2379d2).pdf",
+		"2014 (audit_4e28ab2cce8c44a781d376d0ac47dc92).pdf",
+		"2014.pdf",
+	}
+
+	require.NoError(t, exporter.RunInspectionReports())
+	assert.Equal(t, expected, listReports(t, dir))
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "2014.pdf"), []byte("outdated"), 0666))
+	require.NoError(t, exporter.RunInspectionReports())
+	assert.Equal(t, expected, listReports(t, dir))
+
+	content, err := os.ReadFile(filepath.Join(dir, "2014.pdf"))
+	require.NoError(t, err)
+	assert.NotEqual(t, "outdated", string(content))
+}
+
+func TestSafetyCultureExporter_RunInspectionReports_should_reject_invalid_path_template(t *testing.T) {
+	exporter, _ := getMockAPIExporter(t, func(cfg *api.ExporterConfiguration) {
+		cfg.SheqsyUsername = ""
+		cfg.Report.Format = []string{"PDF"}
+		cfg.Report.PathTemplate = "{inspection_name}.{ext}"
+	})
+
+	err := exporter.RunInspectionReports()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unknown field {inspection_name}")
+}
@@ -0,0 +1,137 @@
+package api_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/SafetyCulture/safetyculture-exporter/pkg/api"
+	"github.com/SafetyCulture/safetyculture-exporter/pkg/mockapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const failingReportAuditID = "audit_4e28ab2cce8c44a781d376d0ac47dc92"
+
+// failingReports fails the generation of the reports of an inspection while enabled
+type failingReports struct {
+	handler   http.Handler
+	fail      atomic.Bool
+	initiated atomic.Int32
+}
+
+func (f *failingReports) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/report") {
+		f.initiated.Add(1)
+	}
+
+	if f.fail.Load() && strings.HasPrefix(r.URL.Path, "/audits/"+failingReportAuditID+"/report/") {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"status": "FAILED"}`))
+		return
+	}
+	f.handler.ServeHTTP(w, r)
+}
+
+func statusesByAuditID(statuses []api.ReportStatusResponseItem) map[string]api.ReportStatusResponseItem {
+	res := map[string]api.ReportStatusResponseItem{}
+	for _, s := range statuses {
+		res[s.AuditID] = s
+	}
+	return res
+}
+
+func TestSafetyCultureExporter_RunInspectionReports_should_retry_failed_reports(t *testing.T) {
+	reports := &failingReports{handler: mockapi.NewServer(mockapi.Seed())}
+	reports.fail.Store(true)
+	srv := httptest.NewServer(reports)
+	t.Cleanup(srv.Close)
+
+	var cfg *api.ExporterConfiguration
+	exporter, _ := getMockAPIExporter(t, func(c *api.ExporterConfiguration) {
+		c.API.URL = srv.URL
+		c.SheqsyUsername = ""
+		c.Report.Format = []string{"PDF"}
+		c.Report.FilenameConvention = "INSPECTION_ID"
+		c.Report.RetryTimeout = 15
+		cfg = c
+	})
+
+	err := exporter.RunInspectionReports()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to generate 1 PDF reports")
+	assert.EqualValues(t, 3, reports.initiated.Load())
+
+	statuses, err := exporter.ListReportStatuses()
+	require.NoError(t, err)
+	require.Len(t, statuses, 3)
+
+	failed := statusesByAuditID(statuses)[failingReportAuditID]
+	assert.Equal(t, "FAILED", failed.Status)
+	assert.Equal(t, 1, failed.Attempts)
+	assert.NotNil(t, failed.LastAttemptAt)
+	assert.Empty(t, failed.Paths)
+	require.Len(t, failed.Errors, 1)
+	assert.Contains(t, failed.Errors[0], "PDF: PDF report generation failed on server")
+
+	for _, s := range statuses {
+		if s.AuditID != failingReportAuditID {
+			assert.Equal(t, "SUCCEEDED", s.Status)
+			require.Len(t, s.Paths, 1)
+			assert.True(t, strings.HasSuffix(s.Paths[0], s.AuditID+".pdf"))
+			assert.Empty(t, s.Errors)
+		}
+	}
+
+	// only the failed report is generated again
+	reports.fail.Store(false)
+	cfg.Report.RetryFailed = true
+	exporter.SetConfiguration(cfg)
+
+	require.NoError(t, exporter.RunInspectionReports())
+	assert.EqualValues(t, 4, reports.initiated.Load())
+
+	statuses, err = exporter.ListReportStatuses()
+	require.NoError(t, err)
+	require.Len(t, statuses, 3)
+	for _, s := range statuses {
+		assert.Equal(t, "SUCCEEDED", s.Status)
+		assert.Empty(t, s.Errors)
+	}
+
+	retried := statusesByAuditID(statuses)[failingReportAuditID]
+	assert.Equal(t, 2, retried.Attempts)
+	require.Len(t, retried.Paths, 1)
+	assert.True(t, strings.HasSuffix(retried.Paths[0], failingReportAuditID+".pdf"))
+}
+
+func TestSafetyCultureExporter_ListReportStatuses_should_list_pending_reports(t *testing.T) {
+	var cfg *api.ExporterConfiguration
+	exporter, _ := getMockAPIExporter(t, func(c *api.ExporterConfiguration) {
+		c.SheqsyUsername = ""
+		c.Report.Format = []string{"PDF"}
+		c.Report.FilenameConvention = "INSPECTION_TITLE"
+		c.Report.RetryTimeout = 15
+		cfg = c
+	})
+
+	_, err := exporter.ListReportStatuses()
+	assert.ErrorContains(t, err, "no report export found")
+
+	require.NoError(t, exporter.RunInspectionReports())
+
+	// the WORD reports are yet to be generated
+	cfg.Report.Format = []string{"PDF", "WORD"}
+	exporter.SetConfiguration(cfg)
+
+	statuses, err := exporter.ListReportStatuses()
+	require.NoError(t, err)
+	require.Len(t, statuses, 3)
+	for _, s := range statuses {
+		assert.Equal(t, "PENDING", s.Status)
+		assert.Equal(t, 1, s.Attempts)
+		assert.Len(t, s.Paths, 1)
+	}
+}
@@ -0,0 +1,118 @@
+package api_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/SafetyCulture/safetyculture-exporter/pkg/internal/feed"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/h2non/gock.v1"
+)
+
+const whoAmIOrgID = "role_ada3042f16a44249915ddc088adef92a"
+
+func mockWhoAmI() {
+	gock.New("http://localhost:9999").
+		Get("/accounts/user/v1/user:WhoAmI").
+		Reply(200).
+		BodyString(`
+		{
+			"user_id": "user_bda3042f16a44249915ddc088adef92b",
+			"organisation_id": "role_ada3042f16a44249915ddc088adef92a",
+			"firstname": "Test",
+			"lastname": "Test"
+		  }
+		`)
+}
+
+func TestExporterFeedClient_ExportFeeds_should_save_progress_of_interrupted_export(t *testing.T) {
+	defer gock.Off()
+
+	exporter, err := getTemporaryCSVExporter()
+	require.NoError(t, err)
+
+	apiClient := GetTestClient()
+	gock.InterceptClient(apiClient.HTTPClient())
+
+	mockWhoAmI()
+	gock.New("http://localhost:9999").
+		Get("/feed/users").
+		MatchParam("next_page_token", "page_2").
+		Reply(http.StatusBadRequest).
+		BodyString(`{"error": "bad request"}`)
+	gock.New("http://localhost:9999").
+		Get("/feed/users").
+		Reply(200).
+		BodyString(`{
+			"metadata": {"next_page": "/feed/users?next_page_token=page_2", "remaining_records": 1},
+			"data": [{"id": "user_1", "organisation_id": "role_1", "email": "user_1@example.com", "active": true}]
+		}`)
+
+	cfg := &feed.ExporterFeedCfg{
+		AccessToken:  "token-123",
+		ExportTables: []string{"users"},
+	}
+	exporterApp := feed.NewExporterApp(apiClient, apiClient, cfg)
+
+	err = exporterApp.ExportFeeds(exporter, context.Background())
+	assert.Error(t, err)
+
+	state, err := exporter.GetExportState("users", whoAmIOrgID)
+	require.NoError(t, err)
+	require.NotNil(t, state)
+	assert.Equal(t, feed.ExportStateFailed, state.Status)
+	assert.Equal(t, "/feed/users?next_page_token=page_2", state.NextPage)
+}
+
+func TestExporterFeedClient_ExportFeeds_should_resume_interrupted_export(t *testing.T) {
+	defer gock.Off()
+
+	exporter, err := getTemporaryCSVExporter()
+	require.NoError(t, err)
+
+	apiClient := GetTestClient()
+	gock.InterceptClient(apiClient.HTTPClient())
+
+	usersFeed := &feed.UserFeed{}
+	require.NoError(t, exporter.InitFeed(usersFeed, &feed.InitFeedOptions{Truncate: true}))
+	require.NoError(t, exporter.WriteRows(usersFeed, []*feed.User{{ID: "user_1", OrganisationID: "role_1", Email: "user_1@example.com"}}))
+	require.NoError(t, exporter.SaveExportState(&feed.ExportState{
+		FeedName:       "users",
+		OrganisationID: whoAmIOrgID,
+		NextPage:       "/feed/users?next_page_token=page_2",
+		Status:         feed.ExportStateFailed,
+	}))
+
+	mockWhoAmI()
+	gock.New("http://localhost:9999").
+		Get("/feed/users").
+		MatchParam("next_page_token", "page_2").
+		Reply(200).
+		BodyString(`{
+			"metadata": {"next_page": null, "remaining_records": 0},
+			"data": [{"id": "user_2", "organisation_id": "role_1", "email": "user_2@example.com", "active": true}]
+		}`)
+
+	cfg := &feed.ExporterFeedCfg{
+		AccessToken:  "token-123",
+		ExportTables: []string{"users"},
+		ExportResume: true,
+	}
+	exporterApp := feed.NewExporterApp(apiClient, apiClient, cfg)
+
+	err = exporterApp.ExportFeeds(exporter, context.Background())
+	require.NoError(t, err)
+	assert.True(t, gock.IsDone())
+
+	var count int64
+	require.NoError(t, exporter.DB.Table("users").Count(&count).Error)
+	assert.EqualValues(t, 2, count)
+
+	state, err := exporter.GetExportState("users", whoAmIOrgID)
+	require.NoError(t, err)
+	require.NotNil(t, state)
+	assert.Equal(t, feed.ExportStateCompleted, state.Status)
+	assert.Empty(t, state.NextPage)
+}
@@ -0,0 +1,112 @@
+package api_test
+
+import (
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/SafetyCulture/safetyculture-exporter/pkg/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/h2non/gock.v1"
+)
+
+func getRunsTestExporter(t *testing.T, apiClientReplies func()) *api.SafetyCultureExporter {
+	dir, err := os.MkdirTemp("", "export")
+	require.NoError(t, err)
+
+	cfg := api.ExporterConfiguration{}
+	cfg.AccessToken = "token-123"
+	cfg.Csv.MaxRowsPerFile = 100000
+	cfg.Export.Path = dir
+	cfg.Export.Tables = []string{"users"}
+
+	apiClient := GetTestClient()
+	gock.InterceptClient(apiClient.HTTPClient())
+	apiClientReplies()
+
+	exporter, err := api.NewSafetyCultureExporter(&cfg, &api.AppVersion{})
+	require.NoError(t, err)
+	exporter.SetApiClient(apiClient)
+	exporter.SetSheqsyApiClient(apiClient)
+	return exporter
+}
+
+func TestSafetyCultureExporter_ListExportRuns_should_record_successful_run(t *testing.T) {
+	defer gock.Off()
+
+	exporter := getRunsTestExporter(t, func() {
+		mockWhoAmI()
+		gock.New("http://localhost:9999").
+			Get("/feed/users").
+			Reply(200).
+			File("mocks/set_1/feed_users_1.json")
+	})
+
+	require.NoError(t, exporter.RunCSV())
+
+	runs, err := exporter.ListExportRuns("csv", 0)
+	require.NoError(t, err)
+	require.Len(t, runs, 1)
+	assert.Equal(t, "SUCCEEDED", runs[0].Status)
+	assert.Equal(t, "user_bda3042f16a44249915ddc088adef92b", runs[0].UserID)
+	assert.Equal(t, "Test Test", runs[0].UserName)
+	assert.Equal(t, whoAmIOrgID, runs[0].OrganisationID)
+	assert.Len(t, runs[0].ConfigHash, 64)
+	assert.NotNil(t, runs[0].FinishedAt)
+	assert.Zero(t, runs[0].ErrorCount)
+
+	run, err := exporter.GetExportRun("csv", runs[0].ID)
+	require.NoError(t, err)
+	require.Len(t, run.Feeds, 1)
+	assert.Equal(t, "users", run.Feeds[0].FeedName)
+	assert.Equal(t, "SUCCEEDED", run.Feeds[0].Status)
+	assert.EqualValues(t, 4, run.Feeds[0].RowsWritten)
+}
+
+func TestSafetyCultureExporter_ListExportRuns_should_record_failed_run(t *testing.T) {
+	defer gock.Off()
+
+	exporter := getRunsTestExporter(t, func() {
+		mockWhoAmI()
+		gock.New("http://localhost:9999").
+			Get("/feed/users").
+			Reply(http.StatusBadRequest).
+			BodyString(`{"error": "bad request"}`)
+	})
+
+	require.Error(t, exporter.RunCSV())
+
+	runs, err := exporter.ListExportRuns("csv", 0)
+	require.NoError(t, err)
+	require.Len(t, runs, 1)
+	assert.Equal(t, "FAILED", runs[0].Status)
+	assert.Equal(t, 1, runs[0].ErrorCount)
+	require.Len(t, runs[0].Errors, 1)
+	assert.Contains(t, runs[0].Errors[0], `feed "users"`)
+
+	run, err := exporter.GetExportRun("csv", runs[0].ID)
+	require.NoError(t, err)
+	require.Len(t, run.Feeds, 1)
+	assert.Equal(t, "FAILED", run.Feeds[0].Status)
+	assert.NotEmpty(t, run.Feeds[0].Error)
+}
+
+func TestSafetyCultureExporter_GetExportRun_should_fail_when_run_is_unknown(t *testing.T) {
+	defer gock.Off()
+
+	exporter := getRunsTestExporter(t, func() {
+		mockWhoAmI()
+		gock.New("http://localhost:9999").
+			Get("/feed/users").
+			Reply(200).
+			File("mocks/set_1/feed_users_1.json")
+	})
+	require.NoError(t, exporter.RunCSV())
+
+	_, err := exporter.GetExportRun("csv", "unknown")
+	assert.EqualError(t, err, `export run "unknown" not found`)
+
+	_, err = exporter.ListExportRuns("parquet-gz", 0)
+	assert.EqualError(t, err, `open export history: unsupported export format "parquet-gz"`)
+}
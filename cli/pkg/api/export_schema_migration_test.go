@@ -0,0 +1,118 @@
+package api_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/SafetyCulture/safetyculture-exporter/pkg/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestSafetyCultureExporter_ApplySchemaMigration_should_bring_schema_up_to_date(t *testing.T) {
+	exporter, _ := getMockAPIExporter(t, func(cfg *api.ExporterConfiguration) {
+		cfg.Db.AutoMigrateDisabled = true
+		cfg.Export.MediaStore = "content"
+	})
+
+	plan, err := exporter.PlanSchemaMigration()
+	require.NoError(t, err)
+	assert.False(t, plan.UpToDate)
+	assert.Empty(t, plan.CurrentVersion)
+	assert.Contains(t, plan.Script, "CREATE TABLE `inspections`")
+	assert.Contains(t, plan.Script, "CREATE TABLE `media`")
+	assert.Contains(t, plan.Script, "CREATE TABLE `schema_migrations`")
+	assert.Contains(t, plan.Script, "INSERT INTO `schema_migrations`")
+
+	applied, err := exporter.ApplySchemaMigration()
+	require.NoError(t, err)
+	assert.Equal(t, plan.Version, applied.Version)
+
+	plan, err = exporter.PlanSchemaMigration()
+	require.NoError(t, err)
+	assert.True(t, plan.UpToDate)
+	assert.Equal(t, applied.Version, plan.CurrentVersion)
+	assert.Empty(t, plan.Statements)
+
+	migrations, err := exporter.ListSchemaMigrations()
+	require.NoError(t, err)
+	require.Len(t, migrations, 1)
+	assert.Equal(t, applied.Version, migrations[0].Version)
+	assert.Equal(t, len(applied.Statements), migrations[0].Statements)
+
+	require.NoError(t, exporter.RunSQL())
+}
+
+func TestSafetyCultureExporter_PlanSchemaMigration_should_add_missing_columns(t *testing.T) {
+	configure := func(keepUTC bool) func(cfg *api.ExporterConfiguration) {
+		return func(cfg *api.ExporterConfiguration) {
+			cfg.Db.AutoMigrateDisabled = true
+			cfg.Export.KeepUTCTime = keepUTC
+		}
+	}
+	exporter, dir := getMockAPIExporter(t, configure(false))
+	_, err := exporter.ApplySchemaMigration()
+	require.NoError(t, err)
+
+	exporter, _ = getMockAPIExporter(t, func(cfg *api.ExporterConfiguration) {
+		configure(true)(cfg)
+		cfg.Db.ConnectionString = filepath.Join(dir, "sql.db")
+	})
+
+	plan, err := exporter.PlanSchemaMigration()
+	require.NoError(t, err)
+	assert.False(t, plan.UpToDate)
+	assert.Contains(t, plan.Statements, "ALTER TABLE `inspections` ADD `modified_at_utc` datetime")
+	assert.NotContains(t, plan.Script, "CREATE TABLE")
+
+	// the export refuses to run against the out-of-date schema
+	err = exporter.RunSQL()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "the database schema is out of date")
+
+	_, err = exporter.ApplySchemaMigration()
+	require.NoError(t, err)
+	require.NoError(t, exporter.RunSQL())
+}
+
+func TestSafetyCultureExporter_PlanSchemaMigration_should_alter_and_drop_columns(t *testing.T) {
+	exporter, dir := getMockAPIExporter(t, func(cfg *api.ExporterConfiguration) {
+		cfg.Db.AutoMigrateDisabled = true
+	})
+	_, err := exporter.ApplySchemaMigration()
+	require.NoError(t, err)
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(dir, "sql.db")), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.Exec("ALTER TABLE inspections ADD legacy_score text").Error)
+	require.NoError(t, db.Exec("ALTER TABLE inspections DROP COLUMN score").Error)
+	require.NoError(t, db.Exec("ALTER TABLE inspections ADD score text").Error)
+	require.NoError(t, db.Exec("INSERT INTO inspections (audit_id, score) VALUES ('audit_1', '12.5')").Error)
+
+	plan, err := exporter.PlanSchemaMigration()
+	require.NoError(t, err)
+	assert.False(t, plan.UpToDate)
+	assert.Equal(t, []string{
+		"ALTER TABLE `inspections` RENAME COLUMN `score` TO `score__replaced`",
+		"ALTER TABLE `inspections` ADD `score` real",
+		"UPDATE `inspections` SET `score` = `score__replaced`",
+		"ALTER TABLE `inspections` DROP COLUMN `score__replaced`",
+		"ALTER TABLE `inspections` DROP COLUMN `legacy_score`",
+	}, plan.Destructive)
+	assert.Contains(t, plan.Script, "-- destructive, data may be lost\nALTER TABLE `inspections` DROP COLUMN `legacy_score`;")
+
+	_, err = exporter.ApplySchemaMigration()
+	require.NoError(t, err)
+
+	plan, err = exporter.PlanSchemaMigration()
+	require.NoError(t, err)
+	assert.True(t, plan.UpToDate)
+
+	// the values are kept when the type of their column changes
+	var score float64
+	require.NoError(t, db.Table("inspections").Select("score").Where("audit_id = ?", "audit_1").Scan(&score).Error)
+	assert.Equal(t, 12.5, score)
+	assert.False(t, db.Migrator().HasColumn("inspections", "legacy_score"))
+}
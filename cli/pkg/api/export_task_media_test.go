@@ -0,0 +1,73 @@
+package api_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/SafetyCulture/safetyculture-exporter/pkg/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// the first action and the first issue of the seed reference the media 12345
+const (
+	seedActionID = "123"
+	seedIssueID  = "56bc5efa-2420-483d-bad1-27b35922c403"
+)
+
+func TestSafetyCultureExporter_RunCSV_should_export_media_of_actions_and_issues(t *testing.T) {
+	exporter, dir := getMockAPIExporter(t, func(cfg *api.ExporterConfiguration) {
+		cfg.SheqsyUsername = ""
+		cfg.Export.Tables = []string{"actions", "issues"}
+	})
+
+	require.NoError(t, exporter.RunCSV())
+	assert.FileExists(t, filepath.Join(dir, "media", "actions", seedActionID, "12345.jpeg"))
+	assert.FileExists(t, filepath.Join(dir, "media", "issues", seedIssueID, "12345.jpeg"))
+
+	content, err := os.ReadFile(filepath.Join(dir, "actions.csv"))
+	require.NoError(t, err)
+	assert.Contains(t, string(content), ",12345,http://")
+}
+
+func TestSafetyCultureExporter_RunCSV_should_store_media_of_actions_and_issues_by_content(t *testing.T) {
+	exporter, dir := getMockAPIExporter(t, func(cfg *api.ExporterConfiguration) {
+		cfg.SheqsyUsername = ""
+		cfg.Export.Tables = []string{"actions", "issues"}
+		cfg.Export.MediaStore = "content"
+	})
+
+	require.NoError(t, exporter.RunCSV())
+	assert.NoDirExists(t, filepath.Join(dir, "media", "actions"))
+	assert.NoDirExists(t, filepath.Join(dir, "media", "issues"))
+
+	files, err := filepath.Glob(filepath.Join(dir, "media", "*", "*.jpeg"))
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(dir, "sqlite.db")), &gorm.Config{})
+	require.NoError(t, err)
+
+	var audits []string
+	require.NoError(t, db.Table("media").Where("media_id = ?", "12345").Order("audit_id").Pluck("audit_id", &audits).Error)
+	assert.Equal(t, []string{"actions/" + seedActionID, "issues/" + seedIssueID}, audits)
+}
+
+func TestSafetyCultureExporter_RunCSV_should_not_export_media_of_actions_when_disabled(t *testing.T) {
+	exporter, dir := getMockAPIExporter(t, func(cfg *api.ExporterConfiguration) {
+		cfg.SheqsyUsername = ""
+		cfg.Export.Media = false
+		cfg.Export.Tables = []string{"actions"}
+	})
+
+	require.NoError(t, exporter.RunCSV())
+	assert.NoDirExists(t, filepath.Join(dir, "media", "actions"))
+
+	// the media IDs are recorded regardless
+	content, err := os.ReadFile(filepath.Join(dir, "actions.csv"))
+	require.NoError(t, err)
+	assert.Contains(t, string(content), ",12345,")
+}
@@ -0,0 +1,143 @@
+package api_test
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/SafetyCulture/safetyculture-exporter/pkg/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// readCSVRecords reads the only CSV file exported for a feed, keyed by column
+func readCSVRecords(t *testing.T, dir string, feedName string) []map[string]string {
+	files, err := filepath.Glob(filepath.Join(dir, feedName+"*.csv"))
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+
+	file, err := os.Open(files[0])
+	require.NoError(t, err)
+	defer file.Close()
+
+	records, err := csv.NewReader(file).ReadAll()
+	require.NoError(t, err)
+	require.NotEmpty(t, records)
+
+	var rows []map[string]string
+	for _, record := range records[1:] {
+		row := map[string]string{}
+		for i, column := range records[0] {
+			row[column] = record[i]
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+func TestSafetyCultureExporter_RunCSV_should_write_timestamps_in_time_zone(t *testing.T) {
+	exporter, dir := getMockAPIExporter(t, func(cfg *api.ExporterConfiguration) {
+		cfg.Export.Tables = []string{"users", "inspections"}
+		cfg.Export.TimeZone = "Australia/Sydney"
+		cfg.SheqsyUsername = ""
+	})
+
+	require.NoError(t, exporter.RunCSV())
+
+	users := readCSVRecords(t, dir, "users")
+	require.Len(t, users, 4)
+	// 2020-10-29T12:28:42Z during daylight saving time in Sydney
+	assert.Equal(t, "2020-10-29T23:28:42+11:00", users[0]["last_seen_at"])
+	assert.NotContains(t, users[0], "last_seen_at_utc")
+
+	inspections := readCSVRecords(t, dir, "inspections")
+	require.NotEmpty(t, inspections)
+	// 2014-01-28T23:14:23Z
+	assert.Equal(t, "2014-01-29T10:14:23+11:00", inspections[0]["created_at"])
+}
+
+func TestSafetyCultureExporter_RunCSV_should_keep_utc_timestamps(t *testing.T) {
+	exporter, dir := getMockAPIExporter(t, func(cfg *api.ExporterConfiguration) {
+		cfg.Export.Tables = []string{"users"}
+		cfg.Export.TimeZone = "America/New_York"
+		cfg.Export.KeepUTCTime = true
+		cfg.SheqsyUsername = ""
+	})
+
+	require.NoError(t, exporter.RunCSV())
+
+	users := readCSVRecords(t, dir, "users")
+	require.Len(t, users, 4)
+	assert.Equal(t, "2020-10-29T08:28:42-04:00", users[0]["last_seen_at"])
+	assert.Equal(t, "2020-10-29T12:28:42Z", users[0]["last_seen_at_utc"])
+	assert.Empty(t, users[1]["last_seen_at"])
+	assert.Empty(t, users[1]["last_seen_at_utc"])
+
+	exportedAt, err := time.Parse(time.RFC3339Nano, users[0]["exported_at"])
+	require.NoError(t, err)
+	exportedAtUTC, err := time.Parse(time.RFC3339Nano, users[0]["exported_at_utc"])
+	require.NoError(t, err)
+	assert.True(t, exportedAt.Equal(exportedAtUTC))
+	assert.Equal(t, time.UTC, exportedAtUTC.Location())
+}
+
+func TestSafetyCultureExporter_RunSQLite_should_keep_utc_timestamps(t *testing.T) {
+	exporter, dir := getMockAPIExporter(t, func(cfg *api.ExporterConfiguration) {
+		cfg.Export.Tables = []string{"users"}
+		cfg.Export.TimeZone = "Asia/Tokyo"
+		cfg.Export.KeepUTCTime = true
+		cfg.SheqsyUsername = ""
+	})
+
+	require.NoError(t, exporter.RunSQLite())
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(dir, "sqlite_export.db")), &gorm.Config{})
+	require.NoError(t, err)
+
+	var row struct {
+		LastSeenAt    string
+		LastSeenAtUTC string `gorm:"column:last_seen_at_utc"`
+	}
+	require.NoError(t, db.Raw("SELECT last_seen_at, last_seen_at_utc FROM users WHERE user_id = ?", "user_1").Scan(&row).Error)
+	assert.Equal(t, "2020-10-29T21:28:42+09:00", row.LastSeenAt)
+	assert.Equal(t, "2020-10-29T12:28:42Z", row.LastSeenAtUTC)
+}
+
+func TestSafetyCultureExporter_RunJSONL_should_write_timestamps_in_time_zone(t *testing.T) {
+	exporter, dir := getMockAPIExporter(t, func(cfg *api.ExporterConfiguration) {
+		cfg.Export.Tables = []string{"users"}
+		cfg.Export.TimeZone = "Europe/Paris"
+		cfg.Export.KeepUTCTime = true
+		cfg.SheqsyUsername = ""
+	})
+
+	require.NoError(t, exporter.RunJSONL())
+
+	files, err := filepath.Glob(filepath.Join(dir, "users*.jsonl"))
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	file, err := os.Open(files[0])
+	require.NoError(t, err)
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	require.True(t, scanner.Scan())
+	var user map[string]interface{}
+	require.NoError(t, json.Unmarshal(scanner.Bytes(), &user))
+	assert.Equal(t, "2020-10-29T12:28:42Z", user["last_seen_at_utc"])
+	assert.Equal(t, "2020-10-29T13:28:42+01:00", user["last_seen_at"])
+}
+
+func TestSafetyCultureExporter_RunCSV_should_fail_when_time_zone_is_invalid(t *testing.T) {
+	exporter, _ := getMockAPIExporter(t, func(cfg *api.ExporterConfiguration) {
+		cfg.Export.TimeZone = "Mars/Olympus_Mons"
+	})
+
+	assert.ErrorContains(t, exporter.RunCSV(), `invalid time zone "Mars/Olympus_Mons"`)
+}
@@ -4,22 +4,40 @@ import (
 	"context"
 	"encoding/base64"
 	"fmt"
+	"io"
 	"net/url"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/SafetyCulture/safetyculture-exporter/pkg/httpapi"
 	"github.com/SafetyCulture/safetyculture-exporter/pkg/internal/exporter"
 	"github.com/SafetyCulture/safetyculture-exporter/pkg/internal/feed"
 	"github.com/SafetyCulture/safetyculture-exporter/pkg/internal/inspections"
+	"github.com/SafetyCulture/safetyculture-exporter/pkg/internal/objectstore"
 	"github.com/SafetyCulture/safetyculture-exporter/pkg/internal/templates"
 	"github.com/SafetyCulture/safetyculture-exporter/pkg/internal/util"
 	"github.com/pkg/errors"
 )
 
-var ctx context.Context
-var cancelFunc context.CancelFunc
+// cancelFunc cancels the export started by the last Run call, guarded by cancelMu as the export may be cancelled from
+// another goroutine such as the UI
+var (
+	cancelMu   sync.Mutex
+	cancelFunc context.CancelFunc
+)
+
+// exportContext returns the context of an export started by a Run call, cancelled by CancelExport
+func exportContext() context.Context {
+	cancelMu.Lock()
+	defer cancelMu.Unlock()
+
+	var ctx context.Context
+	ctx, cancelFunc = context.WithCancel(context.Background())
+	return ctx
+}
 
 // NewSafetyCultureExporter builds a SafetyCultureExporter with clients inferred from own configuration
 func NewSafetyCultureExporter(cfg *ExporterConfiguration, version *AppVersion) (*SafetyCultureExporter, error) {
@@ -33,9 +51,23 @@ func NewSafetyCultureExporter(cfg *ExporterConfiguration, version *AppVersion) (
 		return nil, err
 	}
 
+	var organisations []feed.Organisation
+	for _, org := range cfg.Organisations {
+		orgApiClient, err := getAPIClient(cfg.ToOrganisationApiConfig(org), version)
+		if err != nil {
+			return nil, err
+		}
+		organisations = append(organisations, feed.Organisation{
+			Name:                    org.Name,
+			APIClient:               orgApiClient,
+			MaxConcurrentGoRoutines: org.MaxConcurrency,
+		})
+	}
+
 	return &SafetyCultureExporter{
 		apiClient:       apiClient,
 		sheqsyApiClient: sheqsyApiClient,
+		organisations:   organisations,
 		cfg:             cfg,
 		version:         version,
 		exportStatus:    feed.GetExporterStatus(),
@@ -115,6 +147,11 @@ func NewReportExporter(exportPath string, reportCfg *ReportExporterCfg) (*feed.R
 		return nil, res.Error
 	}
 
+	auditIDs, err := readAuditIDs(reportCfg.Filter.AuditIDsFile)
+	if err != nil {
+		return nil, err
+	}
+
 	return &feed.ReportExporter{
 		SQLExporter:  sqlExporter,
 		Logger:       sqlExporter.Logger,
@@ -122,7 +159,20 @@ func NewReportExporter(exportPath string, reportCfg *ReportExporterCfg) (*feed.R
 		Format:       reportCfg.Format,
 		PreferenceID: reportCfg.PreferenceID,
 		Filename:     reportCfg.Filename,
+		PathTemplate: reportCfg.PathTemplate,
 		RetryTimeout: reportCfg.RetryTimeout,
+		Concurrency:  reportCfg.Concurrency,
+		RetryFailed:  reportCfg.RetryFailed,
+		BundleBy:     reportCfg.BundleBy,
+		Filter: feed.ReportFilter{
+			SiteIDs:     reportCfg.Filter.SiteIDs,
+			TemplateIDs: reportCfg.Filter.TemplateIDs,
+			DateFrom:    reportCfg.Filter.DateFrom,
+			DateTo:      reportCfg.Filter.DateTo,
+			ScoreMin:    reportCfg.Filter.ScoreMin,
+			ScoreMax:    reportCfg.Filter.ScoreMax,
+			AuditIDs:    auditIDs,
+		},
 	}, nil
 }
 
@@ -130,7 +180,50 @@ type ReportExporterCfg struct {
 	Format       []string
 	PreferenceID string
 	Filename     string
+	PathTemplate string
 	RetryTimeout int
+	Concurrency  int
+	RetryFailed  bool
+	// Source is where the inspections are read from, api or sql
+	Source   string
+	Filter   ReportFilterCfg
+	BundleBy []string
+}
+
+// ReportFilterCfg limits the inspections the reports are generated for
+type ReportFilterCfg struct {
+	SiteIDs     []string
+	TemplateIDs []string
+	DateFrom    time.Time
+	DateTo      time.Time
+	ScoreMin    float64
+	ScoreMax    float64
+	// AuditIDsFile is a file listing the IDs of the inspections, one per line
+	AuditIDsFile string
+}
+
+// readAuditIDs reads the audit IDs of a file, one per line. Empty lines and lines starting with # are skipped
+func readAuditIDs(path string) ([]string, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "read audit IDs file")
+	}
+
+	var ids []string
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "#") {
+			ids = append(ids, line)
+		}
+	}
+	if len(ids) == 0 {
+		return nil, fmt.Errorf("no audit IDs found in %s", path)
+	}
+	return ids, nil
 }
 
 type HttpApiCfg struct {
@@ -147,6 +240,7 @@ type HttpApiCfg struct {
 type SafetyCultureExporter struct {
 	apiClient       *httpapi.Client
 	sheqsyApiClient *httpapi.Client
+	organisations   []feed.Organisation
 	cfg             *ExporterConfiguration
 	version         *AppVersion
 	exportStatus    *feed.ExportStatus
@@ -160,6 +254,38 @@ func (s *SafetyCultureExporter) SetSheqsyApiClient(apiClient *httpapi.Client) {
 	s.sheqsyApiClient = apiClient
 }
 
+// newFeedExporterApp returns the app exporting the feeds of the configured organisations
+func (s *SafetyCultureExporter) newFeedExporterApp() *feed.ExporterFeedClient {
+	exporterApp := feed.NewExporterApp(s.apiClient, s.sheqsyApiClient, s.cfg.ToExporterConfig())
+	for _, org := range s.organisations {
+		exporterApp.AddOrganisation(org)
+	}
+	return exporterApp
+}
+
+// openMirror opens the object storage the exported files are copied to, it returns nil when none is configured.
+// The files of the export path are copied under the prefix of the bucket, and the media under its media folder
+func (s *SafetyCultureExporter) openMirror(ctx context.Context) (*objectstore.Mirror, error) {
+	if s.cfg.Export.ObjectStorage.URL == "" {
+		return nil, nil
+	}
+
+	bucket, err := objectstore.Open(ctx, s.cfg.Export.ObjectStorage.URL)
+	if err != nil {
+		return nil, errors.Wrap(err, "open object storage")
+	}
+
+	mirror := objectstore.NewMirror(bucket)
+	mirror.AddDir(s.cfg.Export.Path, "")
+	mirror.AddDir(s.cfg.Export.MediaPath, "media")
+	return mirror, nil
+}
+
+// hasFeedCredentials returns whether credentials are configured to export feeds
+func (s *SafetyCultureExporter) hasFeedCredentials() bool {
+	return len(s.cfg.AccessToken) != 0 || len(s.organisations) != 0 || len(s.cfg.SheqsyUsername) != 0
+}
+
 func (s *SafetyCultureExporter) RunInspectionJSON() error {
 	exportPath := fmt.Sprintf("%s/json/", s.cfg.Export.Path)
 	err := os.MkdirAll(exportPath, os.ModePerm)
@@ -167,7 +293,12 @@ func (s *SafetyCultureExporter) RunInspectionJSON() error {
 		return errors.Wrapf(err, "Failed to create directory %s", exportPath)
 	}
 
-	e := exporter.NewJSONExporter(exportPath)
+	mirror, err := s.openMirror(context.Background())
+	if err != nil {
+		return err
+	}
+
+	e := exporter.NewJSONExporter(exportPath, mirror)
 	cfg := inspections.InspectionClientCfg{
 		SkipIDs:       s.cfg.Export.Inspection.SkipIds,
 		ModifiedAfter: s.cfg.Export.ModifiedAfter.Time,
@@ -194,7 +325,11 @@ func (s *SafetyCultureExporter) CheckDBConnection() error {
 }
 
 func (s *SafetyCultureExporter) RunSQL() error {
-	ctx, cancelFunc = context.WithCancel(context.Background())
+	return s.runSQL(exportContext())
+}
+
+// runSQL runs the export into the SQL database until ctx is done
+func (s *SafetyCultureExporter) runSQL(ctx context.Context) error {
 	if s.cfg.Export.Media {
 		err := os.MkdirAll(s.cfg.Export.MediaPath, os.ModePerm)
 		if err != nil {
@@ -206,13 +341,17 @@ func (s *SafetyCultureExporter) RunSQL() error {
 	if err != nil {
 		return errors.Wrap(err, "create sql exporter")
 	}
+	e.Mirror, err = s.openMirror(ctx)
+	if err != nil {
+		return err
+	}
 
-	exporterApp := feed.NewExporterApp(s.apiClient, s.sheqsyApiClient, s.cfg.ToExporterConfig())
+	exporterApp := s.newFeedExporterApp()
 	if s.cfg.Export.SchemaOnly {
 		return exporterApp.ExportSchemas(e)
 	}
 
-	if len(s.cfg.AccessToken) != 0 || len(s.cfg.SheqsyUsername) != 0 {
+	if s.hasFeedCredentials() {
 		err = exporterApp.ExportFeeds(e, ctx)
 		if err != nil {
 			return errors.Wrap(err, "exporting feeds")
@@ -224,43 +363,77 @@ func (s *SafetyCultureExporter) RunSQL() error {
 
 // RunSQLite - runs the export and will save into a local sqlite db file
 func (s *SafetyCultureExporter) RunSQLite() error {
-	ctx, cancelFunc = context.WithCancel(context.Background())
-	exportPath := s.cfg.Export.Path
-
-	err := os.MkdirAll(exportPath, os.ModePerm)
-	if err != nil {
-		return errors.Wrapf(err, "Failed to create directory %s", exportPath)
-	}
+	return s.runSQLite(exportContext())
+}
 
-	if s.cfg.Export.Media {
-		err := os.MkdirAll(s.cfg.Export.MediaPath, os.ModePerm)
+func (s *SafetyCultureExporter) runSQLite(ctx context.Context) error {
+	return s.runFileExport(ctx, func(exportPath string, mirror *objectstore.Mirror) (feed.Exporter, error) {
+		e, err := feed.NewSQLiteExporter(exportPath, s.cfg.Export.MediaPath)
 		if err != nil {
-			return errors.Wrapf(err, "Failed to create directory %s", s.cfg.Export.MediaPath)
+			return nil, errors.Wrap(err, "unable to create sqlite exporter")
 		}
-	}
+		e.Mirror = mirror
+		return e, nil
+	})
+}
 
-	sqlExporter, err := feed.NewSQLiteExporter(exportPath, s.cfg.Export.MediaPath)
-	if err != nil {
-		return errors.Wrap(err, "unable to create sqlite exporter")
-	}
+func (s *SafetyCultureExporter) RunCSV() error {
+	return s.runCSV(exportContext())
+}
 
-	exporterApp := feed.NewExporterApp(s.apiClient, s.sheqsyApiClient, s.cfg.ToExporterConfig())
-	if s.cfg.Export.SchemaOnly {
-		return exporterApp.ExportSchemas(sqlExporter)
-	}
+func (s *SafetyCultureExporter) runCSV(ctx context.Context) error {
+	return s.runFileExport(ctx, func(exportPath string, mirror *objectstore.Mirror) (feed.Exporter, error) {
+		if s.cfg.Csv.Streaming {
+			e, err := feed.NewStreamingCSVExporter(exportPath, s.cfg.Export.MediaPath, s.cfg.Csv.MaxRowsPerFile)
+			if err != nil {
+				return nil, errors.Wrap(err, "unable to create csv exporter")
+			}
+			e.Mirror = mirror
+			return e, nil
+		}
 
-	if len(s.cfg.AccessToken) != 0 || len(s.cfg.SheqsyUsername) != 0 {
-		err = exporterApp.ExportFeeds(sqlExporter, ctx)
+		e, err := feed.NewCSVExporter(exportPath, s.cfg.Export.MediaPath, s.cfg.Csv.MaxRowsPerFile)
 		if err != nil {
-			return errors.Wrap(err, "exporting feeds")
+			return nil, errors.Wrap(err, "unable to create csv exporter")
 		}
-	}
+		e.Mirror = mirror
+		return e, nil
+	})
+}
 
-	return nil
+func (s *SafetyCultureExporter) RunParquet() error {
+	return s.runParquet(exportContext())
 }
 
-func (s *SafetyCultureExporter) RunCSV() error {
-	ctx, cancelFunc = context.WithCancel(context.Background())
+func (s *SafetyCultureExporter) runParquet(ctx context.Context) error {
+	return s.runFileExport(ctx, func(exportPath string, mirror *objectstore.Mirror) (feed.Exporter, error) {
+		e, err := feed.NewParquetExporter(exportPath, s.cfg.Export.MediaPath, s.cfg.Parquet.MaxRowsPerFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to create parquet exporter")
+		}
+		e.Mirror = mirror
+		return e, nil
+	})
+}
+
+func (s *SafetyCultureExporter) RunJSONL() error {
+	return s.runJSONL(exportContext())
+}
+
+func (s *SafetyCultureExporter) runJSONL(ctx context.Context) error {
+	return s.runFileExport(ctx, func(exportPath string, mirror *objectstore.Mirror) (feed.Exporter, error) {
+		e, err := feed.NewJSONLExporter(exportPath, s.cfg.Export.MediaPath, s.cfg.Jsonl.MaxRowsPerFile, s.cfg.Jsonl.Gzip)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to create jsonl exporter")
+		}
+		e.Mirror = mirror
+		return e, nil
+	})
+}
+
+// runFileExport runs the export of the feeds into files of the export path, with the exporter returned by newExporter,
+// until ctx is done
+func (s *SafetyCultureExporter) runFileExport(ctx context.Context, newExporter func(exportPath string, mirror *objectstore.Mirror) (feed.Exporter, error)) error {
 	exportPath := s.cfg.Export.Path
 
 	err := os.MkdirAll(exportPath, os.ModePerm)
@@ -275,17 +448,22 @@ func (s *SafetyCultureExporter) RunCSV() error {
 		}
 	}
 
-	e, err := feed.NewCSVExporter(exportPath, s.cfg.Export.MediaPath, s.cfg.Csv.MaxRowsPerFile)
+	mirror, err := s.openMirror(ctx)
 	if err != nil {
-		return errors.Wrap(err, "unable to create csv exporter")
+		return err
 	}
 
-	exporterApp := feed.NewExporterApp(s.apiClient, s.sheqsyApiClient, s.cfg.ToExporterConfig())
+	e, err := newExporter(exportPath, mirror)
+	if err != nil {
+		return err
+	}
+
+	exporterApp := s.newFeedExporterApp()
 	if s.cfg.Export.SchemaOnly {
 		return exporterApp.ExportSchemas(e)
 	}
 
-	if len(s.cfg.AccessToken) != 0 || len(s.cfg.SheqsyUsername) != 0 {
+	if s.hasFeedCredentials() {
 		err = exporterApp.ExportFeeds(e, ctx)
 		if err != nil {
 			return errors.Wrap(err, "exporting feeds")
@@ -296,7 +474,16 @@ func (s *SafetyCultureExporter) RunCSV() error {
 }
 
 func (s *SafetyCultureExporter) RunInspectionReports() error {
-	ctx, cancelFunc = context.WithCancel(context.Background())
+	return s.runInspectionReports(exportContext())
+}
+
+// runInspectionReports generates the reports of the inspections until ctx is done
+func (s *SafetyCultureExporter) runInspectionReports(ctx context.Context) error {
+	// the inspections are downloaded from the API when no source is set
+	if s.cfg.Report.Source != "" && s.cfg.Report.Source != "api" && s.cfg.Report.Source != "sql" {
+		return fmt.Errorf("invalid report source %q, expected %q or %q", s.cfg.Report.Source, "api", "sql")
+	}
+
 	err := os.MkdirAll(s.cfg.Export.Path, os.ModePerm)
 	if err != nil {
 		return errors.Wrapf(err, "Failed to create directory %s", s.cfg.Export.Path)
@@ -306,6 +493,10 @@ func (s *SafetyCultureExporter) RunInspectionReports() error {
 	if err != nil {
 		return errors.Wrap(err, "unable to create report exporter")
 	}
+	e.Mirror, err = s.openMirror(ctx)
+	if err != nil {
+		return err
+	}
 
 	// Create a dedicated client with a longer timeout for report downloads,
 	// as Word report generation and download can exceed the default 120s timeout.
@@ -316,6 +507,21 @@ func (s *SafetyCultureExporter) RunInspectionReports() error {
 	reportClient.HTTPClient().Timeout = 10 * time.Minute
 	e.ReportClient = reportClient
 
+	// the inspections are read from the SQL database they were exported to rather than the API
+	if s.cfg.Report.Source == "sql" {
+		e.Source, err = feed.NewSQLExporter(s.cfg.Db.Dialect, s.cfg.Db.ConnectionString, false, "")
+		if err != nil {
+			return errors.Wrap(err, "unable to connect to the SQL database")
+		}
+
+		// the daemon generates reports repeatedly, the connections are released once they are generated
+		sourceDB, err := e.Source.DB.DB()
+		if err != nil {
+			return errors.Wrap(err, "unable to connect to the SQL database")
+		}
+		defer sourceDB.Close()
+	}
+
 	exporterApp := feed.NewExporterApp(s.apiClient, s.sheqsyApiClient, s.cfg.ToExporterConfig())
 	err = exporterApp.ExportInspectionReports(e, ctx)
 	if err != nil {
@@ -325,14 +531,54 @@ func (s *SafetyCultureExporter) RunInspectionReports() error {
 	return nil
 }
 
+// ListReportStatuses returns the state of the reports of the inspections exported by the previous report exports
+func (s *SafetyCultureExporter) ListReportStatuses() ([]ReportStatusResponseItem, error) {
+	dbPath := filepath.Join(s.cfg.Export.Path, "reports.db")
+	if _, err := os.Stat(dbPath); err != nil {
+		return nil, errors.Wrapf(err, "no report export found in %s", s.cfg.Export.Path)
+	}
+
+	e, err := NewReportExporter(s.cfg.Export.Path, s.cfg.ToReporterConfig())
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to create report exporter")
+	}
+
+	statuses, err := e.ListReportStatuses()
+	if err != nil {
+		return nil, errors.Wrap(err, "list report statuses")
+	}
+
+	transformer := func(data feed.ReportStatus) ReportStatusResponseItem {
+		return ReportStatusResponseItem{
+			AuditID:       data.AuditID,
+			Name:          data.Name,
+			Status:        string(data.State),
+			Attempts:      data.Attempts,
+			LastAttemptAt: data.LastAttemptAt,
+			Paths:         data.Paths,
+			Errors:        data.Errors,
+		}
+	}
+	return util.GenericCollectionMapper(statuses, transformer), nil
+}
+
 func (s *SafetyCultureExporter) RunPrintSchema() error {
-	e, err := feed.NewSchemaExporter(os.Stdout)
+	return s.WriteSchemas(os.Stdout, string(feed.SchemaFormatTable), "")
+}
+
+// WriteSchemas writes the schemas of the tables in the format: table, ddl, jsonschema or dbt.
+// The DDL statements and the dbt data types are written for the dialect, the configured database dialect by default
+func (s *SafetyCultureExporter) WriteSchemas(w io.Writer, format string, dialect string) error {
+	schemaFormat, err := feed.ParseSchemaFormat(format)
 	if err != nil {
-		return errors.Wrap(err, "unable to create exporter")
+		return err
+	}
+	if dialect == "" {
+		dialect = s.cfg.Db.Dialect
 	}
 
 	exporterApp := feed.NewExporterApp(s.apiClient, s.sheqsyApiClient, s.cfg.ToExporterConfig())
-	err = exporterApp.PrintSchemas(e)
+	err = exporterApp.WriteSchemas(w, schemaFormat, dialect)
 	if err != nil {
 		return errors.Wrap(err, "error while printing schema")
 	}
@@ -340,6 +586,165 @@ func (s *SafetyCultureExporter) RunPrintSchema() error {
 	return nil
 }
 
+// openExportRunStore opens the database holding the run history of an export format
+func (s *SafetyCultureExporter) openExportRunStore(format string) (feed.ExportRunStore, error) {
+	if format == "sql" {
+		return feed.NewSQLExporter(s.cfg.Db.Dialect, s.cfg.Db.ConnectionString, !s.cfg.Db.AutoMigrateDisabled, "")
+	}
+
+	var dbFile string
+	switch format {
+	case "sqlite":
+		dbFile = "sqlite_export.db"
+	case "csv":
+		dbFile = "sqlite.db"
+		if s.cfg.Csv.Streaming {
+			dbFile = "csv_index.db"
+		}
+	case "parquet", "jsonl":
+		dbFile = "sqlite.db"
+	default:
+		return nil, fmt.Errorf("unsupported export format %q", format)
+	}
+
+	dbPath := filepath.Join(s.cfg.Export.Path, dbFile)
+	if _, err := os.Stat(dbPath); err != nil {
+		return nil, errors.Wrapf(err, "no export history found in %s", s.cfg.Export.Path)
+	}
+	return feed.NewSQLExporter("sqlite", dbPath, true, "")
+}
+
+// ListExportRuns returns the most recent runs of an export format, limit <= 0 returns all of them
+func (s *SafetyCultureExporter) ListExportRuns(format string, limit int) ([]ExportRunResponseItem, error) {
+	store, err := s.openExportRunStore(format)
+	if err != nil {
+		return nil, errors.Wrap(err, "open export history")
+	}
+
+	runs, err := store.ListExportRuns(limit)
+	if err != nil {
+		return nil, errors.Wrap(err, "list export runs")
+	}
+
+	return util.GenericCollectionMapper(runs, toExportRunResponseItem), nil
+}
+
+// GetExportRun returns a run of an export format with the feeds it exported
+func (s *SafetyCultureExporter) GetExportRun(format string, id string) (*ExportRunResponse, error) {
+	store, err := s.openExportRunStore(format)
+	if err != nil {
+		return nil, errors.Wrap(err, "open export history")
+	}
+
+	run, feeds, err := store.GetExportRun(id)
+	if err != nil {
+		return nil, errors.Wrap(err, "get export run")
+	}
+	if run == nil {
+		return nil, fmt.Errorf("export run %q not found", id)
+	}
+
+	transformer := func(data feed.ExportRunFeed) ExportRunFeedResponseItem {
+		return ExportRunFeedResponseItem{
+			FeedName:    data.FeedName,
+			StartedAt:   data.StartedAt,
+			FinishedAt:  data.FinishedAt,
+			RowsWritten: data.RowsWritten,
+			Status:      string(data.Status),
+			Error:       data.Error,
+		}
+	}
+
+	return &ExportRunResponse{
+		ExportRunResponseItem: toExportRunResponseItem(*run),
+		Feeds:                 util.GenericCollectionMapper(feeds, transformer),
+	}, nil
+}
+
+func toExportRunResponseItem(data feed.ExportRun) ExportRunResponseItem {
+	var errs []string
+	if data.Errors != "" {
+		errs = strings.Split(data.Errors, "\n")
+	}
+
+	return ExportRunResponseItem{
+		ID:             data.ID,
+		StartedAt:      data.StartedAt,
+		FinishedAt:     data.FinishedAt,
+		ConfigHash:     data.ConfigHash,
+		UserID:         data.UserID,
+		UserName:       data.UserName,
+		OrganisationID: data.OrganisationID,
+		Status:         string(data.Status),
+		ErrorCount:     data.ErrorCount,
+		Errors:         errs,
+	}
+}
+
+// PlanSchemaMigration returns the DDL bringing the schema of the SQL database up to date, without running it
+func (s *SafetyCultureExporter) PlanSchemaMigration() (*SchemaMigrationPlanResponse, error) {
+	e, err := feed.NewSQLExporter(s.cfg.Db.Dialect, s.cfg.Db.ConnectionString, false, "")
+	if err != nil {
+		return nil, errors.Wrap(err, "create sql exporter")
+	}
+
+	exporterApp := feed.NewExporterApp(s.apiClient, s.sheqsyApiClient, s.cfg.ToExporterConfig())
+	plan, err := exporterApp.PlanSchemaMigration(e)
+	if err != nil {
+		return nil, errors.Wrap(err, "plan schema migration")
+	}
+	return toSchemaMigrationPlanResponse(plan), nil
+}
+
+// ApplySchemaMigration brings the schema of the SQL database up to date and records the migration
+func (s *SafetyCultureExporter) ApplySchemaMigration() (*SchemaMigrationPlanResponse, error) {
+	e, err := feed.NewSQLExporter(s.cfg.Db.Dialect, s.cfg.Db.ConnectionString, false, "")
+	if err != nil {
+		return nil, errors.Wrap(err, "create sql exporter")
+	}
+
+	exporterApp := feed.NewExporterApp(s.apiClient, s.sheqsyApiClient, s.cfg.ToExporterConfig())
+	plan, err := exporterApp.ApplySchemaMigration(e)
+	if err != nil {
+		return nil, errors.Wrap(err, "apply schema migration")
+	}
+	return toSchemaMigrationPlanResponse(plan), nil
+}
+
+// ListSchemaMigrations returns the schema migrations applied to the SQL database, the most recent first
+func (s *SafetyCultureExporter) ListSchemaMigrations() ([]SchemaMigrationResponseItem, error) {
+	e, err := feed.NewSQLExporter(s.cfg.Db.Dialect, s.cfg.Db.ConnectionString, false, "")
+	if err != nil {
+		return nil, errors.Wrap(err, "create sql exporter")
+	}
+
+	migrations, err := e.ListSchemaMigrations()
+	if err != nil {
+		return nil, errors.Wrap(err, "list schema migrations")
+	}
+
+	transformer := func(data feed.SchemaMigration) SchemaMigrationResponseItem {
+		return SchemaMigrationResponseItem{
+			Version:    data.Version,
+			AppliedAt:  data.AppliedAt,
+			Statements: data.Statements,
+		}
+	}
+	return util.GenericCollectionMapper(migrations, transformer), nil
+}
+
+func toSchemaMigrationPlanResponse(plan *feed.SchemaMigrationPlan) *SchemaMigrationPlanResponse {
+	return &SchemaMigrationPlanResponse{
+		Dialect:        plan.Dialect,
+		Version:        plan.Version,
+		CurrentVersion: plan.CurrentVersion,
+		UpToDate:       plan.UpToDate(),
+		Statements:     plan.Statements,
+		Destructive:    plan.Destructive,
+		Script:         plan.Script(),
+	}
+}
+
 func (s *SafetyCultureExporter) GetTemplateList() []TemplateResponseItem {
 	client := templates.NewTemplatesClient(s.apiClient)
 	res := client.GetTemplateList(context.Background(), 1000)
@@ -394,5 +799,10 @@ func (s *SafetyCultureExporter) CleanExportStatus() {
 }
 
 func (s *SafetyCultureExporter) CancelExport() {
-	cancelFunc()
+	cancelMu.Lock()
+	defer cancelMu.Unlock()
+
+	if cancelFunc != nil {
+		cancelFunc()
+	}
 }
@@ -0,0 +1,290 @@
+package api_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SafetyCulture/safetyculture-exporter/pkg/internal/feed"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const streamingUsersHeader = "user_id,organisation_id,email,firstname,lastname,active,last_seen_at,exported_at,seat_type,created_at"
+
+func readStreamingUsers(t *testing.T, exportPath string, fileName string) string {
+	content, err := os.ReadFile(filepath.Join(exportPath, fileName))
+	require.Nil(t, err)
+	return dateRegex.ReplaceAllLiteralString(strings.TrimSpace(string(content)), "--date--")
+}
+
+func TestStreamingCSVExporterWriteRows_should_write_rows_to_file_as_they_arrive(t *testing.T) {
+	exporter, err := getTemporaryStreamingCSVExporter(100)
+	require.Nil(t, err)
+
+	userFeed := &feed.UserFeed{}
+	err = exporter.InitFeed(userFeed, &feed.InitFeedOptions{
+		Truncate: false,
+	})
+	require.Nil(t, err)
+
+	err = exporter.WriteRows(userFeed, []feed.User{
+		{ID: "user_1", OrganisationID: "role_123", Email: "user.1@example.com", Firstname: "User 1", Lastname: "User 1"},
+	})
+	require.Nil(t, err)
+	err = exporter.WriteRows(userFeed, []*feed.User{
+		{ID: "user_2", OrganisationID: "role_123", Email: "user.2@example.com", Firstname: "User 2", Lastname: "User 2"},
+	})
+	require.Nil(t, err)
+
+	err = exporter.FinaliseExport(userFeed, &[]feed.User{})
+	require.Nil(t, err)
+
+	expected := streamingUsersHeader + `
+user_1,role_123,user.1@example.com,User 1,User 1,false,,--date--,,--date--
+user_2,role_123,user.2@example.com,User 2,User 2,false,,--date--,,--date--`
+	assert.Equal(t, expected, readStreamingUsers(t, exporter.ExportPath, "users.csv"))
+
+	// SQLite is only used for the index
+	var rowCount int64
+	resp := exporter.DB.Raw("SELECT COUNT(*) FROM sqlite_master WHERE type='table' AND name = 'users'").Scan(&rowCount)
+	require.Nil(t, resp.Error)
+	assert.EqualValues(t, 0, rowCount)
+}
+
+func TestStreamingCSVExporterFinaliseExport_should_keep_latest_version_of_upserted_rows(t *testing.T) {
+	exporter, err := getTemporaryStreamingCSVExporter(100)
+	require.Nil(t, err)
+
+	userFeed := &feed.UserFeed{}
+	err = exporter.InitFeed(userFeed, &feed.InitFeedOptions{
+		Truncate: false,
+	})
+	require.Nil(t, err)
+
+	err = exporter.WriteRows(userFeed, []feed.User{
+		{ID: "user_1", OrganisationID: "role_123", Firstname: "User 1"},
+		{ID: "user_2", OrganisationID: "role_123", Firstname: "User 2"},
+	})
+	require.Nil(t, err)
+	err = exporter.WriteRows(userFeed, []feed.User{
+		{ID: "user_1", OrganisationID: "role_123", Firstname: "User 1 updated"},
+		{ID: "user_3", OrganisationID: "role_123", Firstname: "User 3"},
+		{ID: "user_3", OrganisationID: "role_123", Firstname: "User 3 updated"},
+	})
+	require.Nil(t, err)
+
+	// only the rewritten keys are indexed, until the export is finalised
+	var indexed []string
+	require.Nil(t, exporter.DB.Table("csv_stream_index").Order("pk").Pluck("pk", &indexed).Error)
+	assert.Equal(t, []string{"user_1", "user_3"}, indexed)
+
+	err = exporter.FinaliseExport(userFeed, &[]feed.User{})
+	require.Nil(t, err)
+
+	expected := streamingUsersHeader + `
+user_2,role_123,,User 2,,false,,--date--,,--date--
+user_1,role_123,,User 1 updated,,false,,--date--,,--date--
+user_3,role_123,,User 3 updated,,false,,--date--,,--date--`
+	assert.Equal(t, expected, readStreamingUsers(t, exporter.ExportPath, "users.csv"))
+
+	require.Nil(t, exporter.DB.Table("csv_stream_index").Pluck("pk", &indexed).Error)
+	assert.Empty(t, indexed)
+}
+
+func TestStreamingCSVExporterFinaliseExport_should_apply_updates_and_deletes(t *testing.T) {
+	exporter, err := getTemporaryStreamingCSVExporter(100)
+	require.Nil(t, err)
+
+	userFeed := &feed.UserFeed{}
+	err = exporter.InitFeed(userFeed, &feed.InitFeedOptions{
+		Truncate: false,
+	})
+	require.Nil(t, err)
+
+	err = exporter.WriteRows(userFeed, []feed.User{
+		{ID: "user_1", OrganisationID: "role_123"},
+		{ID: "user_2", OrganisationID: "role_123"},
+		{ID: "user_3", OrganisationID: "role_456"},
+	})
+	require.Nil(t, err)
+
+	updated, err := exporter.UpdateRows(userFeed, []string{"user_1", "user_3", "user_4"}, map[string]interface{}{"active": true})
+	require.Nil(t, err)
+	assert.EqualValues(t, 2, updated)
+
+	err = exporter.DeleteRowsIfExist(userFeed, "organisation_id = ?", "role_123")
+	require.Nil(t, err)
+
+	// rows written after the delete are kept
+	err = exporter.WriteRows(userFeed, []feed.User{
+		{ID: "user_2", OrganisationID: "role_123"},
+	})
+	require.Nil(t, err)
+
+	err = exporter.FinaliseExport(userFeed, &[]feed.User{})
+	require.Nil(t, err)
+
+	expected := streamingUsersHeader + `
+user_3,role_456,,,,true,,--date--,,--date--
+user_2,role_123,,,,false,,--date--,,--date--`
+	assert.Equal(t, expected, readStreamingUsers(t, exporter.ExportPath, "users.csv"))
+}
+
+func TestStreamingCSVExporter_should_do_rollover_files(t *testing.T) {
+	exporter, err := getTemporaryStreamingCSVExporter(2)
+	require.Nil(t, err)
+
+	userFeed := &feed.UserFeed{}
+	err = exporter.InitFeed(userFeed, &feed.InitFeedOptions{
+		Truncate: false,
+	})
+	require.Nil(t, err)
+
+	err = exporter.WriteRows(userFeed, []feed.User{
+		{ID: "user_1", OrganisationID: "role_123"},
+		{ID: "user_2", OrganisationID: "role_123"},
+		{ID: "user_3", OrganisationID: "role_123"},
+		{ID: "user_4", OrganisationID: "role_123"},
+		{ID: "user_5", OrganisationID: "role_123"},
+	})
+	require.Nil(t, err)
+
+	err = exporter.FinaliseExport(userFeed, &[]feed.User{})
+	require.Nil(t, err)
+
+	files, err := filepath.Glob(filepath.Join(exporter.ExportPath, "users-*.csv"))
+	require.Nil(t, err)
+	require.Len(t, files, 2)
+
+	expected := streamingUsersHeader + `
+user_5,role_123,,,,false,,--date--,,--date--`
+	assert.Equal(t, expected, readStreamingUsers(t, exporter.ExportPath, "users.csv"))
+
+	first, err := os.Stat(files[0])
+	require.Nil(t, err)
+
+	// only the file holding the upserted row is rewritten
+	err = exporter.WriteRows(userFeed, []feed.User{
+		{ID: "user_3", OrganisationID: "role_123", Firstname: "User 3"},
+	})
+	require.Nil(t, err)
+
+	err = exporter.FinaliseExport(userFeed, &[]feed.User{})
+	require.Nil(t, err)
+
+	rewritten, err := filepath.Glob(filepath.Join(exporter.ExportPath, "users-*.csv"))
+	require.Nil(t, err)
+	assert.Equal(t, files, rewritten)
+
+	unchanged, err := os.Stat(files[0])
+	require.Nil(t, err)
+	assert.True(t, os.SameFile(first, unchanged))
+
+	expected = streamingUsersHeader + `
+user_4,role_123,,,,false,,--date--,,--date--`
+	assert.Equal(t, expected, readStreamingUsers(t, exporter.ExportPath, filepath.Base(files[1])))
+
+	expected = streamingUsersHeader + `
+user_5,role_123,,,,false,,--date--,,--date--
+user_3,role_123,,User 3,,false,,--date--,,--date--`
+	assert.Equal(t, expected, readStreamingUsers(t, exporter.ExportPath, "users.csv"))
+
+	// the rows of the current file keep being replaced after a compaction
+	err = exporter.WriteRows(userFeed, []feed.User{
+		{ID: "user_3", OrganisationID: "role_123", Firstname: "User 3 updated"},
+	})
+	require.Nil(t, err)
+
+	err = exporter.FinaliseExport(userFeed, &[]feed.User{})
+	require.Nil(t, err)
+
+	files, err = filepath.Glob(filepath.Join(exporter.ExportPath, "users-*.csv"))
+	require.Nil(t, err)
+	require.Len(t, files, 3)
+
+	expected = streamingUsersHeader + `
+user_5,role_123,,,,false,,--date--,,--date--`
+	assert.Equal(t, expected, readStreamingUsers(t, exporter.ExportPath, filepath.Base(files[2])))
+
+	expected = streamingUsersHeader + `
+user_3,role_123,,User 3 updated,,false,,--date--,,--date--`
+	assert.Equal(t, expected, readStreamingUsers(t, exporter.ExportPath, "users.csv"))
+}
+
+func TestStreamingCSVExporter_should_resume_incremental_exports(t *testing.T) {
+	exporter, err := getTemporaryStreamingCSVExporter(100)
+	require.Nil(t, err)
+
+	actionFeed := &feed.ActionFeed{}
+	err = exporter.InitFeed(actionFeed, &feed.InitFeedOptions{
+		Truncate: false,
+	})
+	require.Nil(t, err)
+
+	modifiedAt := time.Date(2023, 5, 1, 10, 0, 0, 0, time.UTC)
+	err = exporter.WriteRows(actionFeed, []feed.Action{
+		{ID: "action_1", OrganisationID: "role_123", ModifiedAt: modifiedAt.Add(-time.Hour)},
+		{ID: "action_2", OrganisationID: "role_123", ModifiedAt: modifiedAt},
+	})
+	require.Nil(t, err)
+
+	err = exporter.FinaliseExport(actionFeed, &[]feed.Action{})
+	require.Nil(t, err)
+
+	// a new exporter picks up where the previous one stopped
+	resumed, err := feed.NewStreamingCSVExporter(exporter.ExportPath, "", 100)
+	require.Nil(t, err)
+
+	lastModifiedAt, err := resumed.LastModifiedAt(actionFeed, time.Time{}, "role_123")
+	require.Nil(t, err)
+	assert.True(t, modifiedAt.Equal(lastModifiedAt))
+
+	err = resumed.InitFeed(actionFeed, &feed.InitFeedOptions{
+		Truncate: false,
+	})
+	require.Nil(t, err)
+
+	err = resumed.WriteRows(actionFeed, []feed.Action{
+		{ID: "action_1", OrganisationID: "role_123", Title: "updated", ModifiedAt: modifiedAt.Add(time.Hour)},
+	})
+	require.Nil(t, err)
+
+	err = resumed.FinaliseExport(actionFeed, &[]feed.Action{})
+	require.Nil(t, err)
+
+	content := readStreamingUsers(t, resumed.ExportPath, "actions.csv")
+	lines := strings.Split(content, "\n")
+	require.Len(t, lines, 3)
+	assert.True(t, strings.HasPrefix(lines[1], "action_2,"))
+	assert.True(t, strings.HasPrefix(lines[2], "action_1,updated,"))
+}
+
+func TestStreamingCSVExporterInitFeed_should_remove_files_if_truncate_is_true(t *testing.T) {
+	exporter, err := getTemporaryStreamingCSVExporter(100)
+	require.Nil(t, err)
+
+	userFeed := &feed.UserFeed{}
+	err = exporter.InitFeed(userFeed, &feed.InitFeedOptions{
+		Truncate: false,
+	})
+	require.Nil(t, err)
+
+	err = exporter.WriteRows(userFeed, []feed.User{
+		{ID: "user_1", OrganisationID: "role_123"},
+	})
+	require.Nil(t, err)
+
+	err = exporter.InitFeed(userFeed, &feed.InitFeedOptions{
+		Truncate: true,
+	})
+	require.Nil(t, err)
+
+	err = exporter.FinaliseExport(userFeed, &[]feed.User{})
+	require.Nil(t, err)
+
+	_, err = os.Stat(filepath.Join(exporter.ExportPath, "users.csv"))
+	assert.True(t, os.IsNotExist(err))
+}
@@ -0,0 +1,247 @@
+package api_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SafetyCulture/safetyculture-exporter/pkg/api"
+	"github.com/SafetyCulture/safetyculture-exporter/pkg/internal/feed"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJSONLExporterCreateSchema_should_write_empty_file(t *testing.T) {
+	exporter, err := getTemporaryJSONLExporter(100, false)
+	require.Nil(t, err)
+
+	err = exporter.CreateSchema(&feed.UserFeed{}, &[]feed.User{})
+	require.Nil(t, err)
+
+	content, err := os.ReadFile(filepath.Join(exporter.ExportPath, "users.jsonl"))
+	require.Nil(t, err)
+	assert.Empty(t, content)
+}
+
+func TestJSONLExporterFinaliseExport_should_write_rows_out_to_file(t *testing.T) {
+	exporter, err := getTemporaryJSONLExporter(100, false)
+	require.Nil(t, err)
+
+	userFeed := &feed.UserFeed{}
+	err = exporter.InitFeed(userFeed, &feed.InitFeedOptions{
+		Truncate: false,
+	})
+	require.Nil(t, err)
+
+	users := []feed.User{
+		{
+			ID:             "user_1",
+			OrganisationID: "role_123",
+			Email:          "user.1@example.com",
+			Firstname:      "User 1",
+			Lastname:       "User 1",
+		},
+		{
+			ID:             "user_2",
+			OrganisationID: "role_123",
+			Email:          "user.2@example.com",
+			Firstname:      "User 2",
+			Lastname:       "User 2",
+		},
+	}
+
+	err = exporter.WriteRows(userFeed, users)
+	require.Nil(t, err)
+
+	err = exporter.FinaliseExport(userFeed, &[]feed.User{})
+	require.Nil(t, err)
+
+	content, err := os.ReadFile(filepath.Join(exporter.ExportPath, "users.jsonl"))
+	require.Nil(t, err)
+
+	contentString := dateRegex.ReplaceAllLiteralString(strings.TrimSpace(string(content)), "--date--")
+
+	expected := `{"user_id":"user_1","organisation_id":"role_123","email":"user.1@example.com","firstname":"User 1","lastname":"User 1","active":false,"last_seen_at":null,"exported_at":"--date--","seat_type":"","created_at":"--date--"}
+{"user_id":"user_2","organisation_id":"role_123","email":"user.2@example.com","firstname":"User 2","lastname":"User 2","active":false,"last_seen_at":null,"exported_at":"--date--","seat_type":"","created_at":"--date--"}`
+	assert.Equal(t, expected, contentString)
+}
+
+func TestJSONLExporter_should_do_rollover_gzip_files(t *testing.T) {
+	exporter, err := getTemporaryJSONLExporter(1, true)
+	require.Nil(t, err)
+
+	userFeed := &feed.UserFeed{}
+	err = exporter.InitFeed(userFeed, &feed.InitFeedOptions{
+		Truncate: false,
+	})
+	require.Nil(t, err)
+
+	users := []feed.User{
+		{ID: "user_1", OrganisationID: "role_123"},
+		{ID: "user_2", OrganisationID: "role_123"},
+	}
+
+	err = exporter.WriteRows(userFeed, users)
+	require.Nil(t, err)
+
+	err = exporter.FinaliseExport(userFeed, &[]feed.User{})
+	require.Nil(t, err)
+
+	files, err := filepath.Glob(filepath.Join(exporter.ExportPath, "users*.jsonl.gz"))
+	require.Nil(t, err)
+	assert.Len(t, files, 2)
+
+	file, err := os.Open(filepath.Join(exporter.ExportPath, "users.jsonl.gz"))
+	require.Nil(t, err)
+	defer file.Close()
+
+	reader, err := gzip.NewReader(file)
+	require.Nil(t, err)
+
+	content, err := io.ReadAll(reader)
+	require.Nil(t, err)
+
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	require.Len(t, lines, 1)
+	assert.Contains(t, lines[0], `"user_id":"user_2"`)
+}
+
+func TestJSONLExporter_should_fill_every_file_up_to_max_rows(t *testing.T) {
+	exporter, err := getTemporaryJSONLExporter(2, false)
+	require.Nil(t, err)
+
+	userFeed := &feed.UserFeed{}
+	err = exporter.InitFeed(userFeed, &feed.InitFeedOptions{
+		Truncate: false,
+	})
+	require.Nil(t, err)
+
+	var users []feed.User
+	for i := 1; i <= 5; i++ {
+		users = append(users, feed.User{ID: fmt.Sprintf("user_%d", i), OrganisationID: "role_123"})
+	}
+	require.Nil(t, exporter.WriteRows(userFeed, users))
+	require.Nil(t, exporter.FinaliseExport(userFeed, &[]feed.User{}))
+
+	files, err := filepath.Glob(filepath.Join(exporter.ExportPath, "users*.jsonl"))
+	require.Nil(t, err)
+	sort.Strings(files)
+	require.Len(t, files, 3)
+
+	var lines []int
+	for _, f := range files {
+		content, err := os.ReadFile(f)
+		require.Nil(t, err)
+		lines = append(lines, len(strings.Split(strings.TrimSpace(string(content)), "\n")))
+	}
+	// the rollover files sort first, users.jsonl holds the last row
+	assert.Equal(t, []int{2, 2, 1}, lines)
+}
+
+func TestJSONLExporter_should_fail_if_path_is_wrong(t *testing.T) {
+	exporter, err := feed.NewJSONLExporter("/xyz", "", 1, false)
+	require.Nil(t, exporter)
+	require.NotNil(t, err)
+}
+
+func TestJSONLExporter_should_write_rows_matching_the_json_schema(t *testing.T) {
+	exporter, dir := getMockAPIExporter(t, func(cfg *api.ExporterConfiguration) {
+		cfg.Export.Media = false
+	})
+	require.NoError(t, exporter.RunJSONL())
+
+	var buf bytes.Buffer
+	require.NoError(t, exporter.WriteSchemas(&buf, string(feed.SchemaFormatJSONSchema), "sqlite"))
+	var schema struct {
+		Defs map[string]struct {
+			Required             []string                          `json:"required"`
+			Properties           map[string]map[string]interface{} `json:"properties"`
+			AdditionalProperties bool                              `json:"additionalProperties"`
+		} `json:"$defs"`
+	}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &schema))
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.jsonl"))
+	require.NoError(t, err)
+	require.NotEmpty(t, files)
+
+	validated := 0
+	for _, file := range files {
+		table := strings.TrimSuffix(filepath.Base(file), ".jsonl")
+		def, ok := schema.Defs[table]
+		require.True(t, ok, table)
+
+		content, err := os.ReadFile(file)
+		require.NoError(t, err)
+		for _, line := range strings.Split(strings.TrimSpace(string(content)), "\n") {
+			if line == "" {
+				continue
+			}
+
+			var row map[string]interface{}
+			require.NoError(t, json.Unmarshal([]byte(line), &row), table)
+			for _, key := range def.Required {
+				assert.Contains(t, row, key, table)
+			}
+			for key, value := range row {
+				property, ok := def.Properties[key]
+				if !assert.True(t, ok || def.AdditionalProperties, "%s has no property %s", table, key) {
+					continue
+				}
+				assert.True(t, matchesJSONSchemaType(property, value), "%s.%s: %v doesn't match %v", table, key, value, property)
+			}
+			validated++
+		}
+	}
+	assert.NotZero(t, validated)
+}
+
+// matchesJSONSchemaType returns true if a JSON value has one of the types of a JSON Schema property
+func matchesJSONSchemaType(property map[string]interface{}, value interface{}) bool {
+	var types []interface{}
+	switch t := property["type"].(type) {
+	case nil:
+		return true
+	case string:
+		types = []interface{}{t}
+	case []interface{}:
+		types = t
+	}
+
+	for _, t := range types {
+		switch v := value.(type) {
+		case nil:
+			if t == "null" {
+				return true
+			}
+		case string:
+			if t != "string" {
+				continue
+			}
+			if property["format"] == "date-time" {
+				if _, err := time.Parse(time.RFC3339Nano, v); err != nil {
+					continue
+				}
+			}
+			return true
+		case bool:
+			if t == "boolean" {
+				return true
+			}
+		case float64:
+			if t == "number" || (t == "integer" && v == math.Trunc(v)) {
+				return true
+			}
+		}
+	}
+	return false
+}
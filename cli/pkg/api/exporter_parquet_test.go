@@ -0,0 +1,176 @@
+package api_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/SafetyCulture/safetyculture-exporter/pkg/internal/feed"
+	"github.com/parquet-go/parquet-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParquetExporterCreateSchema_should_write_empty_file_with_schema(t *testing.T) {
+	exporter, err := getTemporaryParquetExporter(100)
+	require.Nil(t, err)
+
+	userFeed := &feed.UserFeed{}
+	err = exporter.CreateSchema(userFeed, &[]feed.User{})
+	require.Nil(t, err)
+
+	file, err := os.Open(filepath.Join(exporter.ExportPath, "users.parquet"))
+	require.Nil(t, err)
+	defer file.Close()
+
+	stat, err := file.Stat()
+	require.Nil(t, err)
+
+	pf, err := parquet.OpenFile(file, stat.Size())
+	require.Nil(t, err)
+	assert.EqualValues(t, 0, pf.NumRows())
+
+	var columns []string
+	for _, field := range pf.Schema().Fields() {
+		columns = append(columns, field.Name())
+	}
+	assert.ElementsMatch(t, []string{
+		"user_id", "organisation_id", "email", "firstname", "lastname", "active",
+		"last_seen_at", "exported_at", "seat_type", "created_at",
+	}, columns)
+}
+
+func TestParquetExporterCreateSchema_should_write_timestamps_in_microseconds(t *testing.T) {
+	exporter, err := getTemporaryParquetExporter(100)
+	require.Nil(t, err)
+
+	err = exporter.CreateSchema(&feed.UserFeed{}, &[]feed.User{})
+	require.Nil(t, err)
+
+	file, err := os.Open(filepath.Join(exporter.ExportPath, "users.parquet"))
+	require.Nil(t, err)
+	defer file.Close()
+
+	stat, err := file.Stat()
+	require.Nil(t, err)
+
+	pf, err := parquet.OpenFile(file, stat.Size())
+	require.Nil(t, err)
+
+	// last_seen_at is nullable, exported_at isn't
+	for _, name := range []string{"last_seen_at", "exported_at"} {
+		column, ok := pf.Schema().Lookup(name)
+		require.True(t, ok, name)
+
+		logicalType := column.Node.Type().LogicalType()
+		require.NotNil(t, logicalType, name)
+		require.NotNil(t, logicalType.Timestamp, name)
+		assert.NotNil(t, logicalType.Timestamp.Unit.Micros, name)
+	}
+	lastSeenAt, _ := pf.Schema().Lookup("last_seen_at")
+	assert.True(t, lastSeenAt.Node.Optional())
+}
+
+func TestParquetExporterFinaliseExport_should_write_rows_out_to_file(t *testing.T) {
+	exporter, err := getTemporaryParquetExporter(100)
+	require.Nil(t, err)
+
+	userFeed := &feed.UserFeed{}
+	err = exporter.InitFeed(userFeed, &feed.InitFeedOptions{
+		Truncate: false,
+	})
+	require.Nil(t, err)
+
+	lastSeenAt := time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)
+	users := []feed.User{
+		{
+			ID:             "user_1",
+			OrganisationID: "role_123",
+			Email:          "user.1@example.com",
+			Firstname:      "User 1",
+			Lastname:       "User 1",
+			LastSeenAt:     &lastSeenAt,
+		},
+		{
+			ID:             "user_2",
+			OrganisationID: "role_123",
+			Email:          "user.2@example.com",
+			Firstname:      "User 2",
+			Lastname:       "User 2",
+		},
+	}
+
+	err = exporter.WriteRows(userFeed, users)
+	require.Nil(t, err)
+
+	err = exporter.FinaliseExport(userFeed, &[]feed.User{})
+	require.Nil(t, err)
+
+	rows, err := parquet.ReadFile[parquetUser](filepath.Join(exporter.ExportPath, "users.parquet"))
+	require.Nil(t, err)
+	require.Len(t, rows, 2)
+
+	assert.Equal(t, "user_1", rows[0].ID)
+	assert.Equal(t, "user.1@example.com", rows[0].Email)
+	assert.True(t, lastSeenAt.Equal(rows[0].LastSeenAt))
+	assert.Equal(t, "user_2", rows[1].ID)
+	assert.True(t, rows[1].LastSeenAt.IsZero())
+
+	// the missing timestamps are written as nulls
+	file, err := os.Open(filepath.Join(exporter.ExportPath, "users.parquet"))
+	require.Nil(t, err)
+	defer file.Close()
+
+	reader := parquet.NewReader(file)
+	column, ok := reader.Schema().Lookup("last_seen_at")
+	require.True(t, ok)
+	values := make([]parquet.Row, 2)
+	n, _ := reader.ReadRows(values)
+	require.Equal(t, 2, n)
+	assert.False(t, values[0][column.ColumnIndex].IsNull())
+	assert.True(t, values[1][column.ColumnIndex].IsNull())
+}
+
+func TestParquetExporter_should_do_rollover_files(t *testing.T) {
+	exporter, err := getTemporaryParquetExporter(1)
+	require.Nil(t, err)
+
+	userFeed := &feed.UserFeed{}
+	err = exporter.InitFeed(userFeed, &feed.InitFeedOptions{
+		Truncate: false,
+	})
+	require.Nil(t, err)
+
+	users := []feed.User{
+		{ID: "user_1", OrganisationID: "role_123"},
+		{ID: "user_2", OrganisationID: "role_123"},
+	}
+
+	err = exporter.WriteRows(userFeed, users)
+	require.Nil(t, err)
+
+	err = exporter.FinaliseExport(userFeed, &[]feed.User{})
+	require.Nil(t, err)
+
+	files, err := filepath.Glob(filepath.Join(exporter.ExportPath, "users*.parquet"))
+	require.Nil(t, err)
+	assert.Len(t, files, 2)
+
+	rows, err := parquet.ReadFile[parquetUser](filepath.Join(exporter.ExportPath, "users.parquet"))
+	require.Nil(t, err)
+	require.Len(t, rows, 1)
+	assert.Equal(t, "user_2", rows[0].ID)
+}
+
+func TestParquetExporter_should_fail_if_path_is_wrong(t *testing.T) {
+	exporter, err := feed.NewParquetExporter("/xyz", "", 1)
+	require.Nil(t, exporter)
+	require.NotNil(t, err)
+}
+
+type parquetUser struct {
+	ID         string    `parquet:"user_id"`
+	Email      string    `parquet:"email"`
+	LastSeenAt time.Time `parquet:"last_seen_at,optional,timestamp(microsecond)"`
+}
@@ -164,7 +164,7 @@ func TestExportReports_should_not_run_if_all_exported(t *testing.T) {
 
 	gock.New("http://localhost:9999").
 		Post("/accounts/history/v1/activity_log/list").
-		BodyString(`{"org_id":"","page_size":0,"page_token":"","filters":{"timeframe":{"from":"2014-03-17T00:35:40Z"},"event_types":["inspection.deleted"],"limit":0}}`).
+		BodyString(`{"org_id":"","page_size":100,"page_token":"","filters":{"timeframe":{"from":"2014-03-17T00:35:40Z"},"event_types":["inspection.deleted"],"limit":100}}`).
 		Reply(http.StatusOK).
 		BodyString(`{"activites": []}`)
 
@@ -258,7 +258,7 @@ func TestExportReports_should_take_care_of_invalid_file_names(t *testing.T) {
 
 	gock.New("http://localhost:9999").
 		Post("/accounts/history/v1/activity_log/list").
-		BodyString(`{"org_id":"","page_size":0,"page_token":"","filters":{"timeframe":{"from":"0001-01-01T00:00:00Z"},"event_types":["inspection.deleted"],"limit":0}}`).
+		BodyString(`{"org_id":"","page_size":100,"page_token":"","filters":{"timeframe":{"from":"0001-01-01T00:00:00Z"},"event_types":["inspection.deleted"],"limit":100}}`).
 		Reply(http.StatusOK).
 		BodyString(`{"activites": []}`)
 
@@ -270,7 +270,7 @@ func TestExportReports_should_take_care_of_invalid_file_names(t *testing.T) {
 	assert.NoError(t, err)
 
 	fileExists(t, filepath.Join(exporter.ExportPath, "My-Audit-1.pdf"))
-	fileExists(t, filepath.Join(exporter.ExportPath, "My-Audit-1 (1).pdf"))
+	fileExists(t, filepath.Join(exporter.ExportPath, "My-Audit-1 (audit_2).pdf"))
 	var files []string
 	filepath.Walk(exporter.ExportPath, func(path string, info os.FileInfo, err error) error {
 		files = append(files, path)
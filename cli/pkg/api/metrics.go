@@ -0,0 +1,46 @@
+package api
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/SafetyCulture/safetyculture-exporter/pkg/internal/metrics"
+	"github.com/SafetyCulture/safetyculture-exporter/pkg/logger"
+	"github.com/pkg/errors"
+)
+
+// StartMetricsServer serves the Prometheus metrics on /metrics when enabled in the configuration.
+// The returned func stops the server
+func (s *SafetyCultureExporter) StartMetricsServer() (func(), error) {
+	if !s.cfg.Metrics.Enabled {
+		return func() {}, nil
+	}
+
+	listener, err := net.Listen("tcp", s.cfg.Metrics.Address)
+	if err != nil {
+		return nil, errors.Wrapf(err, "listen on %s", s.cfg.Metrics.Address)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics.Handler())
+	server := &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	log := logger.GetLogger()
+	log.Infof("serving metrics on %s/metrics", listener.Addr().String())
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Errorf("metrics server: %v", err)
+		}
+	}()
+
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = server.Shutdown(ctx)
+	}, nil
+}
@@ -0,0 +1,53 @@
+package api_test
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/SafetyCulture/safetyculture-exporter/pkg/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSafetyCultureExporter_StartMetricsServer_should_serve_metrics(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := listener.Addr().String()
+	require.NoError(t, listener.Close())
+
+	cfg := api.ExporterConfiguration{}
+	cfg.Metrics.Enabled = true
+	cfg.Metrics.Address = addr
+
+	exporter, err := api.NewSafetyCultureExporter(&cfg, &api.AppVersion{})
+	require.NoError(t, err)
+
+	stop, err := exporter.StartMetricsServer()
+	require.NoError(t, err)
+	defer stop()
+
+	// use a dedicated transport, the default one may be intercepted by gock
+	client := &http.Client{Transport: &http.Transport{}}
+	resp, err := client.Get(fmt.Sprintf("http://%s/metrics", addr))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, string(body), "safetyculture_exporter_last_successful_run_timestamp_seconds")
+}
+
+func TestSafetyCultureExporter_StartMetricsServer_should_do_nothing_when_disabled(t *testing.T) {
+	cfg := api.ExporterConfiguration{}
+
+	exporter, err := api.NewSafetyCultureExporter(&cfg, &api.AppVersion{})
+	require.NoError(t, err)
+
+	stop, err := exporter.StartMetricsServer()
+	require.NoError(t, err)
+	stop()
+}
@@ -107,13 +107,13 @@ func initMockFeedsSet1(httpClient *http.Client) {
 
 	gock.New("http://localhost:9999").
 		Post("/accounts/history/v1/activity_log/list").
-		BodyString(`{"org_id":"","page_size":0,"page_token":"","filters":{"timeframe":{"from":"0001-01-01T00:00:00Z"},"event_types":["inspection.deleted"],"limit":0}}`).
+		BodyString(`{"org_id":"","page_size":100,"page_token":"","filters":{"timeframe":{"from":"0001-01-01T00:00:00Z"},"event_types":["inspection.deleted"],"limit":100}}`).
 		Reply(http.StatusOK).
 		File(path.Join("mocks", "set_1", "inspections_deleted_single_page.json"))
 
 	gock.New("http://localhost:9999").
 		Post("/accounts/history/v1/activity_log/list").
-		BodyString(`{"org_id":"","page_size":0,"page_token":"","filters":{"timeframe":{"from":"0001-01-01T00:00:00Z"},"event_types":["action.actions_deleted"],"limit":0}}`).
+		BodyString(`{"org_id":"","page_size":100,"page_token":"","filters":{"timeframe":{"from":"0001-01-01T00:00:00Z"},"event_types":["action.actions_deleted"],"limit":100}}`).
 		Reply(http.StatusOK).
 		File(path.Join("mocks", "set_1", "actions_deleted_single_page.json"))
 
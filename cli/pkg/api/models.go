@@ -29,3 +29,62 @@ type ExportStatusResponseItem struct {
 	HasError           bool   `json:"has_error"`
 	DurationMs         int64  `json:"duration_ms"`
 }
+
+// ExportRunResponseItem representation of a past export run
+type ExportRunResponseItem struct {
+	ID             string     `json:"id"`
+	StartedAt      time.Time  `json:"started_at"`
+	FinishedAt     *time.Time `json:"finished_at"`
+	ConfigHash     string     `json:"config_hash"`
+	UserID         string     `json:"user_id"`
+	UserName       string     `json:"user_name"`
+	OrganisationID string     `json:"organisation_id"`
+	Status         string     `json:"status"`
+	ErrorCount     int        `json:"error_count"`
+	Errors         []string   `json:"errors"`
+}
+
+// ExportRunFeedResponseItem representation of a feed exported during an export run
+type ExportRunFeedResponseItem struct {
+	FeedName    string     `json:"feed_name"`
+	StartedAt   time.Time  `json:"started_at"`
+	FinishedAt  *time.Time `json:"finished_at"`
+	RowsWritten int64      `json:"rows_written"`
+	Status      string     `json:"status"`
+	Error       string     `json:"error"`
+}
+
+// ExportRunResponse representation of a past export run with its feeds
+type ExportRunResponse struct {
+	ExportRunResponseItem
+	Feeds []ExportRunFeedResponseItem `json:"feeds"`
+}
+
+// ReportStatusResponseItem representation of the state of the reports of an inspection
+type ReportStatusResponseItem struct {
+	AuditID       string     `json:"audit_id"`
+	Name          string     `json:"name"`
+	Status        string     `json:"status"`
+	Attempts      int        `json:"attempts"`
+	LastAttemptAt *time.Time `json:"last_attempt_at"`
+	Paths         []string   `json:"paths"`
+	Errors        []string   `json:"errors"`
+}
+
+// SchemaMigrationPlanResponse representation of the DDL bringing the schema of a database up to date
+type SchemaMigrationPlanResponse struct {
+	Dialect        string   `json:"dialect"`
+	Version        string   `json:"version"`
+	CurrentVersion string   `json:"current_version"`
+	UpToDate       bool     `json:"up_to_date"`
+	Statements     []string `json:"statements"`
+	Destructive    []string `json:"destructive"`
+	Script         string   `json:"script"`
+}
+
+// SchemaMigrationResponseItem representation of a schema migration applied to a database
+type SchemaMigrationResponseItem struct {
+	Version    string    `json:"version"`
+	AppliedAt  time.Time `json:"applied_at"`
+	Statements int       `json:"statements"`
+}
@@ -133,6 +133,36 @@ func getTemporaryCSVExporterWithMaxRowsLimit(maxRowsPerFile int) (*feed.CSVExpor
 	return feed.NewCSVExporter(dir, "", maxRowsPerFile)
 }
 
+// getTemporaryStreamingCSVExporter creates a StreamingCSVExporter that writes to a temp folder with row limit
+func getTemporaryStreamingCSVExporter(maxRowsPerFile int) (*feed.StreamingCSVExporter, error) {
+	dir, err := os.MkdirTemp("", "export")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	return feed.NewStreamingCSVExporter(dir, "", maxRowsPerFile)
+}
+
+// getTemporaryParquetExporter creates a ParquetExporter that writes to a temp folder with row limit
+func getTemporaryParquetExporter(maxRowsPerFile int) (*feed.ParquetExporter, error) {
+	dir, err := os.MkdirTemp("", "export")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	return feed.NewParquetExporter(dir, "", maxRowsPerFile)
+}
+
+// getTemporaryJSONLExporter creates a JSONLExporter that writes to a temp folder with row limit
+func getTemporaryJSONLExporter(maxRowsPerFile int, gzip bool) (*feed.JSONLExporter, error) {
+	dir, err := os.MkdirTemp("", "export")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	return feed.NewJSONLExporter(dir, "", maxRowsPerFile, gzip)
+}
+
 var dateRegex = regexp.MustCompile(`(?m)(-?(?:[1-9][0-9]*)?[0-9]{4})-(1[0-2]|0[1-9])-(3[01]|0[1-9]|[12][0-9])T(2[0-3]|[01][0-9]):([0-5][0-9]):([0-5][0-9])(\.[0-9]+)?(\+|Z)(2[0-3]|[01][0-9])?:?([0-5][0-9])?`)
 
 // getTestingSQLExporter creates a temporary DB on the target SQL Database
@@ -10,8 +10,11 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/SafetyCulture/safetyculture-exporter/pkg/internal/metrics"
 	"github.com/SafetyCulture/safetyculture-exporter/pkg/logger"
 	"github.com/dghubble/sling"
 	"github.com/pkg/errors"
@@ -34,12 +37,17 @@ type Client struct {
 	httpClient    *http.Client
 	httpTransport *http.Transport
 
-	Duration      time.Duration
+	// duration is the duration of the last request in nanoseconds, the feeds of an export share the client
+	duration      atomic.Int64
 	CheckForRetry CheckForRetry
 	backoff       Backoff
 	RetryMax      int
 	RetryWaitMin  time.Duration
 	RetryWaitMax  time.Duration
+
+	// throttledUntil holds the requests of every caller once the API is rate limiting the client
+	throttleMu     sync.Mutex
+	throttledUntil time.Time
 }
 
 type ClientCfg struct {
@@ -84,7 +92,6 @@ func NewClient(cfg *ClientCfg, opts ...Opt) *Client {
 		BaseURL:       cfg.Addr,
 		httpTransport: httpTransport,
 		sling:         s,
-		Duration:      0,
 		CheckForRetry: DefaultRetryPolicy,
 		backoff:       DefaultBackoff,
 		RetryMax:      defaultRetryMax,
@@ -173,6 +180,11 @@ func OptAddTLSCert(certPath string) Opt {
 	}
 }
 
+// Duration returns the duration of the last request made by the client
+func (a *Client) Duration() time.Duration {
+	return time.Duration(a.duration.Load())
+}
+
 func (a *Client) Do(ctx context.Context, doer HTTPDoer) (*http.Response, error) {
 	u := doer.URL()
 	iter := 0
@@ -181,17 +193,25 @@ func (a *Client) Do(ctx context.Context, doer HTTPDoer) (*http.Response, error)
 			return nil, ctx.Err()
 		}
 
+		if err := a.waitForThrottle(ctx); err != nil {
+			return nil, err
+		}
+
 		iter++
 		a.logger.Debugw("http request", "url", u)
 
 		start := time.Now()
 		resp, err := doer.Do()
-		a.Duration = time.Since(start)
+		duration := time.Since(start)
+		a.duration.Store(int64(duration))
 
 		status := ""
+		statusCode := 0
 		if resp != nil {
 			status = resp.Status
+			statusCode = resp.StatusCode
 		}
+		metrics.ObserveAPIRequest(u, statusCode, duration)
 
 		if err != nil {
 			a.logger.Errorw("http request error", "url", u, "status", status, "err", err)
@@ -230,16 +250,50 @@ func (a *Client) Do(ctx context.Context, doer HTTPDoer) (*http.Response, error)
 			return resp, err
 		}
 
+		wait := a.backoff(a.RetryWaitMin, a.RetryWaitMax, iter, resp)
+		if resp != nil && resp.StatusCode == http.StatusTooManyRequests {
+			a.throttle(wait)
+		}
+
 		remain := a.RetryMax - iter
 		if remain == 0 {
 			break
 		}
 
-		wait := a.backoff(a.RetryWaitMin, a.RetryWaitMax, iter, resp)
 		a.logger.Infof("retrying URL %s after %v", u, wait)
+		metrics.IncAPIRetry(u)
 
 		time.Sleep(wait)
 	}
 
 	return nil, fmt.Errorf("%s giving up after %d attempt(s)", u, iter+1)
 }
+
+// throttle holds the requests of every caller of the client for the wait duration, so that concurrent callers
+// back off together once the API starts rate limiting
+func (a *Client) throttle(wait time.Duration) {
+	a.throttleMu.Lock()
+	defer a.throttleMu.Unlock()
+
+	if until := time.Now().Add(wait); until.After(a.throttledUntil) {
+		a.throttledUntil = until
+	}
+}
+
+// waitForThrottle waits until the client is no longer rate limited
+func (a *Client) waitForThrottle(ctx context.Context) error {
+	a.throttleMu.Lock()
+	wait := time.Until(a.throttledUntil)
+	a.throttleMu.Unlock()
+
+	if wait <= 0 {
+		return nil
+	}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(wait):
+		return nil
+	}
+}
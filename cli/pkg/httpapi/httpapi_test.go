@@ -2,7 +2,10 @@ package httpapi_test
 
 import (
 	"context"
+	"net/http"
+	"net/http/httptest"
 	"net/url"
+	"sync"
 	"testing"
 	"time"
 
@@ -183,3 +186,46 @@ func TestClient_HeadersShouldMatch(t *testing.T) {
 	require.Nil(t, err)
 	require.NotNil(t, r)
 }
+
+func TestClient_Do_should_hold_concurrent_requests_when_rate_limited(t *testing.T) {
+	var limitedAt, otherAt time.Time
+	limited := make(chan struct{})
+	var mu sync.Mutex
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		switch {
+		case r.URL.Path == "/limited" && limitedAt.IsZero():
+			limitedAt = time.Now()
+			w.WriteHeader(http.StatusTooManyRequests)
+			close(limited)
+		case r.URL.Path == "/other":
+			otherAt = time.Now()
+		}
+	}))
+	defer srv.Close()
+
+	apiClient := httpapi.NewClient(&httpapi.ClientCfg{Addr: srv.URL})
+	apiClient.RetryWaitMin = 300 * time.Millisecond
+	apiClient.RetryWaitMax = 300 * time.Millisecond
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		resp, err := httpapi.ExecuteRawGet(context.Background(), apiClient, "/limited")
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	}()
+
+	// a request made while the client is rate limited waits for the backoff of the other
+	<-limited
+	time.Sleep(50 * time.Millisecond)
+	resp, err := httpapi.ExecuteRawGet(context.Background(), apiClient, "/other")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	wg.Wait()
+
+	assert.GreaterOrEqual(t, otherAt.Sub(limitedAt), 250*time.Millisecond)
+}
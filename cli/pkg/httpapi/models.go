@@ -48,6 +48,7 @@ type InspectionReportExportCompletionResponse struct {
 
 type activityResponse struct {
 	Type     string            `json:"type"`
+	EventAt  time.Time         `json:"event_at"`
 	Metadata map[string]string `json:"metadata"`
 }
 
@@ -2,6 +2,7 @@ package exporter
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"os"
@@ -9,6 +10,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/SafetyCulture/safetyculture-exporter/pkg/internal/objectstore"
 	"github.com/SafetyCulture/safetyculture-exporter/pkg/internal/util"
 )
 
@@ -21,12 +23,15 @@ const (
 type JSONExporter struct {
 	exportPath       string
 	lastModifiedFile *os.File
+	mirror           *objectstore.Mirror
 }
 
-// NewJSONExporter creates new instance of JSONExporter
-func NewJSONExporter(exportPath string) SafetyCultureJSONExporter {
+// NewJSONExporter creates new instance of JSONExporter. The inspection files are copied to the object storage of the
+// mirror, when not nil
+func NewJSONExporter(exportPath string, mirror *objectstore.Mirror) SafetyCultureJSONExporter {
 	return &JSONExporter{
 		exportPath: exportPath,
+		mirror:     mirror,
 	}
 }
 
@@ -87,8 +92,11 @@ func (e *JSONExporter) WriteRow(name string, row *json.RawMessage) {
 	exportFilePath := filepath.Join(e.exportPath, fmt.Sprintf("%s.json", name))
 	file, err := os.OpenFile(exportFilePath, os.O_RDWR|os.O_CREATE, 0666)
 	util.Check(err, "Failed to open file")
-	defer file.Close()
 
 	_, err = file.WriteAt(str, 0)
 	util.Check(err, "Failed to write inspection to a file")
+	util.Check(file.Close(), "Failed to close file")
+
+	err = e.mirror.Upload(context.Background(), exportFilePath)
+	util.Check(err, "Failed to upload inspection to object storage")
 }
@@ -26,7 +26,7 @@ func TestGetLastModifiedAt(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			jsonExporter := exporter.NewJSONExporter("")
+			jsonExporter := exporter.NewJSONExporter("", nil)
 			resp := jsonExporter.GetLastModifiedAt(time.Time{})
 			assert.Equal(t, tt.expected, resp)
 		})
@@ -92,11 +92,11 @@ func TestLastModifiedAtAfterRestart(t *testing.T) {
 	dir, err := os.MkdirTemp("", "export")
 	assert.NoError(t, err)
 
-	tmpExporter1 := exporter.NewJSONExporter(dir)
+	tmpExporter1 := exporter.NewJSONExporter(dir, nil)
 	now := time.Now()
 	tmpExporter1.SetLastModifiedAt(now)
 
-	tmpExporter2 := exporter.NewJSONExporter(dir)
+	tmpExporter2 := exporter.NewJSONExporter(dir, nil)
 	lastModified := tmpExporter2.GetLastModifiedAt(time.Time{})
 	assert.NotNil(t, lastModified)
 
@@ -120,11 +120,11 @@ func TestHandlesOverWrittingLongString(t *testing.T) {
 	_, err = lastModifiedFile.Write([]byte("a-really-long-string-abc-123-456-789-longer-than-a-time-stamp"))
 	assert.NoError(t, err)
 
-	tmpExporter1 := exporter.NewJSONExporter(dir)
+	tmpExporter1 := exporter.NewJSONExporter(dir, nil)
 	now := time.Now()
 	tmpExporter1.SetLastModifiedAt(now)
 
-	tmpExporter2 := exporter.NewJSONExporter(dir)
+	tmpExporter2 := exporter.NewJSONExporter(dir, nil)
 	lastModified := tmpExporter2.GetLastModifiedAt(time.Time{})
 	assert.NotNil(t, lastModified)
 
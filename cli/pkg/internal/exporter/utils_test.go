@@ -14,5 +14,5 @@ func getTemporaryJSONExporter() exporter.SafetyCultureJSONExporter {
 		log.Fatal(err)
 	}
 
-	return exporter.NewJSONExporter(dir)
+	return exporter.NewJSONExporter(dir, nil)
 }
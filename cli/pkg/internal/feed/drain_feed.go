@@ -14,6 +14,13 @@ func DrainFeed(ctx context.Context, apiClient *httpapi.Client, request *GetFeedR
 	var nextURL string
 	// Used to both ensure the fetchFn is called at least once
 	first := true
+
+	// Pick up from the last page drained by an interrupted export
+	checkpoint := exportCheckpointFrom(ctx)
+	if resumePage := checkpoint.resumePage(request.InitialURL); resumePage != "" {
+		nextURL = resumePage
+		first = false
+	}
 	for nextURL != "" || first {
 		first = false
 		execURL := request.InitialURL
@@ -34,6 +41,8 @@ func DrainFeed(ctx context.Context, apiClient *httpapi.Client, request *GetFeedR
 		if err != nil {
 			return events.NewEventError(err, events.ErrorSeverityError, events.ErrorSubSystemAPI, false)
 		}
+
+		checkpoint.savePage(nextURL)
 	}
 
 	return nil
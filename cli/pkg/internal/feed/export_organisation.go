@@ -0,0 +1,285 @@
+package feed
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/MickStanciu/go-fn/fn"
+	"github.com/SafetyCulture/safetyculture-exporter/pkg/httpapi"
+	"github.com/SafetyCulture/safetyculture-exporter/pkg/internal/events"
+	"github.com/SafetyCulture/safetyculture-exporter/pkg/logger"
+	gormschema "gorm.io/gorm/schema"
+)
+
+// Organisation is a SafetyCulture organisation exported with its own credentials
+type Organisation struct {
+	Name      string
+	APIClient *httpapi.Client
+	// MaxConcurrentGoRoutines limits the feeds of the organisation exported at the same time, the limit of the
+	// configuration is used when not set
+	MaxConcurrentGoRoutines int
+}
+
+// AddOrganisation adds an organisation to export. Once organisations are added, they are exported instead of the
+// organisation of the access token
+func (e *ExporterFeedClient) AddOrganisation(org Organisation) {
+	e.organisations = append(e.organisations, org)
+}
+
+// exportOrganisations exports the feeds of every organisation into the same destination. The organisations are
+// exported at the same time, each with its own concurrency limit so that a slow organisation doesn't hold the others.
+// Each organisation is recorded as its own export run
+func (e *ExporterFeedClient) exportOrganisations(ctx context.Context, exporter Exporter, tzExporter Exporter, staging *stagingExporter) {
+	// a single organisation owns the destination, its feeds are truncated and finalised as usual
+	var finaliser *organisationFinaliser
+	if len(e.organisations) > 1 {
+		finaliser = newOrganisationFinaliser(tzExporter, len(e.organisations))
+	}
+
+	var wg sync.WaitGroup
+	for _, org := range e.organisations {
+		wg.Add(1)
+		go func(org Organisation) {
+			defer wg.Done()
+			e.exportOrganisation(ctx, org, exporter, tzExporter, staging, finaliser)
+		}(org)
+	}
+	wg.Wait()
+}
+
+// exportOrganisation exports the feeds of an organisation, the errors are reported with the name of the organisation.
+// The feeds keep the watermarks of the organisation while exported, each organisation exports its own instances
+func (e *ExporterFeedClient) exportOrganisation(ctx context.Context, org Organisation, exporter Exporter, tzExporter Exporter, staging *stagingExporter, finaliser *organisationFinaliser) {
+	log := logger.GetLogger().With("org", org.Name)
+	feeds := e.selectedFeeds()
+
+	var errMu sync.Mutex
+	var errs []error
+	addError := func(err error) {
+		errMu.Lock()
+		errs = append(errs, err)
+		errMu.Unlock()
+		e.addError(fmt.Errorf("organisation %s: %w", org.Name, err))
+	}
+
+	run := newExportRunRecorder(exporter, e.configuration)
+	var orgErr error
+	defer func() {
+		errMu.Lock()
+		defer errMu.Unlock()
+		run.finish(orgErr, errs, ctx.Err() != nil)
+	}()
+
+	resp, err := httpapi.WhoAmI(ctx, org.APIClient)
+	if err != nil {
+		orgErr = fmt.Errorf("get details of the current user: %w", err)
+		addError(orgErr)
+		finaliser.skip(feeds)
+		return
+	}
+
+	run.setUser(resp)
+
+	log = log.With(
+		"user.id", resp.UserID,
+		"user.org_id", resp.OrganisationID,
+		"user.name", fmt.Sprintf("%s %s", resp.Firstname, resp.Lastname),
+	)
+	log.Infof("exporting data for user")
+
+	maxConcurrentRoutines := fn.GetOrElse(org.MaxConcurrentGoRoutines, e.configuration.MaxConcurrentGoRoutines, func(i int) bool {
+		return i > 0
+	})
+	maxConcurrentRoutines = fn.GetOrElse(maxConcurrentRoutines, maxConcurrentGoRoutines, func(i int) bool {
+		return i > 0
+	})
+
+	orgExporter := tzExporter
+	if finaliser != nil {
+		orgExporter = &organisationExporter{Exporter: tzExporter, orgID: resp.OrganisationID, finaliser: finaliser}
+	}
+	status := GetExporterStatus()
+
+	var wg sync.WaitGroup
+	semaphore := make(chan int, maxConcurrentRoutines)
+	for _, feed := range feeds {
+		semaphore <- 1
+		wg.Add(1)
+
+		go func(f Feed) {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+
+			select {
+			case <-ctx.Done():
+				log.Infof(" ... canceling export")
+				finaliser.skip([]Feed{f})
+				return
+			default:
+			}
+
+			log.Infof(" ... queueing %s\n", f.Name())
+			status.StartFeedExport(f.Name(), f.HasRemainingInformation())
+			feedExporter, finishFeed := run.trackFeed(f, orgExporter)
+			exportErr := e.exportFeed(ctx, f, org.APIClient, exporter, feedExporter, resp.OrganisationID)
+			finaliseErr := finaliser.done(f)
+			if exportErr == nil {
+				exportErr = finaliseErr
+			}
+			exportErr = staging.finishFeed(f, exportErr)
+			finishFeed(exportErr)
+
+			var curatedErr error
+			if exportErr != nil {
+				addError(exportErr)
+				if events.IsBlockingError(exportErr) {
+					log.Errorf("exporting feeds: %v", exportErr)
+					curatedErr = exportErr
+				}
+			}
+			status.FinishFeedExport(f.Name(), curatedErr)
+		}(feed)
+	}
+	wg.Wait()
+
+	if len(errs) != 0 {
+		orgErr = errs[0]
+	}
+}
+
+// organisationExporter writes the feeds of an organisation into a destination shared with other organisations.
+// Truncating a feed only deletes the rows of the organisation and the feeds are finalised once exported for every
+// organisation
+type organisationExporter struct {
+	Exporter
+	orgID     string
+	finaliser *organisationFinaliser
+}
+
+// InitFeed initialises the feed, the truncation is limited to the rows of the organisation. The rows of the tables
+// without an organisation_id column can't be told apart, these tables are truncated by the first organisation
+// initialising them, before any organisation writes to them
+func (e *organisationExporter) InitFeed(feed Feed, opts *InitFeedOptions) error {
+	if err := e.Exporter.InitFeed(feed, &InitFeedOptions{Truncate: false, Resume: opts.Resume}); err != nil {
+		return err
+	}
+
+	if !opts.Truncate {
+		return nil
+	}
+	if !hasOrganisationColumn(feed) {
+		return e.finaliser.truncate(feed, func() error {
+			return e.Exporter.DeleteRowsIfExist(feed, "1 = 1")
+		})
+	}
+	return e.Exporter.DeleteRowsIfExist(feed, "organisation_id = ?", e.orgID)
+}
+
+// FinaliseExport defers the finalisation of the feed until it is exported for every organisation
+func (e *organisationExporter) FinaliseExport(feed Feed, rows interface{}) error {
+	e.finaliser.prepare(feed, rows)
+	return nil
+}
+
+// hasOrganisationColumn returns whether the table of the feed has an organisation_id column
+func hasOrganisationColumn(feed Feed) bool {
+	s, err := gormschema.Parse(feed.Model(), &sync.Map{}, gormschema.NamingStrategy{})
+	if err != nil {
+		return false
+	}
+	return s.LookUpField("organisation_id") != nil
+}
+
+// organisationFinaliser finalises the export of a feed once every organisation is done exporting it
+type organisationFinaliser struct {
+	exporter Exporter
+	orgs     int
+
+	mu        sync.Mutex
+	pending   map[string]int
+	rows      map[string]interface{}
+	truncated map[string]*feedTruncation
+}
+
+// feedTruncation is the truncation of the table of a feed shared by the organisations
+type feedTruncation struct {
+	once sync.Once
+	err  error
+}
+
+func newOrganisationFinaliser(exporter Exporter, orgs int) *organisationFinaliser {
+	return &organisationFinaliser{
+		exporter:  exporter,
+		orgs:      orgs,
+		pending:   map[string]int{},
+		rows:      map[string]interface{}{},
+		truncated: map[string]*feedTruncation{},
+	}
+}
+
+// truncate truncates the table of the feed once for every organisation, the organisations initialising the feed
+// meanwhile wait for the truncation to complete
+func (f *organisationFinaliser) truncate(feed Feed, truncate func() error) error {
+	f.mu.Lock()
+	t, ok := f.truncated[feed.Name()]
+	if !ok {
+		t = &feedTruncation{}
+		f.truncated[feed.Name()] = t
+	}
+	f.mu.Unlock()
+
+	t.once.Do(func() {
+		t.err = truncate()
+	})
+	return t.err
+}
+
+// prepare records the rows model the feed is finalised with
+func (f *organisationFinaliser) prepare(feed Feed, rows interface{}) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.rows[feed.Name()] = rows
+}
+
+// done records that an organisation exported the feed. The last organisation finalises the feed, if it was
+// prepared by any of them
+func (f *organisationFinaliser) done(feed Feed) error {
+	if f == nil {
+		return nil
+	}
+
+	f.mu.Lock()
+	if _, ok := f.pending[feed.Name()]; !ok {
+		f.pending[feed.Name()] = f.orgs
+	}
+	f.pending[feed.Name()]--
+	remaining := f.pending[feed.Name()]
+	rows, prepared := f.rows[feed.Name()]
+	f.mu.Unlock()
+
+	if remaining != 0 || !prepared {
+		return nil
+	}
+	if err := f.exporter.FinaliseExport(feed, rows); err != nil {
+		return fmt.Errorf("finalise export: %w", err)
+	}
+	return nil
+}
+
+// skip records that an organisation won't export the feeds
+func (f *organisationFinaliser) skip(feeds []Feed) {
+	if f == nil {
+		return
+	}
+
+	var errs []error
+	for _, feed := range feeds {
+		errs = append(errs, f.done(feed))
+	}
+	if err := errors.Join(errs...); err != nil {
+		logger.GetLogger().Warnf("unable to finalise the export: %v", err)
+	}
+}
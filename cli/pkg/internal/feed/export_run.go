@@ -0,0 +1,319 @@
+package feed
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/SafetyCulture/safetyculture-exporter/pkg/httpapi"
+	"github.com/SafetyCulture/safetyculture-exporter/pkg/internal/events"
+	"github.com/SafetyCulture/safetyculture-exporter/pkg/internal/metrics"
+	"github.com/SafetyCulture/safetyculture-exporter/pkg/logger"
+	"github.com/gofrs/uuid"
+	"gorm.io/gorm/clause"
+)
+
+// ExportRunStatus is the status of an export run
+type ExportRunStatus string
+
+const (
+	ExportRunRunning   ExportRunStatus = "RUNNING"
+	ExportRunSucceeded ExportRunStatus = "SUCCEEDED"
+	ExportRunFailed    ExportRunStatus = "FAILED"
+	ExportRunCancelled ExportRunStatus = "CANCELLED"
+)
+
+// ExportRun is the record of a past export
+type ExportRun struct {
+	ID             string          `json:"id" gorm:"primarykey;size:36"`
+	StartedAt      time.Time       `json:"started_at" gorm:"index"`
+	FinishedAt     *time.Time      `json:"finished_at"`
+	ConfigHash     string          `json:"config_hash" gorm:"size:64"`
+	UserID         string          `json:"user_id" gorm:"size:37"`
+	UserName       string          `json:"user_name" gorm:"size:256"`
+	OrganisationID string          `json:"organisation_id" gorm:"size:37"`
+	Status         ExportRunStatus `json:"status" gorm:"size:20"`
+	ErrorCount     int             `json:"error_count"`
+	Errors         string          `json:"errors"`
+}
+
+// TableName returns the name of the table holding the export runs
+func (ExportRun) TableName() string {
+	return "exporter_runs"
+}
+
+// ExportRunFeed is the record of a feed exported during an export run
+type ExportRunFeed struct {
+	RunID       string          `json:"run_id" gorm:"primarykey;size:36"`
+	FeedName    string          `json:"feed_name" gorm:"primarykey;size:128"`
+	StartedAt   time.Time       `json:"started_at"`
+	FinishedAt  *time.Time      `json:"finished_at"`
+	RowsWritten int64           `json:"rows_written"`
+	Status      ExportRunStatus `json:"status" gorm:"size:20"`
+	Error       string          `json:"error"`
+}
+
+// TableName returns the name of the table holding the feeds of the export runs
+func (ExportRunFeed) TableName() string {
+	return "exporter_run_feeds"
+}
+
+// ExportRunStore is implemented by the exporters able to keep the history of the export runs
+type ExportRunStore interface {
+	SaveExportRun(run *ExportRun) error
+	SaveExportRunFeed(runFeed *ExportRunFeed) error
+	ListExportRuns(limit int) ([]ExportRun, error)
+	GetExportRun(id string) (*ExportRun, []ExportRunFeed, error)
+}
+
+// SaveExportRun persists an export run
+func (e *SQLExporter) SaveExportRun(run *ExportRun) error {
+	if err := e.migrateExportRuns(); err != nil {
+		return err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	result := e.DB.Clauses(clause.OnConflict{UpdateAll: true}).Create(run)
+	if result.Error != nil {
+		return events.NewEventErrorWithMessage(result.Error, events.ErrorSeverityWarning, events.ErrorSubSystemDB, false, "unable to save export run")
+	}
+	return nil
+}
+
+// SaveExportRunFeed persists the record of a feed exported during an export run
+func (e *SQLExporter) SaveExportRunFeed(runFeed *ExportRunFeed) error {
+	if err := e.migrateExportRuns(); err != nil {
+		return err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	result := e.DB.Clauses(clause.OnConflict{UpdateAll: true}).Create(runFeed)
+	if result.Error != nil {
+		return events.NewEventErrorWithMessage(result.Error, events.ErrorSeverityWarning, events.ErrorSubSystemDB, false, "unable to save export run feed")
+	}
+	return nil
+}
+
+// ListExportRuns returns the most recent export runs first
+func (e *SQLExporter) ListExportRuns(limit int) ([]ExportRun, error) {
+	if err := e.migrateExportRuns(); err != nil {
+		return nil, err
+	}
+
+	var runs []ExportRun
+	query := e.DB.Order("started_at DESC")
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	if result := query.Find(&runs); result.Error != nil {
+		return nil, events.NewEventErrorWithMessage(result.Error, events.ErrorSeverityError, events.ErrorSubSystemDB, false, "unable to list export runs")
+	}
+	return runs, nil
+}
+
+// GetExportRun returns an export run with its feeds, nil if the run doesn't exist
+func (e *SQLExporter) GetExportRun(id string) (*ExportRun, []ExportRunFeed, error) {
+	if err := e.migrateExportRuns(); err != nil {
+		return nil, nil, err
+	}
+
+	var run ExportRun
+	result := e.DB.Where("id = ?", id).Limit(1).Find(&run)
+	if result.Error != nil {
+		return nil, nil, events.NewEventErrorWithMessage(result.Error, events.ErrorSeverityError, events.ErrorSubSystemDB, false, "unable to read export run")
+	}
+	if result.RowsAffected == 0 {
+		return nil, nil, nil
+	}
+
+	var feeds []ExportRunFeed
+	result = e.DB.Where("run_id = ?", id).Order("feed_name").Find(&feeds)
+	if result.Error != nil {
+		return nil, nil, events.NewEventErrorWithMessage(result.Error, events.ErrorSeverityError, events.ErrorSubSystemDB, false, "unable to read export run feeds")
+	}
+
+	return &run, feeds, nil
+}
+
+func (e *SQLExporter) migrateExportRuns() error {
+	e.runsOnce.Do(func() {
+		if e.AutoMigrate {
+			e.runsErr = e.DB.AutoMigrate(&ExportRun{}, &ExportRunFeed{})
+		}
+	})
+
+	if e.runsErr != nil {
+		return events.NewEventErrorWithMessage(e.runsErr, events.ErrorSeverityWarning, events.ErrorSubSystemDB, false, "unable to create export run tables")
+	}
+	return nil
+}
+
+// exportRunRecorder writes the history of an export run. Failing to record the run doesn't stop the export
+type exportRunRecorder struct {
+	mu    sync.Mutex
+	store ExportRunStore
+	run   ExportRun
+	feeds map[string]*ExportRunFeed
+}
+
+// newExportRunRecorder starts recording a run, nil is returned when the exporter doesn't keep the history
+func newExportRunRecorder(exporter Exporter, cfg *ExporterFeedCfg) *exportRunRecorder {
+	store, ok := exporter.(ExportRunStore)
+	if !ok {
+		return nil
+	}
+
+	r := &exportRunRecorder{
+		store: store,
+		run: ExportRun{
+			ID:         uuid.Must(uuid.NewV4()).String(),
+			StartedAt:  time.Now().UTC(),
+			ConfigHash: configHash(cfg),
+			Status:     ExportRunRunning,
+		},
+		feeds: map[string]*ExportRunFeed{},
+	}
+	r.save()
+	return r
+}
+
+// configHash identifies the configuration of a run, credentials are left out
+func configHash(cfg *ExporterFeedCfg) string {
+	c := *cfg
+	c.AccessToken = ""
+	c.SheqsyUsername = ""
+
+	data, err := json.Marshal(c)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func (r *exportRunRecorder) setUser(user *httpapi.WhoAmIResponse) {
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.run.UserID = user.UserID
+	r.run.UserName = strings.TrimSpace(fmt.Sprintf("%s %s", user.Firstname, user.Lastname))
+	r.run.OrganisationID = user.OrganisationID
+	r.save()
+}
+
+// trackFeed returns an exporter counting the rows written for the feed
+func (r *exportRunRecorder) trackFeed(feed Feed, exporter Exporter) (Exporter, func(err error)) {
+	if r == nil {
+		return &rowCountingExporter{Exporter: exporter}, func(error) {}
+	}
+
+	runFeed := &ExportRunFeed{
+		RunID:     r.run.ID,
+		FeedName:  feed.Name(),
+		StartedAt: time.Now().UTC(),
+		Status:    ExportRunRunning,
+	}
+	r.mu.Lock()
+	r.feeds[feed.Name()] = runFeed
+	r.mu.Unlock()
+	r.saveFeed(runFeed)
+
+	counter := &rowCountingExporter{Exporter: exporter}
+	return counter, func(err error) {
+		finishedAt := time.Now().UTC()
+
+		r.mu.Lock()
+		runFeed.FinishedAt = &finishedAt
+		runFeed.RowsWritten = atomic.LoadInt64(&counter.rows)
+		runFeed.Status = ExportRunSucceeded
+		if err != nil {
+			runFeed.Status = ExportRunFailed
+			runFeed.Error = err.Error()
+		}
+		r.mu.Unlock()
+
+		r.saveFeed(runFeed)
+	}
+}
+
+// finish records the exit status of the run
+func (r *exportRunRecorder) finish(exportErr error, errs []error, cancelled bool) {
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	finishedAt := time.Now().UTC()
+	r.run.FinishedAt = &finishedAt
+
+	if exportErr != nil && len(errs) == 0 {
+		errs = []error{exportErr}
+	}
+	messages := make([]string, 0, len(errs))
+	for _, err := range errs {
+		messages = append(messages, err.Error())
+	}
+	r.run.ErrorCount = len(errs)
+	r.run.Errors = strings.Join(messages, "\n")
+
+	switch {
+	case cancelled:
+		r.run.Status = ExportRunCancelled
+	case exportErr != nil:
+		r.run.Status = ExportRunFailed
+	default:
+		r.run.Status = ExportRunSucceeded
+	}
+	r.save()
+}
+
+func (r *exportRunRecorder) save() {
+	if err := r.store.SaveExportRun(&r.run); err != nil {
+		logger.GetLogger().With("run_id", r.run.ID).Warnf("unable to save export run: %v", err)
+	}
+}
+
+func (r *exportRunRecorder) saveFeed(runFeed *ExportRunFeed) {
+	r.mu.Lock()
+	feed := *runFeed
+	r.mu.Unlock()
+
+	if err := r.store.SaveExportRunFeed(&feed); err != nil {
+		logger.GetLogger().With("run_id", r.run.ID, "feed", feed.FeedName).Warnf("unable to save export run feed: %v", err)
+	}
+}
+
+// rowCountingExporter counts the rows written by a feed
+type rowCountingExporter struct {
+	Exporter
+	rows int64
+}
+
+// WriteRows writes the rows and counts them once written
+func (e *rowCountingExporter) WriteRows(feed Feed, rows interface{}) error {
+	if err := e.Exporter.WriteRows(feed, rows); err != nil {
+		return err
+	}
+
+	if v := reflect.Indirect(reflect.ValueOf(rows)); v.Kind() == reflect.Slice {
+		atomic.AddInt64(&e.rows, int64(v.Len()))
+		metrics.AddRowsExported(feed.Name(), v.Len())
+	}
+	return nil
+}
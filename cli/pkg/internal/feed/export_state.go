@@ -0,0 +1,268 @@
+package feed
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/SafetyCulture/safetyculture-exporter/pkg/internal/events"
+	"github.com/SafetyCulture/safetyculture-exporter/pkg/logger"
+	"gorm.io/gorm/clause"
+)
+
+// ExportStateStatus is the status of the last export of a feed
+type ExportStateStatus string
+
+const (
+	ExportStateInProgress ExportStateStatus = "IN_PROGRESS"
+	ExportStateCompleted  ExportStateStatus = "COMPLETED"
+	ExportStateFailed     ExportStateStatus = "FAILED"
+)
+
+// ExportState is the progress of a feed export, persisted so an interrupted export can be resumed
+type ExportState struct {
+	FeedName       string `gorm:"primarykey;size:128"`
+	OrganisationID string `gorm:"primarykey;size:37"`
+	NextPage       string `gorm:"size:2048"`
+	BlockStart     *time.Time
+	// DeletedAfter is the time of the latest deletion read from the activity log for the feed
+	DeletedAfter *time.Time
+	Status       ExportStateStatus `gorm:"size:20"`
+	UpdatedAt    time.Time
+}
+
+// TableName returns the name of the table holding the export state
+func (ExportState) TableName() string {
+	return "exporter_state"
+}
+
+// ExportStateStore is implemented by the exporters able to persist the progress of the feeds
+type ExportStateStore interface {
+	GetExportState(feedName string, orgID string) (*ExportState, error)
+	SaveExportState(state *ExportState) error
+}
+
+// GetExportState returns the persisted state of a feed, nil if the feed has never been exported
+func (e *SQLExporter) GetExportState(feedName string, orgID string) (*ExportState, error) {
+	if err := e.migrateExportState(); err != nil {
+		return nil, err
+	}
+
+	var state ExportState
+	result := e.DB.
+		Where("feed_name = ? AND organisation_id = ?", feedName, orgID).
+		Limit(1).
+		Find(&state)
+	if result.Error != nil {
+		return nil, events.NewEventErrorWithMessage(result.Error, events.ErrorSeverityWarning, events.ErrorSubSystemDB, false, "unable to read export state")
+	}
+	if result.RowsAffected == 0 {
+		return nil, nil
+	}
+
+	return &state, nil
+}
+
+// SaveExportState persists the state of a feed
+func (e *SQLExporter) SaveExportState(state *ExportState) error {
+	if err := e.migrateExportState(); err != nil {
+		return err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	state.UpdatedAt = time.Now()
+	result := e.DB.Clauses(clause.OnConflict{UpdateAll: true}).Create(state)
+	if result.Error != nil {
+		return events.NewEventErrorWithMessage(result.Error, events.ErrorSeverityWarning, events.ErrorSubSystemDB, false, "unable to save export state")
+	}
+
+	return nil
+}
+
+func (e *SQLExporter) migrateExportState() error {
+	e.stateOnce.Do(func() {
+		if e.AutoMigrate {
+			e.stateErr = e.DB.AutoMigrate(&ExportState{})
+		}
+	})
+
+	if e.stateErr != nil {
+		return events.NewEventErrorWithMessage(e.stateErr, events.ErrorSeverityWarning, events.ErrorSubSystemDB, false, "unable to create export state table")
+	}
+	return nil
+}
+
+// exportCheckpoint tracks the progress of a feed while it is drained
+type exportCheckpoint struct {
+	mu       sync.Mutex
+	store    ExportStateStore
+	state    ExportState
+	resuming bool
+}
+
+type exportCheckpointKey struct{}
+
+// newExportCheckpoint loads the state of the feed. The persisted progress is only kept when resuming
+func newExportCheckpoint(store ExportStateStore, feedName string, orgID string, resume bool) (*exportCheckpoint, error) {
+	state, err := store.GetExportState(feedName, orgID)
+	if err != nil {
+		return nil, err
+	}
+
+	cp := &exportCheckpoint{
+		store: store,
+		state: ExportState{
+			FeedName:       feedName,
+			OrganisationID: orgID,
+		},
+	}
+
+	if state != nil {
+		cp.state.DeletedAfter = state.DeletedAfter
+	}
+	if resume && state != nil && state.Status != ExportStateCompleted {
+		cp.state.NextPage = state.NextPage
+		cp.state.BlockStart = state.BlockStart
+		cp.resuming = true
+	}
+
+	cp.setStatus(ExportStateInProgress)
+	return cp, nil
+}
+
+// withExportCheckpoint returns a context carrying the checkpoint of the feed being exported
+func withExportCheckpoint(ctx context.Context, cp *exportCheckpoint) context.Context {
+	return context.WithValue(ctx, exportCheckpointKey{}, cp)
+}
+
+func exportCheckpointFrom(ctx context.Context) *exportCheckpoint {
+	cp, _ := ctx.Value(exportCheckpointKey{}).(*exportCheckpoint)
+	return cp
+}
+
+// resumePage returns the page to resume the request from, it is only returned once
+func (cp *exportCheckpoint) resumePage(initialURL string) string {
+	if cp == nil {
+		return ""
+	}
+
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+
+	if !cp.resuming || cp.state.NextPage == "" || !strings.HasPrefix(cp.state.NextPage, initialURL) {
+		return ""
+	}
+
+	nextPage := cp.state.NextPage
+	cp.resuming = false
+	return nextPage
+}
+
+// resumeBlock returns the start of the time block to resume from, if any
+func (cp *exportCheckpoint) resumeBlock() *time.Time {
+	if cp == nil {
+		return nil
+	}
+
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+
+	if !cp.resuming {
+		return nil
+	}
+	return cp.state.BlockStart
+}
+
+// savePage persists the next page to drain. Failing to save the progress doesn't stop the export
+func (cp *exportCheckpoint) savePage(nextPage string) {
+	if cp == nil {
+		return
+	}
+
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+
+	cp.state.NextPage = nextPage
+	cp.save()
+}
+
+// saveBlock persists the time block being drained
+func (cp *exportCheckpoint) saveBlock(start time.Time) {
+	if cp == nil {
+		return
+	}
+
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+
+	// a new block starts from its first page
+	if cp.state.BlockStart == nil || !cp.state.BlockStart.Equal(start) {
+		cp.state.NextPage = ""
+	}
+	cp.state.BlockStart = &start
+	cp.save()
+}
+
+// deletedAfter returns the time to read the deletions of the activity log from, the latest deletion already read
+// when it is later than the given time
+func (cp *exportCheckpoint) deletedAfter(deletedAfter time.Time) time.Time {
+	if cp == nil {
+		return deletedAfter
+	}
+
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+
+	if cp.state.DeletedAfter != nil && cp.state.DeletedAfter.After(deletedAfter) {
+		return *cp.state.DeletedAfter
+	}
+	return deletedAfter
+}
+
+// saveDeletedAfter persists the time of the latest deletion read from the activity log
+func (cp *exportCheckpoint) saveDeletedAfter(deletedAt time.Time) {
+	if cp == nil {
+		return
+	}
+
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+
+	if cp.state.DeletedAfter != nil && !deletedAt.After(*cp.state.DeletedAfter) {
+		return
+	}
+	cp.state.DeletedAfter = &deletedAt
+	cp.save()
+}
+
+// setStatus persists the status of the feed, the progress is cleared once completed
+func (cp *exportCheckpoint) setStatus(status ExportStateStatus) {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+
+	cp.state.Status = status
+	if status == ExportStateCompleted {
+		cp.state.NextPage = ""
+		cp.state.BlockStart = nil
+	}
+	cp.save()
+}
+
+func (cp *exportCheckpoint) save() {
+	if err := cp.store.SaveExportState(&cp.state); err != nil {
+		logger.GetLogger().With("feed", cp.state.FeedName).Warnf("unable to save export progress: %v", err)
+	}
+}
+
+// resumingExporter doesn't truncate the feed it resumes, the rows exported before the interruption are kept
+type resumingExporter struct {
+	Exporter
+}
+
+// InitFeed initialises the feed without truncating it
+func (e *resumingExporter) InitFeed(feed Feed, opts *InitFeedOptions) error {
+	return e.Exporter.InitFeed(feed, &InitFeedOptions{Truncate: false, Resume: opts.Truncate})
+}
@@ -124,7 +124,7 @@ func (e *CSVExporter) FinaliseExport(feed Feed, rows interface{}) error {
 	}
 
 	logger.Info("CSV exported")
-	return nil
+	return e.syncFiles(e.ExportPath, fmt.Sprintf("%s.csv", feed.Name()), fmt.Sprintf("%s-*.csv", feed.Name()))
 }
 
 func (e *CSVExporter) createNewFile(feedName string) (*os.File, error) {
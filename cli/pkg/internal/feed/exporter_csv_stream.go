@@ -0,0 +1,1250 @@
+package feed
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/SafetyCulture/safetyculture-exporter/pkg/internal/events"
+	"github.com/gocarina/gocsv"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	gormschema "gorm.io/gorm/schema"
+)
+
+// csvStreamChunkSize is the number of rows processed at once when compacting the CSV files of a feed
+const csvStreamChunkSize = 1000
+
+const (
+	// csvStreamFilterBitsPerKey and csvStreamFilterHashes give the key filters a false positive rate of about 1%
+	csvStreamFilterBitsPerKey = 10
+	csvStreamFilterHashes     = 7
+	// csvStreamFilterMaxKeys caps the size of the key filter of a file, about 1.3MB
+	csvStreamFilterMaxKeys = 1 << 20
+)
+
+// StreamingCSVExporter is an interface to export data feeds to CSV files as the rows are drained,
+// without staging them through SQLite.
+//
+// Each CSV file of a feed keeps a bloom filter of the primary keys of its rows, of a fixed size. A row whose key may
+// already be in a file marks that file dirty and is recorded in the index until the export is finalised, when only
+// the dirty files are rewritten without the rows that were upserted, updated or deleted since.
+type StreamingCSVExporter struct {
+	*SQLExporter
+	ExportPath     string
+	MaxRowsPerFile int
+	Logger         *zap.SugaredLogger
+	duration       time.Duration
+
+	mu      sync.Mutex
+	streams map[string]*csvStream
+	schemas sync.Map
+}
+
+// csvStream is the state of a feed being streamed to CSV
+type csvStream struct {
+	state      csvStreamFeed
+	header     []string
+	columns    map[string]int
+	pkIdx      []int
+	watermarks map[csvWatermarkKey]time.Time
+	// files are the CSV files of the feed in the order they were written, the current file being the last one
+	files  []*csvStreamFile
+	file   *os.File
+	writer *csv.Writer
+}
+
+type csvWatermarkKey struct {
+	organisationID string
+	column         string
+}
+
+// key returns the dedupe key of a record, made of its primary key values
+func (s *csvStream) key(record []string) string {
+	values := make([]string, len(s.pkIdx))
+	for i, idx := range s.pkIdx {
+		values[i] = record[idx]
+	}
+	return strings.Join(values, "\x1f")
+}
+
+// current returns the file the rows are appended to, nil if it isn't created yet
+func (s *csvStream) current() *csvStreamFile {
+	if len(s.files) == 0 || s.files[len(s.files)-1].FileName != fmt.Sprintf("%s.csv", s.state.FeedName) {
+		return nil
+	}
+	return s.files[len(s.files)-1]
+}
+
+// markFiles marks dirty the files that may hold a row with the key, returning false if none does
+func (s *csvStream) markFiles(key string) bool {
+	found := false
+	for _, f := range s.files {
+		if keyFilterContains(f.Keys, key) {
+			f.Dirty = true
+			found = true
+		}
+	}
+	return found
+}
+
+// dirtyFileIDs returns the IDs of the files to rewrite when compacting
+func (s *csvStream) dirtyFileIDs() []uint {
+	var ids []uint
+	for _, f := range s.files {
+		if f.Dirty {
+			ids = append(ids, f.ID)
+		}
+	}
+	return ids
+}
+
+// csvStreamFeed tracks the rows written to the CSV files of a feed
+type csvStreamFeed struct {
+	FeedName string `gorm:"primarykey;size:128"`
+	// Rows is the number of rows written to the CSV files, the next row will get the sequence Rows+1
+	Rows int64
+	// FileRows is the number of rows in the current CSV file
+	FileRows int
+	// Dirty is true when deletes need to be applied to the CSV files
+	Dirty bool
+}
+
+func (csvStreamFeed) TableName() string {
+	return "csv_stream_feeds"
+}
+
+// csvStreamFile is a CSV file of a feed. Its rows have the sequences FirstSeq to FirstSeq+Rows-1
+type csvStreamFile struct {
+	ID       uint   `gorm:"primarykey;autoIncrement"`
+	FeedName string `gorm:"size:128;index"`
+	FileName string `gorm:"size:256"`
+	FirstSeq int64
+	Rows     int
+	// Keys is a bloom filter of the primary keys of the rows of the file
+	Keys []byte
+	// Dirty is true when some rows need to be compacted out of the file
+	Dirty bool
+}
+
+func (csvStreamFile) TableName() string {
+	return "csv_stream_files"
+}
+
+// csvStreamIndex holds the sequence of the latest row written for a primary key that may already be in a CSV file.
+// It only holds the keys written since the export was last finalised
+type csvStreamIndex struct {
+	FeedName string `gorm:"primarykey;size:128"`
+	PK       string `gorm:"primarykey;column:pk"`
+	// Seq is 0 when the key was only updated, the updates then apply to the row in the CSV files
+	Seq int64
+	// Updates is a JSON object of the columns to update when compacting
+	Updates string
+}
+
+func (csvStreamIndex) TableName() string {
+	return "csv_stream_index"
+}
+
+// csvStreamDelete is a delete to apply to the rows written before Seq when compacting
+type csvStreamDelete struct {
+	ID       uint   `gorm:"primarykey;autoIncrement"`
+	FeedName string `gorm:"size:128;index"`
+	Seq      int64
+	Query    string
+	Args     string
+}
+
+func (csvStreamDelete) TableName() string {
+	return "csv_stream_deletes"
+}
+
+// csvStreamWatermark is the latest value seen for a time column, used for incremental exports
+type csvStreamWatermark struct {
+	FeedName       string `gorm:"primarykey;size:128"`
+	OrganisationID string `gorm:"primarykey;size:37"`
+	ColumnName     string `gorm:"primarykey;size:128"`
+	Value          time.Time
+}
+
+func (csvStreamWatermark) TableName() string {
+	return "csv_stream_watermarks"
+}
+
+// csvRecorder is a gocsv.CSVWriter keeping the marshalled records in memory
+type csvRecorder struct {
+	records [][]string
+}
+
+func (r *csvRecorder) Write(row []string) error {
+	// gocsv reuses the same slice for every row
+	r.records = append(r.records, append([]string(nil), row...))
+	return nil
+}
+
+func (r *csvRecorder) Flush() {}
+
+func (r *csvRecorder) Error() error {
+	return nil
+}
+
+// CreateSchema generated schema for a feed in csv format
+func (e *StreamingCSVExporter) CreateSchema(feed Feed, rows interface{}) error {
+	logger := e.Logger.With(
+		"feed", feed.Name(),
+	)
+	logger.Info("writing out CSV schema file")
+
+	exportFilePath := filepath.Join(e.ExportPath, fmt.Sprintf("%s.csv", feed.Name()))
+	_, err := os.Stat(exportFilePath)
+
+	if os.IsNotExist(err) {
+		file, err := os.OpenFile(exportFilePath, os.O_RDWR|os.O_CREATE, 0666)
+		if err != nil {
+			return events.NewEventError(err, events.ErrorSeverityError, events.ErrorSubSystemFileOperations, false)
+		}
+		defer file.Close()
+
+		err = gocsv.Marshal(rows, file)
+		if err != nil {
+			return events.NewEventError(err, events.ErrorSeverityError, events.ErrorSubSystemDataIntegrity, false)
+		}
+		return nil
+	}
+
+	logger.Info("CSV file already exists, skipping")
+	return nil
+}
+
+// InitFeed prepares the CSV files of the feed, they are removed when truncating
+func (e *StreamingCSVExporter) InitFeed(feed Feed, opts *InitFeedOptions) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if opts.Truncate {
+		e.Logger.With(
+			"feed", feed.Name(),
+		).Info("truncating")
+
+		if err := e.resetStream(feed.Name()); err != nil {
+			return err
+		}
+	}
+
+	_, err := e.stream(feed)
+	return err
+}
+
+// WriteRows appends the rows to the CSV file of the feed
+func (e *StreamingCSVExporter) WriteRows(feed Feed, rows interface{}) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	start := time.Now()
+	defer func() {
+		e.duration = time.Since(start)
+	}()
+
+	s, err := e.stream(feed)
+	if err != nil {
+		return err
+	}
+
+	values := reflect.Indirect(reflect.ValueOf(rows))
+	if values.Len() == 0 {
+		return nil
+	}
+
+	sch, err := gormschema.Parse(feed.Model(), &e.schemas, e.DB.NamingStrategy)
+	if err != nil {
+		return events.NewEventError(err, events.ErrorSeverityError, events.ErrorSubSystemDataIntegrity, false)
+	}
+	setAutoTimes(sch, values)
+
+	rec := &csvRecorder{}
+	if err := gocsv.MarshalCSVWithoutHeaders(rows, rec); err != nil {
+		return events.NewEventError(err, events.ErrorSeverityError, events.ErrorSubSystemDataIntegrity, false)
+	}
+
+	rewritten, err := e.writeRecords(s, feed.Name(), rec.records)
+	if err != nil {
+		return err
+	}
+
+	entries := make([]csvStreamIndex, 0, len(rewritten))
+	for key, seq := range rewritten {
+		entries = append(entries, csvStreamIndex{FeedName: feed.Name(), PK: key, Seq: seq})
+	}
+
+	changedWatermarks := updateWatermarks(sch, s, values)
+
+	err = e.DB.Transaction(func(tx *gorm.DB) error {
+		if len(entries) > 0 {
+			resp := tx.Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "feed_name"}, {Name: "pk"}},
+				DoUpdates: clause.AssignmentColumns([]string{"seq", "updates"}),
+			}).CreateInBatches(entries, 1000)
+			if resp.Error != nil {
+				return resp.Error
+			}
+
+			if resp := tx.Model(&csvStreamFile{}).Where("id IN ?", s.dirtyFileIDs()).Update("dirty", true); resp.Error != nil {
+				return resp.Error
+			}
+		}
+
+		for _, key := range changedWatermarks {
+			resp := tx.Save(&csvStreamWatermark{
+				FeedName:       feed.Name(),
+				OrganisationID: key.organisationID,
+				ColumnName:     key.column,
+				Value:          s.watermarks[key],
+			})
+			if resp.Error != nil {
+				return resp.Error
+			}
+		}
+
+		return tx.Save(&s.state).Error
+	})
+	if err != nil {
+		return events.NewEventErrorWithMessage(err, events.ErrorSeverityError, events.ErrorSubSystemDB, false, "unable to update the CSV index")
+	}
+
+	return nil
+}
+
+// UpdateRows records the updates to apply to the rows when compacting. Works with single PKey, not with composed PKeys.
+// The rows are looked up in the key filters of the files, the count can include the odd row that was never written
+func (e *StreamingCSVExporter) UpdateRows(feed Feed, primaryKeys []string, element map[string]interface{}) (int64, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	s, err := e.stream(feed)
+	if err != nil {
+		return 0, err
+	}
+
+	var found []string
+	for _, pk := range primaryKeys {
+		if s.markFiles(pk) {
+			found = append(found, pk)
+		}
+	}
+	if len(found) == 0 {
+		return 0, nil
+	}
+
+	err = e.DB.Transaction(func(tx *gorm.DB) error {
+		for start := 0; start < len(found); start += csvStreamChunkSize {
+			end := start + csvStreamChunkSize
+			if end > len(found) {
+				end = len(found)
+			}
+
+			var existing []csvStreamIndex
+			resp := tx.Where("feed_name = ? AND pk IN ?", feed.Name(), found[start:end]).Find(&existing)
+			if resp.Error != nil {
+				return resp.Error
+			}
+			entries := map[string]csvStreamIndex{}
+			for _, entry := range existing {
+				entries[entry.PK] = entry
+			}
+
+			for _, pk := range found[start:end] {
+				entry, ok := entries[pk]
+				if !ok {
+					entry = csvStreamIndex{FeedName: feed.Name(), PK: pk}
+				}
+
+				updates := map[string]interface{}{}
+				if entry.Updates != "" {
+					if err := json.Unmarshal([]byte(entry.Updates), &updates); err != nil {
+						return err
+					}
+				}
+				for column, value := range element {
+					updates[column] = value
+				}
+
+				raw, err := json.Marshal(updates)
+				if err != nil {
+					return err
+				}
+				entry.Updates = string(raw)
+
+				resp = tx.Clauses(clause.OnConflict{
+					Columns:   []clause.Column{{Name: "feed_name"}, {Name: "pk"}},
+					DoUpdates: clause.AssignmentColumns([]string{"updates"}),
+				}).Create(&entry)
+				if resp.Error != nil {
+					return resp.Error
+				}
+			}
+		}
+
+		return tx.Model(&csvStreamFile{}).Where("id IN ?", s.dirtyFileIDs()).Update("dirty", true).Error
+	})
+	if err != nil {
+		return 0, events.NewEventErrorWithMessage(err, events.ErrorSeverityError, events.ErrorSubSystemDB, false, "unable to update rows")
+	}
+
+	return int64(len(found)), nil
+}
+
+// DeleteDeletedRows records a delete of the rows deleted in SafetyCulture, the time they were deleted at isn't kept
+func (e *StreamingCSVExporter) DeleteDeletedRows(feed Feed, deletedAt time.Time, query string, args ...interface{}) error {
+	return e.DeleteRowsIfExist(feed, query, args...)
+}
+
+// DeleteRowsIfExist records a delete to apply to the rows already written when compacting
+func (e *StreamingCSVExporter) DeleteRowsIfExist(feed Feed, query string, args ...interface{}) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	s, err := e.stream(feed)
+	if err != nil {
+		return err
+	}
+
+	if s.state.Rows == 0 {
+		return nil
+	}
+
+	raw, err := json.Marshal(args)
+	if err != nil {
+		return events.NewEventErrorWithMessage(err, events.ErrorSeverityError, events.ErrorSubSystemDataIntegrity, false, "unable to delete rows")
+	}
+
+	err = e.DB.Transaction(func(tx *gorm.DB) error {
+		resp := tx.Create(&csvStreamDelete{
+			FeedName: feed.Name(),
+			Seq:      s.state.Rows + 1,
+			Query:    query,
+			Args:     string(raw),
+		})
+		if resp.Error != nil {
+			return resp.Error
+		}
+
+		s.state.Dirty = true
+		return tx.Save(&s.state).Error
+	})
+	if err != nil {
+		return events.NewEventErrorWithMessage(err, events.ErrorSeverityError, events.ErrorSubSystemDataIntegrity, false, "unable to delete rows")
+	}
+
+	return nil
+}
+
+// LastModifiedAt returns the latest stored modified at date for the feed
+func (e *StreamingCSVExporter) LastModifiedAt(feed Feed, modifiedAfter time.Time, orgID string) (time.Time, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	s, err := e.stream(feed)
+	if err != nil {
+		return modifiedAfter, err
+	}
+
+	latest, ok := s.watermarks[csvWatermarkKey{organisationID: orgID, column: "modified_at"}]
+	if !ok {
+		// This can happen when there is no org_id stored in the existing data.
+		latest, ok = s.watermarks[csvWatermarkKey{column: "modified_at"}]
+	}
+	if ok && modifiedAfter.Before(latest) {
+		return latest, nil
+	}
+
+	return modifiedAfter, nil
+}
+
+// LastRecord returns the latest stored record the feed
+func (e *StreamingCSVExporter) LastRecord(feed Feed, fallbackTime time.Time, orgID string, sortColumn string) time.Time {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	s, err := e.stream(feed)
+	if err != nil {
+		return fallbackTime
+	}
+
+	if latest, ok := s.watermarks[csvWatermarkKey{organisationID: orgID, column: sortColumn}]; ok {
+		return latest
+	}
+
+	return fallbackTime
+}
+
+// FinaliseExport closes the CSV file of the feed and compacts the files holding rows that were upserted, updated or deleted
+func (e *StreamingCSVExporter) FinaliseExport(feed Feed, _ interface{}) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	logger := e.Logger.With("feed", feed.Name())
+	status := GetExporterStatus()
+	status.UpdateStage(feed.Name(), StageCsv, false)
+
+	s, err := e.stream(feed)
+	if err != nil {
+		return err
+	}
+
+	if err := e.closeStream(s); err != nil {
+		return err
+	}
+	if current := s.current(); current != nil {
+		if resp := e.DB.Save(current); resp.Error != nil {
+			return events.NewEventError(resp.Error, events.ErrorSeverityError, events.ErrorSubSystemDB, false)
+		}
+	}
+
+	start := time.Now()
+	if s.state.Dirty || len(s.dirtyFileIDs()) > 0 {
+		logger.Info("compacting CSV files")
+		if err := e.compact(feed, s); err != nil {
+			return err
+		}
+	}
+
+	status.UpdateStatus(feed.Name(), s.state.Rows, time.Since(start).Milliseconds())
+	logger.With("rows", s.state.Rows).Info("CSV exported")
+	return e.syncFiles(e.ExportPath, fmt.Sprintf("%s.csv", feed.Name()), fmt.Sprintf("%s-*.csv", feed.Name()))
+}
+
+// GetDuration will return the duration for exporting a batch
+func (e *StreamingCSVExporter) GetDuration() time.Duration {
+	return e.duration
+}
+
+// stream returns the state of the feed, loading it from the index on first use
+func (e *StreamingCSVExporter) stream(feed Feed) (*csvStream, error) {
+	if s, ok := e.streams[feed.Name()]; ok {
+		return s, nil
+	}
+
+	rec := &csvRecorder{}
+	emptyRows := reflect.New(reflect.SliceOf(reflect.TypeOf(feed.Model()))).Interface()
+	if err := gocsv.MarshalCSV(emptyRows, rec); err != nil || len(rec.records) == 0 {
+		return nil, events.NewEventErrorWithMessage(err, events.ErrorSeverityError, events.ErrorSubSystemDataIntegrity, false, "unable to read CSV header")
+	}
+
+	s := &csvStream{
+		header:     rec.records[0],
+		columns:    map[string]int{},
+		watermarks: map[csvWatermarkKey]time.Time{},
+	}
+	for i, column := range s.header {
+		s.columns[column] = i
+	}
+	for _, pk := range feed.PrimaryKey() {
+		idx, ok := s.columns[pk]
+		if !ok {
+			return nil, events.NewEventErrorWithMessage(fmt.Errorf("primary key %s is not a CSV column", pk), events.ErrorSeverityError, events.ErrorSubSystemDataIntegrity, false, "unable to index CSV rows")
+		}
+		s.pkIdx = append(s.pkIdx, idx)
+	}
+
+	resp := e.DB.Where("feed_name = ?", feed.Name()).Limit(1).Find(&s.state)
+	if resp.Error != nil {
+		return nil, events.NewEventError(resp.Error, events.ErrorSeverityError, events.ErrorSubSystemDB, false)
+	}
+
+	if resp.RowsAffected == 0 {
+		// files left by a previous export are not indexed, start again from scratch
+		if err := e.files().cleanOldFiles(feed.Name()); err != nil {
+			return nil, err
+		}
+
+		s.state = csvStreamFeed{FeedName: feed.Name()}
+		if resp := e.DB.Create(&s.state); resp.Error != nil {
+			return nil, events.NewEventError(resp.Error, events.ErrorSeverityError, events.ErrorSubSystemDB, false)
+		}
+	}
+
+	resp = e.DB.Where("feed_name = ?", feed.Name()).Order("id").Find(&s.files)
+	if resp.Error != nil {
+		return nil, events.NewEventError(resp.Error, events.ErrorSeverityError, events.ErrorSubSystemDB, false)
+	}
+
+	// the key filter of the current file is saved when the export is finalised, it is read again from the file
+	// when a previous export stopped before that
+	if current := s.current(); current != nil && current.Rows != s.state.FileRows {
+		if err := e.indexFile(s, current); err != nil {
+			return nil, err
+		}
+	}
+
+	var watermarks []csvStreamWatermark
+	resp = e.DB.Where("feed_name = ?", feed.Name()).Find(&watermarks)
+	if resp.Error != nil {
+		return nil, events.NewEventError(resp.Error, events.ErrorSeverityError, events.ErrorSubSystemDB, false)
+	}
+	for _, w := range watermarks {
+		s.watermarks[csvWatermarkKey{organisationID: w.OrganisationID, column: w.ColumnName}] = w.Value
+	}
+
+	e.streams[feed.Name()] = s
+	return s, nil
+}
+
+// indexFile reads the rows of a CSV file to build its key filter
+func (e *StreamingCSVExporter) indexFile(s *csvStream, f *csvStreamFile) error {
+	f.Keys = newKeyFilter(e.MaxRowsPerFile)
+	f.Rows = 0
+	err := readCSVFile(filepath.Join(e.ExportPath, f.FileName), s, func(record []string) error {
+		keyFilterAdd(f.Keys, s.key(record))
+		f.Rows++
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if resp := e.DB.Save(f); resp.Error != nil {
+		return events.NewEventError(resp.Error, events.ErrorSeverityError, events.ErrorSubSystemDB, false)
+	}
+	return nil
+}
+
+// resetStream removes the CSV files of the feed along with its index
+func (e *StreamingCSVExporter) resetStream(feedName string) error {
+	if s, ok := e.streams[feedName]; ok {
+		if err := e.closeStream(s); err != nil {
+			return err
+		}
+		delete(e.streams, feedName)
+	}
+
+	err := e.DB.Transaction(func(tx *gorm.DB) error {
+		for _, model := range []interface{}{&csvStreamFeed{}, &csvStreamFile{}, &csvStreamIndex{}, &csvStreamDelete{}, &csvStreamWatermark{}} {
+			if resp := tx.Where("feed_name = ?", feedName).Delete(model); resp.Error != nil {
+				return resp.Error
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return events.NewEventError(err, events.ErrorSeverityError, events.ErrorSubSystemDB, true)
+	}
+
+	return e.files().cleanOldFiles(feedName)
+}
+
+// writeRecords appends the records to the current CSV file, rolling it over when reaching MaxRowsPerFile.
+// It returns the sequence of the records whose key may already be in a file, the files holding them are marked dirty
+func (e *StreamingCSVExporter) writeRecords(s *csvStream, feedName string, records [][]string) (map[string]int64, error) {
+	rewritten := map[string]int64{}
+	for _, record := range records {
+		if s.state.FileRows >= e.MaxRowsPerFile {
+			if err := e.closeStream(s); err != nil {
+				return nil, err
+			}
+			if err := e.createRolloverFile(s, feedName, time.Now()); err != nil {
+				return nil, err
+			}
+			s.state.FileRows = 0
+		}
+
+		if s.file == nil {
+			if err := e.openStream(s, feedName); err != nil {
+				return nil, err
+			}
+		}
+
+		key := s.key(record)
+		s.state.Rows++
+		if s.markFiles(key) {
+			rewritten[key] = s.state.Rows
+		}
+
+		if err := s.writer.Write(record); err != nil {
+			return nil, events.NewEventError(err, events.ErrorSeverityError, events.ErrorSubSystemFileOperations, false)
+		}
+
+		current := s.current()
+		keyFilterAdd(current.Keys, key)
+		current.Rows++
+		s.state.FileRows++
+	}
+	return rewritten, nil
+}
+
+// openStream opens the current CSV file of the feed for appending, writing the header if the file is new
+func (e *StreamingCSVExporter) openStream(s *csvStream, feedName string) error {
+	if s.current() == nil {
+		current := &csvStreamFile{
+			FeedName: feedName,
+			FileName: fmt.Sprintf("%s.csv", feedName),
+			FirstSeq: s.state.Rows + 1,
+			Keys:     newKeyFilter(e.MaxRowsPerFile),
+		}
+		if resp := e.DB.Create(current); resp.Error != nil {
+			return events.NewEventError(resp.Error, events.ErrorSeverityError, events.ErrorSubSystemDB, false)
+		}
+		s.files = append(s.files, current)
+	}
+
+	exportFilePath := filepath.Join(e.ExportPath, fmt.Sprintf("%s.csv", feedName))
+	file, err := os.OpenFile(exportFilePath, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0666)
+	if err != nil {
+		return events.NewEventError(err, events.ErrorSeverityError, events.ErrorSubSystemFileOperations, false)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return events.NewEventError(err, events.ErrorSeverityError, events.ErrorSubSystemFileOperations, false)
+	}
+
+	writer := csv.NewWriter(file)
+	if info.Size() == 0 {
+		if err := writer.Write(s.header); err != nil {
+			file.Close()
+			return events.NewEventError(err, events.ErrorSeverityError, events.ErrorSubSystemFileOperations, false)
+		}
+	}
+
+	s.file = file
+	s.writer = writer
+	return nil
+}
+
+func (e *StreamingCSVExporter) closeStream(s *csvStream) error {
+	if s.file == nil {
+		return nil
+	}
+
+	s.writer.Flush()
+	err := s.writer.Error()
+	if closeErr := s.file.Close(); err == nil {
+		err = closeErr
+	}
+	s.file, s.writer = nil, nil
+
+	if err != nil {
+		return events.NewEventError(err, events.ErrorSeverityError, events.ErrorSubSystemFileOperations, false)
+	}
+	return nil
+}
+
+// compact rewrites the dirty CSV files of the feed keeping only the latest version of each row, with the recorded
+// updates and deletes applied. Deletes are conditions on any column, every file is read when there are some
+func (e *StreamingCSVExporter) compact(feed Feed, s *csvStream) error {
+	var deletes []csvStreamDelete
+	if resp := e.DB.Where("feed_name = ?", feed.Name()).Order("seq").Find(&deletes); resp.Error != nil {
+		return events.NewEventError(resp.Error, events.ErrorSeverityError, events.ErrorSubSystemDB, false)
+	}
+
+	c := &csvCompaction{
+		exporter: e,
+		feedName: feed.Name(),
+		stream:   s,
+		deletes:  deletes,
+	}
+	if err := c.prepare(); err != nil {
+		return err
+	}
+	defer c.cleanup()
+
+	for _, f := range s.files {
+		if !f.Dirty && len(deletes) == 0 {
+			continue
+		}
+		if err := c.compactFile(f); err != nil {
+			return err
+		}
+		f.Dirty = false
+	}
+
+	// the rows appended to the current file carry on from the latest sequence
+	if current := s.current(); current != nil {
+		current.FirstSeq = s.state.Rows - int64(current.Rows) + 1
+		s.state.FileRows = current.Rows
+	}
+
+	err := e.DB.Transaction(func(tx *gorm.DB) error {
+		for _, f := range s.files {
+			if resp := tx.Save(f); resp.Error != nil {
+				return resp.Error
+			}
+		}
+		if resp := tx.Where("feed_name = ?", feed.Name()).Delete(&csvStreamIndex{}); resp.Error != nil {
+			return resp.Error
+		}
+		if resp := tx.Where("feed_name = ?", feed.Name()).Delete(&csvStreamDelete{}); resp.Error != nil {
+			return resp.Error
+		}
+
+		s.state.Dirty = false
+		return tx.Save(&s.state).Error
+	})
+	if err != nil {
+		return events.NewEventError(err, events.ErrorSeverityError, events.ErrorSubSystemDB, false)
+	}
+
+	return nil
+}
+
+func (e *StreamingCSVExporter) rolloverFilePath(feedName string, t time.Time) string {
+	// the fraction is zero padded so the rollover files sort in the order they were written
+	return filepath.Join(e.ExportPath, fmt.Sprintf("%s-%s.csv", feedName, t.Format("20060102150405.000000")))
+}
+
+// createRolloverFile renames the current file of the feed, saving its key filter
+func (e *StreamingCSVExporter) createRolloverFile(s *csvStream, feedName string, t time.Time) error {
+	exportFilePath := filepath.Join(e.ExportPath, fmt.Sprintf("%s.csv", feedName))
+	rolloverFilePath := e.rolloverFilePath(feedName, t)
+
+	err := os.Rename(exportFilePath, rolloverFilePath)
+	if err != nil {
+		return events.NewEventError(err, events.ErrorSeverityError, events.ErrorSubSystemFileOperations, false)
+	}
+
+	if current := s.current(); current != nil {
+		current.FileName = filepath.Base(rolloverFilePath)
+		if resp := e.DB.Save(current); resp.Error != nil {
+			return events.NewEventError(resp.Error, events.ErrorSeverityError, events.ErrorSubSystemDB, false)
+		}
+	}
+
+	return nil
+}
+
+// files returns the names of the CSV files of the feeds
+func (e *StreamingCSVExporter) files() feedFiles {
+	return feedFiles{exportPath: e.ExportPath, extension: "csv"}
+}
+
+// setAutoTimes fills the autoCreateTime and autoUpdateTime columns the same way gorm does on insert
+func setAutoTimes(sch *gormschema.Schema, values reflect.Value) {
+	ctx := context.Background()
+	now := time.Now()
+	for _, field := range sch.Fields {
+		if field.AutoCreateTime == 0 && field.AutoUpdateTime == 0 {
+			continue
+		}
+		if field.FieldType != reflect.TypeOf(time.Time{}) && field.FieldType != reflect.TypeOf(&time.Time{}) {
+			continue
+		}
+
+		for i := 0; i < values.Len(); i++ {
+			row := reflect.Indirect(values.Index(i))
+			if _, isZero := field.ValueOf(ctx, row); isZero {
+				_ = field.Set(ctx, row, now)
+			}
+		}
+	}
+}
+
+// updateWatermarks keeps track of the latest value of every time column, per organisation
+func updateWatermarks(sch *gormschema.Schema, s *csvStream, values reflect.Value) []csvWatermarkKey {
+	ctx := context.Background()
+	orgField := sch.LookUpField("organisation_id")
+
+	changed := map[csvWatermarkKey]bool{}
+	for i := 0; i < values.Len(); i++ {
+		row := reflect.Indirect(values.Index(i))
+
+		orgID := ""
+		if orgField != nil {
+			if v, isZero := orgField.ValueOf(ctx, row); !isZero {
+				orgID = fmt.Sprint(reflect.Indirect(reflect.ValueOf(v)).Interface())
+			}
+		}
+
+		for _, field := range sch.Fields {
+			v, isZero := field.ValueOf(ctx, row)
+			if isZero {
+				continue
+			}
+
+			var t time.Time
+			switch value := v.(type) {
+			case time.Time:
+				t = value
+			case *time.Time:
+				t = *value
+			default:
+				continue
+			}
+
+			key := csvWatermarkKey{organisationID: orgID, column: field.DBName}
+			if latest, ok := s.watermarks[key]; !ok || latest.Before(t) {
+				s.watermarks[key] = t
+				changed[key] = true
+			}
+		}
+	}
+
+	keys := make([]csvWatermarkKey, 0, len(changed))
+	for key := range changed {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// csvCompaction rewrites the CSV files of a feed keeping only the latest version of their rows
+type csvCompaction struct {
+	exporter *StreamingCSVExporter
+	feedName string
+	stream   *csvStream
+	deletes  []csvStreamDelete
+
+	// the file being compacted
+	out     *csv.Writer
+	keys    []byte
+	rows    int
+	changed bool
+	chunk   []csvCompactionRow
+}
+
+type csvCompactionRow struct {
+	seq    int64
+	key    string
+	record []string
+}
+
+const csvCompactionChunkTable = "csv_stream_chunk"
+
+func (c *csvCompaction) prepare() error {
+	if len(c.deletes) == 0 {
+		return nil
+	}
+	db := c.exporter.DB
+
+	// deletes are expressed as SQL conditions, they are evaluated against a table holding the current chunk
+	columns := []string{`"_seq" INTEGER`}
+	for _, column := range c.stream.header {
+		columns = append(columns, fmt.Sprintf(`"%s" TEXT`, column))
+	}
+	if resp := db.Exec(fmt.Sprintf("DROP TABLE IF EXISTS %s", csvCompactionChunkTable)); resp.Error != nil {
+		return events.NewEventError(resp.Error, events.ErrorSeverityError, events.ErrorSubSystemDB, false)
+	}
+	if resp := db.Exec(fmt.Sprintf("CREATE TABLE %s (%s)", csvCompactionChunkTable, strings.Join(columns, ", "))); resp.Error != nil {
+		return events.NewEventError(resp.Error, events.ErrorSeverityError, events.ErrorSubSystemDB, false)
+	}
+	return nil
+}
+
+func (c *csvCompaction) cleanup() {
+	c.exporter.DB.Exec(fmt.Sprintf("DROP TABLE IF EXISTS %s", csvCompactionChunkTable))
+}
+
+// compactFile rewrites a CSV file without the rows replaced, updated or deleted since they were written. The file
+// is left as it is if none of its rows changed
+func (c *csvCompaction) compactFile(f *csvStreamFile) error {
+	filePath := filepath.Join(c.exporter.ExportPath, f.FileName)
+	tmp, err := os.CreateTemp(c.exporter.ExportPath, fmt.Sprintf(".%s-*.tmp", f.FileName))
+	if err != nil {
+		return events.NewEventError(err, events.ErrorSeverityError, events.ErrorSubSystemFileOperations, false)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	c.out = csv.NewWriter(tmp)
+	c.keys = newKeyFilter(c.exporter.MaxRowsPerFile)
+	c.rows = 0
+	c.changed = false
+	c.chunk = c.chunk[:0]
+
+	if err := c.out.Write(c.stream.header); err != nil {
+		return events.NewEventError(err, events.ErrorSeverityError, events.ErrorSubSystemFileOperations, false)
+	}
+
+	seq := f.FirstSeq
+	err = readCSVFile(filePath, c.stream, func(record []string) error {
+		c.chunk = append(c.chunk, csvCompactionRow{
+			seq:    seq,
+			key:    c.stream.key(record),
+			record: record,
+		})
+		seq++
+
+		if len(c.chunk) >= csvStreamChunkSize {
+			return c.flush()
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if err := c.flush(); err != nil {
+		return err
+	}
+
+	c.out.Flush()
+	if err := c.out.Error(); err != nil {
+		return events.NewEventError(err, events.ErrorSeverityError, events.ErrorSubSystemFileOperations, false)
+	}
+	if err := tmp.Close(); err != nil {
+		return events.NewEventError(err, events.ErrorSeverityError, events.ErrorSubSystemFileOperations, false)
+	}
+
+	if !c.changed {
+		return nil
+	}
+	if err := os.Rename(tmp.Name(), filePath); err != nil {
+		return events.NewEventError(err, events.ErrorSeverityError, events.ErrorSubSystemFileOperations, false)
+	}
+	f.Keys = c.keys
+	f.Rows = c.rows
+	return nil
+}
+
+// flush writes out the rows of the current chunk that are still the latest version of their primary key
+func (c *csvCompaction) flush() error {
+	if len(c.chunk) == 0 {
+		return nil
+	}
+	db := c.exporter.DB
+
+	keys := make([]string, 0, len(c.chunk))
+	for _, row := range c.chunk {
+		keys = append(keys, row.key)
+	}
+
+	var entries []csvStreamIndex
+	if resp := db.Where("feed_name = ? AND pk IN ?", c.feedName, keys).Find(&entries); resp.Error != nil {
+		return events.NewEventError(resp.Error, events.ErrorSeverityError, events.ErrorSubSystemDB, false)
+	}
+	latest := make(map[string]csvStreamIndex, len(entries))
+	for _, entry := range entries {
+		latest[entry.PK] = entry
+	}
+
+	rows := make([]csvCompactionRow, 0, len(c.chunk))
+	for _, row := range c.chunk {
+		if entry, ok := latest[row.key]; ok && entry.Seq > row.seq {
+			continue
+		}
+		rows = append(rows, row)
+	}
+
+	rows, err := c.applyDeletes(rows)
+	if err != nil {
+		return err
+	}
+	if len(rows) != len(c.chunk) {
+		c.changed = true
+	}
+
+	for _, row := range rows {
+		entry := latest[row.key]
+		if entry.Updates != "" && (entry.Seq == 0 || entry.Seq == row.seq) {
+			var values map[string]interface{}
+			if err := json.Unmarshal([]byte(entry.Updates), &values); err != nil {
+				return events.NewEventError(err, events.ErrorSeverityError, events.ErrorSubSystemDataIntegrity, false)
+			}
+			for column, value := range values {
+				if idx, ok := c.stream.columns[column]; ok {
+					row.record[idx] = fmt.Sprint(value)
+					c.changed = true
+				}
+			}
+		}
+
+		if err := c.out.Write(row.record); err != nil {
+			return events.NewEventError(err, events.ErrorSeverityError, events.ErrorSubSystemFileOperations, false)
+		}
+		keyFilterAdd(c.keys, row.key)
+		c.rows++
+	}
+
+	c.chunk = c.chunk[:0]
+	return nil
+}
+
+// applyDeletes removes the rows matching a delete recorded after they were written
+func (c *csvCompaction) applyDeletes(rows []csvCompactionRow) ([]csvCompactionRow, error) {
+	if len(rows) == 0 || len(c.deletes) == 0 || c.deletes[len(c.deletes)-1].Seq <= rows[0].seq {
+		return rows, nil
+	}
+	db := c.exporter.DB
+
+	if resp := db.Exec(fmt.Sprintf("DELETE FROM %s", csvCompactionChunkTable)); resp.Error != nil {
+		return nil, events.NewEventError(resp.Error, events.ErrorSeverityError, events.ErrorSubSystemDB, false)
+	}
+
+	values := make([]map[string]interface{}, 0, len(rows))
+	for _, row := range rows {
+		value := map[string]interface{}{"_seq": row.seq}
+		for i, column := range c.stream.header {
+			value[column] = row.record[i]
+		}
+		values = append(values, value)
+	}
+	batchSize := c.exporter.ParameterLimit() / (len(c.stream.header) + 1)
+	if resp := db.Table(csvCompactionChunkTable).CreateInBatches(values, batchSize); resp.Error != nil {
+		return nil, events.NewEventError(resp.Error, events.ErrorSeverityError, events.ErrorSubSystemDB, false)
+	}
+
+	deleted := map[int64]bool{}
+	for _, d := range c.deletes {
+		if d.Seq <= rows[0].seq {
+			continue
+		}
+
+		var args []interface{}
+		if err := json.Unmarshal([]byte(d.Args), &args); err != nil {
+			return nil, events.NewEventError(err, events.ErrorSeverityError, events.ErrorSubSystemDataIntegrity, false)
+		}
+
+		var seqs []int64
+		resp := db.Table(csvCompactionChunkTable).
+			Where("_seq < ?", d.Seq).
+			Where(d.Query, args...).
+			Pluck("_seq", &seqs)
+		if resp.Error != nil {
+			return nil, events.NewEventErrorWithMessage(resp.Error, events.ErrorSeverityError, events.ErrorSubSystemDataIntegrity, false, "unable to delete rows")
+		}
+		for _, seq := range seqs {
+			deleted[seq] = true
+		}
+	}
+
+	kept := rows[:0]
+	for _, row := range rows {
+		if !deleted[row.seq] {
+			kept = append(kept, row)
+		}
+	}
+	return kept, nil
+}
+
+// readCSVFile reads the records of a CSV file of the feed, mapping its columns onto the current header in case the
+// schema changed between exports
+func readCSVFile(fileName string, s *csvStream, fn func(record []string) error) error {
+	file, err := os.Open(fileName)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return events.NewEventError(err, events.ErrorSeverityError, events.ErrorSubSystemFileOperations, false)
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		return events.NewEventError(err, events.ErrorSeverityError, events.ErrorSubSystemFileOperations, false)
+	}
+
+	mapping := make([]int, len(header))
+	for i, column := range header {
+		mapping[i] = -1
+		if idx, ok := s.columns[column]; ok {
+			mapping[i] = idx
+		}
+	}
+
+	for {
+		values, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return events.NewEventError(err, events.ErrorSeverityError, events.ErrorSubSystemFileOperations, false)
+		}
+
+		record := make([]string, len(s.header))
+		for i, value := range values {
+			if i < len(mapping) && mapping[i] >= 0 {
+				record[mapping[i]] = value
+			}
+		}
+
+		if err := fn(record); err != nil {
+			return err
+		}
+	}
+}
+
+// newKeyFilter returns an empty bloom filter sized for the keys of a file, up to csvStreamFilterMaxKeys
+func newKeyFilter(keys int) []byte {
+	if keys > csvStreamFilterMaxKeys {
+		keys = csvStreamFilterMaxKeys
+	}
+	if keys < 1 {
+		keys = 1
+	}
+	return make([]byte, (keys*csvStreamFilterBitsPerKey+7)/8)
+}
+
+// keyFilterBits returns the positions of the bits of a key in a filter, using double hashing
+func keyFilterBits(filter []byte, key string) []uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	h1 := h.Sum64()
+	h2 := h1>>33 | h1<<31 | 1
+
+	size := uint64(len(filter)) * 8
+	bits := make([]uint64, csvStreamFilterHashes)
+	for i := range bits {
+		bits[i] = (h1 + uint64(i)*h2) % size
+	}
+	return bits
+}
+
+func keyFilterAdd(filter []byte, key string) {
+	if len(filter) == 0 {
+		return
+	}
+	for _, bit := range keyFilterBits(filter, key) {
+		filter[bit/8] |= 1 << (bit % 8)
+	}
+}
+
+// keyFilterContains returns true if the key may have been added to the filter
+func keyFilterContains(filter []byte, key string) bool {
+	if len(filter) == 0 {
+		return false
+	}
+	for _, bit := range keyFilterBits(filter, key) {
+		if filter[bit/8]&(1<<(bit%8)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// NewStreamingCSVExporter creates a new instance of StreamingCSVExporter
+func NewStreamingCSVExporter(exportPath, exportMediaPath string, maxRowsPerFile int) (*StreamingCSVExporter, error) {
+	sqlExporter, err := NewSQLExporter("sqlite", filepath.Join(exportPath, "csv_index.db"), true, exportMediaPath)
+	if err != nil {
+		return nil, err
+	}
+	if res := sqlExporter.DB.Exec("PRAGMA busy_timeout = 20000"); res.Error != nil {
+		return nil, res.Error
+	}
+
+	err = sqlExporter.DB.AutoMigrate(&csvStreamFeed{}, &csvStreamFile{}, &csvStreamIndex{}, &csvStreamDelete{}, &csvStreamWatermark{})
+	if err != nil {
+		return nil, events.NewEventError(err, events.ErrorSeverityError, events.ErrorSubSystemDB, true)
+	}
+
+	return &StreamingCSVExporter{
+		SQLExporter:    sqlExporter,
+		ExportPath:     exportPath,
+		MaxRowsPerFile: maxRowsPerFile,
+		Logger:         sqlExporter.Logger,
+		duration:       0,
+		streams:        map[string]*csvStream{},
+	}, nil
+}
@@ -0,0 +1,199 @@
+package feed
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/SafetyCulture/safetyculture-exporter/pkg/internal/events"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// fileExportBatchSize is the maximum number of rows written out to a file at once
+const fileExportBatchSize = 10000
+
+// feedFiles names the files a feed is exported to, <feed>.<ext> and its rollovers <feed>-<time>.<ext>
+type feedFiles struct {
+	exportPath string
+	extension  string
+}
+
+// path returns the path of the current file of the feed
+func (f feedFiles) path(feedName string) string {
+	return filepath.Join(f.exportPath, fmt.Sprintf("%s.%s", feedName, f.extension))
+}
+
+// patterns returns the glob patterns matching the current file of the feed and its rollovers
+func (f feedFiles) patterns(feedName string) []string {
+	return []string{
+		fmt.Sprintf("%s.%s", feedName, f.extension),
+		fmt.Sprintf("%s-*.%s", feedName, f.extension),
+	}
+}
+
+func (f feedFiles) createRolloverFile(feedName string) error {
+	exportFilePath := f.path(feedName)
+	newFilePath := filepath.Join(f.exportPath, fmt.Sprintf("%s-%s.%s", feedName, time.Now().Format("20060102150405.999999"), f.extension))
+
+	_, err := fileExists(exportFilePath)
+	if err != nil {
+		return events.NewEventError(err, events.ErrorSeverityError, events.ErrorSubSystemFileOperations, false)
+	}
+
+	err = os.Rename(exportFilePath, newFilePath)
+	if err != nil {
+		return events.NewEventError(err, events.ErrorSeverityError, events.ErrorSubSystemFileOperations, false)
+	}
+
+	return nil
+}
+
+func (f feedFiles) cleanOldFiles(feedName string) error {
+	// match the current file and its rollovers only, so that "inspection" doesn't pick up "inspection_item"
+	files, err := filepath.Glob(filepath.Join(f.exportPath, fmt.Sprintf("%s-*.%s", feedName, f.extension)))
+	if err != nil {
+		return events.NewEventError(err, events.ErrorSeverityError, events.ErrorSubSystemFileOperations, false)
+	}
+	files = append(files, f.path(feedName))
+
+	for _, file := range files {
+		err = os.Remove(file)
+		if err != nil && !os.IsNotExist(err) {
+			return events.NewEventError(err, events.ErrorSeverityError, events.ErrorSubSystemFileOperations, false)
+		}
+	}
+
+	return nil
+}
+
+// feedFileWriter writes the rows of a feed to a file of an export format
+type feedFileWriter interface {
+	// create creates the file and prepares it for writing
+	create(filePath string) error
+	// write writes a slice of rows of the feed model
+	write(rows interface{}) error
+	// close completes the file
+	close() error
+}
+
+// writeFeedFiles writes out the rows of a feed staged in the database, rolling the files over at maxRowsPerFile.
+// The rows are read in the order of the feed through a single cursor, rather than paginated, so that the time it
+// takes grows linearly with the number of rows
+func writeFeedFiles(db *gorm.DB, feed Feed, files feedFiles, maxRowsPerFile int, w feedFileWriter, logger *zap.SugaredLogger) (err error) {
+	status := GetExporterStatus()
+
+	if err := files.cleanOldFiles(feed.Name()); err != nil {
+		return err
+	}
+
+	batchSize := fileExportBatchSize
+	if batchSize > maxRowsPerFile {
+		batchSize = maxRowsPerFile
+	}
+
+	cursor, err := db.Table(feed.Name()).Order(feed.Order()).Rows()
+	if err != nil {
+		return events.NewEventError(err, events.ErrorSeverityError, events.ErrorSubSystemDB, false)
+	}
+	defer cursor.Close()
+
+	opened := false
+	defer func() {
+		if opened && err != nil {
+			w.close()
+		}
+	}()
+
+	modelType := reflect.TypeOf(feed.Model())
+	batch := reflect.MakeSlice(reflect.SliceOf(modelType), 0, batchSize)
+	rowsAdded, fileRows := 0, 0
+	preTime := time.Now()
+
+	flush := func() error {
+		if batch.Len() == 0 {
+			return nil
+		}
+		postQueryTime := time.Now()
+
+		if opened && fileRows >= maxRowsPerFile {
+			opened = false
+			if err := w.close(); err != nil {
+				return err
+			}
+			if err := files.createRolloverFile(feed.Name()); err != nil {
+				return err
+			}
+		}
+		if !opened {
+			if err := w.create(files.path(feed.Name())); err != nil {
+				return err
+			}
+			opened = true
+			fileRows = 0
+		}
+
+		if err := w.write(batch.Interface()); err != nil {
+			return err
+		}
+		postWriteTime := time.Now()
+
+		rowsAdded += batch.Len()
+		fileRows += batch.Len()
+		status.UpdateStatus(feed.Name(), int64(rowsAdded), postWriteTime.Sub(preTime).Milliseconds())
+
+		logger.With(
+			"rows_added", rowsAdded,
+			"total_time_ms", postWriteTime.Sub(preTime).Milliseconds(),
+			"query_time_ms", postQueryTime.Sub(preTime).Milliseconds(),
+			"write_time_ms", postWriteTime.Sub(postQueryTime).Milliseconds(),
+		).Info("writing out batch")
+
+		batch = batch.Slice(0, 0)
+		preTime = time.Now()
+		return nil
+	}
+
+	for cursor.Next() {
+		row := reflect.New(modelType)
+		if err := db.ScanRows(cursor, row.Interface()); err != nil {
+			return events.NewEventError(err, events.ErrorSeverityError, events.ErrorSubSystemDB, false)
+		}
+		batch = reflect.Append(batch, row.Elem())
+
+		// the batch is written out once full, or once it fills up the current file
+		room := maxRowsPerFile - fileRows
+		if !opened || room <= 0 {
+			room = maxRowsPerFile
+		}
+		if batch.Len() >= batchSize || batch.Len() >= room {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return events.NewEventError(err, events.ErrorSeverityError, events.ErrorSubSystemDB, false)
+	}
+	if err := flush(); err != nil {
+		return err
+	}
+
+	if !opened {
+		return nil
+	}
+	opened = false
+	return w.close()
+}
+
+// fileColumnName returns the name of the column of a model field in the file exports, the same as the CSV column
+func fileColumnName(field reflect.StructField) string {
+	name := strings.Split(field.Tag.Get("csv"), ",")[0]
+	if name == "" || name == "-" {
+		name = field.Name
+	}
+	return name
+}
@@ -0,0 +1,218 @@
+package feed
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+
+	"github.com/SafetyCulture/safetyculture-exporter/pkg/internal/events"
+	"go.uber.org/zap"
+)
+
+// JSONLExporter is an interface to export data feeds to newline delimited JSON files
+type JSONLExporter struct {
+	*SQLExporter
+	ExportPath     string
+	MaxRowsPerFile int
+	Gzip           bool
+	Logger         *zap.SugaredLogger
+
+	rowTypesMu sync.Mutex
+	rowTypes   map[string]reflect.Type
+}
+
+// jsonlFile is an open JSONL file, optionally gzip compressed
+type jsonlFile struct {
+	file    *os.File
+	gz      *gzip.Writer
+	buf     *bufio.Writer
+	encoder *json.Encoder
+}
+
+func (f *jsonlFile) Close() error {
+	if err := f.buf.Flush(); err != nil {
+		f.file.Close()
+		return err
+	}
+
+	if f.gz != nil {
+		if err := f.gz.Close(); err != nil {
+			f.file.Close()
+			return err
+		}
+	}
+
+	return f.file.Close()
+}
+
+// CreateSchema generates an empty JSONL file for a feed
+func (e *JSONLExporter) CreateSchema(feed Feed, _ interface{}) error {
+	logger := e.Logger.With(
+		"feed", feed.Name(),
+	)
+	logger.Info("writing out JSONL schema file")
+
+	filePath := e.files().path(feed.Name())
+	_, err := os.Stat(filePath)
+	if os.IsNotExist(err) {
+		file, err := e.createFile(filePath)
+		if err != nil {
+			return err
+		}
+		return e.closeFile(file)
+	}
+
+	logger.Info("JSONL file already exists, skipping")
+	return nil
+}
+
+// FinaliseExport closes out an export
+func (e *JSONLExporter) FinaliseExport(feed Feed, _ interface{}) error {
+	logger := e.Logger.With("feed", feed.Name())
+	logger.Info("writing out JSONL file")
+	status := GetExporterStatus()
+	status.UpdateStage(feed.Name(), StageJSONL, false)
+
+	err := writeFeedFiles(e.DB, feed, e.files(), e.MaxRowsPerFile, &jsonlFileWriter{exporter: e, feed: feed}, logger)
+	if err != nil {
+		return err
+	}
+
+	logger.Info("JSONL exported")
+	return e.syncFiles(e.ExportPath, e.files().patterns(feed.Name())...)
+}
+
+// jsonlFileWriter writes the rows of a feed to a JSONL file
+type jsonlFileWriter struct {
+	exporter *JSONLExporter
+	feed     Feed
+	file     *jsonlFile
+}
+
+func (w *jsonlFileWriter) create(filePath string) error {
+	file, err := w.exporter.createFile(filePath)
+	if err != nil {
+		return err
+	}
+	w.file = file
+	return nil
+}
+
+func (w *jsonlFileWriter) write(rows interface{}) error {
+	return w.exporter.writeJSONLRows(w.file, w.feed, rows)
+}
+
+func (w *jsonlFileWriter) close() error {
+	file := w.file
+	w.file = nil
+	return w.exporter.closeFile(file)
+}
+
+// writeJSONLRows encodes each row of the feed model as a single JSON line
+func (e *JSONLExporter) writeJSONLRows(file *jsonlFile, feed Feed, rows interface{}) error {
+	rowType := e.rowTypeOf(feed)
+
+	values := reflect.Indirect(reflect.ValueOf(rows))
+	for i := 0; i < values.Len(); i++ {
+		row := reflect.Indirect(values.Index(i))
+		if !row.IsValid() {
+			continue
+		}
+
+		if err := file.encoder.Encode(row.Convert(rowType).Interface()); err != nil {
+			return events.NewEventError(err, events.ErrorSeverityError, events.ErrorSubSystemDataIntegrity, false)
+		}
+	}
+	return nil
+}
+
+// rowTypeOf returns a struct type with the same layout as the feed model, with the JSON keys
+// named after the CSV columns so every file export shares the same naming
+func (e *JSONLExporter) rowTypeOf(feed Feed) reflect.Type {
+	e.rowTypesMu.Lock()
+	defer e.rowTypesMu.Unlock()
+
+	if e.rowTypes == nil {
+		e.rowTypes = map[string]reflect.Type{}
+	}
+	if rowType, ok := e.rowTypes[feed.Name()]; ok {
+		return rowType
+	}
+
+	modelType := reflect.TypeOf(feed.Model())
+	fields := make([]reflect.StructField, 0, modelType.NumField())
+	for i := 0; i < modelType.NumField(); i++ {
+		field := modelType.Field(i)
+
+		field.Tag = reflect.StructTag(fmt.Sprintf(`json:"%s"`, fileColumnName(field)))
+		fields = append(fields, field)
+	}
+
+	rowType := reflect.StructOf(fields)
+	e.rowTypes[feed.Name()] = rowType
+	return rowType
+}
+
+// files returns the names of the JSONL files of the feeds
+func (e *JSONLExporter) files() feedFiles {
+	if e.Gzip {
+		return feedFiles{exportPath: e.ExportPath, extension: "jsonl.gz"}
+	}
+	return feedFiles{exportPath: e.ExportPath, extension: "jsonl"}
+}
+
+func (e *JSONLExporter) createFile(filePath string) (*jsonlFile, error) {
+	file, err := os.OpenFile(filePath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
+	if err != nil {
+		return nil, events.NewEventError(err, events.ErrorSeverityError, events.ErrorSubSystemFileOperations, false)
+	}
+
+	res := &jsonlFile{file: file}
+
+	var w io.Writer = file
+	if e.Gzip {
+		res.gz = gzip.NewWriter(file)
+		w = res.gz
+	}
+	res.buf = bufio.NewWriter(w)
+	res.encoder = json.NewEncoder(res.buf)
+	res.encoder.SetEscapeHTML(false)
+
+	return res, nil
+}
+
+func (e *JSONLExporter) closeFile(file *jsonlFile) error {
+	if file == nil {
+		return nil
+	}
+
+	if err := file.Close(); err != nil {
+		return events.NewEventError(err, events.ErrorSeverityError, events.ErrorSubSystemFileOperations, false)
+	}
+	return nil
+}
+
+// NewJSONLExporter creates a new instance of JSONLExporter
+func NewJSONLExporter(exportPath, exportMediaPath string, maxRowsPerFile int, gzip bool) (*JSONLExporter, error) {
+	sqlExporter, err := NewSQLExporter("sqlite", filepath.Join(exportPath, "sqlite.db"), true, exportMediaPath)
+	if err != nil {
+		return nil, err
+	}
+	if res := sqlExporter.DB.Exec("PRAGMA busy_timeout = 20000"); res.Error != nil {
+		return nil, res.Error
+	}
+
+	return &JSONLExporter{
+		SQLExporter:    sqlExporter,
+		ExportPath:     exportPath,
+		MaxRowsPerFile: maxRowsPerFile,
+		Gzip:           gzip,
+		Logger:         sqlExporter.Logger,
+	}, nil
+}
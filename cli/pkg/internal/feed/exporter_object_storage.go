@@ -0,0 +1,25 @@
+package feed
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/SafetyCulture/safetyculture-exporter/pkg/internal/events"
+)
+
+// uploadFile copies a written file to the object storage, when configured
+func (e *SQLExporter) uploadFile(localPath string) error {
+	if err := e.Mirror.Upload(context.Background(), localPath); err != nil {
+		return events.NewEventErrorWithMessage(err, events.ErrorSeverityError, events.ErrorSubSystemFileOperations, false, fmt.Sprintf("upload %s to object storage", localPath))
+	}
+	return nil
+}
+
+// syncFiles copies the files of a feed, such as the current file and its rollovers, to the object storage when
+// configured. The objects of the feed left from previous exports are removed
+func (e *SQLExporter) syncFiles(dir string, patterns ...string) error {
+	if err := e.Mirror.Sync(context.Background(), dir, patterns...); err != nil {
+		return events.NewEventErrorWithMessage(err, events.ErrorSeverityError, events.ErrorSubSystemFileOperations, false, "upload files to object storage")
+	}
+	return nil
+}
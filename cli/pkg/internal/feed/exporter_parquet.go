@@ -0,0 +1,221 @@
+package feed
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/SafetyCulture/safetyculture-exporter/pkg/internal/events"
+	"github.com/parquet-go/parquet-go"
+	"go.uber.org/zap"
+)
+
+// ParquetExporter is an interface to export data feeds to Parquet files
+type ParquetExporter struct {
+	*SQLExporter
+	ExportPath     string
+	MaxRowsPerFile int
+	Logger         *zap.SugaredLogger
+
+	rowTypesMu sync.Mutex
+	rowTypes   map[string]reflect.Type
+}
+
+// CreateSchema generates an empty Parquet file holding the schema of a feed
+func (e *ParquetExporter) CreateSchema(feed Feed, _ interface{}) error {
+	logger := e.Logger.With(
+		"feed", feed.Name(),
+	)
+	logger.Info("writing out Parquet schema file")
+
+	exportFilePath := e.files().path(feed.Name())
+	_, err := os.Stat(exportFilePath)
+
+	if os.IsNotExist(err) {
+		file, err := e.createFile(exportFilePath)
+		if err != nil {
+			return err
+		}
+
+		writer := parquet.NewWriter(file, e.schemaOf(feed))
+		if err := writer.Close(); err != nil {
+			file.Close()
+			return events.NewEventError(err, events.ErrorSeverityError, events.ErrorSubSystemDataIntegrity, false)
+		}
+		return file.Close()
+	}
+
+	logger.Info("Parquet file already exists, skipping")
+	return nil
+}
+
+// FinaliseExport closes out an export
+func (e *ParquetExporter) FinaliseExport(feed Feed, _ interface{}) error {
+	logger := e.Logger.With("feed", feed.Name())
+	logger.Info("writing out Parquet file")
+	status := GetExporterStatus()
+	status.UpdateStage(feed.Name(), StageParquet, false)
+
+	err := writeFeedFiles(e.DB, feed, e.files(), e.MaxRowsPerFile, &parquetFileWriter{exporter: e, feed: feed}, logger)
+	if err != nil {
+		return err
+	}
+
+	logger.Info("Parquet exported")
+	return e.syncFiles(e.ExportPath, e.files().patterns(feed.Name())...)
+}
+
+// parquetFileWriter writes the rows of a feed to a Parquet file
+type parquetFileWriter struct {
+	exporter *ParquetExporter
+	feed     Feed
+	file     *os.File
+	writer   *parquet.Writer
+}
+
+func (w *parquetFileWriter) create(filePath string) error {
+	file, err := w.exporter.createFile(filePath)
+	if err != nil {
+		return err
+	}
+	w.file = file
+	w.writer = parquet.NewWriter(file, w.exporter.schemaOf(w.feed))
+	return nil
+}
+
+func (w *parquetFileWriter) write(rows interface{}) error {
+	return w.exporter.writeParquetRows(w.writer, w.feed, rows)
+}
+
+func (w *parquetFileWriter) close() error {
+	writer, file := w.writer, w.file
+	w.writer, w.file = nil, nil
+	return w.exporter.closeWriter(writer, file)
+}
+
+// writeParquetRows converts each row of the feed model into the Parquet row type and writes it out
+func (e *ParquetExporter) writeParquetRows(writer *parquet.Writer, feed Feed, rows interface{}) error {
+	rowType := e.rowTypeOf(feed)
+
+	values := reflect.Indirect(reflect.ValueOf(rows))
+	for i := 0; i < values.Len(); i++ {
+		row := reflect.Indirect(values.Index(i))
+		if !row.IsValid() {
+			continue
+		}
+
+		if err := writer.Write(parquetRow(row, rowType).Interface()); err != nil {
+			return events.NewEventError(err, events.ErrorSeverityError, events.ErrorSubSystemDataIntegrity, false)
+		}
+	}
+	return nil
+}
+
+// parquetRow converts a row of the feed model into the Parquet row type, dereferencing the nullable timestamps
+func parquetRow(row reflect.Value, rowType reflect.Type) reflect.Value {
+	converted := reflect.New(rowType).Elem()
+	for i := 0; i < rowType.NumField(); i++ {
+		field := row.Field(i)
+		if field.Type() == rowType.Field(i).Type {
+			converted.Field(i).Set(field)
+		} else if !field.IsNil() {
+			converted.Field(i).Set(field.Elem())
+		}
+	}
+	return converted
+}
+
+func (e *ParquetExporter) closeWriter(writer *parquet.Writer, file *os.File) error {
+	if writer != nil {
+		if err := writer.Close(); err != nil {
+			return events.NewEventError(err, events.ErrorSeverityError, events.ErrorSubSystemFileOperations, false)
+		}
+	}
+
+	if file != nil {
+		if err := file.Close(); err != nil {
+			return events.NewEventError(err, events.ErrorSeverityError, events.ErrorSubSystemFileOperations, false)
+		}
+	}
+	return nil
+}
+
+func (e *ParquetExporter) schemaOf(feed Feed) *parquet.Schema {
+	return parquet.SchemaOf(reflect.New(e.rowTypeOf(feed)).Elem().Interface())
+}
+
+// rowTypeOf returns a struct type with the same layout as the feed model, tagged for Parquet.
+// Columns are named after the CSV columns so both exports share the same naming, pointer
+// fields become optional (nullable) columns and timestamps, nullable or not, are stored as TIMESTAMP(MICROS)
+func (e *ParquetExporter) rowTypeOf(feed Feed) reflect.Type {
+	e.rowTypesMu.Lock()
+	defer e.rowTypesMu.Unlock()
+
+	if e.rowTypes == nil {
+		e.rowTypes = map[string]reflect.Type{}
+	}
+	if rowType, ok := e.rowTypes[feed.Name()]; ok {
+		return rowType
+	}
+
+	modelType := reflect.TypeOf(feed.Model())
+	fields := make([]reflect.StructField, 0, modelType.NumField())
+	for i := 0; i < modelType.NumField(); i++ {
+		field := modelType.Field(i)
+
+		options := []string{fileColumnName(field)}
+		switch {
+		case field.Type == reflect.PointerTo(reflect.TypeOf(time.Time{})):
+			// the timestamp tag only applies to time.Time fields, nullable timestamps are written from optional
+			// time.Time fields, null when zero
+			field.Type = field.Type.Elem()
+			options = append(options, "optional", "timestamp(microsecond)")
+		case field.Type.Kind() == reflect.Ptr:
+			options = append(options, "optional")
+		case field.Type == reflect.TypeOf(time.Time{}):
+			options = append(options, "timestamp(microsecond)")
+		}
+
+		field.Tag = reflect.StructTag(fmt.Sprintf(`parquet:"%s"`, strings.Join(options, ",")))
+		fields = append(fields, field)
+	}
+
+	rowType := reflect.StructOf(fields)
+	e.rowTypes[feed.Name()] = rowType
+	return rowType
+}
+
+// files returns the names of the Parquet files of the feeds
+func (e *ParquetExporter) files() feedFiles {
+	return feedFiles{exportPath: e.ExportPath, extension: "parquet"}
+}
+
+func (e *ParquetExporter) createFile(filePath string) (*os.File, error) {
+	file, err := os.OpenFile(filePath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
+	if err != nil {
+		return nil, events.NewEventError(err, events.ErrorSeverityError, events.ErrorSubSystemFileOperations, false)
+	}
+	return file, nil
+}
+
+// NewParquetExporter creates a new instance of ParquetExporter
+func NewParquetExporter(exportPath, exportMediaPath string, maxRowsPerFile int) (*ParquetExporter, error) {
+	sqlExporter, err := NewSQLExporter("sqlite", filepath.Join(exportPath, "sqlite.db"), true, exportMediaPath)
+	if err != nil {
+		return nil, err
+	}
+	if res := sqlExporter.DB.Exec("PRAGMA busy_timeout = 20000"); res.Error != nil {
+		return nil, res.Error
+	}
+
+	return &ParquetExporter{
+		SQLExporter:    sqlExporter,
+		ExportPath:     exportPath,
+		MaxRowsPerFile: maxRowsPerFile,
+		Logger:         sqlExporter.Logger,
+	}, nil
+}
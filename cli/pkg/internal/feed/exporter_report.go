@@ -9,9 +9,12 @@ import (
 	"regexp"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/MickStanciu/go-fn/fn"
 	"github.com/SafetyCulture/safetyculture-exporter/pkg/httpapi"
+	"github.com/SafetyCulture/safetyculture-exporter/pkg/internal/metrics"
 	"github.com/SafetyCulture/safetyculture-exporter/pkg/internal/report"
 
 	"github.com/pkg/errors"
@@ -22,6 +25,13 @@ import (
 
 const feedReports = "reports"
 
+const (
+	// defaultReportConcurrency is the number of reports generated at the same time when not configured
+	defaultReportConcurrency = 10
+	// reportPageSize is the number of inspections loaded at once to queue their reports
+	reportPageSize = 100
+)
+
 // ReportExporter is an interface to export data feeds to CSV files
 type ReportExporter struct {
 	*SQLExporter
@@ -29,10 +39,26 @@ type ReportExporter struct {
 	ExportPath   string
 	PreferenceID string
 	Filename     string
+	// PathTemplate is the path of the reports relative to the export path, see reportPathTemplate. The filename
+	// convention is used when not set
+	PathTemplate string
 	Format       []string
-	Mu           sync.Mutex
 	RetryTimeout int
+	// Concurrency is the number of reports generated at the same time, each one is initiated, polled and downloaded
+	// independently of the others
+	Concurrency int
+	// RetryFailed only generates the reports that failed during the previous runs, the inspections aren't exported
+	RetryFailed bool
+	// Filter limits the inspections the reports are generated for
+	Filter ReportFilter
+	// Source is the SQL database the inspections are read from when set, instead of the API
+	Source *SQLExporter
+	// BundleBy groups the reports into ZIP archives by template, site and/or month, see bundleReports
+	BundleBy     []string
 	ReportClient *httpapi.Client
+	paths        *reportPaths
+	// dbMu serialises the access to the reports database shared by the workers
+	dbMu sync.Mutex
 }
 
 type reportExportFormat struct {
@@ -40,11 +66,18 @@ type reportExportFormat struct {
 	WORD bool
 }
 
+// reportExport is the state of the reports of an inspection, pdf and word are 1 once saved and -1 when failed
 type reportExport struct {
-	AuditID         string    `gorm:"primarykey;column:audit_id"`
-	AuditModifiedAt time.Time `gorm:"column:modified_at"`
-	PDF             int       `gorm:"column:pdf"`
-	WORD            int       `gorm:"column:word"`
+	AuditID         string     `gorm:"primarykey;column:audit_id"`
+	AuditModifiedAt time.Time  `gorm:"column:modified_at"`
+	PDF             int        `gorm:"column:pdf"`
+	WORD            int        `gorm:"column:word"`
+	PDFError        string     `gorm:"column:pdf_error"`
+	WORDError       string     `gorm:"column:word_error"`
+	PDFPath         string     `gorm:"column:pdf_path"`
+	WORDPath        string     `gorm:"column:word_path"`
+	Attempts        int        `gorm:"column:attempts"`
+	LastAttemptAt   *time.Time `gorm:"column:last_attempt_at"`
 }
 
 type reportExportResult struct {
@@ -72,7 +105,20 @@ func (e *ReportExporter) SaveReports(ctx context.Context, apiClient *httpapi.Cli
 		return err
 	}
 
-	if !feed.Incremental {
+	queued, err := e.queuedInspections(format)
+	if err != nil {
+		return err
+	}
+	if queued != nil {
+		e.Logger.Infof("Generating the reports of %d inspections", len(queued))
+	}
+
+	e.paths, err = e.newReportPaths(format.names())
+	if err != nil {
+		return fmt.Errorf("resolve report paths: %w", err)
+	}
+
+	if !feed.Incremental && !e.RetryFailed {
 		result := e.DB.Session(&gorm.Session{AllowGlobalUpdate: true}).Unscoped().Delete(r)
 		if result.Error != nil {
 			return errors.Wrap(result.Error, "Unable to truncate table")
@@ -81,28 +127,59 @@ func (e *ReportExporter) SaveReports(ctx context.Context, apiClient *httpapi.Cli
 
 	res := &reportExportResult{}
 
-	// you can specify level of concurrency by increasing channel size
-	buffers := make(chan bool, 3)
-	var wg sync.WaitGroup
-
 	var totalInspections int64 = 0
 	cntRsp := e.DB.Model(&Inspection{}).Count(&totalInspections)
 	if cntRsp.Error != nil {
 		return cntRsp.Error
 	}
+	if queued != nil {
+		totalInspections = int64(len(queued))
+	}
 
 	status.started = true
 	status.StartFeedExport(feedReports, true)
 
-	limit := 1
+	concurrency := fn.GetOrElse(e.Concurrency, defaultReportConcurrency, func(i int) bool {
+		return i > 0
+	})
+
+	// the reports are generated by a pool of workers, so that the reports of many inspections are initiated and
+	// polled at the same time
+	inspections := make(chan *Inspection)
+	saved := map[string]bool{}
+	var processed atomic.Int64
+	var resMu sync.Mutex
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for inspection := range inspections {
+				rep := e.saveReport(ctx, apiClient, inspection, format)
+				remaining := totalInspections - processed.Add(1)
+				status.UpdateStatus(feedReports, remaining, 0)
+
+				resMu.Lock()
+				e.updateReportResult(rep, res, inspection, remaining)
+				if rep != nil && (rep.PDF == 1 || rep.WORD == 1) {
+					saved[inspection.ID] = true
+				}
+				resMu.Unlock()
+			}
+		}()
+	}
+
 	offset := 0
+queue:
 	for {
 		rows := &[]*Inspection{}
+		e.dbMu.Lock()
 		resp := e.DB.
 			Order(feed.Order()).
-			Limit(limit).
+			Limit(reportPageSize).
 			Offset(offset).
 			Find(rows)
+		e.dbMu.Unlock()
 
 		if resp.Error != nil {
 			err = resp.Error
@@ -113,30 +190,23 @@ func (e *ReportExporter) SaveReports(ctx context.Context, apiClient *httpapi.Cli
 			break
 		}
 
-		offset = offset + limit
+		offset = offset + reportPageSize
 
 		for _, r := range *rows {
-			wg.Add(1)
-
-			go func(inspection *Inspection, remaining int64, c context.Context) {
-				defer wg.Done()
-				select {
-				case <-c.Done():
-					e.Logger.Infof(" ... canceling save reports ")
-					return
-				default:
-					buffers <- true
-
-					rep := e.saveReport(c, apiClient, inspection, format)
-					status.UpdateStatus(feedReports, remaining, 0)
-					e.updateReportResult(rep, res, inspection, remaining)
-
-					<-buffers
-				}
-			}(r, totalInspections-int64(offset), ctx)
+			if queued != nil && !queued[r.ID] {
+				continue
+			}
+
+			select {
+			case <-ctx.Done():
+				e.Logger.Infof(" ... canceling save reports ")
+				break queue
+			case inspections <- r:
+			}
 		}
 	}
 
+	close(inspections)
 	wg.Wait()
 
 	if res.NoChange > 0 {
@@ -151,12 +221,33 @@ func (e *ReportExporter) SaveReports(ctx context.Context, apiClient *httpapi.Cli
 		err = fmt.Errorf("failed to generate %d PDF reports and %d WORD reports", res.PDFErrors, res.WORDErrors)
 	}
 
+	if ctx.Err() == nil {
+		if bErr := e.bundleReports(saved); bErr != nil && err == nil {
+			err = fmt.Errorf("bundle reports: %w", bErr)
+		}
+	}
+
 	status.FinishFeedExport(feedReports, err)
 	status.MarkExportCompleted()
+	if err == nil {
+		metrics.SetLastSuccessfulRun(time.Now())
+	}
 
 	return err
 }
 
+// names returns the names of the formats exported
+func (f *reportExportFormat) names() []string {
+	var names []string
+	if f.PDF {
+		names = append(names, "PDF")
+	}
+	if f.WORD {
+		names = append(names, "WORD")
+	}
+	return names
+}
+
 func (e *ReportExporter) getFormats() (*reportExportFormat, error) {
 	format := &reportExportFormat{}
 	for _, f := range e.Format {
@@ -181,7 +272,9 @@ func (e *ReportExporter) saveReport(ctx context.Context, apiClient *httpapi.Clie
 	exportPDF, exportWORD := format.PDF, format.WORD
 
 	r := &reportExport{}
+	e.dbMu.Lock()
 	err := e.DB.First(&r, "audit_id = ?", inspection.ID).Error
+	e.dbMu.Unlock()
 	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
 		e.Logger.Errorf("Error during loading report from reports db: %s", err)
 		return r
@@ -190,6 +283,7 @@ func (e *ReportExporter) saveReport(ctx context.Context, apiClient *httpapi.Clie
 	if r.AuditModifiedAt != inspection.ModifiedAt {
 		r.AuditID = inspection.ID
 		r.AuditModifiedAt = inspection.ModifiedAt
+		r.Attempts = 0
 	} else {
 		exportPDF = exportPDF && r.PDF != 1
 		exportWORD = exportWORD && r.WORD != 1
@@ -198,30 +292,26 @@ func (e *ReportExporter) saveReport(ctx context.Context, apiClient *httpapi.Clie
 		}
 	}
 
+	now := time.Now()
+	r.Attempts++
+	r.LastAttemptAt = &now
+
 	if exportPDF {
-		err = e.exportInspection(ctx, apiClient, inspection, "PDF")
-		if err != nil {
-			e.Logger.Errorf("PDF export failed for '%s'. Error: %s", inspection.ID, err)
-			r.PDF = -1
-		} else {
-			r.PDF = 1
-		}
+		r.PDF, r.PDFPath, r.PDFError = e.exportReport(ctx, apiClient, inspection, "PDF")
 	}
 
 	if exportWORD {
-		err = e.exportInspection(ctx, apiClient, inspection, "WORD")
-		if err != nil {
-			e.Logger.Errorf("WORD export failed for '%s'. Error: %s", inspection.ID, err)
-			r.WORD = -1
-		} else {
-			r.WORD = 1
-		}
+		r.WORD, r.WORDPath, r.WORDError = e.exportReport(ctx, apiClient, inspection, "WORD")
 	}
 
+	e.dbMu.Lock()
 	result := e.DB.Clauses(clause.OnConflict{
-		Columns:   []clause.Column{{Name: "audit_id"}},
-		DoUpdates: clause.AssignmentColumns([]string{"modified_at", "pdf", "word"}),
+		Columns: []clause.Column{{Name: "audit_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{
+			"modified_at", "pdf", "word", "pdf_error", "word_error", "pdf_path", "word_path", "attempts", "last_attempt_at",
+		}),
 	}).Create(&r)
+	e.dbMu.Unlock()
 
 	if result.Error != nil {
 		e.Logger.Errorf("Failed to update save report status to local db for %s", inspection.ID)
@@ -230,16 +320,28 @@ func (e *ReportExporter) saveReport(ctx context.Context, apiClient *httpapi.Clie
 	return r
 }
 
+// exportReport exports the report of an inspection in a format, and returns its state, the file it was saved to or
+// the error it failed with
+func (e *ReportExporter) exportReport(ctx context.Context, apiClient *httpapi.Client, inspection *Inspection, format string) (int, string, string) {
+	filePath, err := e.exportInspection(ctx, apiClient, inspection, format)
+	metrics.ObserveReport(format, err)
+	if err != nil {
+		e.Logger.Errorf("%s export failed for '%s'. Error: %s", format, inspection.ID, err)
+		return -1, "", err.Error()
+	}
+	return 1, filePath, ""
+}
+
 // GetDuration will return the duration for exporting a batch
 func (e *ReportExporter) GetDuration() time.Duration {
 	// NOT IMPLEMENTED
 	return 0
 }
 
-func (e *ReportExporter) exportInspection(ctx context.Context, apiClient *httpapi.Client, inspection *Inspection, format string) error {
+func (e *ReportExporter) exportInspection(ctx context.Context, apiClient *httpapi.Client, inspection *Inspection, format string) (string, error) {
 	messageID, err := report.InitiateInspectionReportExport(ctx, apiClient, inspection.ID, format, e.PreferenceID)
 	if err != nil {
-		return err
+		return "", err
 	}
 
 	downloadClient := apiClient
@@ -247,30 +349,30 @@ func (e *ReportExporter) exportInspection(ctx context.Context, apiClient *httpap
 		downloadClient = e.ReportClient
 	}
 
-	// Lock the entire poll + download + write cycle so that:
-	// 1. The pre-signed download URL doesn't expire while waiting for the lock
-	// 2. The HTTP client timeout doesn't expire on an unread response body
-	e.Mu.Lock()
-	defer e.Mu.Unlock()
-
 	tries := 0
+	filePath := ""
 
 	for {
 		// wait for stipulated time before checking for report completion
-		time.Sleep(GetWaitTime(e.RetryTimeout) * time.Second)
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-time.After(GetWaitTime(e.RetryTimeout) * time.Second):
+		}
 
 		rec, cErr := httpapi.CheckInspectionReportExportCompletion(ctx, apiClient, inspection.ID, messageID)
 		if cErr != nil {
 			err = cErr
 			break
 		} else if rec.Status == "SUCCESS" {
+			// the pre-signed URL is downloaded as soon as the report is ready, before it expires
 			resp, dErr := httpapi.ExecuteRawGet(ctx, downloadClient, rec.URL)
 			if dErr != nil {
 				err = dErr
 				break
 			}
 
-			err = e.saveReportResponse(resp.Body, inspection, format)
+			filePath, err = e.saveReportResponse(resp.Body, inspection, format)
 			break
 		} else if rec.Status == "FAILED" {
 			err = fmt.Errorf("%s report generation failed on server for %s", format, fmt.Sprintf("%s (%s)", inspection.Name, inspection.ID))
@@ -285,7 +387,7 @@ func (e *ReportExporter) exportInspection(ctx context.Context, apiClient *httpap
 		}
 	}
 
-	return err
+	return filePath, err
 }
 
 func (e *ReportExporter) updateReportResult(rep *reportExport, res *reportExportResult, inspection *Inspection, remaining int64) {
@@ -312,21 +414,31 @@ func (e *ReportExporter) updateReportResult(rep *reportExport, res *reportExport
 	}
 }
 
-func (e *ReportExporter) saveReportResponse(resp io.ReadCloser, inspection *Inspection, format string) error {
-	filePath, pErr := getFilePath(e.ExportPath, inspection, format, e.Filename)
+// saveReportResponse saves a report and returns the file it was saved to
+func (e *ReportExporter) saveReportResponse(resp io.ReadCloser, inspection *Inspection, format string) (string, error) {
+	filePath, pErr := e.paths.path(inspection, format)
 	if pErr != nil {
-		return pErr
+		return "", pErr
 	}
 
+	if err := os.MkdirAll(filepath.Dir(filePath), os.ModePerm); err != nil {
+		return "", err
+	}
+
+	// the report of the inspection generated by a previous run is overwritten
 	out, err := os.Create(filePath)
 	if err != nil {
-		return err
+		return "", err
 	}
 
 	_, err = io.Copy(out, resp)
 	resp.Close()
 	out.Close()
-	return err
+	if err != nil {
+		return "", err
+	}
+
+	return filePath, e.uploadFile(filePath)
 }
 
 func sanitizeName(name string) string {
@@ -359,34 +471,3 @@ func GetWaitTime(retryTimeout int) time.Duration {
 	}
 	return time.Duration(waitTime)
 }
-
-func getFilePath(exportPath string, inspection *Inspection, format string, filenameConvention string) (string, error) {
-	dupIndex := 0
-	for {
-		fileName := sanitizeName(inspection.Name)
-		if strings.TrimSpace(fileName) == "" || filenameConvention == "INSPECTION_ID" {
-			fileName = inspection.ID
-		}
-
-		// fully qualified file names on Windows cannot be longer than 260
-		// characters. The limit has been set to 250 because we are appending
-		// more characters like the extension to the end of the file
-		maxLength := 250
-		if len(fileName)+len(exportPath) > maxLength {
-			fileName = fileName[:maxLength-len(exportPath)]
-		}
-
-		if dupIndex > 0 {
-			fileName = fmt.Sprintf("%s (%d)", fileName, dupIndex)
-		}
-
-		exportFilePath := filepath.Join(exportPath, fmt.Sprintf("%s.%s", fileName, getFileExtension(format)))
-		if _, err := os.Stat(exportFilePath); err == nil {
-			dupIndex++
-		} else if os.IsNotExist(err) {
-			return exportFilePath, nil
-		} else {
-			return "", err
-		}
-	}
-}
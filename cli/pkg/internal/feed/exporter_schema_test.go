@@ -2,6 +2,7 @@ package feed_test
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"os"
 	"strings"
@@ -10,6 +11,8 @@ import (
 	exporterAPI "github.com/SafetyCulture/safetyculture-exporter/pkg/api"
 	"github.com/SafetyCulture/safetyculture-exporter/pkg/internal/feed"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
 )
 
 func TestSchemaWriter_should_write_schema(t *testing.T) {
@@ -56,3 +59,99 @@ func TestSchemaWriter_should_write_all_schemas(t *testing.T) {
 
 	assert.NotNil(t, buf.String())
 }
+
+func TestParseSchemaFormat(t *testing.T) {
+	format, err := feed.ParseSchemaFormat("")
+	assert.NoError(t, err)
+	assert.Equal(t, feed.SchemaFormatTable, format)
+
+	format, err = feed.ParseSchemaFormat("dbt")
+	assert.NoError(t, err)
+	assert.Equal(t, feed.SchemaFormatDBT, format)
+
+	_, err = feed.ParseSchemaFormat("avro")
+	assert.Error(t, err)
+}
+
+func TestExporterFeedClient_WriteSchemas_should_write_ddl(t *testing.T) {
+	cfg := &exporterAPI.ExporterConfiguration{}
+	exporterApp := feed.NewExporterApp(nil, nil, cfg.ToExporterConfig())
+
+	for _, dialect := range []string{"mysql", "postgres", "sqlserver", "sqlite"} {
+		var buf bytes.Buffer
+		require.NoError(t, exporterApp.WriteSchemas(&buf, feed.SchemaFormatDDL, dialect), dialect)
+		assert.Contains(t, buf.String(), "CREATE TABLE", dialect)
+		assert.Contains(t, buf.String(), "-- inspections\n", dialect)
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, exporterApp.WriteSchemas(&buf, feed.SchemaFormatDDL, "postgres"))
+	assert.Contains(t, buf.String(), `CREATE TABLE "inspections"`)
+
+	assert.Error(t, exporterApp.WriteSchemas(&buf, feed.SchemaFormatDDL, "oracle"))
+}
+
+func TestExporterFeedClient_WriteSchemas_should_write_json_schema(t *testing.T) {
+	cfg := &exporterAPI.ExporterConfiguration{}
+	exporterApp := feed.NewExporterApp(nil, nil, cfg.ToExporterConfig())
+
+	var buf bytes.Buffer
+	require.NoError(t, exporterApp.WriteSchemas(&buf, feed.SchemaFormatJSONSchema, "sqlite"))
+
+	var schema struct {
+		Defs map[string]struct {
+			Required   []string                          `json:"required"`
+			Properties map[string]map[string]interface{} `json:"properties"`
+		} `json:"$defs"`
+	}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &schema))
+
+	inspections, ok := schema.Defs["inspections"]
+	require.True(t, ok)
+	assert.Equal(t, []string{"audit_id"}, inspections.Required)
+	assert.Equal(t, "string", inspections.Properties["audit_id"]["type"])
+	assert.Equal(t, "date-time", inspections.Properties["modified_at"]["format"])
+}
+
+func TestExporterFeedClient_WriteSchemas_should_write_dbt_sources(t *testing.T) {
+	cfg := &exporterAPI.ExporterConfiguration{}
+	exporterApp := feed.NewExporterApp(nil, nil, cfg.ToExporterConfig())
+
+	var buf bytes.Buffer
+	require.NoError(t, exporterApp.WriteSchemas(&buf, feed.SchemaFormatDBT, "postgres"))
+
+	var sources struct {
+		Version int `yaml:"version"`
+		Sources []struct {
+			Name   string `yaml:"name"`
+			Tables []struct {
+				Name    string `yaml:"name"`
+				Columns []struct {
+					Name        string                 `yaml:"name"`
+					Description string                 `yaml:"description"`
+					Meta        map[string]interface{} `yaml:"meta"`
+					Tests       []interface{}          `yaml:"tests"`
+				} `yaml:"columns"`
+			} `yaml:"tables"`
+		} `yaml:"sources"`
+	}
+	require.NoError(t, yaml.Unmarshal(buf.Bytes(), &sources))
+	assert.Equal(t, 2, sources.Version)
+	require.Len(t, sources.Sources, 1)
+	assert.Equal(t, "safetyculture", sources.Sources[0].Name)
+
+	for _, table := range sources.Sources[0].Tables {
+		if table.Name != "inspections" {
+			continue
+		}
+		for _, column := range table.Columns {
+			if column.Name == "template_id" {
+				assert.Equal(t, "ID of the template of the inspection", column.Description)
+				assert.Equal(t, map[string]interface{}{"table": "templates", "column": "template_id"}, column.Meta["references"])
+				assert.NotContains(t, fmt.Sprint(column.Tests), "relationships")
+				return
+			}
+		}
+	}
+	t.Fatal("missing template_id column of the inspections table")
+}
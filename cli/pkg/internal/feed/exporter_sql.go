@@ -11,6 +11,8 @@ import (
 	"github.com/SafetyCulture/safetyculture-exporter/pkg/logger"
 
 	"github.com/SafetyCulture/safetyculture-exporter/pkg/internal/events"
+	"github.com/SafetyCulture/safetyculture-exporter/pkg/internal/metrics"
+	"github.com/SafetyCulture/safetyculture-exporter/pkg/internal/objectstore"
 	"github.com/pkg/errors"
 	"go.uber.org/zap"
 	"gorm.io/driver/mysql"
@@ -27,8 +29,17 @@ type SQLExporter struct {
 	Logger          *zap.SugaredLogger
 	AutoMigrate     bool
 	ExportMediaPath string
-	duration        time.Duration
-	mu              sync.Mutex
+	// Mirror copies the exported files to an object storage, it is nil when none is configured
+	Mirror   *objectstore.Mirror
+	duration time.Duration
+	mu       sync.Mutex
+
+	stateOnce sync.Once
+	stateErr  error
+	runsOnce  sync.Once
+	runsErr   error
+	mediaOnce sync.Once
+	mediaErr  error
 }
 
 // DBConnection db connection
@@ -69,7 +80,7 @@ func (e *SQLExporter) InitFeed(feed Feed, opts *InitFeedOptions) error {
 	model := feed.Model()
 
 	if e.AutoMigrate {
-		err := e.DB.AutoMigrate(model)
+		err := e.DB.Table(feed.Name()).AutoMigrate(model)
 		if err != nil {
 			return events.NewEventError(err, events.ErrorSeverityError, events.ErrorSubSystemDB, true)
 		}
@@ -79,7 +90,7 @@ func (e *SQLExporter) InitFeed(feed Feed, opts *InitFeedOptions) error {
 		e.Logger.With(
 			"feed", feed.Name(),
 		).Info("truncating")
-		result := e.DB.Session(&gorm.Session{AllowGlobalUpdate: true}).Unscoped().Delete(model)
+		result := e.DB.Table(feed.Name()).Session(&gorm.Session{AllowGlobalUpdate: true}).Unscoped().Delete(model)
 		if result.Error != nil {
 			return events.NewEventError(result.Error, events.ErrorSeverityError, events.ErrorSubSystemDB, true)
 		}
@@ -93,6 +104,11 @@ func (e *SQLExporter) GetDuration() time.Duration {
 	return e.duration
 }
 
+// DeleteDeletedRows deletes the rows deleted in SafetyCulture, the time they were deleted at isn't kept
+func (e *SQLExporter) DeleteDeletedRows(feed Feed, deletedAt time.Time, query string, args ...interface{}) error {
+	return e.DeleteRowsIfExist(feed, query, args...)
+}
+
 // DeleteRowsIfExist will delete the rows if already exist
 func (e *SQLExporter) DeleteRowsIfExist(feed Feed, query string, args ...interface{}) error {
 	e.mu.Lock()
@@ -134,6 +150,7 @@ func (e *SQLExporter) WriteRows(feed Feed, rows interface{}) error {
 		}).
 		Create(rows)
 	e.duration = time.Since(start)
+	metrics.ObserveDBWrite(feed.Name(), e.duration)
 	if insert.Error != nil {
 		return events.NewEventErrorWithMessage(insert.Error, events.ErrorSeverityError, events.ErrorSubSystemDB, false, "unable to insert rows")
 	}
@@ -147,6 +164,7 @@ func (e *SQLExporter) UpdateRows(feed Feed, primaryKeys []string, element map[st
 	defer e.mu.Unlock()
 
 	result := e.DB.
+		Table(feed.Name()).
 		Model(feed.Model()).
 		Where(primaryKeys).
 		Updates(element)
@@ -234,14 +252,16 @@ func (e *SQLExporter) WriteMedia(auditID, mediaID, contentType string, body []by
 	if err != nil {
 		return events.NewEventErrorWithMessage(err, events.ErrorSeverityError, events.ErrorSubSystemFileOperations, false, fmt.Sprintf("open file %v", exportFilePath))
 	}
-	defer file.Close()
 
 	_, err = file.WriteAt(body, 0)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
 	if err != nil {
 		return events.NewEventErrorWithMessage(err, events.ErrorSeverityError, events.ErrorSubSystemFileOperations, false, "write media to file")
 	}
 
-	return nil
+	return e.uploadFile(exportFilePath)
 }
 
 // NewSQLExporter creates a new instance of the SQLExporter
@@ -0,0 +1,617 @@
+package feed
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/SafetyCulture/safetyculture-exporter/pkg/internal/events"
+	"github.com/SafetyCulture/safetyculture-exporter/pkg/logger"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	gormschema "gorm.io/gorm/schema"
+)
+
+// historyTableSuffix is appended to the name of a table to name the table keeping the versions of its rows
+const historyTableSuffix = "_history"
+
+// historyExporter appends the rows that changed to the history table of their feed, <feed>_history, on top of
+// writing them to the feed table. Each version of a row is valid from valid_from until valid_to, the current
+// version having no valid_to
+type historyExporter struct {
+	Exporter
+	sql    *SQLExporter
+	tables map[string]bool
+
+	mu    sync.Mutex
+	feeds map[string]*historyFeed
+}
+
+// newHistoryExporter wraps the exporter to keep the history of the given tables in the database of the SQL exporter.
+// The exporter is returned as is when no history is kept, only the SQL exporter supports it
+func newHistoryExporter(exporter Exporter, sqlExporter Exporter, tables []string) Exporter {
+	if len(tables) == 0 {
+		return exporter
+	}
+
+	e, ok := sqlExporter.(*SQLExporter)
+	if !ok {
+		logger.GetLogger().Warn("history tables are only supported when exporting to a database, no history will be kept")
+		return exporter
+	}
+
+	h := &historyExporter{
+		Exporter: exporter,
+		sql:      e,
+		tables:   map[string]bool{},
+		feeds:    map[string]*historyFeed{},
+	}
+	for _, table := range tables {
+		h.tables[table] = true
+	}
+	return h
+}
+
+// InitFeed initialises the feed and its history table. The history table is never truncated
+func (e *historyExporter) InitFeed(feed Feed, opts *InitFeedOptions) error {
+	if err := e.Exporter.InitFeed(feed, opts); err != nil {
+		return err
+	}
+
+	if history := e.feed(feed); history != nil {
+		return e.sql.InitFeed(history, &InitFeedOptions{})
+	}
+	return nil
+}
+
+// WriteRows writes the rows and appends the new versions of the rows to the history table of the feed
+func (e *historyExporter) WriteRows(feed Feed, rows interface{}) error {
+	history := e.feed(feed)
+	if history == nil {
+		return e.Exporter.WriteRows(feed, rows)
+	}
+
+	// the rows are hashed before they are written, the database sets the timestamps left empty such as created_at
+	hashes, err := history.hashes(rows)
+	if err != nil {
+		return err
+	}
+	if err := e.Exporter.WriteRows(feed, rows); err != nil {
+		return err
+	}
+	return e.sql.writeHistory(history, rows, hashes)
+}
+
+// UpdateRows updates the rows and appends their updated versions to the history table of the feed, such as the rows
+// soft deleted
+func (e *historyExporter) UpdateRows(feed Feed, primaryKeys []string, element map[string]interface{}) (int64, error) {
+	rowsUpdated, err := e.Exporter.UpdateRows(feed, primaryKeys, element)
+	if err != nil {
+		return rowsUpdated, err
+	}
+
+	if history := e.feed(feed); history != nil {
+		if err := e.sql.updateHistory(history, primaryKeys, element); err != nil {
+			return rowsUpdated, err
+		}
+	}
+	return rowsUpdated, nil
+}
+
+// DeleteRowsIfExist deletes the rows and closes their current version in the history table of the feed
+func (e *historyExporter) DeleteRowsIfExist(feed Feed, query string, args ...interface{}) error {
+	if err := e.Exporter.DeleteRowsIfExist(feed, query, args...); err != nil {
+		return err
+	}
+
+	if history := e.feed(feed); history != nil {
+		return e.sql.closeHistory(history, time.Now(), query, args...)
+	}
+	return nil
+}
+
+// DeleteDeletedRows deletes the rows and closes their current version in the history table of the feed at the time
+// they were deleted at
+func (e *historyExporter) DeleteDeletedRows(feed Feed, deletedAt time.Time, query string, args ...interface{}) error {
+	if err := e.Exporter.DeleteDeletedRows(feed, deletedAt, query, args...); err != nil {
+		return err
+	}
+
+	if history := e.feed(feed); history != nil {
+		return e.sql.closeHistory(history, deletedAt, query, args...)
+	}
+	return nil
+}
+
+func (e *historyExporter) feed(feed Feed) *historyFeed {
+	if !e.tables[feed.Name()] {
+		return nil
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if f, ok := e.feeds[feed.Name()]; ok {
+		return f
+	}
+	f := newHistoryFeed(feed)
+	e.feeds[feed.Name()] = f
+	return f
+}
+
+// historyFeed is the history table of a feed. Its model holds the fields of the feed model, followed by the validity
+// of the version and the hash of the row it was created from
+type historyFeed struct {
+	Feed
+	model   reflect.Type
+	columns []string
+	// keyFields are the fields of the feed model holding its primary key
+	keyFields []int
+	// modifiedAtField is the modified_at field of the feed model, -1 when there is none
+	modifiedAtField int
+	// hashedFields are the fields of the feed model a change is detected on, the timestamps set on write are left out
+	hashedFields []int
+}
+
+func newHistoryFeed(feed Feed) *historyFeed {
+	modelType := reflect.TypeOf(feed.Model())
+	f := &historyFeed{
+		Feed:            feed,
+		modifiedAtField: -1,
+	}
+
+	primaryKey := map[string]bool{}
+	for _, column := range feed.PrimaryKey() {
+		primaryKey[column] = true
+	}
+	keyIndex := fmt.Sprintf("idx_%s%s_key", feed.Name(), historyTableSuffix)
+
+	fields := []reflect.StructField{{
+		Name: "HistoryID",
+		Type: reflect.TypeOf(uint64(0)),
+		Tag:  `json:"history_id" csv:"history_id" gorm:"column:history_id;primaryKey;autoIncrement"`,
+	}}
+	f.columns = []string{"history_id"}
+	for i := 0; i < modelType.NumField(); i++ {
+		field := withoutGormSettings(modelType.Field(i), "PRIMARYKEY", "PRIMARY_KEY", "INDEX", "UNIQUEINDEX", "UNIQUE")
+
+		column := columnName(field)
+		switch {
+		case primaryKey[column]:
+			f.keyFields = append(f.keyFields, i)
+			field = withGormSetting(field, "index:"+keyIndex)
+		case column == "modified_at":
+			f.modifiedAtField = i
+		}
+
+		settings := gormschema.ParseTagSetting(field.Tag.Get("gorm"), ";")
+		_, autoCreate := settings["AUTOCREATETIME"]
+		_, autoUpdate := settings["AUTOUPDATETIME"]
+		if !autoCreate && !autoUpdate {
+			f.hashedFields = append(f.hashedFields, i)
+		}
+
+		f.columns = append(f.columns, column)
+		fields = append(fields, field)
+	}
+
+	fields = append(fields,
+		reflect.StructField{
+			Name: "ValidFrom",
+			Type: timeType,
+			Tag:  `json:"valid_from" csv:"valid_from" gorm:"column:valid_from"`,
+		},
+		reflect.StructField{
+			Name: "ValidTo",
+			Type: timePtrType,
+			Tag:  `json:"valid_to" csv:"valid_to" gorm:"column:valid_to"`,
+		},
+		reflect.StructField{
+			Name: "RowHash",
+			Type: reflect.TypeOf(""),
+			Tag:  `json:"row_hash" csv:"row_hash" gorm:"column:row_hash;size:64"`,
+		},
+	)
+	f.columns = append(f.columns, "valid_from", "valid_to", "row_hash")
+	f.model = reflect.StructOf(fields)
+	return f
+}
+
+// Name returns the name of the history table
+func (f *historyFeed) Name() string {
+	return f.Feed.Name() + historyTableSuffix
+}
+
+// Model returns the model of the history table
+func (f *historyFeed) Model() interface{} {
+	return reflect.New(f.model).Elem().Interface()
+}
+
+// RowsModel returns the model of the history table
+func (f *historyFeed) RowsModel() interface{} {
+	return reflect.New(reflect.SliceOf(reflect.PointerTo(f.model))).Interface()
+}
+
+// PrimaryKey returns the primary key of the history table
+func (f *historyFeed) PrimaryKey() []string {
+	return []string{"history_id"}
+}
+
+// Columns returns the columns of the history table
+func (f *historyFeed) Columns() []string {
+	return f.columns
+}
+
+// keyColumns returns the columns of the primary key of the feed
+func (f *historyFeed) keyColumns() []string {
+	return f.Feed.PrimaryKey()
+}
+
+// key returns the values of the primary key of the row
+func (f *historyFeed) key(row reflect.Value) []interface{} {
+	key := make([]interface{}, len(f.keyFields))
+	for i, field := range f.keyFields {
+		key[i] = row.Field(field).Interface()
+	}
+	return key
+}
+
+// hash returns the hash of the fields of the row a change is detected on
+func (f *historyFeed) hash(row reflect.Value) (string, error) {
+	values := make([]interface{}, len(f.hashedFields))
+	for i, field := range f.hashedFields {
+		value := row.Field(field).Interface()
+		// the hash doesn't depend on the time zone the timestamps are written in
+		switch v := value.(type) {
+		case time.Time:
+			value = v.UTC()
+		case *time.Time:
+			if v != nil {
+				value = v.UTC()
+			}
+		}
+		values[i] = value
+	}
+
+	b, err := json.Marshal(values)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// hashes returns the hashes of the rows, a pointer to a slice of feed models
+func (f *historyFeed) hashes(rows interface{}) ([]string, error) {
+	values := reflect.Indirect(reflect.ValueOf(rows))
+	if values.Kind() != reflect.Slice {
+		return nil, nil
+	}
+
+	modelType := reflect.TypeOf(f.Feed.Model())
+	hashes := make([]string, values.Len())
+	for i := range hashes {
+		row := reflect.Indirect(values.Index(i))
+		if !row.IsValid() || row.Type() != modelType {
+			return nil, events.NewEventErrorWithMessage(fmt.Errorf("unexpected row type %T", values.Index(i).Interface()),
+				events.ErrorSeverityError, events.ErrorSubSystemDataIntegrity, false, "unable to write history")
+		}
+
+		hash, err := f.hash(row)
+		if err != nil {
+			return nil, events.NewEventErrorWithMessage(err, events.ErrorSeverityError, events.ErrorSubSystemDataIntegrity, false, "unable to write history")
+		}
+		hashes[i] = hash
+	}
+	return hashes, nil
+}
+
+// validFrom returns the time the version of the row is valid from, when it was modified if known
+func (f *historyFeed) validFrom(row reflect.Value, now time.Time) time.Time {
+	if f.modifiedAtField == -1 {
+		return now
+	}
+
+	switch v := row.Field(f.modifiedAtField).Interface().(type) {
+	case time.Time:
+		if !v.IsZero() {
+			return v
+		}
+	case *time.Time:
+		if v != nil && !v.IsZero() {
+			return *v
+		}
+	}
+	return now
+}
+
+// version returns a new version of the row for the history table
+func (f *historyFeed) version(row reflect.Value, validFrom time.Time, hash string) reflect.Value {
+	version := reflect.New(f.model).Elem()
+	for i := 0; i < row.NumField(); i++ {
+		version.Field(i + 1).Set(row.Field(i))
+	}
+	version.FieldByName("ValidFrom").Set(reflect.ValueOf(validFrom))
+	version.FieldByName("RowHash").SetString(hash)
+	return version.Addr()
+}
+
+// row returns the row of the feed a version was created from
+func (f *historyFeed) row(version reflect.Value) reflect.Value {
+	row := reflect.New(reflect.TypeOf(f.Feed.Model())).Elem()
+	for i := 0; i < row.NumField(); i++ {
+		row.Field(i).Set(version.Field(i + 1))
+	}
+	return row
+}
+
+// update sets the columns of the row to the values of the element, the columns the row doesn't have are ignored
+func (f *historyFeed) update(row reflect.Value, element map[string]interface{}) {
+	for i, column := range f.columns[1 : row.NumField()+1] {
+		value, ok := element[column]
+		if !ok {
+			continue
+		}
+
+		field := row.Field(i)
+		v := reflect.ValueOf(value)
+		switch {
+		case value == nil:
+			field.Set(reflect.Zero(field.Type()))
+		case v.Type().AssignableTo(field.Type()):
+			field.Set(v)
+		case field.Kind() == reflect.Pointer && v.Type().AssignableTo(field.Type().Elem()):
+			ptr := reflect.New(field.Type().Elem())
+			ptr.Elem().Set(v)
+			field.Set(ptr)
+		case v.Type().ConvertibleTo(field.Type()):
+			field.Set(v.Convert(field.Type()))
+		}
+	}
+}
+
+// historyKey identifies a row of the feed across versions
+func historyKey(key []interface{}) string {
+	parts := make([]string, len(key))
+	for i, value := range key {
+		if b, ok := value.([]byte); ok {
+			value = string(b)
+		}
+		parts[i] = fmt.Sprint(value)
+	}
+	return strings.Join(parts, "\x00")
+}
+
+// writeHistory appends the rows that changed since their current version to the history table, closing the
+// versions they replace. hashes are the hashes of the rows
+func (e *SQLExporter) writeHistory(feed *historyFeed, rows interface{}, hashes []string) error {
+	values := reflect.Indirect(reflect.ValueOf(rows))
+	if values.Kind() != reflect.Slice || values.Len() == 0 {
+		return nil
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	keyColumns := feed.keyColumns()
+	keyCondition := func(key []interface{}) clause.Expression {
+		exprs := make([]clause.Expression, len(keyColumns))
+		for i, column := range keyColumns {
+			exprs[i] = clause.Eq{Column: clause.Column{Name: column}, Value: key[i]}
+		}
+		return clause.And(exprs...)
+	}
+
+	keys := make([][]interface{}, values.Len())
+	conditions := make([]clause.Expression, values.Len())
+	for i := 0; i < values.Len(); i++ {
+		keys[i] = feed.key(reflect.Indirect(values.Index(i)))
+		conditions[i] = keyCondition(keys[i])
+	}
+
+	// the hashes of the current versions of the rows
+	var current []map[string]interface{}
+	result := e.DB.Table(feed.Name()).
+		Select(append(append([]string{}, keyColumns...), "row_hash", "valid_from")).
+		Where("valid_to IS NULL").
+		Where(clause.Or(conditions...)).
+		Find(&current)
+	if result.Error != nil {
+		return events.NewEventErrorWithMessage(result.Error, events.ErrorSeverityError, events.ErrorSubSystemDB, false, "unable to read history")
+	}
+	currentHashes := map[string]string{}
+	currentValidFrom := map[string]time.Time{}
+	for _, version := range current {
+		key := make([]interface{}, len(keyColumns))
+		for i, column := range keyColumns {
+			key[i] = version[column]
+		}
+		currentHashes[historyKey(key)] = fmt.Sprint(version["row_hash"])
+		if validFrom, ok := version["valid_from"].(time.Time); ok {
+			currentValidFrom[historyKey(key)] = validFrom
+		}
+	}
+
+	type closedVersion struct {
+		key     []interface{}
+		validTo time.Time
+	}
+	var closed []closedVersion
+	pending := map[string]reflect.Value{}
+	versions := reflect.MakeSlice(reflect.SliceOf(reflect.PointerTo(feed.model)), 0, values.Len())
+
+	now := time.Now()
+	for i := 0; i < values.Len(); i++ {
+		row := reflect.Indirect(values.Index(i))
+		key, hash := historyKey(keys[i]), hashes[i]
+		validFrom := feed.validFrom(row, now)
+
+		if version, ok := pending[key]; ok {
+			// the row is written more than once in the batch, the version created for it is closed right away
+			if version.Elem().FieldByName("RowHash").String() == hash {
+				continue
+			}
+			validFrom = laterTime(validFrom, version.Elem().FieldByName("ValidFrom").Interface().(time.Time))
+			validTo := validFrom
+			version.Elem().FieldByName("ValidTo").Set(reflect.ValueOf(&validTo))
+		} else if currentHash, ok := currentHashes[key]; ok {
+			if currentHash == hash {
+				continue
+			}
+			// the version replacing the current one isn't valid from before it
+			validFrom = laterTime(validFrom, currentValidFrom[key])
+			closed = append(closed, closedVersion{key: keys[i], validTo: validFrom})
+		}
+
+		version := feed.version(row, validFrom, hash)
+		pending[key] = version
+		versions = reflect.Append(versions, version)
+	}
+
+	if versions.Len() == 0 {
+		return nil
+	}
+
+	batchSize := e.ParameterLimit() / len(feed.columns)
+	err := e.DB.Transaction(func(tx *gorm.DB) error {
+		for _, c := range closed {
+			err := tx.Table(feed.Name()).
+				Where("valid_to IS NULL").
+				Where(keyCondition(c.key)).
+				Update("valid_to", c.validTo).Error
+			if err != nil {
+				return err
+			}
+		}
+		return tx.Table(feed.Name()).CreateInBatches(versions.Interface(), batchSize).Error
+	})
+	if err != nil {
+		return events.NewEventErrorWithMessage(err, events.ErrorSeverityError, events.ErrorSubSystemDB, false, "unable to write history")
+	}
+
+	e.Logger.With(
+		"feed", feed.Name(),
+	).Debugf("%d versions written to history", versions.Len())
+	return nil
+}
+
+// updateHistory appends the versions of the rows updated with the element to the history table, closing their
+// current version. The versions are valid from the time the rows were deleted at when they are soft deleted, and never
+// from before the version they replace
+func (e *SQLExporter) updateHistory(feed *historyFeed, primaryKeys []string, element map[string]interface{}) error {
+	if len(primaryKeys) == 0 {
+		return nil
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	keyColumn := feed.keyColumns()[0]
+	current := reflect.New(reflect.SliceOf(reflect.PointerTo(feed.model)))
+	result := e.DB.Table(feed.Name()).
+		Where("valid_to IS NULL").
+		Where(fmt.Sprintf("%s IN ?", keyColumn), primaryKeys).
+		Find(current.Interface())
+	if result.Error != nil {
+		return events.NewEventErrorWithMessage(result.Error, events.ErrorSeverityError, events.ErrorSubSystemDB, false, "unable to read history")
+	}
+
+	updatedAt := time.Now()
+	if deletedAt, ok := element["deleted_at"].(time.Time); ok && !deletedAt.IsZero() {
+		updatedAt = deletedAt
+	}
+
+	type closedVersion struct {
+		historyID interface{}
+		validTo   time.Time
+	}
+	var closed []closedVersion
+	versions := reflect.MakeSlice(reflect.SliceOf(reflect.PointerTo(feed.model)), 0, current.Elem().Len())
+	for i := 0; i < current.Elem().Len(); i++ {
+		version := current.Elem().Index(i).Elem()
+		row := feed.row(version)
+		feed.update(row, element)
+
+		hash, err := feed.hash(row)
+		if err != nil {
+			return events.NewEventErrorWithMessage(err, events.ErrorSeverityError, events.ErrorSubSystemDataIntegrity, false, "unable to write history")
+		}
+		if hash == version.FieldByName("RowHash").String() {
+			continue
+		}
+
+		validFrom := laterTime(updatedAt, version.FieldByName("ValidFrom").Interface().(time.Time))
+		closed = append(closed, closedVersion{historyID: version.FieldByName("HistoryID").Interface(), validTo: validFrom})
+		versions = reflect.Append(versions, feed.version(row, validFrom, hash))
+	}
+
+	if versions.Len() == 0 {
+		return nil
+	}
+
+	batchSize := e.ParameterLimit() / len(feed.columns)
+	err := e.DB.Transaction(func(tx *gorm.DB) error {
+		for _, c := range closed {
+			err := tx.Table(feed.Name()).
+				Where("history_id = ?", c.historyID).
+				Update("valid_to", c.validTo).Error
+			if err != nil {
+				return err
+			}
+		}
+		return tx.Table(feed.Name()).CreateInBatches(versions.Interface(), batchSize).Error
+	})
+	if err != nil {
+		return events.NewEventErrorWithMessage(err, events.ErrorSeverityError, events.ErrorSubSystemDB, false, "unable to write history")
+	}
+	return nil
+}
+
+// closeHistory closes the current version of the rows matching the query at validTo, the rows being deleted. A version
+// is never closed before it is valid from
+func (e *SQLExporter) closeHistory(feed *historyFeed, validTo time.Time, query string, args ...interface{}) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	err := e.DB.Table(feed.Name()).
+		Where("valid_to IS NULL").
+		Where(clause.Expr{SQL: query, Vars: args}).
+		Update("valid_to", gorm.Expr("CASE WHEN valid_from > ? THEN valid_from ELSE ? END", validTo, validTo)).Error
+	if err != nil {
+		return events.NewEventErrorWithMessage(err, events.ErrorSeverityError, events.ErrorSubSystemDB, false, "unable to write history")
+	}
+	return nil
+}
+
+// laterTime returns the latest of two times
+func laterTime(a, b time.Time) time.Time {
+	if b.After(a) {
+		return b
+	}
+	return a
+}
+
+// withGormSetting returns a copy of the field with the gorm setting appended to its settings
+func withGormSetting(field reflect.StructField, setting string) reflect.StructField {
+	tag, ok := field.Tag.Lookup("gorm")
+	if !ok {
+		field.Tag = reflect.StructTag(strings.TrimSpace(fmt.Sprintf(`%s gorm:"%s"`, field.Tag, setting)))
+		return field
+	}
+
+	settings := setting
+	if tag != "" {
+		settings = tag + ";" + setting
+	}
+	field.Tag = reflect.StructTag(strings.Replace(string(field.Tag),
+		fmt.Sprintf(`gorm:"%s"`, tag),
+		fmt.Sprintf(`gorm:"%s"`, settings),
+		1,
+	))
+	return field
+}
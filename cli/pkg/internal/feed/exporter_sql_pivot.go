@@ -0,0 +1,257 @@
+package feed
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/SafetyCulture/safetyculture-exporter/pkg/httpapi"
+	"github.com/SafetyCulture/safetyculture-exporter/pkg/internal/events"
+	"github.com/SafetyCulture/safetyculture-exporter/pkg/logger"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// pivotTablePrefix is prepended to the ID of a template to name its pivot table
+const pivotTablePrefix = "inspection_pivot_"
+
+// pivotColumnMaxLength keeps the names of the question columns within the identifier limits of every dialect
+const pivotColumnMaxLength = 48
+
+// pivotSkippedItemTypes are the types of the items holding no response
+var pivotSkippedItemTypes = []string{"section", "category"}
+
+var pivotIdentifierRegexp = regexp.MustCompile(`[^a-z0-9]+`)
+
+// pivotFeed is the pivot table of the inspection items of a template, one row per inspection and one column per
+// question. The columns are named after the labels of the latest version of the questions
+type pivotFeed struct {
+	templateID string
+	model      reflect.Type
+	columns    []pivotColumn
+}
+
+// pivotColumn is the column holding the responses of a question
+type pivotColumn struct {
+	itemID string
+	name   string
+}
+
+// pivotItem is a question of a template, as last exported
+type pivotItem struct {
+	ItemID    string
+	Label     string
+	ItemIndex int64
+}
+
+func newPivotFeed(templateID string, items []pivotItem) *pivotFeed {
+	f := &pivotFeed{templateID: templateID}
+
+	fields := []reflect.StructField{
+		{
+			Name: "AuditID",
+			Type: reflect.TypeOf(""),
+			Tag:  `json:"audit_id" csv:"audit_id" gorm:"primarykey;column:audit_id;size:100"`,
+		},
+		{
+			Name: "OrganisationID",
+			Type: reflect.TypeOf(""),
+			Tag:  `json:"organisation_id" csv:"organisation_id" gorm:"column:organisation_id;size:37"`,
+		},
+		{
+			Name: "ModifiedAt",
+			Type: reflect.TypeOf(time.Time{}),
+			Tag:  `json:"modified_at" csv:"modified_at" gorm:"column:modified_at"`,
+		},
+	}
+
+	used := map[string]bool{"audit_id": true, "organisation_id": true, "modified_at": true}
+	for i, item := range items {
+		base := pivotColumnName(item.Label)
+		name := base
+		for n := 2; used[name]; n++ {
+			name = fmt.Sprintf("%s_%d", base, n)
+		}
+		used[name] = true
+
+		f.columns = append(f.columns, pivotColumn{itemID: item.ItemID, name: name})
+		fields = append(fields, reflect.StructField{
+			Name: fmt.Sprintf("Item%d", i),
+			Type: reflect.TypeOf((*string)(nil)),
+			Tag:  reflect.StructTag(fmt.Sprintf(`json:"%[1]s" csv:"%[1]s" gorm:"column:%[1]s"`, name)),
+		})
+	}
+	f.model = reflect.StructOf(fields)
+	return f
+}
+
+// pivotColumnName returns the name of the column of a question label, in snake case
+func pivotColumnName(label string) string {
+	name := strings.Trim(pivotIdentifierRegexp.ReplaceAllString(strings.ToLower(label), "_"), "_")
+	if len(name) > pivotColumnMaxLength {
+		name = strings.TrimRight(name[:pivotColumnMaxLength], "_")
+	}
+	switch {
+	case name == "":
+		name = "item"
+	case name[0] >= '0' && name[0] <= '9':
+		name = "item_" + name
+	}
+	return name
+}
+
+// pivotTableName returns the name of the pivot table of a template
+func pivotTableName(templateID string) string {
+	return pivotTablePrefix + strings.Trim(pivotIdentifierRegexp.ReplaceAllString(strings.ToLower(templateID), "_"), "_")
+}
+
+// Name is the name of the pivot table
+func (f *pivotFeed) Name() string {
+	return pivotTableName(f.templateID)
+}
+
+// HasRemainingInformation returns false, the pivot table is built from the exported inspection items
+func (f *pivotFeed) HasRemainingInformation() bool {
+	return false
+}
+
+// Model returns the model of the pivot table
+func (f *pivotFeed) Model() interface{} {
+	return reflect.New(f.model).Elem().Interface()
+}
+
+// RowsModel returns the model of the pivot table rows
+func (f *pivotFeed) RowsModel() interface{} {
+	return reflect.New(reflect.SliceOf(reflect.PointerTo(f.model))).Interface()
+}
+
+// PrimaryKey returns the primary key of the pivot table
+func (f *pivotFeed) PrimaryKey() []string {
+	return []string{"audit_id"}
+}
+
+// Columns returns the columns of the pivot table
+func (f *pivotFeed) Columns() []string {
+	columns := []string{"organisation_id", "modified_at"}
+	for _, column := range f.columns {
+		columns = append(columns, column.name)
+	}
+	return columns
+}
+
+// Order returns the order of the rows of the pivot table
+func (f *pivotFeed) Order() string {
+	return "audit_id"
+}
+
+// CreateSchema creates the schema of the pivot table for the supplied exporter
+func (f *pivotFeed) CreateSchema(exporter Exporter) error {
+	return exporter.CreateSchema(f, f.RowsModel())
+}
+
+// Export writes out the pivot table built in the database of the exporter, nothing is fetched from the API
+func (f *pivotFeed) Export(_ context.Context, _ *httpapi.Client, exporter Exporter, _ string) error {
+	return exporter.FinaliseExport(f, f.RowsModel())
+}
+
+// pivotTableWriter is implemented by the exporters keeping the inspection items in a database
+type pivotTableWriter interface {
+	writePivotTable(templateID string) (*pivotFeed, error)
+}
+
+// writePivotTable creates or refreshes the pivot table of the inspection items of a template. The table is created
+// again on each refresh, so that the questions added or renamed in new versions of the template are picked up
+func (e *SQLExporter) writePivotTable(templateID string) (*pivotFeed, error) {
+	itemsFeed := &InspectionItemFeed{}
+	if !e.DB.Migrator().HasTable(itemsFeed.Name()) {
+		return nil, fmt.Errorf("pivot of template %s: the inspection items weren't exported", templateID)
+	}
+
+	var items []pivotItem
+	err := e.DB.Raw(`SELECT i.item_id AS item_id, MAX(i.label) AS label, MIN(i.item_index) AS item_index
+FROM ? i
+JOIN (SELECT item_id, MAX(modified_at) AS modified_at FROM ? WHERE template_id = ? GROUP BY item_id) latest
+ON latest.item_id = i.item_id AND latest.modified_at = i.modified_at
+WHERE i.template_id = ? AND i.type NOT IN ?
+GROUP BY i.item_id
+ORDER BY item_index, item_id`,
+		clause.Table{Name: itemsFeed.Name()}, clause.Table{Name: itemsFeed.Name()}, templateID, templateID, pivotSkippedItemTypes,
+	).Scan(&items).Error
+	if err != nil {
+		return nil, events.NewEventErrorWithMessage(err, events.ErrorSeverityError, events.ErrorSubSystemDB, false, "unable to read the questions of the template")
+	}
+
+	pivot := newPivotFeed(templateID, items)
+	if params := len(pivot.columns) + len(pivotSkippedItemTypes) + 1; params > e.ParameterLimit() {
+		return nil, fmt.Errorf("pivot of template %s: %d questions exceed the parameters supported by the database", templateID, len(pivot.columns))
+	}
+
+	columns := []interface{}{clause.Column{Name: "audit_id"}, clause.Column{Name: "organisation_id"}, clause.Column{Name: "modified_at"}}
+	var responses strings.Builder
+	var vars []interface{}
+	for _, column := range pivot.columns {
+		columns = append(columns, clause.Column{Name: column.name})
+		responses.WriteString(", MAX(CASE WHEN item_id = ? THEN response END)")
+		vars = append(vars, column.itemID)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.Logger.With(
+		"feed", pivot.Name(),
+		"columns", len(pivot.columns),
+	).Info("refreshing pivot table")
+
+	err = e.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Migrator().DropTable(pivot.Name()); err != nil {
+			return err
+		}
+		if err := tx.Table(pivot.Name()).Migrator().CreateTable(pivot.Model()); err != nil {
+			return err
+		}
+
+		query := fmt.Sprintf("INSERT INTO ? ? SELECT audit_id, MAX(organisation_id), MAX(modified_at)%s FROM ? WHERE template_id = ? AND type NOT IN ? GROUP BY audit_id", responses.String())
+		args := append([]interface{}{clause.Table{Name: pivot.Name()}, columns}, vars...)
+		args = append(args, clause.Table{Name: itemsFeed.Name()}, templateID, pivotSkippedItemTypes)
+		return tx.Exec(query, args...).Error
+	})
+	if err != nil {
+		return nil, events.NewEventErrorWithMessage(err, events.ErrorSeverityError, events.ErrorSubSystemDB, false, "unable to refresh pivot table")
+	}
+	return pivot, nil
+}
+
+// exportPivotTables refreshes the pivot tables of the configured templates once the inspection items are exported,
+// and writes them out in the format of the exporter
+func (e *ExporterFeedClient) exportPivotTables(ctx context.Context, exporter Exporter) {
+	if len(e.configuration.ExportPivotTemplateIds) == 0 {
+		return
+	}
+
+	log := logger.GetLogger()
+	writer, ok := exporter.(pivotTableWriter)
+	if _, streaming := exporter.(*StreamingCSVExporter); !ok || streaming {
+		log.Warn("pivot tables are only supported when the inspection items are exported to a database, no pivot table will be written")
+		return
+	}
+
+	status := GetExporterStatus()
+	for _, templateID := range e.configuration.ExportPivotTemplateIds {
+		pivot, err := writer.writePivotTable(templateID)
+		if err != nil {
+			e.addError(err)
+			continue
+		}
+
+		status.StartFeedExport(pivot.Name(), pivot.HasRemainingInformation())
+		err = pivot.Export(ctx, nil, exporter, "")
+		if err != nil {
+			e.addError(events.WrapEventError(err, "write pivot table"))
+		}
+		status.FinishFeedExport(pivot.Name(), err)
+	}
+}
@@ -0,0 +1,38 @@
+package feed
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPivotColumnName(t *testing.T) {
+	tests := map[string]struct {
+		label    string
+		expected string
+	}{
+		"snake case":       {label: "Client / Site", expected: "client_site"},
+		"leading digit":    {label: "1. Is the area clean?", expected: "item_1_is_the_area_clean"},
+		"no letters":       {label: "???", expected: "item"},
+		"truncated":        {label: strings.Repeat("long label ", 10), expected: "long_label_long_label_long_label_long_label_long"},
+		"accented letters": {label: "Préparé par", expected: "pr_par_par"},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, pivotColumnName(tt.label))
+		})
+	}
+}
+
+func TestNewPivotFeed_should_dedupe_column_names(t *testing.T) {
+	pivot := newPivotFeed("template_1", []pivotItem{
+		{ItemID: "item_1", Label: "Comment"},
+		{ItemID: "item_2", Label: "Comment"},
+		{ItemID: "item_3", Label: "Audit ID"},
+	})
+
+	assert.Equal(t, "inspection_pivot_template_1", pivot.Name())
+	assert.Equal(t, []string{"organisation_id", "modified_at", "comment", "comment_2", "audit_id_2"}, pivot.Columns())
+}
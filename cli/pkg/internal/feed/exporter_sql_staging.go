@@ -0,0 +1,343 @@
+package feed
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/SafetyCulture/safetyculture-exporter/pkg/internal/events"
+	"github.com/SafetyCulture/safetyculture-exporter/pkg/logger"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// stagingTableSuffix is appended to the name of a table to name the table a full refresh is loaded into
+	stagingTableSuffix = "__staging"
+	// replacedTableSuffix names the table being replaced while swapping in a staging table on MySQL and Postgres
+	replacedTableSuffix = "__replaced"
+)
+
+// stagingFeed is a feed written to its staging table
+type stagingFeed struct {
+	Feed
+}
+
+// Name returns the name of the staging table
+func (f *stagingFeed) Name() string {
+	return f.Feed.Name() + stagingTableSuffix
+}
+
+// stagingExporter loads the feeds refreshed in full into staging tables. A staging table replaces the live table
+// only once its feed is exported, the live table is left untouched until then
+type stagingExporter struct {
+	Exporter
+	sql *SQLExporter
+
+	mu     sync.Mutex
+	staged map[string]*stagingFeed
+}
+
+// newStagingExporter wraps the exporter to load the feeds refreshed in full into staging tables when enabled.
+// Only the SQL exporter supports it, other exporters are left as is
+func newStagingExporter(exporter Exporter, enabled bool) *stagingExporter {
+	e := &stagingExporter{
+		Exporter: exporter,
+		staged:   map[string]*stagingFeed{},
+	}
+
+	sqlExporter, ok := exporter.(*SQLExporter)
+	switch {
+	case !enabled:
+	case !ok:
+		logger.GetLogger().Warn("atomic refresh is only supported when exporting to a database, tables will be truncated")
+	case !sqlExporter.AutoMigrate:
+		logger.GetLogger().Warn("atomic refresh requires the database auto migrations, tables will be truncated")
+	default:
+		e.sql = sqlExporter
+	}
+	return e
+}
+
+// InitFeed creates the staging table of the feed instead of truncating it
+func (e *stagingExporter) InitFeed(feed Feed, opts *InitFeedOptions) error {
+	if e.sql == nil {
+		return e.Exporter.InitFeed(feed, opts)
+	}
+
+	staging := &stagingFeed{Feed: feed}
+	switch {
+	case opts.Truncate:
+		if err := e.sql.createStagingTable(staging); err != nil {
+			return err
+		}
+	case opts.Resume && e.sql.DB.Migrator().HasTable(staging.Name()):
+		// the rows loaded before the interruption are kept in the staging table
+	default:
+		return e.Exporter.InitFeed(feed, opts)
+	}
+
+	e.mu.Lock()
+	e.staged[feed.Name()] = staging
+	e.mu.Unlock()
+	return nil
+}
+
+// WriteRows writes the rows to the staging table of the feed, if any
+func (e *stagingExporter) WriteRows(feed Feed, rows interface{}) error {
+	return e.Exporter.WriteRows(e.feed(feed), rows)
+}
+
+// UpdateRows updates the rows of the staging table of the feed, if any
+func (e *stagingExporter) UpdateRows(feed Feed, primaryKeys []string, element map[string]interface{}) (int64, error) {
+	return e.Exporter.UpdateRows(e.feed(feed), primaryKeys, element)
+}
+
+// DeleteRowsIfExist deletes the rows of the staging table of the feed, if any
+func (e *stagingExporter) DeleteRowsIfExist(feed Feed, query string, args ...interface{}) error {
+	return e.Exporter.DeleteRowsIfExist(e.feed(feed), query, args...)
+}
+
+// DeleteDeletedRows deletes the rows of the staging table of the feed deleted in SafetyCulture, if any
+func (e *stagingExporter) DeleteDeletedRows(feed Feed, deletedAt time.Time, query string, args ...interface{}) error {
+	return e.Exporter.DeleteDeletedRows(e.feed(feed), deletedAt, query, args...)
+}
+
+// LastModifiedAt returns the latest modified at date of the staging table of the feed, if any
+func (e *stagingExporter) LastModifiedAt(feed Feed, modifiedAfter time.Time, orgID string) (time.Time, error) {
+	return e.Exporter.LastModifiedAt(e.feed(feed), modifiedAfter, orgID)
+}
+
+// LastRecord returns the latest record of the staging table of the feed, if any
+func (e *stagingExporter) LastRecord(feed Feed, fallbackTime time.Time, orgID string, sortColumn string) time.Time {
+	return e.Exporter.LastRecord(e.feed(feed), fallbackTime, orgID, sortColumn)
+}
+
+// FinaliseExport closes out the export of the staging table of the feed, if any
+func (e *stagingExporter) FinaliseExport(feed Feed, rows interface{}) error {
+	return e.Exporter.FinaliseExport(e.feed(feed), rows)
+}
+
+// finishFeed swaps in the staging table of the feed once exported. The staging table is kept when the export failed
+// so that it can be resumed, the live table keeps the previous data
+func (e *stagingExporter) finishFeed(feed Feed, exportErr error) error {
+	e.mu.Lock()
+	staging, ok := e.staged[feed.Name()]
+	delete(e.staged, feed.Name())
+	e.mu.Unlock()
+
+	if !ok || exportErr != nil {
+		return exportErr
+	}
+	return e.sql.swapStagingTable(staging)
+}
+
+func (e *stagingExporter) feed(feed Feed) Feed {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if staging, ok := e.staged[feed.Name()]; ok {
+		return staging
+	}
+	return feed
+}
+
+// createStagingTable creates an empty staging table for the feed. The indexes are created once the table is swapped in,
+// index names being unique per database with SQLite and Postgres
+func (e *SQLExporter) createStagingTable(staging *stagingFeed) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.Logger.With(
+		"feed", staging.Feed.Name(),
+	).Info("creating staging table")
+
+	if err := e.DB.Migrator().DropTable(staging.Name()); err != nil {
+		return events.NewEventErrorWithMessage(err, events.ErrorSeverityError, events.ErrorSubSystemDB, true, "unable to drop staging table")
+	}
+	if err := e.DB.Table(staging.Name()).AutoMigrate(withoutIndexes(staging.Model())); err != nil {
+		return events.NewEventErrorWithMessage(err, events.ErrorSeverityError, events.ErrorSubSystemDB, true, "unable to create staging table")
+	}
+	return nil
+}
+
+// swapStagingTable replaces the live table of the feed with its staging table
+func (e *SQLExporter) swapStagingTable(staging *stagingFeed) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	live := staging.Feed.Name()
+	e.Logger.With(
+		"feed", live,
+	).Info("swapping in staging table")
+
+	var err error
+	switch e.DB.Dialector.Name() {
+	case "mysql":
+		err = e.swapMySQLStagingTable(staging)
+	case "postgres":
+		err = e.swapPostgresStagingTable(staging)
+	default:
+		err = e.DB.Transaction(func(tx *gorm.DB) error {
+			if tx.Dialector.Name() == "sqlite" {
+				// views are bound to the live table by name, SQLite would otherwise refuse to rename the staging table
+				// while they refer to a table that no longer exists
+				if err := tx.Exec("PRAGMA legacy_alter_table = ON").Error; err != nil {
+					return err
+				}
+				defer tx.Exec("PRAGMA legacy_alter_table = OFF")
+			}
+			if err := tx.Migrator().DropTable(live); err != nil {
+				return err
+			}
+			if err := tx.Migrator().RenameTable(staging.Name(), live); err != nil {
+				return err
+			}
+			return tx.Table(live).AutoMigrate(staging.Model())
+		})
+	}
+
+	if err != nil {
+		return events.NewEventErrorWithMessage(err, events.ErrorSeverityError, events.ErrorSubSystemDB, false, "unable to swap in staging table")
+	}
+	return nil
+}
+
+// swapPostgresStagingTable swaps the tables in a transaction. Views are bound to the table they were created for rather
+// than its name, they would be dropped along with the live table so the rows of the staging table are copied into the
+// live table instead when there are some
+func (e *SQLExporter) swapPostgresStagingTable(staging *stagingFeed) error {
+	live, replaced := staging.Feed.Name(), staging.Feed.Name()+replacedTableSuffix
+
+	var views []string
+	err := e.DB.Raw(`SELECT DISTINCT dependent.relname FROM pg_depend
+		JOIN pg_rewrite ON pg_rewrite.oid = pg_depend.objid
+		JOIN pg_class dependent ON dependent.oid = pg_rewrite.ev_class
+		WHERE pg_depend.refobjid = to_regclass(?) AND dependent.oid <> pg_depend.refobjid
+		ORDER BY dependent.relname`, live).Scan(&views).Error
+	if err != nil {
+		return err
+	}
+	if len(views) > 0 {
+		return e.copyStagingTable(staging)
+	}
+
+	return e.DB.Transaction(func(tx *gorm.DB) error {
+		// DropTable cascades to the objects depending on the table, the tables are dropped without it
+		if err := tx.Exec("DROP TABLE IF EXISTS ?", clause.Table{Name: replaced}).Error; err != nil {
+			return err
+		}
+		if tx.Migrator().HasTable(live) {
+			if err := tx.Migrator().RenameTable(live, replaced); err != nil {
+				return err
+			}
+		}
+		if err := tx.Migrator().RenameTable(staging.Name(), live); err != nil {
+			return err
+		}
+		if err := tx.Exec("DROP TABLE IF EXISTS ?", clause.Table{Name: replaced}).Error; err != nil {
+			return err
+		}
+
+		// the primary key index is named after the table it was created for
+		err := tx.Exec("ALTER INDEX IF EXISTS ? RENAME TO ?",
+			clause.Table{Name: staging.Name() + "_pkey"},
+			clause.Table{Name: live + "_pkey"},
+		).Error
+		if err != nil {
+			return err
+		}
+		return tx.Table(live).AutoMigrate(staging.Model())
+	})
+}
+
+// copyStagingTable replaces the rows of the live table with the rows of the staging table in a transaction, the objects
+// depending on the live table are kept
+func (e *SQLExporter) copyStagingTable(staging *stagingFeed) error {
+	live := staging.Feed.Name()
+
+	return e.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Table(live).AutoMigrate(staging.Model()); err != nil {
+			return err
+		}
+
+		columnTypes, err := tx.Migrator().ColumnTypes(staging.Name())
+		if err != nil {
+			return err
+		}
+		columns := make([]string, 0, len(columnTypes))
+		for _, columnType := range columnTypes {
+			columns = append(columns, tx.Statement.Quote(columnType.Name()))
+		}
+		columnList := strings.Join(columns, ", ")
+
+		if err := tx.Exec("TRUNCATE TABLE ?", clause.Table{Name: live}).Error; err != nil {
+			return err
+		}
+		err = tx.Exec(fmt.Sprintf("INSERT INTO ? (%s) SELECT %s FROM ?", columnList, columnList),
+			clause.Table{Name: live}, clause.Table{Name: staging.Name()},
+		).Error
+		if err != nil {
+			return err
+		}
+		return tx.Exec("DROP TABLE ?", clause.Table{Name: staging.Name()}).Error
+	})
+}
+
+// swapMySQLStagingTable swaps the tables with a single RENAME TABLE statement, MySQL commits DDL statements implicitly
+// so they can't be grouped in a transaction
+func (e *SQLExporter) swapMySQLStagingTable(staging *stagingFeed) error {
+	live, replaced := staging.Feed.Name(), staging.Feed.Name()+replacedTableSuffix
+
+	if err := e.DB.Migrator().DropTable(replaced); err != nil {
+		return err
+	}
+
+	if e.DB.Migrator().HasTable(live) {
+		err := e.DB.Exec("RENAME TABLE ? TO ?, ? TO ?",
+			clause.Table{Name: live}, clause.Table{Name: replaced},
+			clause.Table{Name: staging.Name()}, clause.Table{Name: live},
+		).Error
+		if err != nil {
+			return err
+		}
+		if err := e.DB.Migrator().DropTable(replaced); err != nil {
+			return err
+		}
+	} else if err := e.DB.Migrator().RenameTable(staging.Name(), live); err != nil {
+		return err
+	}
+
+	return e.DB.Table(live).AutoMigrate(staging.Model())
+}
+
+// withoutIndexes returns a copy of the model without the index settings of its fields
+func withoutIndexes(model interface{}) interface{} {
+	modelType := reflect.TypeOf(model)
+	fields := make([]reflect.StructField, modelType.NumField())
+	for i := range fields {
+		fields[i] = withoutGormSettings(modelType.Field(i), "INDEX", "UNIQUEINDEX")
+	}
+	return reflect.New(reflect.StructOf(fields)).Elem().Interface()
+}
+
+// withoutGormSettings returns a copy of the field without the given gorm settings, such as INDEX
+func withoutGormSettings(field reflect.StructField, keys ...string) reflect.StructField {
+	tag := field.Tag.Get("gorm")
+	var settings []string
+	for _, setting := range strings.Split(tag, ";") {
+		key := strings.ToUpper(strings.TrimSpace(strings.SplitN(setting, ":", 2)[0]))
+		if !slices.Contains(keys, key) {
+			settings = append(settings, setting)
+		}
+	}
+	field.Tag = reflect.StructTag(strings.Replace(string(field.Tag),
+		fmt.Sprintf(`gorm:"%s"`, tag),
+		fmt.Sprintf(`gorm:"%s"`, strings.Join(settings, ";")),
+		1,
+	))
+	return field
+}
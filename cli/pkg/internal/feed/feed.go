@@ -29,6 +29,8 @@ type Feed interface {
 // InitFeedOptions contains the options used when initialising a feed
 type InitFeedOptions struct {
 	Truncate bool
+	// Resume is set instead of Truncate when the feed resumes an interrupted export, the rows already written are kept
+	Resume bool
 }
 
 // Exporter is an interface to a Feed exporter. It provides methods to write rows out to an implemented format
@@ -44,6 +46,8 @@ type Exporter interface {
 	LastRecord(feed Feed, fallbackTime time.Time, orgID string, sortColumn string) time.Time
 	WriteMedia(auditID string, mediaID string, contentType string, body []byte) error
 	DeleteRowsIfExist(feed Feed, query string, args ...interface{}) error
+	// DeleteDeletedRows deletes the rows deleted in SafetyCulture at deletedAt
+	DeleteDeletedRows(feed Feed, deletedAt time.Time, query string, args ...interface{}) error
 	GetDuration() time.Duration
 
 	SupportsUpsert() bool
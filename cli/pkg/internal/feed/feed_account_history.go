@@ -18,15 +18,15 @@ const accountHistorySortingColumn = "event_at"
 
 // AccountHistory represents a row from the account history feed
 type AccountHistory struct {
-	ID             string    `json:"id" csv:"event_id" gorm:"primarykey;column:event_id;size:41"`
-	EventAt        time.Time `json:"event_at" csv:"event_at" gorm:"autoUpdateTime"`
-	Type           string    `json:"type" csv:"type"`
-	UserID         string    `json:"user_id" csv:"user_id" gorm:"size:37"`
-	OrganisationID string    `json:"organisation_id" csv:"organisation_id" gorm:"size:37"`
-	ClientClass    string    `json:"client_class" csv:"client_class" gorm:"client_class"`
-	Agent          string    `json:"agent" csv:"agent" gorm:"agent"`
-	Initiator      string    `json:"initiator" csv:"initiator" gorm:"size:100"`
-	ExportedAt     time.Time `json:"exported_at" csv:"exported_at" gorm:"autoUpdateTime"`
+	ID             string    `json:"id" csv:"event_id" gorm:"primarykey;column:event_id;size:41" description:"ID of the event"`
+	EventAt        time.Time `json:"event_at" csv:"event_at" gorm:"autoUpdateTime" description:"Time the event happened"`
+	Type           string    `json:"type" csv:"type" description:"Type of the event, such as a login or a password change"`
+	UserID         string    `json:"user_id" csv:"user_id" gorm:"size:37" description:"ID of the user the event is about"`
+	OrganisationID string    `json:"organisation_id" csv:"organisation_id" gorm:"size:37" description:"ID of the organisation the row belongs to"`
+	ClientClass    string    `json:"client_class" csv:"client_class" gorm:"client_class" description:"Class of the client the event came from"`
+	Agent          string    `json:"agent" csv:"agent" gorm:"agent" description:"User agent of the client the event came from"`
+	Initiator      string    `json:"initiator" csv:"initiator" gorm:"size:100" description:"Initiator of the event"`
+	ExportedAt     time.Time `json:"exported_at" csv:"exported_at" gorm:"autoUpdateTime" description:"Time the row was last written by the exporter"`
 }
 
 // AccountHistoryFeed is a representation of the account history feed
@@ -131,11 +131,11 @@ func (f *AccountHistoryFeed) Export(ctx context.Context, apiClient *httpapi.Clie
 			}
 		}
 
-		status.IncrementStatus(f.Name(), int64(numRows), apiClient.Duration.Milliseconds())
+		status.IncrementStatus(f.Name(), int64(numRows), apiClient.Duration().Milliseconds())
 
 		l.With(
 			"downloaded", status.ReadCounter(f.Name()),
-			"duration_ms", apiClient.Duration.Milliseconds(),
+			"duration_ms", apiClient.Duration().Milliseconds(),
 			"export_duration_ms", exporter.GetDuration().Milliseconds(),
 		).Info("export batch complete")
 		return nil
@@ -4,7 +4,6 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"strings"
 	"time"
 
 	"github.com/SafetyCulture/safetyculture-exporter/pkg/internal/util"
@@ -16,29 +15,33 @@ import (
 
 // Action represents a row from the actions feed
 type Action struct {
-	ID              string     `json:"id" csv:"action_id" gorm:"primarykey;column:action_id;size:36"`
-	Title           string     `json:"title" csv:"title"`
-	Description     string     `json:"description" csv:"description"`
-	SiteID          string     `json:"site_id" csv:"site_id" gorm:"size:41"`
-	Priority        string     `json:"priority" csv:"priority" gorm:"size:20"`
-	Status          string     `json:"status" csv:"status" gorm:"size:20"`
-	DueDate         time.Time  `json:"due_date" csv:"due_date"`
-	CreatedAt       time.Time  `json:"created_at" csv:"created_at"`
-	ModifiedAt      time.Time  `json:"modified_at" csv:"modified_at" gorm:"index:idx_act_modified_at,sort:desc"`
-	ExportedAt      time.Time  `json:"exported_at" csv:"exported_at" gorm:"index:idx_act_modified_at;autoUpdateTime"`
-	CreatorUserID   string     `json:"creator_user_id" csv:"creator_user_id" gorm:"size:37"`
-	CreatorUserName string     `json:"creator_user_name" csv:"creator_user_name"`
-	TemplateID      string     `json:"template_id" csv:"template_id" gorm:"size:100"`
-	AuditID         string     `json:"audit_id" csv:"audit_id" gorm:"size:100"`
-	AuditTitle      string     `json:"audit_title" csv:"audit_title"`
-	AuditItemID     string     `json:"audit_item_id" csv:"audit_item_id" gorm:"size:100"`
-	AuditItemLabel  string     `json:"audit_item_label" csv:"audit_item_label"`
-	OrganisationID  string     `json:"organisation_id" csv:"organisation_id" gorm:"index:idx_act_modified_at;size:37"`
-	CompletedAt     *time.Time `json:"completed_at" csv:"completed_at"`
-	ActionLabel     string     `json:"action_label" csv:"action_label"`
-	Deleted         bool       `json:"deleted" csv:"deleted"`
-	AssetID         string     `json:"asset_id" csv:"asset_id" gorm:"size:36"`
-	UniqueID        string     `json:"unique_id" csv:"unique_id"`
+	ID              string     `json:"id" csv:"action_id" gorm:"primarykey;column:action_id;size:36" description:"ID of the action"`
+	Title           string     `json:"title" csv:"title" description:"Title of the action"`
+	Description     string     `json:"description" csv:"description" description:"Description of the action"`
+	SiteID          string     `json:"site_id" csv:"site_id" gorm:"size:41" description:"ID of the site of the action"`
+	Priority        string     `json:"priority" csv:"priority" gorm:"size:20" description:"Priority of the action"`
+	Status          string     `json:"status" csv:"status" gorm:"size:20" description:"Status of the action"`
+	DueDate         time.Time  `json:"due_date" csv:"due_date" description:"Time the action is due"`
+	CreatedAt       time.Time  `json:"created_at" csv:"created_at" description:"Time the action was created"`
+	ModifiedAt      time.Time  `json:"modified_at" csv:"modified_at" gorm:"index:idx_act_modified_at,sort:desc" description:"Time the action was last modified"`
+	ExportedAt      time.Time  `json:"exported_at" csv:"exported_at" gorm:"index:idx_act_modified_at;autoUpdateTime" description:"Time the row was last written by the exporter"`
+	CreatorUserID   string     `json:"creator_user_id" csv:"creator_user_id" gorm:"size:37" description:"ID of the user who created the action"`
+	CreatorUserName string     `json:"creator_user_name" csv:"creator_user_name" description:"Name of the user who created the action"`
+	TemplateID      string     `json:"template_id" csv:"template_id" gorm:"size:100" description:"ID of the template of the action"`
+	AuditID         string     `json:"audit_id" csv:"audit_id" gorm:"size:100" description:"ID of the inspection of the action"`
+	AuditTitle      string     `json:"audit_title" csv:"audit_title" description:"Title of the inspection the action was raised in"`
+	AuditItemID     string     `json:"audit_item_id" csv:"audit_item_id" gorm:"size:100" description:"ID of the inspection item the action was raised on"`
+	AuditItemLabel  string     `json:"audit_item_label" csv:"audit_item_label" description:"Label of the inspection item the action was raised on"`
+	OrganisationID  string     `json:"organisation_id" csv:"organisation_id" gorm:"index:idx_act_modified_at;size:37" description:"ID of the organisation the row belongs to"`
+	CompletedAt     *time.Time `json:"completed_at" csv:"completed_at" description:"Time the action was completed"`
+	ActionLabel     string     `json:"action_label" csv:"action_label" description:"Labels of the action"`
+	Deleted         bool       `json:"deleted" csv:"deleted" description:"Whether the action was deleted"`
+	AssetID         string     `json:"asset_id" csv:"asset_id" gorm:"size:36" description:"ID of the asset of the action"`
+	UniqueID        string     `json:"unique_id" csv:"unique_id" description:"Unique ID of the action shown in SafetyCulture"`
+	DeletedAt       *time.Time `json:"deleted_at" csv:"deleted_at" description:"Time the action was deleted, empty when it was not"`
+	// MediaIDs are the IDs of the media attached to the action, one per line
+	MediaIDs                string `json:"media_ids" csv:"media_ids" description:"IDs of the media attached to the action, one per line"`
+	MediaHypertextReference string `json:"media_hypertext_reference" csv:"media_hypertext_reference" description:"Links to the media attached to the action, one per line"`
 }
 
 // ActionFeed is a representation of the actions feed
@@ -46,6 +49,13 @@ type ActionFeed struct {
 	ModifiedAfter time.Time
 	Incremental   bool
 	Limit         int
+	DeletionMode  DeletionMode
+	// ExportMedia downloads the media of the actions to <media_path>/actions/<action_id>
+	ExportMedia bool
+	// MediaDownloader is the pool the media are downloaded through, shared with the other feeds of the export
+	MediaDownloader *MediaDownloader
+	// MediaStore keeps the media in a content-addressed store, they are written per task when not set
+	MediaStore MediaStore
 }
 
 // Name is the name of the feed
@@ -96,6 +106,8 @@ func (f *ActionFeed) Columns() []string {
 		"completed_at",
 		"action_label",
 		"unique_id",
+		"media_ids",
+		"media_hypertext_reference",
 	}
 }
 
@@ -134,6 +146,15 @@ func (f *ActionFeed) Export(ctx context.Context, apiClient *httpapi.Client, expo
 			return events.NewEventErrorWithMessage(err, events.ErrorSeverityError, events.ErrorSubSystemDataIntegrity, false, "map data")
 		}
 
+		var media []taskMedia
+		for _, row := range rows {
+			row.MediaIDs = taskMediaIDs(row.MediaIDs, row.MediaHypertextReference)
+			if f.ExportMedia {
+				media = append(media, collectTaskMedia(f.Name(), row.ID, row.MediaHypertextReference)...)
+			}
+		}
+		writeTaskMedia(ctx, f.MediaDownloader, apiClient, exporter, f.MediaStore, media)
+
 		if len(rows) != 0 {
 			// Calculate the size of the batch we can insert into the DB at once. Column count + buffer to account for primary keys
 			batchSize := exporter.ParameterLimit() / (len(f.Columns()) + 4)
@@ -153,7 +174,7 @@ func (f *ActionFeed) Export(ctx context.Context, apiClient *httpapi.Client, expo
 
 		l.With(
 			"estimated_remaining", resp.Metadata.RemainingRecords,
-			"duration_ms", apiClient.Duration.Milliseconds(),
+			"duration_ms", apiClient.Duration().Milliseconds(),
 			"export_duration_ms", exporter.GetDuration().Milliseconds(),
 		).Info("export batch complete")
 		return nil
@@ -181,28 +202,5 @@ func (f *ActionFeed) Export(ctx context.Context, apiClient *httpapi.Client, expo
 }
 
 func (f *ActionFeed) processDeletedActions(ctx context.Context, apiClient *httpapi.Client, exporter Exporter) error {
-	lg := logger.GetLogger()
-	dreq := httpapi.NewGetAccountsActivityLogRequest(f.Limit, f.ModifiedAfter, []string{"action.actions_deleted"})
-	delFn := func(resp *httpapi.GetAccountsActivityLogResponse) error {
-		var pkeys = make([]string, 0, len(resp.Activities))
-		for _, act := range resp.Activities {
-			for key, value := range act.Metadata {
-				if strings.HasPrefix(key, "action_id") {
-					pkeys = append(pkeys, value)
-				}
-			}
-		}
-		if len(pkeys) > 0 {
-			rowsUpdated, err := exporter.UpdateRows(f, pkeys, map[string]interface{}{"deleted": true})
-			if err != nil {
-				return events.NewEventErrorWithMessage(err,
-					events.ErrorSeverityWarning, events.ErrorSubSystemDB, false,
-					"unable to database records")
-			}
-			lg.Infof("there were %d rows marked as deleted", rowsUpdated)
-		}
-
-		return nil
-	}
-	return DrainAccountActivityHistoryLog(ctx, apiClient, dreq, delFn)
+	return processDeletedRows(ctx, apiClient, exporter, f, actionDeletionEvent, f.DeletionMode, f.Limit, f.ModifiedAfter, !f.Incremental)
 }
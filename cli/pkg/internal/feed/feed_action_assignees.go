@@ -16,14 +16,14 @@ import (
 
 // ActionAssignee represents a row from the action_assignees feed
 type ActionAssignee struct {
-	ID             string    `json:"id" csv:"id" gorm:"primarykey;size:375"`
-	ActionID       string    `json:"action_id" csv:"action_id" gorm:"index:idx_act_action_id;size:36"`
-	AssigneeID     string    `json:"assignee_id" csv:"assignee_id" gorm:"size:256"`
-	Type           string    `json:"type" csv:"type" gorm:"size:10"`
-	Name           string    `json:"name" csv:"name"`
-	OrganisationID string    `json:"organisation_id" csv:"organisation_id" gorm:"index:idx_act_asg_modified_at;size:37"`
-	ModifiedAt     time.Time `json:"modified_at" csv:"modified_at" gorm:"index:idx_act_asg_modified_at,sort:desc"`
-	ExportedAt     time.Time `json:"exported_at" csv:"exported_at" gorm:"index:idx_act_asg_modified_at;autoUpdateTime"`
+	ID             string    `json:"id" csv:"id" gorm:"primarykey;size:375" description:"ID of the assignment"`
+	ActionID       string    `json:"action_id" csv:"action_id" gorm:"index:idx_act_action_id;size:36" description:"ID of the action"`
+	AssigneeID     string    `json:"assignee_id" csv:"assignee_id" gorm:"size:256" description:"ID of the user or group assigned"`
+	Type           string    `json:"type" csv:"type" gorm:"size:10" description:"Type of the assignee, user or group"`
+	Name           string    `json:"name" csv:"name" description:"Name of the user or group assigned"`
+	OrganisationID string    `json:"organisation_id" csv:"organisation_id" gorm:"index:idx_act_asg_modified_at;size:37" description:"ID of the organisation the row belongs to"`
+	ModifiedAt     time.Time `json:"modified_at" csv:"modified_at" gorm:"index:idx_act_asg_modified_at,sort:desc" description:"Time the action was last modified"`
+	ExportedAt     time.Time `json:"exported_at" csv:"exported_at" gorm:"index:idx_act_asg_modified_at;autoUpdateTime" description:"Time the row was last written by the exporter"`
 }
 
 // ActionAssigneeFeed is a representation of the action_assignees feed
@@ -141,7 +141,7 @@ func (f *ActionAssigneeFeed) Export(ctx context.Context, apiClient *httpapi.Clie
 
 		l.With(
 			"estimated_remaining", resp.Metadata.RemainingRecords,
-			"duration_ms", apiClient.Duration.Milliseconds(),
+			"duration_ms", apiClient.Duration().Milliseconds(),
 			"export_duration_ms", exporter.GetDuration().Milliseconds(),
 		).Info("export batch complete")
 		return nil
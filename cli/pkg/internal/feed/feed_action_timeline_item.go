@@ -15,16 +15,19 @@ import (
 
 // ActionTimelineItem represents a row from the action timeline items feed
 type ActionTimelineItem struct {
-	ID              string    `json:"id" csv:"item_id" gorm:"primarykey;column:item_id;size:36"`
-	TaskID          string    `json:"task_id" csv:"task_id"`
-	OrganisationID  string    `json:"organisation_id" csv:"organisation_id"`
-	TaskCreatorID   string    `json:"task_creator_id" csv:"task_creator_id"`
-	TaskCreatorName string    `json:"task_creator_name" csv:"task_creator_name"`
-	Timestamp       time.Time `json:"timestamp" csv:"timestamp" gorm:"index:idx_act_tim_timestamp,sort:desc"`
-	CreatorID       string    `json:"creator_id" csv:"creator_id"`
-	CreatorName     string    `json:"creator_name" csv:"creator_name"`
-	ItemType        string    `json:"item_type" csv:"item_type"`
-	ItemData        string    `json:"item_data" csv:"item_data"`
+	ID              string    `json:"id" csv:"item_id" gorm:"primarykey;column:item_id;size:36" description:"ID of the timeline item"`
+	TaskID          string    `json:"task_id" csv:"task_id" description:"ID of the action"`
+	OrganisationID  string    `json:"organisation_id" csv:"organisation_id" description:"ID of the organisation the row belongs to"`
+	TaskCreatorID   string    `json:"task_creator_id" csv:"task_creator_id" description:"ID of the user who created the action"`
+	TaskCreatorName string    `json:"task_creator_name" csv:"task_creator_name" description:"Name of the user who created the action"`
+	Timestamp       time.Time `json:"timestamp" csv:"timestamp" gorm:"index:idx_act_tim_timestamp,sort:desc" description:"Time the timeline item was created"`
+	CreatorID       string    `json:"creator_id" csv:"creator_id" description:"ID of the user who created the timeline item"`
+	CreatorName     string    `json:"creator_name" csv:"creator_name" description:"Name of the user who created the timeline item"`
+	ItemType        string    `json:"item_type" csv:"item_type" description:"Type of the timeline item, such as a comment or a status change"`
+	ItemData        string    `json:"item_data" csv:"item_data" description:"Content of the timeline item"`
+	// MediaIDs are the IDs of the media attached to the timeline item, one per line
+	MediaIDs                string `json:"media_ids" csv:"media_ids" description:"IDs of the media attached to the timeline item, one per line"`
+	MediaHypertextReference string `json:"media_hypertext_reference" csv:"media_hypertext_reference" description:"Links to the media attached to the timeline item, one per line"`
 }
 
 // ActionTimelineItemFeed is a representation of the action timeline items feed
@@ -32,6 +35,12 @@ type ActionTimelineItemFeed struct {
 	ModifiedAfter time.Time
 	Limit         int
 	Incremental   bool
+	// ExportMedia downloads the media of the timeline items to <media_path>/actions/<task_id>
+	ExportMedia bool
+	// MediaDownloader is the pool the media are downloaded through, shared with the other feeds of the export
+	MediaDownloader *MediaDownloader
+	// MediaStore keeps the media in a content-addressed store, they are written per task when not set
+	MediaStore MediaStore
 }
 
 // Name is the name of the feed
@@ -71,6 +80,8 @@ func (f *ActionTimelineItemFeed) Columns() []string {
 		"creator_name",
 		"item_type",
 		"item_data",
+		"media_ids",
+		"media_hypertext_reference",
 	}
 }
 
@@ -113,6 +124,16 @@ func (f *ActionTimelineItemFeed) Export(ctx context.Context, apiClient *httpapi.
 			return fmt.Sprintf("pk__%s", row.ID)
 		})
 
+		actionsFeed := &ActionFeed{}
+		var media []taskMedia
+		for _, row := range deDupedRows {
+			row.MediaIDs = taskMediaIDs(row.MediaIDs, row.MediaHypertextReference)
+			if f.ExportMedia {
+				media = append(media, collectTaskMedia(actionsFeed.Name(), row.TaskID, row.MediaHypertextReference)...)
+			}
+		}
+		writeTaskMedia(ctx, f.MediaDownloader, apiClient, exporter, f.MediaStore, media)
+
 		if len(deDupedRows) != 0 {
 			// Calculate the size of the batch we can insert into the DB at once.
 			// Column count + buffer to account for primary keys
@@ -133,7 +154,7 @@ func (f *ActionTimelineItemFeed) Export(ctx context.Context, apiClient *httpapi.
 
 		l.With(
 			"estimated_remaining", resp.Metadata.RemainingRecords,
-			"duration_ms", apiClient.Duration.Milliseconds(),
+			"duration_ms", apiClient.Duration().Milliseconds(),
 			"export_duration_ms", exporter.GetDuration().Milliseconds(),
 		).Info("export batch complete")
 		return nil
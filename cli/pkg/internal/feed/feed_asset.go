@@ -14,22 +14,26 @@ import (
 
 // Asset represents a row from the assets feed
 type Asset struct {
-	ID            string    `json:"id" csv:"asset_id" gorm:"primarykey;column:asset_id;size:36"`
-	Code          string    `json:"code" csv:"code"`
-	TypeID        string    `json:"type_id" csv:"type_id"`
-	TypeName      string    `json:"type_name" csv:"type_name"`
-	Fields        string    `json:"fields" csv:"fields"`
-	CreatedAt     time.Time `json:"created_at" csv:"created_at"`
-	ModifiedAt    time.Time `json:"modified_at" csv:"modified_at" gorm:"index:idx_ast_modified_at,sort:desc"`
-	SiteID        string    `json:"site_id" csv:"site_id" gorm:"size:41"`
-	State         string    `json:"state" csv:"state"`
-	StatusOptions string    `json:"status_options" csv:"status_options"`
+	ID            string     `json:"id" csv:"asset_id" gorm:"primarykey;column:asset_id;size:36" description:"ID of the asset"`
+	Code          string     `json:"code" csv:"code" description:"Code of the asset"`
+	TypeID        string     `json:"type_id" csv:"type_id" description:"ID of the type of the asset"`
+	TypeName      string     `json:"type_name" csv:"type_name" description:"Name of the type of the asset"`
+	Fields        string     `json:"fields" csv:"fields" description:"Fields of the asset and their values"`
+	CreatedAt     time.Time  `json:"created_at" csv:"created_at" description:"Time the asset was created"`
+	ModifiedAt    time.Time  `json:"modified_at" csv:"modified_at" gorm:"index:idx_ast_modified_at,sort:desc" description:"Time the asset was last modified"`
+	SiteID        string     `json:"site_id" csv:"site_id" gorm:"size:41" description:"ID of the site of the asset"`
+	State         string     `json:"state" csv:"state" description:"State of the asset"`
+	StatusOptions string     `json:"status_options" csv:"status_options" description:"Status options of the asset"`
+	Deleted       bool       `json:"deleted" csv:"deleted" description:"Whether the asset was deleted"`
+	DeletedAt     *time.Time `json:"deleted_at" csv:"deleted_at" description:"Time the asset was deleted, empty when it was not"`
 }
 
 // AssetFeed is a representation of the assets feed
 type AssetFeed struct {
-	Limit       int
-	Incremental bool
+	Limit        int
+	Incremental  bool
+	DeletionMode DeletionMode
+	DeletedAfter time.Time
 }
 
 // Name is the name of the feed
@@ -121,7 +125,7 @@ func (f *AssetFeed) Export(ctx context.Context, apiClient *httpapi.Client, expor
 
 		l.With(
 			"estimated_remaining", resp.Metadata.RemainingRecords,
-			"duration_ms", apiClient.Duration.Milliseconds(),
+			"duration_ms", apiClient.Duration().Milliseconds(),
 			"export_duration_ms", exporter.GetDuration().Milliseconds(),
 		).Info("export batch complete")
 		return nil
@@ -137,5 +141,11 @@ func (f *AssetFeed) Export(ctx context.Context, apiClient *httpapi.Client, expor
 	if err := DrainFeed(ctx, apiClient, req, drainFn); err != nil {
 		return fmt.Errorf("assets feed %q: %w", f.Name(), err)
 	}
+
+	// Process Deleted Assets
+	if err := processDeletedRows(ctx, apiClient, exporter, f, assetDeletionEvent, f.DeletionMode, f.Limit, f.DeletedAfter, !f.Incremental); err != nil && events.IsBlockingError(err) {
+		return events.WrapEventError(err, "process deleted assets")
+	}
+
 	return exporter.FinaliseExport(f, &[]*Asset{})
 }
@@ -0,0 +1,152 @@
+package feed
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/SafetyCulture/safetyculture-exporter/pkg/httpapi"
+	"github.com/SafetyCulture/safetyculture-exporter/pkg/internal/events"
+	"github.com/SafetyCulture/safetyculture-exporter/pkg/logger"
+)
+
+// DeletionMode is how the rows deleted in SafetyCulture are reflected in the export
+type DeletionMode string
+
+const (
+	// DeletionModeSoft flags the deleted rows through their deleted and deleted_at columns
+	DeletionModeSoft DeletionMode = "soft"
+	// DeletionModeHard deletes the rows
+	DeletionModeHard DeletionMode = "hard"
+)
+
+// ParseDeletionMode validates a deletion mode, an empty mode is a soft deletion
+func ParseDeletionMode(mode string) (DeletionMode, error) {
+	switch DeletionMode(mode) {
+	case "", DeletionModeSoft:
+		return DeletionModeSoft, nil
+	case DeletionModeHard:
+		return DeletionModeHard, nil
+	}
+	return "", fmt.Errorf("invalid deletion mode %q, expected %q or %q", mode, DeletionModeSoft, DeletionModeHard)
+}
+
+// deletionEvent describes the activity log event recording the deletion of the rows of a feed
+type deletionEvent struct {
+	// eventType is the type of the activity log event
+	eventType string
+	// metadataKey is the metadata holding the ID of a deleted row. Events deleting several rows hold one key per row,
+	// suffixed with its position, such as action_id_0
+	metadataKey string
+	// idPrefix is prepended to the UUIDs read from the metadata, without their dashes, to build the IDs of the feed
+	idPrefix string
+	// column holds the IDs of the deleted rows, the primary key of the feed when empty
+	column string
+}
+
+// deletedRowsPageSize is the number of activity log events read per page for the feeds without a limit of their own
+const deletedRowsPageSize = 100
+
+var (
+	inspectionDeletionEvent = deletionEvent{eventType: "inspection.deleted", metadataKey: "inspection_id", idPrefix: "audit_"}
+	// the items of the inspections deleted are deleted along with them
+	inspectionItemDeletionEvent = deletionEvent{eventType: "inspection.deleted", metadataKey: "inspection_id", idPrefix: "audit_", column: "audit_id"}
+	actionDeletionEvent         = deletionEvent{eventType: "action.actions_deleted", metadataKey: "action_id"}
+	issueDeletionEvent          = deletionEvent{eventType: "issue.deleted", metadataKey: "issue_id"}
+	templateDeletionEvent       = deletionEvent{eventType: "template.deleted", metadataKey: "template_id", idPrefix: "template_"}
+	siteDeletionEvent           = deletionEvent{eventType: "site.deleted", metadataKey: "site_id", idPrefix: "location_"}
+	assetDeletionEvent          = deletionEvent{eventType: "asset.deleted", metadataKey: "asset_id"}
+)
+
+// ids returns the IDs of the rows deleted by an event
+func (d deletionEvent) ids(metadata map[string]string) []string {
+	var ids []string
+	for key, value := range metadata {
+		if value == "" || (key != d.metadataKey && !strings.HasPrefix(key, d.metadataKey+"_")) {
+			continue
+		}
+		if d.idPrefix != "" && !strings.HasPrefix(value, d.idPrefix) {
+			value = d.idPrefix + strings.ReplaceAll(value, "-", "")
+		}
+		ids = append(ids, value)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// processDeletedRows reflects the deletions recorded in the activity log since deletedAfter in the feed. The deletions
+// are read from the latest one read by the previous export of the feed when its progress is saved, unless the rows of
+// the feed were reloaded in full by this export or are hard deleted: the rows fetched again would otherwise undo the
+// deletions already read
+func processDeletedRows(ctx context.Context, apiClient *httpapi.Client, exporter Exporter, feed Feed, event deletionEvent, mode DeletionMode, pageSize int, deletedAfter time.Time, reloaded bool) error {
+	lg := logger.GetLogger().With("feed", feed.Name())
+	checkpoint := exportCheckpointFrom(ctx)
+
+	column := event.column
+	if column == "" {
+		column = feed.PrimaryKey()[0]
+	}
+	if pageSize <= 0 {
+		pageSize = deletedRowsPageSize
+	}
+
+	from := deletedAfter
+	if !reloaded && mode != DeletionModeHard {
+		from = checkpoint.deletedAfter(deletedAfter)
+	}
+
+	var lastDeletedAt time.Time
+	dreq := httpapi.NewGetAccountsActivityLogRequest(pageSize, from, []string{event.eventType})
+	delFn := func(resp *httpapi.GetAccountsActivityLogResponse) error {
+		for _, a := range resp.Activities {
+			if a.EventAt.After(lastDeletedAt) {
+				lastDeletedAt = a.EventAt
+			}
+		}
+
+		for _, a := range resp.Activities {
+			pkeys := event.ids(a.Metadata)
+			if len(pkeys) == 0 {
+				continue
+			}
+
+			deletedAt := a.EventAt
+			if deletedAt.IsZero() {
+				deletedAt = time.Now()
+			}
+
+			if mode == DeletionModeHard {
+				query := fmt.Sprintf("%s IN ?", column)
+				if err := exporter.DeleteDeletedRows(feed, deletedAt.UTC(), query, pkeys); err != nil {
+					return events.NewEventErrorWithMessage(err,
+						events.ErrorSeverityWarning, events.ErrorSubSystemDB, false,
+						"unable to delete database records")
+				}
+				lg.Infof("there were %d rows deleted", len(pkeys))
+				continue
+			}
+
+			rowsUpdated, err := exporter.UpdateRows(feed, pkeys, map[string]interface{}{
+				"deleted":    true,
+				"deleted_at": deletedAt.UTC(),
+			})
+			if err != nil {
+				return events.NewEventErrorWithMessage(err,
+					events.ErrorSeverityWarning, events.ErrorSubSystemDB, false,
+					"unable to update database records")
+			}
+			lg.Infof("there were %d rows marked as deleted", rowsUpdated)
+		}
+		return nil
+	}
+	if err := DrainAccountActivityHistoryLog(ctx, apiClient, dreq, delFn); err != nil {
+		return err
+	}
+
+	if !lastDeletedAt.IsZero() {
+		checkpoint.saveDeletedAfter(lastDeletedAt)
+	}
+	return nil
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/MickStanciu/go-fn/fn"
 	"github.com/SafetyCulture/safetyculture-exporter/pkg/httpapi"
 	"github.com/SafetyCulture/safetyculture-exporter/pkg/internal/events"
+	"github.com/SafetyCulture/safetyculture-exporter/pkg/internal/metrics"
 	"github.com/SafetyCulture/safetyculture-exporter/pkg/logger"
 )
 
@@ -36,6 +37,9 @@ type ExporterFeedClient struct {
 	configuration   *ExporterFeedCfg
 	apiClient       *httpapi.Client
 	sheqsyApiClient *httpapi.Client
+	organisations   []Organisation
+	mediaStore      MediaStore
+	mediaDownloader *MediaDownloader
 	errMu           sync.Mutex
 	errs            []error
 }
@@ -58,6 +62,9 @@ type ExporterFeedCfg struct {
 	ExportIncremental                     bool
 	ExportInspectionLimit                 int
 	ExportMedia                           bool
+	ExportMediaStore                      string
+	ExportMediaConcurrency                int
+	ExportMediaRetries                    int
 	ExportSiteIncludeDeleted              bool
 	ExportActionLimit                     int
 	ExportSiteIncludeFullHierarchy        bool
@@ -65,6 +72,13 @@ type ExporterFeedCfg struct {
 	ExportAssetLimit                      int
 	ExportCourseProgressLimit             int
 	ExportScheduleResumeDownload          bool
+	ExportResume                          bool
+	ExportAtomicRefresh                   bool
+	ExportDeletionMode                    string
+	ExportHistoryTables                   []string
+	ExportPivotTemplateIds                []string
+	ExportTimeZone                        string
+	ExportTimeZoneKeepUTC                 bool
 	MaxConcurrentGoRoutines               int
 }
 
@@ -78,6 +92,11 @@ func NewExporterApp(scApiClient *httpapi.Client, sheqsyApiClient *httpapi.Client
 
 // ExportSchemas generates schemas for the data feeds without fetching any data
 func (e *ExporterFeedClient) ExportSchemas(exporter Exporter) error {
+	exporter, err := newTimeZoneExporter(exporter, exporter, e.configuration.ExportTimeZone, e.configuration.ExportTimeZoneKeepUTC)
+	if err != nil {
+		return err
+	}
+
 	var lastErr error = nil
 	feeds := e.GetFeeds()
 	for _, feed := range feeds {
@@ -94,12 +113,59 @@ func (e *ExporterFeedClient) addError(err error) {
 }
 
 // ExportFeeds fetches all the feeds data from server and stores them in the format provided
-func (e *ExporterFeedClient) ExportFeeds(exporter Exporter, ctx context.Context) error {
+func (e *ExporterFeedClient) ExportFeeds(exporter Exporter, ctx context.Context) (exportErr error) {
 	log := logger.GetLogger()
 
 	status := GetExporterStatus()
 	status.Reset()
 
+	// the organisations are recorded as runs of their own, the run records the SHEQSY data exported along them
+	var run *exportRunRecorder
+	if len(e.organisations) == 0 || len(e.configuration.SheqsyUsername) != 0 {
+		run = newExportRunRecorder(exporter, e.configuration)
+	}
+	defer func() {
+		e.errMu.Lock()
+		errs := append([]error{}, e.errs...)
+		e.errMu.Unlock()
+		run.finish(exportErr, errs, ctx.Err() != nil)
+	}()
+
+	if _, err := ParseDeletionMode(e.configuration.ExportDeletionMode); err != nil {
+		return err
+	}
+
+	if err := e.checkSchemaVersion(exporter); err != nil {
+		return err
+	}
+
+	if err := e.initMediaStore(exporter); err != nil {
+		return err
+	}
+
+	// the media of every feed are downloaded through a single pool, the media failing to download are reported
+	// along the errors of the feeds
+	e.mediaDownloader = NewMediaDownloader(e.configuration.ExportMediaConcurrency, e.configuration.ExportMediaRetries, e.addError)
+	if e.configuration.ExportMedia {
+		status.StartFeedExport("media", false)
+	}
+
+	if len(e.organisations) > 1 && e.configuration.ExportAtomicRefresh {
+		return errors.New("atomic refresh is not supported when exporting several organisations")
+	}
+
+	// tables refreshed in full are loaded into staging tables, swapped in once their feed is exported
+	staging := newStagingExporter(exporter, e.configuration.ExportAtomicRefresh)
+
+	// the rows that changed are appended to the history table of their feed, if kept
+	history := newHistoryExporter(staging, exporter, e.configuration.ExportHistoryTables)
+
+	// timestamps are written in the configured time zone, the export state and history are kept as is
+	tzExporter, err := newTimeZoneExporter(history, exporter, e.configuration.ExportTimeZone, e.configuration.ExportTimeZoneKeepUTC)
+	if err != nil {
+		return err
+	}
+
 	tables := e.configuration.ExportTables
 	tablesMap := map[string]bool{}
 	for _, table := range tables {
@@ -114,25 +180,35 @@ func (e *ExporterFeedClient) ExportFeeds(exporter Exporter, ctx context.Context)
 	semaphore := make(chan int, maxConcurrentRoutines)
 
 	atLeastOneRun := false
+	exportedInspections := false
 
 	// Run export for SafetyCulture data
-	if len(e.configuration.AccessToken) != 0 {
+	if len(e.organisations) != 0 {
 		status.started = true
 		atLeastOneRun = true
-		log.Info("exporting SafetyCulture data")
+		exportedInspections = true
+		log.Infof("exporting SafetyCulture data of %d organisations", len(e.organisations))
 
-		var feeds []Feed
-		for _, feed := range e.GetFeeds() {
-			if tablesMap[feed.Name()] || len(tables) == 0 {
-				feeds = append(feeds, feed)
-			}
+		if len(e.selectedFeeds()) == 0 {
+			return errors.New("no tables selected")
 		}
 
+		e.exportOrganisations(ctx, exporter, tzExporter, staging)
+	} else if len(e.configuration.AccessToken) != 0 {
+		status.started = true
+		atLeastOneRun = true
+		exportedInspections = true
+		log.Info("exporting SafetyCulture data")
+
+		feeds := e.selectedFeeds()
+
 		resp, err := httpapi.WhoAmI(ctx, e.apiClient)
 		if err != nil {
 			return fmt.Errorf("get details of the current user: %w", err)
 		}
 
+		run.setUser(resp)
+
 		log = log.With(
 			"user.id", resp.UserID,
 			"user.org_id", resp.OrganisationID,
@@ -158,7 +234,10 @@ func (e *ExporterFeedClient) ExportFeeds(exporter Exporter, ctx context.Context)
 				default:
 					log.Infof(" ... queueing %s\n", f.Name())
 					status.StartFeedExport(f.Name(), f.HasRemainingInformation())
-					exportErr := f.Export(c, e.apiClient, exporter, resp.OrganisationID)
+					feedExporter, finishFeed := run.trackFeed(f, tzExporter)
+					exportErr := e.exportFeed(c, f, e.apiClient, exporter, feedExporter, resp.OrganisationID)
+					exportErr = staging.finishFeed(f, exportErr)
+					finishFeed(exportErr)
 					var curatedErr error
 					if exportErr != nil {
 						e.addError(exportErr)
@@ -205,7 +284,10 @@ func (e *ExporterFeedClient) ExportFeeds(exporter Exporter, ctx context.Context)
 			go func(f Feed) {
 				log.Infof(" ... queueing %s\n", f.Name())
 				defer wg.Done()
-				err := f.Export(ctx, e.sheqsyApiClient, exporter, resp.CompanyUID)
+				feedExporter, finishFeed := run.trackFeed(f, tzExporter)
+				err := f.Export(ctx, e.sheqsyApiClient, feedExporter, resp.CompanyUID)
+				err = staging.finishFeed(f, err)
+				finishFeed(err)
 				if err != nil {
 					e.addError(err)
 				}
@@ -216,6 +298,20 @@ func (e *ExporterFeedClient) ExportFeeds(exporter Exporter, ctx context.Context)
 
 	wg.Wait()
 
+	if e.configuration.ExportMedia {
+		var mediaErr error
+		if failed := e.mediaDownloader.Failed(); failed != 0 {
+			mediaErr = fmt.Errorf("%d media couldn't be downloaded", failed)
+		}
+		status.FinishFeedExport("media", mediaErr)
+	}
+
+	// the media table and the pivot tables are built while exporting the inspection items
+	if exportedInspections && (tablesMap["inspection_items"] || len(tables) == 0) {
+		e.exportMediaTable(ctx, exporter)
+		e.exportPivotTables(ctx, exporter)
+	}
+
 	if !atLeastOneRun {
 		return errors.New("no API tokens provided")
 	}
@@ -237,16 +333,47 @@ func (e *ExporterFeedClient) ExportFeeds(exporter Exporter, ctx context.Context)
 		return e.errs[0]
 	}
 
+	metrics.SetLastSuccessfulRun(time.Now())
 	return nil
 }
 
+// exportFeed exports a feed through feedExporter, persisting its progress when the exporter
+// supports it so an interrupted export can be resumed
+func (e *ExporterFeedClient) exportFeed(ctx context.Context, f Feed, apiClient *httpapi.Client, exporter Exporter, feedExporter Exporter, orgID string) error {
+	store, ok := exporter.(ExportStateStore)
+	if !ok {
+		return f.Export(ctx, apiClient, feedExporter, orgID)
+	}
+
+	log := logger.GetLogger().With("feed", f.Name(), "org_id", orgID)
+	cp, err := newExportCheckpoint(store, f.Name(), orgID, e.configuration.ExportResume)
+	if err != nil {
+		log.Warnf("export progress won't be saved: %v", err)
+		return f.Export(ctx, apiClient, feedExporter, orgID)
+	}
+
+	if cp.resuming {
+		log.Info("resuming interrupted export")
+		feedExporter = &resumingExporter{Exporter: feedExporter}
+	}
+
+	exportErr := f.Export(withExportCheckpoint(ctx, cp), apiClient, feedExporter, orgID)
+	if exportErr != nil {
+		cp.setStatus(ExportStateFailed)
+	} else {
+		cp.setStatus(ExportStateCompleted)
+	}
+	return exportErr
+}
+
 // GetFeeds returns list of available SafetyCulture feeds
 func (e *ExporterFeedClient) GetFeeds() []Feed {
 	return []Feed{
 		e.getInspectionFeed(),
 		&UserFeed{},
 		&TemplateFeed{
-			Incremental: e.configuration.ExportIncremental,
+			Incremental:  e.configuration.ExportIncremental,
+			DeletionMode: DeletionMode(e.configuration.ExportDeletionMode),
 		},
 		&TemplatePermissionFeed{
 			Incremental: e.configuration.ExportIncremental,
@@ -254,6 +381,8 @@ func (e *ExporterFeedClient) GetFeeds() []Feed {
 		&SiteFeed{
 			IncludeDeleted:       e.configuration.ExportSiteIncludeDeleted,
 			IncludeFullHierarchy: e.configuration.ExportSiteIncludeFullHierarchy,
+			DeletionMode:         DeletionMode(e.configuration.ExportDeletionMode),
+			DeletedAfter:         e.configuration.ExportModifiedAfterTime,
 		},
 		&SiteMemberFeed{},
 		&GroupFeed{},
@@ -270,9 +399,13 @@ func (e *ExporterFeedClient) GetFeeds() []Feed {
 			ResumeDownload: e.configuration.ExportScheduleResumeDownload,
 		},
 		&ActionFeed{
-			ModifiedAfter: e.configuration.ExportModifiedAfterTime,
-			Incremental:   e.configuration.ExportIncremental,
-			Limit:         e.configuration.ExportActionLimit,
+			ModifiedAfter:   e.configuration.ExportModifiedAfterTime,
+			Incremental:     e.configuration.ExportIncremental,
+			Limit:           e.configuration.ExportActionLimit,
+			DeletionMode:    DeletionMode(e.configuration.ExportDeletionMode),
+			ExportMedia:     e.configuration.ExportMedia,
+			MediaDownloader: e.mediaDownloader,
+			MediaStore:      e.mediaStore,
 		},
 		&ActionAssigneeFeed{
 			ModifiedAfter: e.configuration.ExportModifiedAfterTime,
@@ -280,9 +413,12 @@ func (e *ExporterFeedClient) GetFeeds() []Feed {
 			Limit:         e.configuration.ExportActionLimit,
 		},
 		&ActionTimelineItemFeed{
-			ModifiedAfter: e.configuration.ExportModifiedAfterTime,
-			Incremental:   e.configuration.ExportIncremental,
-			Limit:         e.configuration.ExportActionLimit,
+			ModifiedAfter:   e.configuration.ExportModifiedAfterTime,
+			Incremental:     e.configuration.ExportIncremental,
+			Limit:           e.configuration.ExportActionLimit,
+			ExportMedia:     e.configuration.ExportMedia,
+			MediaDownloader: e.mediaDownloader,
+			MediaStore:      e.mediaStore,
 		},
 		&InspectionItemFeed{
 			SkipIDs:         e.configuration.ExportInspectionSkipIds,
@@ -297,18 +433,31 @@ func (e *ExporterFeedClient) GetFeeds() []Feed {
 			Incremental:     e.configuration.ExportIncremental,
 			Limit:           e.configuration.ExportInspectionLimit,
 			ExportMedia:     e.configuration.ExportMedia,
+			MediaDownloader: e.mediaDownloader,
+			MediaStore:      e.mediaStore,
+			DeletionMode:    DeletionMode(e.configuration.ExportDeletionMode),
 		},
 		&IssueFeed{
-			Incremental: false, // this was disabled on request. Issues API doesn't support modified After filters
-			Limit:       e.configuration.ExportIssueLimit,
+			Incremental:     false, // this was disabled on request. Issues API doesn't support modified After filters
+			Limit:           e.configuration.ExportIssueLimit,
+			DeletionMode:    DeletionMode(e.configuration.ExportDeletionMode),
+			DeletedAfter:    e.configuration.ExportModifiedAfterTime,
+			ExportMedia:     e.configuration.ExportMedia,
+			MediaDownloader: e.mediaDownloader,
+			MediaStore:      e.mediaStore,
 		},
 		&IssueTimelineItemFeed{
-			Incremental: false, // Issues API doesn't support modified after filters
-			Limit:       e.configuration.ExportIssueLimit,
+			Incremental:     false, // Issues API doesn't support modified after filters
+			Limit:           e.configuration.ExportIssueLimit,
+			ExportMedia:     e.configuration.ExportMedia,
+			MediaDownloader: e.mediaDownloader,
+			MediaStore:      e.mediaStore,
 		},
 		&AssetFeed{
-			Incremental: false, // Assets API doesn't support modified after filters
-			Limit:       e.configuration.ExportAssetLimit,
+			Incremental:  false, // Assets API doesn't support modified after filters
+			Limit:        e.configuration.ExportAssetLimit,
+			DeletionMode: DeletionMode(e.configuration.ExportDeletionMode),
+			DeletedAfter: e.configuration.ExportModifiedAfterTime,
 		},
 		&TrainingCourseProgressFeed{
 			Incremental:      false, // CourseProgress doesn't support modified after filters,
@@ -326,6 +475,22 @@ func (e *ExporterFeedClient) GetFeeds() []Feed {
 	}
 }
 
+// selectedFeeds returns new instances of the feeds of the configured tables, every feed when no table is configured
+func (e *ExporterFeedClient) selectedFeeds() []Feed {
+	tables := map[string]bool{}
+	for _, table := range e.configuration.ExportTables {
+		tables[table] = true
+	}
+
+	var feeds []Feed
+	for _, feed := range e.GetFeeds() {
+		if tables[feed.Name()] || len(tables) == 0 {
+			feeds = append(feeds, feed)
+		}
+	}
+	return feeds
+}
+
 func (e *ExporterFeedClient) getInspectionFeed() *InspectionFeed {
 	return &InspectionFeed{
 		SkipIDs:        e.configuration.ExportInspectionSkipIds,
@@ -338,6 +503,7 @@ func (e *ExporterFeedClient) getInspectionFeed() *InspectionFeed {
 		Incremental:    e.configuration.ExportIncremental,
 		Limit:          e.configuration.ExportInspectionLimit,
 		WebReportLink:  e.configuration.ExportInspectionWebReportLink,
+		DeletionMode:   DeletionMode(e.configuration.ExportDeletionMode),
 	}
 }
 
@@ -379,12 +545,23 @@ func (e *ExporterFeedClient) ExportInspectionReports(exporter *ReportExporter, c
 
 	log.Infof("Exporting inspection reports by user: %s %s", resp.Firstname, resp.Lastname)
 
+	if _, err := exporter.reportPathTemplate(); err != nil {
+		status.MarkExportCompleted()
+		return err
+	}
+	if err := validateBundleBy(exporter.BundleBy); err != nil {
+		status.MarkExportCompleted()
+		return err
+	}
+	if err := exporter.Filter.validate(); err != nil {
+		status.MarkExportCompleted()
+		return err
+	}
+
 	feed := e.getInspectionFeed()
-	status.StartFeedExport(feed.Name(), feed.HasRemainingInformation())
-	if err := feed.Export(ctx, e.apiClient, exporter, resp.OrganisationID); err != nil {
-		status.FinishFeedExport(feed.Name(), err)
+	if err := e.exportReportInspections(ctx, exporter, feed, resp.OrganisationID); err != nil {
 		status.MarkExportCompleted()
-		return fmt.Errorf("export inspection feed: %w", err)
+		return err
 	}
 
 	err = exporter.SaveReports(ctx, e.apiClient, feed)
@@ -394,3 +571,40 @@ func (e *ExporterFeedClient) ExportInspectionReports(exporter *ReportExporter, c
 
 	return err
 }
+
+// exportReportInspections exports the inspections the reports are generated for, from the API or the SQL database
+// they were exported to. The inspections exported by the previous runs are reused when only the failed reports are
+// generated again
+func (e *ExporterFeedClient) exportReportInspections(ctx context.Context, exporter *ReportExporter, feed *InspectionFeed, orgID string) error {
+	if exporter.RetryFailed {
+		return nil
+	}
+
+	if exporter.Source != nil {
+		if err := exporter.copySourceInspections(ctx, feed); err != nil {
+			return fmt.Errorf("read inspections: %w", err)
+		}
+		if exporter.usesSiteNames() {
+			if err := exporter.copySourceFeed(ctx, &SiteFeed{}); err != nil {
+				return fmt.Errorf("read sites: %w", err)
+			}
+		}
+		return nil
+	}
+
+	status := GetExporterStatus()
+	status.StartFeedExport(feed.Name(), feed.HasRemainingInformation())
+	if err := feed.Export(ctx, e.apiClient, exporter, orgID); err != nil {
+		status.FinishFeedExport(feed.Name(), err)
+		return fmt.Errorf("export inspection feed: %w", err)
+	}
+
+	// the names of the sites are looked up from the sites table when the reports are named after them
+	if exporter.usesSiteNames() {
+		sites := &SiteFeed{IncludeDeleted: true, IncludeFullHierarchy: true}
+		if err := sites.Export(ctx, e.apiClient, exporter, orgID); err != nil {
+			return fmt.Errorf("export site feed: %w", err)
+		}
+	}
+	return nil
+}
@@ -15,10 +15,10 @@ import (
 
 // Group represents a row from the groups feed
 type Group struct {
-	ID             string    `json:"id" csv:"group_id" gorm:"primarykey;column:group_id;size:37"`
-	Name           string    `json:"name" csv:"name"`
-	OrganisationID string    `json:"organisation_id" csv:"organisation_id" gorm:"size:37"`
-	ExportedAt     time.Time `json:"exported_at" csv:"exported_at" gorm:"autoUpdateTime"`
+	ID             string    `json:"id" csv:"group_id" gorm:"primarykey;column:group_id;size:37" description:"ID of the group"`
+	Name           string    `json:"name" csv:"name" description:"Name of the group"`
+	OrganisationID string    `json:"organisation_id" csv:"organisation_id" gorm:"size:37" description:"ID of the organisation the row belongs to"`
+	ExportedAt     time.Time `json:"exported_at" csv:"exported_at" gorm:"autoUpdateTime" description:"Time the row was last written by the exporter"`
 }
 
 // GroupFeed is a representation of the groups feed
@@ -103,11 +103,11 @@ func (f *GroupFeed) Export(ctx context.Context, apiClient *httpapi.Client, expor
 			}
 		}
 
-		status.UpdateStatus(f.Name(), resp.Metadata.RemainingRecords, apiClient.Duration.Milliseconds())
+		status.UpdateStatus(f.Name(), resp.Metadata.RemainingRecords, apiClient.Duration().Milliseconds())
 
 		l.With(
 			"estimated_remaining", resp.Metadata.RemainingRecords,
-			"duration_ms", apiClient.Duration.Milliseconds(),
+			"duration_ms", apiClient.Duration().Milliseconds(),
 			"export_duration_ms", exporter.GetDuration().Milliseconds(),
 		).Info("export batch complete")
 		return nil
@@ -16,10 +16,10 @@ import (
 
 // GroupUser represents a row from the group_users feed
 type GroupUser struct {
-	UserID         string    `json:"user_id" csv:"user_id" gorm:"primaryKey;size:37"`
-	GroupID        string    `json:"group_id" csv:"group_id" gorm:"primaryKey;size:37"`
-	OrganisationID string    `json:"organisation_id" csv:"organisation_id" gorm:"size:37"`
-	ExportedAt     time.Time `json:"exported_at" csv:"exported_at" gorm:"autoUpdateTime"`
+	UserID         string    `json:"user_id" csv:"user_id" gorm:"primaryKey;size:37" description:"ID of the user"`
+	GroupID        string    `json:"group_id" csv:"group_id" gorm:"primaryKey;size:37" description:"ID of the group"`
+	OrganisationID string    `json:"organisation_id" csv:"organisation_id" gorm:"size:37" description:"ID of the organisation the row belongs to"`
+	ExportedAt     time.Time `json:"exported_at" csv:"exported_at" gorm:"autoUpdateTime" description:"Time the row was last written by the exporter"`
 }
 
 // GroupUserFeed is a representation of the group_users feed
@@ -118,7 +118,7 @@ func (f *GroupUserFeed) Export(ctx context.Context, apiClient *httpapi.Client, e
 
 		l.With(
 			"estimated_remaining", resp.Metadata.RemainingRecords,
-			"duration_ms", apiClient.Duration.Milliseconds(),
+			"duration_ms", apiClient.Duration().Milliseconds(),
 			"export_duration_ms", exporter.GetDuration().Milliseconds(),
 		).Info("export batch complete")
 		return nil
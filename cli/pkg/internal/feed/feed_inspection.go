@@ -4,7 +4,6 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"strings"
 	"time"
 
 	"github.com/MickStanciu/go-fn/fn"
@@ -18,39 +17,40 @@ import (
 
 // Inspection represents a row from the inspections feed
 type Inspection struct {
-	ID              string     `json:"id" csv:"audit_id" gorm:"primarykey;column:audit_id;size:100"`
-	Name            string     `json:"name" csv:"name"`
-	Archived        bool       `json:"archived" csv:"archived"`
-	OwnerName       string     `json:"owner_name" csv:"owner_name"`
-	OwnerID         string     `json:"owner_id" csv:"owner_id" gorm:"size:37"`
-	AuthorName      string     `json:"author_name" csv:"author_name"`
-	AuthorID        string     `json:"author_id" csv:"author_id" gorm:"size:37"`
-	Score           float32    `json:"score" csv:"score"`
-	MaxScore        float32    `json:"max_score" csv:"max_score"`
-	ScorePercentage float32    `json:"score_percentage" csv:"score_percentage"`
-	Duration        int64      `json:"duration" csv:"duration"`
-	TemplateID      string     `json:"template_id" csv:"template_id" gorm:"size:100"`
-	OrganisationID  string     `json:"organisation_id" csv:"organisation_id" gorm:"index:idx_ins_modified_at;size:37"`
-	TemplateName    string     `json:"template_name" csv:"template_name"`
-	TemplateAuthor  string     `json:"template_author" csv:"template_author"`
-	SiteID          string     `json:"site_id" csv:"site_id" gorm:"size:41"`
-	DateStarted     time.Time  `json:"date_started" csv:"date_started"`
-	DateCompleted   *time.Time `json:"date_completed" csv:"date_completed"`
-	DateModified    time.Time  `json:"date_modified" csv:"date_modified"`
-	CreatedAt       time.Time  `json:"created_at" csv:"created_at"`
-	ModifiedAt      time.Time  `json:"modified_at" csv:"modified_at" gorm:"index:idx_ins_modified_at,sort:desc"`
-	ExportedAt      time.Time  `json:"exported_at" csv:"exported_at" gorm:"index:idx_ins_modified_at;autoUpdateTime"`
-	DocumentNo      string     `json:"document_no" csv:"document_no"`
-	PreparedBy      string     `json:"prepared_by" csv:"prepared_by"`
-	Location        string     `json:"location" csv:"location"`
-	ConductedOn     *time.Time `json:"conducted_on" csv:"conducted_on"`
-	Personnel       string     `json:"personnel" csv:"personnel"`
-	ClientSite      string     `json:"client_site" csv:"client_site"`
-	Latitude        *float64   `json:"latitude" csv:"latitude"`
-	Longitude       *float64   `json:"longitude" csv:"longitude"`
-	WebReportLink   string     `json:"web_report_link" csv:"web_report_link"`
-	Deleted         bool       `json:"deleted" csv:"deleted"`
-	AssetID         string     `json:"asset_id" csv:"asset_id" gorm:"size:36"`
+	ID              string     `json:"id" csv:"audit_id" gorm:"primarykey;column:audit_id;size:100" description:"ID of the inspection"`
+	Name            string     `json:"name" csv:"name" description:"Title of the inspection"`
+	Archived        bool       `json:"archived" csv:"archived" description:"Whether the inspection is archived"`
+	OwnerName       string     `json:"owner_name" csv:"owner_name" description:"Name of the owner of the inspection"`
+	OwnerID         string     `json:"owner_id" csv:"owner_id" gorm:"size:37" description:"ID of the user owning the inspection"`
+	AuthorName      string     `json:"author_name" csv:"author_name" description:"Name of the last user who modified the inspection"`
+	AuthorID        string     `json:"author_id" csv:"author_id" gorm:"size:37" description:"ID of the last user who modified the inspection"`
+	Score           float32    `json:"score" csv:"score" description:"Score of the inspection"`
+	MaxScore        float32    `json:"max_score" csv:"max_score" description:"Maximum score of the inspection"`
+	ScorePercentage float32    `json:"score_percentage" csv:"score_percentage" description:"Score of the inspection as a percentage of its maximum score"`
+	Duration        int64      `json:"duration" csv:"duration" description:"Time spent on the inspection, in seconds"`
+	TemplateID      string     `json:"template_id" csv:"template_id" gorm:"size:100" description:"ID of the template of the inspection"`
+	OrganisationID  string     `json:"organisation_id" csv:"organisation_id" gorm:"index:idx_ins_modified_at;size:37" description:"ID of the organisation the row belongs to"`
+	TemplateName    string     `json:"template_name" csv:"template_name" description:"Name of the template of the inspection"`
+	TemplateAuthor  string     `json:"template_author" csv:"template_author" description:"Name of the author of the template of the inspection"`
+	SiteID          string     `json:"site_id" csv:"site_id" gorm:"size:41" description:"ID of the site of the inspection"`
+	DateStarted     time.Time  `json:"date_started" csv:"date_started" description:"Time the inspection was started"`
+	DateCompleted   *time.Time `json:"date_completed" csv:"date_completed" description:"Time the inspection was completed, empty when it is not"`
+	DateModified    time.Time  `json:"date_modified" csv:"date_modified" description:"Time the inspection was last modified by a user"`
+	CreatedAt       time.Time  `json:"created_at" csv:"created_at" description:"Time the inspection was created"`
+	ModifiedAt      time.Time  `json:"modified_at" csv:"modified_at" gorm:"index:idx_ins_modified_at,sort:desc" description:"Time the inspection was last modified"`
+	ExportedAt      time.Time  `json:"exported_at" csv:"exported_at" gorm:"index:idx_ins_modified_at;autoUpdateTime" description:"Time the row was last written by the exporter"`
+	DocumentNo      string     `json:"document_no" csv:"document_no" description:"Document number of the inspection"`
+	PreparedBy      string     `json:"prepared_by" csv:"prepared_by" description:"Name of the person who prepared the inspection"`
+	Location        string     `json:"location" csv:"location" description:"Location of the inspection"`
+	ConductedOn     *time.Time `json:"conducted_on" csv:"conducted_on" description:"Time the inspection was conducted on"`
+	Personnel       string     `json:"personnel" csv:"personnel" description:"Personnel of the inspection"`
+	ClientSite      string     `json:"client_site" csv:"client_site" description:"Client or site of the inspection"`
+	Latitude        *float64   `json:"latitude" csv:"latitude" description:"Latitude of the location of the inspection"`
+	Longitude       *float64   `json:"longitude" csv:"longitude" description:"Longitude of the location of the inspection"`
+	WebReportLink   string     `json:"web_report_link" csv:"web_report_link" description:"Link to the web report of the inspection"`
+	Deleted         bool       `json:"deleted" csv:"deleted" description:"Whether the inspection was deleted"`
+	AssetID         string     `json:"asset_id" csv:"asset_id" gorm:"size:36" description:"ID of the asset of the inspection"`
+	DeletedAt       *time.Time `json:"deleted_at" csv:"deleted_at" description:"Time the inspection was deleted, empty when it was not"`
 }
 
 // InspectionFeed is a representation of the inspections feed
@@ -65,6 +65,7 @@ type InspectionFeed struct {
 	Incremental    bool
 	Limit          int
 	WebReportLink  string
+	DeletionMode   DeletionMode
 }
 
 // Name is the name of the feed
@@ -241,7 +242,7 @@ func (f *InspectionFeed) processNewInspections(ctx context.Context, apiClient *h
 
 		l.With(
 			"estimated_remaining", resp.Metadata.RemainingRecords,
-			"duration_ms", apiClient.Duration.Milliseconds(),
+			"duration_ms", apiClient.Duration().Milliseconds(),
 			"export_duration_ms", exporter.GetDuration().Milliseconds(),
 		).Info("export batch complete")
 
@@ -260,8 +261,16 @@ func (f *InspectionFeed) processInspectionsInBlocks(ctx context.Context, apiClie
 		endDate = time.Now()
 	}
 
+	// Resume from the time block an interrupted export stopped in
+	startDate := f.ModifiedAfter
+	checkpoint := exportCheckpointFrom(ctx)
+	if resumeFrom := checkpoint.resumeBlock(); resumeFrom != nil {
+		l.With("start", resumeFrom.Format(time.RFC3339)).Info("resuming block-based export")
+		startDate = *resumeFrom
+	}
+
 	// Generate time blocks
-	blocks, err := util.GenerateTimeBlocksFromString(f.ModifiedAfter, endDate, f.BlockSize)
+	blocks, err := util.GenerateTimeBlocksFromString(startDate, endDate, f.BlockSize)
 	if err != nil {
 		return fmt.Errorf("failed to generate time blocks: %w", err)
 	}
@@ -309,13 +318,14 @@ func (f *InspectionFeed) processInspectionsInBlocks(ctx context.Context, apiClie
 			l.With(
 				"block", i+1,
 				"estimated_remaining", resp.Metadata.RemainingRecords,
-				"duration_ms", apiClient.Duration.Milliseconds(),
+				"duration_ms", apiClient.Duration().Milliseconds(),
 				"export_duration_ms", exporter.GetDuration().Milliseconds(),
 			).Info("export batch complete")
 
 			return nil
 		}
 
+		checkpoint.saveBlock(block.Start)
 		if err := DrainFeed(ctx, apiClient, &req, feedFn); err != nil {
 			l.With("block", i+1, "error", err).Error("failed to process block")
 			return fmt.Errorf("failed to process block %d/%d: %w", i+1, len(blocks), err)
@@ -329,31 +339,5 @@ func (f *InspectionFeed) processInspectionsInBlocks(ctx context.Context, apiClie
 }
 
 func (f *InspectionFeed) processDeletedInspections(ctx context.Context, apiClient *httpapi.Client, exporter Exporter) error {
-	lg := logger.GetLogger()
-	dreq := httpapi.NewGetAccountsActivityLogRequest(f.Limit, f.ModifiedAfter, []string{"inspection.deleted"})
-	delFn := func(resp *httpapi.GetAccountsActivityLogResponse) error {
-		var pkeys = make([]string, 0, len(resp.Activities))
-		for _, a := range resp.Activities {
-			uid := getPrefixID(a.Metadata["inspection_id"])
-			if uid != "" {
-				pkeys = append(pkeys, uid)
-			}
-		}
-		if len(pkeys) > 0 {
-			rowsUpdated, err := exporter.UpdateRows(f, pkeys, map[string]interface{}{"deleted": true})
-			if err != nil {
-				return events.NewEventErrorWithMessage(err,
-					events.ErrorSeverityWarning, events.ErrorSubSystemDB, false,
-					"unable to database records")
-			}
-			lg.Infof("there were %d rows marked as deleted", rowsUpdated)
-		}
-
-		return nil
-	}
-	return DrainAccountActivityHistoryLog(ctx, apiClient, dreq, delFn)
-}
-
-func getPrefixID(id string) string {
-	return fmt.Sprintf("audit_%s", strings.ReplaceAll(id, "-", ""))
+	return processDeletedRows(ctx, apiClient, exporter, f, inspectionDeletionEvent, f.DeletionMode, f.Limit, f.ModifiedAfter, !f.Incremental)
 }
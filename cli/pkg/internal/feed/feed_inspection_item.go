@@ -4,8 +4,6 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"strings"
-	"sync"
 	"time"
 
 	"github.com/SafetyCulture/safetyculture-exporter/pkg/internal/util"
@@ -13,6 +11,7 @@ import (
 
 	"github.com/SafetyCulture/safetyculture-exporter/pkg/httpapi"
 	"github.com/SafetyCulture/safetyculture-exporter/pkg/internal/events"
+	"github.com/SafetyCulture/safetyculture-exporter/pkg/internal/metrics"
 )
 
 const maxGoRoutines = 10
@@ -21,41 +20,41 @@ const IgnoreMediaHypertextReference = "media_hypertext_reference"
 
 // InspectionItem represents a row from the inspection_items feed
 type InspectionItem struct {
-	ID                      string    `json:"id" csv:"id" gorm:"primarykey;size:150"`
-	ItemID                  string    `json:"item_id" csv:"item_id" gorm:"size:100"`
-	AuditID                 string    `json:"audit_id" csv:"audit_id" gorm:"size:100"`
-	ItemIndex               int64     `json:"item_index" csv:"item_index"`
-	TemplateID              string    `json:"template_id" csv:"template_id" gorm:"size:100"`
-	ParentID                string    `json:"parent_id" csv:"parent_id" gorm:"size:100"`
-	CreatedAt               time.Time `json:"created_at" csv:"created_at"`
-	ModifiedAt              time.Time `json:"modified_at" csv:"modified_at" gorm:"index:idx_ins_itm_modified_at,sort:desc"`
-	ExportedAt              time.Time `json:"exported_at" csv:"exported_at" gorm:"index:idx_ins_itm_modified_at;autoUpdateTime"`
-	Type                    string    `json:"type" csv:"type" gorm:"size:20"`
-	Category                string    `json:"category" csv:"category"`
-	CategoryID              string    `json:"category_id" csv:"category_id" gorm:"size:100"`
-	OrganisationID          string    `json:"organisation_id" csv:"organisation_id" gorm:"index:idx_ins_itm_modified_at;size:37"`
-	ParentIDs               string    `json:"parent_ids" csv:"parent_ids"`
-	Label                   string    `json:"label" csv:"label"`
-	Response                string    `json:"response" csv:"response"`
-	ResponseID              string    `json:"response_id" csv:"response_id" gorm:"size:100"`
-	ResponseSetID           string    `json:"response_set_id" csv:"response_set_id" gorm:"size:100"`
-	IsFailedResponse        bool      `json:"is_failed_response" csv:"is_failed_response"`
-	Comment                 string    `json:"comment" csv:"comment"`
-	MediaFiles              string    `json:"media_files" csv:"media_files"`
-	MediaIDs                string    `json:"media_ids" csv:"media_ids"`
-	MediaHypertextReference string    `json:"media_hypertext_reference" csv:"media_hypertext_reference"`
-	Score                   float32   `json:"score" csv:"score"`
-	MaxScore                float32   `json:"max_score" csv:"max_score"`
-	ScorePercentage         float32   `json:"score_percentage" csv:"score_percentage"`
-	CombinedScore           float32   `json:"combined_score" csv:"combined_score"`
-	CombinedMaxScore        float32   `json:"combined_max_score" csv:"combined_max_score"`
-	CombinedScorePercentage float32   `json:"combined_score_percentage" csv:"combined_score_percentage"`
-	Mandatory               bool      `json:"mandatory" csv:"mandatory"`
-	Inactive                bool      `json:"inactive" csv:"inactive"`
-	LocationLatitude        *float32  `json:"location_latitude" csv:"location_latitude"`
-	LocationLongitude       *float32  `json:"location_longitude" csv:"location_longitude"`
-	PrimeelementID          string    `json:"primeelement_id" csv:"primeelement_id" gorm:"size:100"`
-	PrimeelementIndex       *int64    `json:"primeelement_index" csv:"primeelement_index"`
+	ID                      string    `json:"id" csv:"id" gorm:"primarykey;size:150" description:"ID of the row"`
+	ItemID                  string    `json:"item_id" csv:"item_id" gorm:"size:100" description:"ID of the item in the template"`
+	AuditID                 string    `json:"audit_id" csv:"audit_id" gorm:"size:100" description:"ID of the inspection"`
+	ItemIndex               int64     `json:"item_index" csv:"item_index" description:"Position of the item in the inspection"`
+	TemplateID              string    `json:"template_id" csv:"template_id" gorm:"size:100" description:"ID of the template of the inspection"`
+	ParentID                string    `json:"parent_id" csv:"parent_id" gorm:"size:100" description:"ID of the parent item"`
+	CreatedAt               time.Time `json:"created_at" csv:"created_at" description:"Time the inspection was created"`
+	ModifiedAt              time.Time `json:"modified_at" csv:"modified_at" gorm:"index:idx_ins_itm_modified_at,sort:desc" description:"Time the inspection was last modified"`
+	ExportedAt              time.Time `json:"exported_at" csv:"exported_at" gorm:"index:idx_ins_itm_modified_at;autoUpdateTime" description:"Time the row was last written by the exporter"`
+	Type                    string    `json:"type" csv:"type" gorm:"size:20" description:"Type of the item, such as a question, a section or a signature"`
+	Category                string    `json:"category" csv:"category" description:"Label of the section the item is in"`
+	CategoryID              string    `json:"category_id" csv:"category_id" gorm:"size:100" description:"ID of the section the item is in"`
+	OrganisationID          string    `json:"organisation_id" csv:"organisation_id" gorm:"index:idx_ins_itm_modified_at;size:37" description:"ID of the organisation the row belongs to"`
+	ParentIDs               string    `json:"parent_ids" csv:"parent_ids" description:"IDs of the ancestors of the item"`
+	Label                   string    `json:"label" csv:"label" description:"Label of the item"`
+	Response                string    `json:"response" csv:"response" description:"Response to the item"`
+	ResponseID              string    `json:"response_id" csv:"response_id" gorm:"size:100" description:"ID of the response"`
+	ResponseSetID           string    `json:"response_set_id" csv:"response_set_id" gorm:"size:100" description:"ID of the response set of the item"`
+	IsFailedResponse        bool      `json:"is_failed_response" csv:"is_failed_response" description:"Whether the response is marked as failed"`
+	Comment                 string    `json:"comment" csv:"comment" description:"Note added to the item"`
+	MediaFiles              string    `json:"media_files" csv:"media_files" description:"Files of the media attached to the item"`
+	MediaIDs                string    `json:"media_ids" csv:"media_ids" description:"IDs of the media attached to the item, one per line"`
+	MediaHypertextReference string    `json:"media_hypertext_reference" csv:"media_hypertext_reference" description:"Links to the media attached to the item, one per line"`
+	Score                   float32   `json:"score" csv:"score" description:"Score of the item"`
+	MaxScore                float32   `json:"max_score" csv:"max_score" description:"Maximum score of the item"`
+	ScorePercentage         float32   `json:"score_percentage" csv:"score_percentage" description:"Score of the item as a percentage of its maximum score"`
+	CombinedScore           float32   `json:"combined_score" csv:"combined_score" description:"Score of the item and its children"`
+	CombinedMaxScore        float32   `json:"combined_max_score" csv:"combined_max_score" description:"Maximum score of the item and its children"`
+	CombinedScorePercentage float32   `json:"combined_score_percentage" csv:"combined_score_percentage" description:"Score of the item and its children as a percentage of their maximum score"`
+	Mandatory               bool      `json:"mandatory" csv:"mandatory" description:"Whether a response to the item is required"`
+	Inactive                bool      `json:"inactive" csv:"inactive" description:"Whether the item was hidden by a logic condition"`
+	LocationLatitude        *float32  `json:"location_latitude" csv:"location_latitude" description:"Latitude of the location response"`
+	LocationLongitude       *float32  `json:"location_longitude" csv:"location_longitude" description:"Longitude of the location response"`
+	PrimeelementID          string    `json:"primeelement_id" csv:"primeelement_id" gorm:"size:100" description:"ID of the repeated section the item belongs to"`
+	PrimeelementIndex       *int64    `json:"primeelement_index" csv:"primeelement_index" description:"Position of the repeated section the item belongs to"`
 }
 
 // InspectionItemFeed is a representation of the inspection_items feed
@@ -71,7 +70,14 @@ type InspectionItemFeed struct {
 	IncludeInactive bool
 	Incremental     bool
 	ExportMedia     bool
-	Limit           int
+	// MediaDownloader is the pool the media are downloaded through, shared with the other feeds of the export
+	MediaDownloader *MediaDownloader
+	// MediaStore keeps the media in a content-addressed store, they are written per audit when not set
+	MediaStore MediaStore
+	Limit      int
+	// DeletionMode is the deletion mode of the inspections, the items of the inspections are only deleted when they are
+	// hard deleted, soft deleted inspections keep their items
+	DeletionMode DeletionMode
 }
 
 // Name is the name of the feed
@@ -141,6 +147,7 @@ func (f *InspectionItemFeed) Order() string {
 	return "modified_at ASC, id"
 }
 
+// fetchAndWriteMedia downloads a media through the exporter, to the folder of its audit or of its task
 func fetchAndWriteMedia(ctx context.Context, apiClient *httpapi.Client, exporter Exporter, auditID, mediaURL string) error {
 	status := GetExporterStatus()
 	resp, err := GetMedia(
@@ -166,9 +173,18 @@ func fetchAndWriteMedia(ctx context.Context, apiClient *httpapi.Client, exporter
 	}
 
 	status.IncrementStatus("media", 1, 0)
+	metrics.IncMediaDownloaded()
 	return nil
 }
 
+// fetchMedia downloads a media of an item into the content-addressed store when set, or through the exporter
+func (f *InspectionItemFeed) fetchMedia(ctx context.Context, apiClient *httpapi.Client, exporter Exporter, row *InspectionItem, mediaURL string) error {
+	if f.MediaStore != nil {
+		return fetchAndStoreMedia(ctx, apiClient, f.MediaStore, row.AuditID, row.ItemID, mediaURL)
+	}
+	return fetchAndWriteMedia(ctx, apiClient, exporter, row.AuditID, mediaURL)
+}
+
 func (f *InspectionItemFeed) writeRows(ctx context.Context, exporter Exporter, rows []*InspectionItem, skipFields []string, apiClient *httpapi.Client) error {
 	l := logger.GetLogger()
 	skipIDs := map[string]bool{}
@@ -179,14 +195,14 @@ func (f *InspectionItemFeed) writeRows(ctx context.Context, exporter Exporter, r
 	// Calculate the size of the batch we can insert into the DB at once. Column count + buffer to account for primary keys
 	batchSize := exporter.ParameterLimit() / (len(f.Columns()) + 5)
 	err := util.SplitSliceInBatch(batchSize, rows, func(batch []*InspectionItem) error {
-		// you can specify level of concurrency by increasing channel size
-		buffers := make(chan bool, maxGoRoutines)
-		var wg sync.WaitGroup
+		// the media of the batch are downloaded through the shared pool, the rows are written once they are done
+		media := f.MediaDownloader.NewBatch(ctx)
 
 		// Some audits in production have the same item ID multiple times
 		// We can't insert them simultaneously. This means we are dropping data, which sucks.
 		var rowsToInsert []*InspectionItem
 		idSeen := map[string]bool{}
+		cancelled := false
 		for _, row := range batch {
 			skip := skipIDs[row.AuditID]
 			seen := idSeen[row.ID]
@@ -196,39 +212,23 @@ func (f *InspectionItemFeed) writeRows(ctx context.Context, exporter Exporter, r
 			idSeen[row.ID] = true
 			rowsToInsert = append(rowsToInsert, processSkipFields(skipFields, row))
 
-			if !f.ExportMedia || len(row.MediaHypertextReference) == 0 {
+			if !f.ExportMedia || cancelled || len(row.MediaHypertextReference) == 0 {
 				continue
 			}
 
-			mediaURLList := strings.Split(row.MediaHypertextReference, "\n")
-			if len(mediaURLList) > 0 {
-				l.Infof(" downloading media for inspection item %s", row.ItemID)
-			}
-
-			for _, mediaURL := range mediaURLList {
-				wg.Add(1)
-
-				go func(mediaURL string, c context.Context) error {
-					defer wg.Done()
-					select {
-					case <-c.Done():
-						l.Infof(" ... canceling media downloads ")
-						return nil
-					default:
-						buffers <- true
-
-						if err := fetchAndWriteMedia(c, apiClient, exporter, row.AuditID, mediaURL); err != nil {
-							return events.WrapEventError(err, "write media")
-						}
-
-						<-buffers
-						return nil
-					}
-
-				}(mediaURL, ctx)
+			l.Infof(" downloading media for inspection item %s", row.ItemID)
+			for _, mediaURL := range splitMediaReferences(row.MediaHypertextReference) {
+				row, mediaURL := row, mediaURL
+				if !media.Go(mediaURL, func(c context.Context) error {
+					return f.fetchMedia(c, apiClient, exporter, row, mediaURL)
+				}) {
+					l.Infof(" ... canceling media downloads ")
+					cancelled = true
+					break
+				}
 			}
-			wg.Wait()
 		}
+		media.Wait()
 
 		if err := exporter.WriteRows(f, rowsToInsert); err != nil {
 			return err
@@ -285,6 +285,13 @@ func (f *InspectionItemFeed) Export(ctx context.Context, apiClient *httpapi.Clie
 		return events.WrapEventError(err, "export")
 	}
 
+	// Process the items of the deleted inspections
+	if f.DeletionMode == DeletionModeHard {
+		if err := processDeletedRows(ctx, apiClient, exporter, f, inspectionItemDeletionEvent, f.DeletionMode, f.Limit, f.ModifiedAfter, !f.Incremental); err != nil && events.IsBlockingError(err) {
+			return events.WrapEventError(err, "process deleted inspections")
+		}
+	}
+
 	err = exporter.FinaliseExport(f, &[]*InspectionItem{})
 	if err != nil {
 		return events.WrapEventError(err, "finalise export")
@@ -318,7 +325,7 @@ func (f *InspectionItemFeed) processInspectionItems(ctx context.Context, apiClie
 
 		l.With(
 			"estimated_remaining", resp.Metadata.RemainingRecords,
-			"duration_ms", apiClient.Duration.Milliseconds(),
+			"duration_ms", apiClient.Duration().Milliseconds(),
 			"export_duration_ms", exporter.GetDuration().Milliseconds(),
 		).Info("export batch complete")
 		return nil
@@ -337,18 +344,9 @@ func (f *InspectionItemFeed) processInspectionItems(ctx context.Context, apiClie
 		},
 	}
 
-	if f.ExportMedia {
-		status.StartFeedExport("media", false)
-	}
 	if err := DrainFeed(ctx, apiClient, req, drainFn); err != nil {
-		if f.ExportMedia {
-			status.FinishFeedExport("media", err)
-		}
 		return events.WrapEventError(err, fmt.Sprintf("feed %q", f.Name()))
 	}
-	if f.ExportMedia {
-		status.FinishFeedExport("media", nil)
-	}
 	return nil
 }
 
@@ -361,8 +359,16 @@ func (f *InspectionItemFeed) processInspectionItemsInBlocks(ctx context.Context,
 		endDate = time.Now()
 	}
 
+	// Resume from the time block an interrupted export stopped in
+	startDate := f.ModifiedAfter
+	checkpoint := exportCheckpointFrom(ctx)
+	if resumeFrom := checkpoint.resumeBlock(); resumeFrom != nil {
+		l.With("start", resumeFrom.Format(time.RFC3339)).Info("resuming block-based export")
+		startDate = *resumeFrom
+	}
+
 	// Generate time blocks
-	blocks, err := util.GenerateTimeBlocksFromString(f.ModifiedAfter, endDate, f.BlockSize)
+	blocks, err := util.GenerateTimeBlocksFromString(startDate, endDate, f.BlockSize)
 	if err != nil {
 		return fmt.Errorf("failed to generate time blocks: %w", err)
 	}
@@ -409,33 +415,22 @@ func (f *InspectionItemFeed) processInspectionItemsInBlocks(ctx context.Context,
 			l.With(
 				"block", i+1,
 				"estimated_remaining", resp.Metadata.RemainingRecords,
-				"duration_ms", apiClient.Duration.Milliseconds(),
+				"duration_ms", apiClient.Duration().Milliseconds(),
 				"export_duration_ms", exporter.GetDuration().Milliseconds(),
 			).Info("export batch complete")
 
 			return nil
 		}
 
-		if f.ExportMedia && i == 0 { // Start media export only once
-			status.StartFeedExport("media", false)
-		}
-
+		checkpoint.saveBlock(block.Start)
 		if err := DrainFeed(ctx, apiClient, &req, drainFn); err != nil {
 			l.With("block", i+1, "error", err).Error("failed to process block")
-			if f.ExportMedia {
-				status.FinishFeedExport("media", err)
-			}
 			return fmt.Errorf("failed to process block %d/%d: %w", i+1, len(blocks), err)
 		}
 
 		l.With("block", i+1, "total_blocks", len(blocks)).Info("completed time block")
 	}
 
-	// Finish media export if enabled
-	if f.ExportMedia {
-		status.FinishFeedExport("media", nil)
-	}
-
 	l.With("total_blocks", len(blocks)).Info("completed block-based export")
 	return nil
 }
@@ -15,34 +15,47 @@ import (
 
 // Issue represents a row from the issues feed
 type Issue struct {
-	ID              string     `json:"id" csv:"id" gorm:"primarykey;column:id;size:36"`
-	Title           string     `json:"title" csv:"title"`
-	Description     string     `json:"description" csv:"description"`
-	CreatorID       string     `json:"creator_id" csv:"creator_id"`
-	CreatorUserName string     `json:"creator_user_name" csv:"creator_user_name"`
-	CreatedAt       time.Time  `json:"created_at" csv:"created_at"`
-	DueAt           *time.Time `json:"due_at,omitempty" csv:"due_at"`
-	Priority        string     `json:"priority" csv:"priority"`
-	Status          string     `json:"status" csv:"status"`
-	TemplateID      string     `json:"template_id" csv:"template_id"`
-	InspectionID    string     `json:"inspection_id" csv:"inspection_id"`
-	InspectionName  string     `json:"inspection_name" csv:"inspection_name"`
-	SiteID          string     `json:"site_id" csv:"site_id"`
-	SiteName        string     `json:"site_name" csv:"site_name"`
-	LocationName    string     `json:"location_name" csv:"location_name"`
-	CategoryID      string     `json:"category_id" csv:"category_id"`
-	CategoryLabel   string     `json:"category_label" csv:"category_label"`
-	ModifiedAt      time.Time  `json:"modified_at" csv:"modified_at"`
-	CompletedAt     *time.Time `json:"completed_at" csv:"completed_at"`
-	AssetID         string     `json:"asset_id" csv:"asset_id" gorm:"size:36"`
-	UniqueID        string     `json:"unique_id" csv:"unique_id"`
-	OccurredAt      *time.Time `json:"occurred_at" csv:"occurred_at"`
+	ID              string     `json:"id" csv:"id" gorm:"primarykey;column:id;size:36" description:"ID of the issue"`
+	Title           string     `json:"title" csv:"title" description:"Title of the issue"`
+	Description     string     `json:"description" csv:"description" description:"Description of the issue"`
+	CreatorID       string     `json:"creator_id" csv:"creator_id" description:"ID of the user who reported the issue"`
+	CreatorUserName string     `json:"creator_user_name" csv:"creator_user_name" description:"Name of the user who reported the issue"`
+	CreatedAt       time.Time  `json:"created_at" csv:"created_at" description:"Time the issue was created"`
+	DueAt           *time.Time `json:"due_at,omitempty" csv:"due_at" description:"Time the issue is due"`
+	Priority        string     `json:"priority" csv:"priority" description:"Priority of the issue"`
+	Status          string     `json:"status" csv:"status" description:"Status of the issue"`
+	TemplateID      string     `json:"template_id" csv:"template_id" description:"ID of the template of the issue"`
+	InspectionID    string     `json:"inspection_id" csv:"inspection_id" description:"ID of the inspection the issue was raised in"`
+	InspectionName  string     `json:"inspection_name" csv:"inspection_name" description:"Title of the inspection the issue was raised in"`
+	SiteID          string     `json:"site_id" csv:"site_id" description:"ID of the site of the issue"`
+	SiteName        string     `json:"site_name" csv:"site_name" description:"Name of the site of the issue"`
+	LocationName    string     `json:"location_name" csv:"location_name" description:"Location of the issue"`
+	CategoryID      string     `json:"category_id" csv:"category_id" description:"ID of the category of the issue"`
+	CategoryLabel   string     `json:"category_label" csv:"category_label" description:"Label of the category of the issue"`
+	ModifiedAt      time.Time  `json:"modified_at" csv:"modified_at" description:"Time the issue was last modified"`
+	CompletedAt     *time.Time `json:"completed_at" csv:"completed_at" description:"Time the issue was completed"`
+	AssetID         string     `json:"asset_id" csv:"asset_id" gorm:"size:36" description:"ID of the asset of the issue"`
+	UniqueID        string     `json:"unique_id" csv:"unique_id" description:"Unique ID of the issue shown in SafetyCulture"`
+	OccurredAt      *time.Time `json:"occurred_at" csv:"occurred_at" description:"Time the issue occurred"`
+	Deleted         bool       `json:"deleted" csv:"deleted" description:"Whether the issue was deleted"`
+	DeletedAt       *time.Time `json:"deleted_at" csv:"deleted_at" description:"Time the issue was deleted, empty when it was not"`
+	// MediaIDs are the IDs of the media attached to the issue, one per line
+	MediaIDs                string `json:"media_ids" csv:"media_ids" description:"IDs of the media attached to the issue, one per line"`
+	MediaHypertextReference string `json:"media_hypertext_reference" csv:"media_hypertext_reference" description:"Links to the media attached to the issue, one per line"`
 }
 
 // IssueFeed is a representation of the issues feed
 type IssueFeed struct {
-	Limit       int
-	Incremental bool
+	Limit        int
+	Incremental  bool
+	DeletionMode DeletionMode
+	DeletedAfter time.Time
+	// ExportMedia downloads the media of the issues to <media_path>/issues/<issue_id>
+	ExportMedia bool
+	// MediaDownloader is the pool the media are downloaded through, shared with the other feeds of the export
+	MediaDownloader *MediaDownloader
+	// MediaStore keeps the media in a content-addressed store, they are written per task when not set
+	MediaStore MediaStore
 }
 
 // Name returns the name of the feed
@@ -78,6 +91,7 @@ func (f *IssueFeed) Columns() []string {
 		"inspection_id", "inspection_name", "site_id", "site_name",
 		"location_name", "category_id", "category_label", "modified_at",
 		"completed_at", "asset_id", "unique_id", "occurred_at",
+		"media_ids", "media_hypertext_reference",
 	}
 }
 
@@ -110,6 +124,15 @@ func (f *IssueFeed) Export(ctx context.Context, apiClient *httpapi.Client, expor
 			return events.NewEventErrorWithMessage(err, events.ErrorSeverityError, events.ErrorSubSystemDataIntegrity, false, "map data")
 		}
 
+		var media []taskMedia
+		for _, row := range rows {
+			row.MediaIDs = taskMediaIDs(row.MediaIDs, row.MediaHypertextReference)
+			if f.ExportMedia {
+				media = append(media, collectTaskMedia(f.Name(), row.ID, row.MediaHypertextReference)...)
+			}
+		}
+		writeTaskMedia(ctx, f.MediaDownloader, apiClient, exporter, f.MediaStore, media)
+
 		numRows := len(rows)
 		if numRows != 0 {
 			// Calculate the size of the batch we can insert into the DB at once.
@@ -128,11 +151,11 @@ func (f *IssueFeed) Export(ctx context.Context, apiClient *httpapi.Client, expor
 		}
 
 		// note: this feed api doesn't return remaining items
-		status.IncrementStatus(f.Name(), int64(numRows), apiClient.Duration.Milliseconds())
+		status.IncrementStatus(f.Name(), int64(numRows), apiClient.Duration().Milliseconds())
 
 		l.With(
 			"downloaded", status.ReadCounter(f.Name()),
-			"duration_ms", apiClient.Duration.Milliseconds(),
+			"duration_ms", apiClient.Duration().Milliseconds(),
 			"export_duration_ms", exporter.GetDuration().Milliseconds(),
 		).Info("export batch complete")
 		return nil
@@ -148,5 +171,11 @@ func (f *IssueFeed) Export(ctx context.Context, apiClient *httpapi.Client, expor
 	if err := DrainFeed(ctx, apiClient, req, drainFn); err != nil {
 		return events.WrapEventError(err, fmt.Sprintf("feed %q", f.Name()))
 	}
+
+	// Process Deleted Issues
+	if err := processDeletedRows(ctx, apiClient, exporter, f, issueDeletionEvent, f.DeletionMode, f.Limit, f.DeletedAfter, !f.Incremental); err != nil && events.IsBlockingError(err) {
+		return events.WrapEventError(err, "process deleted issues")
+	}
+
 	return exporter.FinaliseExport(f, &[]*Issue{})
 }
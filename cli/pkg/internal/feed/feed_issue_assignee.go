@@ -13,13 +13,13 @@ import (
 )
 
 type IssueAssignee struct {
-	ID             string    `json:"id" csv:"id" gorm:"primarykey;column:id;size:375"`
-	IssueID        string    `json:"issue_id" csv:"issue_id" gorm:"index:idx_issue_asg_issue_id;column:issue_id;size:36"`
-	AssigneeID     string    `json:"assignee_id" csv:"assignee_id" gorm:"size:256"`
-	Name           string    `json:"name" csv:"name"`
-	OrganisationID string    `json:"organisation_id" csv:"organisation_id" gorm:"size:37"`
-	ModifiedAt     time.Time `json:"modified_at" csv:"modified_at" gorm:"index:idx_issue_asg_modified_at,sort:desc;column:modified_at"`
-	Type           string    `json:"type" csv:"type"`
+	ID             string    `json:"id" csv:"id" gorm:"primarykey;column:id;size:375" description:"ID of the assignment"`
+	IssueID        string    `json:"issue_id" csv:"issue_id" gorm:"index:idx_issue_asg_issue_id;column:issue_id;size:36" description:"ID of the issue"`
+	AssigneeID     string    `json:"assignee_id" csv:"assignee_id" gorm:"size:256" description:"ID of the user or group assigned"`
+	Name           string    `json:"name" csv:"name" description:"Name of the user or group assigned"`
+	OrganisationID string    `json:"organisation_id" csv:"organisation_id" gorm:"size:37" description:"ID of the organisation the row belongs to"`
+	ModifiedAt     time.Time `json:"modified_at" csv:"modified_at" gorm:"index:idx_issue_asg_modified_at,sort:desc;column:modified_at" description:"Time the issue was last modified"`
+	Type           string    `json:"type" csv:"type" description:"Type of the assignee, user or group"`
 }
 
 type IssueAssigneeFeed struct {
@@ -78,11 +78,11 @@ func (f *IssueAssigneeFeed) Export(ctx context.Context, apiClient *httpapi.Clien
 			}
 		}
 
-		status.IncrementStatus(f.Name(), int64(numRows), apiClient.Duration.Milliseconds())
+		status.IncrementStatus(f.Name(), int64(numRows), apiClient.Duration().Milliseconds())
 
 		l.With(
 			"downloaded", status.ReadCounter(f.Name()),
-			"duration_ms", apiClient.Duration.Milliseconds(),
+			"duration_ms", apiClient.Duration().Milliseconds(),
 			"export_duration_ms", exporter.GetDuration().Milliseconds(),
 		).Info("export batch complete")
 		return nil
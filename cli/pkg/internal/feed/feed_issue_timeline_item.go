@@ -14,22 +14,31 @@ import (
 
 // IssueTimelineItem represents a row from the issue timeline items feed
 type IssueTimelineItem struct {
-	ID              string    `json:"id" csv:"item_id" gorm:"primarykey;column:item_id;size:36"`
-	TaskID          string    `json:"task_id" csv:"task_id"`
-	OrganisationID  string    `json:"organisation_id" csv:"organisation_id"`
-	TaskCreatorID   string    `json:"task_creator_id" csv:"task_creator_id"`
-	TaskCreatorName string    `json:"task_creator_name" csv:"task_creator_name"`
-	Timestamp       time.Time `json:"timestamp" csv:"timestamp" gorm:"index:idx_iss_tim_timestamp,sort:desc"`
-	CreatorID       string    `json:"creator_id" csv:"creator_id"`
-	CreatorName     string    `json:"creator_name" csv:"creator_name"`
-	ItemType        string    `json:"item_type" csv:"item_type"`
-	ItemData        string    `json:"item_data" csv:"item_data"`
+	ID              string    `json:"id" csv:"item_id" gorm:"primarykey;column:item_id;size:36" description:"ID of the timeline item"`
+	TaskID          string    `json:"task_id" csv:"task_id" description:"ID of the issue"`
+	OrganisationID  string    `json:"organisation_id" csv:"organisation_id" description:"ID of the organisation the row belongs to"`
+	TaskCreatorID   string    `json:"task_creator_id" csv:"task_creator_id" description:"ID of the user who reported the issue"`
+	TaskCreatorName string    `json:"task_creator_name" csv:"task_creator_name" description:"Name of the user who reported the issue"`
+	Timestamp       time.Time `json:"timestamp" csv:"timestamp" gorm:"index:idx_iss_tim_timestamp,sort:desc" description:"Time the timeline item was created"`
+	CreatorID       string    `json:"creator_id" csv:"creator_id" description:"ID of the user who created the timeline item"`
+	CreatorName     string    `json:"creator_name" csv:"creator_name" description:"Name of the user who created the timeline item"`
+	ItemType        string    `json:"item_type" csv:"item_type" description:"Type of the timeline item, such as a comment or a status change"`
+	ItemData        string    `json:"item_data" csv:"item_data" description:"Content of the timeline item"`
+	// MediaIDs are the IDs of the media attached to the timeline item, one per line
+	MediaIDs                string `json:"media_ids" csv:"media_ids" description:"IDs of the media attached to the timeline item, one per line"`
+	MediaHypertextReference string `json:"media_hypertext_reference" csv:"media_hypertext_reference" description:"Links to the media attached to the timeline item, one per line"`
 }
 
 // IssueTimelineItemFeed is a representation of the issue timeline items feed
 type IssueTimelineItemFeed struct {
 	Limit       int
 	Incremental bool
+	// ExportMedia downloads the media of the timeline items to <media_path>/issues/<task_id>
+	ExportMedia bool
+	// MediaDownloader is the pool the media are downloaded through, shared with the other feeds of the export
+	MediaDownloader *MediaDownloader
+	// MediaStore keeps the media in a content-addressed store, they are written per task when not set
+	MediaStore MediaStore
 }
 
 // Name is the name of the feed
@@ -69,6 +78,8 @@ func (f *IssueTimelineItemFeed) Columns() []string {
 		"creator_name",
 		"item_type",
 		"item_data",
+		"media_ids",
+		"media_hypertext_reference",
 	}
 }
 
@@ -101,6 +112,16 @@ func (f *IssueTimelineItemFeed) Export(ctx context.Context, apiClient *httpapi.C
 			return fmt.Errorf("map data: %w", err)
 		}
 
+		issuesFeed := &IssueFeed{}
+		var media []taskMedia
+		for _, row := range rows {
+			row.MediaIDs = taskMediaIDs(row.MediaIDs, row.MediaHypertextReference)
+			if f.ExportMedia {
+				media = append(media, collectTaskMedia(issuesFeed.Name(), row.TaskID, row.MediaHypertextReference)...)
+			}
+		}
+		writeTaskMedia(ctx, f.MediaDownloader, apiClient, exporter, f.MediaStore, media)
+
 		numRows := len(rows)
 		if numRows != 0 {
 			// Calculate the size of the batch we can insert into the DB at once.
@@ -119,11 +140,11 @@ func (f *IssueTimelineItemFeed) Export(ctx context.Context, apiClient *httpapi.C
 		}
 
 		// note: this feed api doesn't return remaining items
-		status.IncrementStatus(f.Name(), int64(numRows), apiClient.Duration.Milliseconds())
+		status.IncrementStatus(f.Name(), int64(numRows), apiClient.Duration().Milliseconds())
 
 		l.With(
 			"downloaded", status.ReadCounter(f.Name()),
-			"duration_ms", apiClient.Duration.Milliseconds(),
+			"duration_ms", apiClient.Duration().Milliseconds(),
 			"export_duration_ms", exporter.GetDuration().Milliseconds(),
 		).Info("export batch complete")
 		return nil
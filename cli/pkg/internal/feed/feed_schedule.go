@@ -15,25 +15,25 @@ import (
 
 // Schedule represents a row from the schedules feed
 type Schedule struct {
-	ID              string     `json:"id" csv:"schedule_id" gorm:"primarykey;column:schedule_id;size:45"`
-	Description     string     `json:"description" csv:"description"`
-	Recurrence      string     `json:"recurrence" csv:"recurrence" gorm:"size:100"`
-	Duration        string     `json:"duration" csv:"duration" gorm:"size:50"`
-	ModifiedAt      time.Time  `json:"modified_at" csv:"modified_at"`
-	ExportedAt      time.Time  `json:"exported_at" csv:"exported_at" gorm:"autoUpdateTime"`
-	FromDate        time.Time  `json:"from_date" csv:"from_date"`
-	ToDate          *time.Time `json:"to_date" csv:"to_date"`
-	StartTimeHour   int        `json:"start_time_hour" csv:"start_time_hour"`
-	StartTimeMinute int        `json:"start_time_minute" csv:"start_time_minute"`
-	AllMustComplete bool       `json:"all_must_complete" csv:"all_must_complete"`
-	Status          string     `json:"status" csv:"status" gorm:"size:10"`
-	OrganisationID  string     `json:"organisation_id" csv:"organisation_id" gorm:"size:37"`
-	Timezone        string     `json:"timezone" csv:"timezone"`
-	CanLateSubmit   bool       `json:"can_late_submit" csv:"can_late_submit"`
-	SiteID          string     `json:"site_id" csv:"site_id" gorm:"size:41"`
-	TemplateID      string     `json:"template_id" csv:"template_id" gorm:"size:100"`
-	CreatorUserID   string     `json:"creator_user_id" csv:"creator_user_id" gorm:"size:37"`
-	AssetID         string     `json:"asset_id" csv:"asset_id" gorm:"size:37"`
+	ID              string     `json:"id" csv:"schedule_id" gorm:"primarykey;column:schedule_id;size:45" description:"ID of the schedule"`
+	Description     string     `json:"description" csv:"description" description:"Description of the schedule"`
+	Recurrence      string     `json:"recurrence" csv:"recurrence" gorm:"size:100" description:"Recurrence rule of the schedule"`
+	Duration        string     `json:"duration" csv:"duration" gorm:"size:50" description:"Time allowed to complete each occurrence, as an ISO 8601 duration"`
+	ModifiedAt      time.Time  `json:"modified_at" csv:"modified_at" description:"Time the schedule was last modified"`
+	ExportedAt      time.Time  `json:"exported_at" csv:"exported_at" gorm:"autoUpdateTime" description:"Time the row was last written by the exporter"`
+	FromDate        time.Time  `json:"from_date" csv:"from_date" description:"Time the schedule starts"`
+	ToDate          *time.Time `json:"to_date" csv:"to_date" description:"Time the schedule ends, empty when it does not"`
+	StartTimeHour   int        `json:"start_time_hour" csv:"start_time_hour" description:"Hour of the day the occurrences start"`
+	StartTimeMinute int        `json:"start_time_minute" csv:"start_time_minute" description:"Minute of the hour the occurrences start"`
+	AllMustComplete bool       `json:"all_must_complete" csv:"all_must_complete" description:"Whether every assignee must complete each occurrence"`
+	Status          string     `json:"status" csv:"status" gorm:"size:10" description:"Status of the schedule"`
+	OrganisationID  string     `json:"organisation_id" csv:"organisation_id" gorm:"size:37" description:"ID of the organisation the row belongs to"`
+	Timezone        string     `json:"timezone" csv:"timezone" description:"Time zone of the schedule"`
+	CanLateSubmit   bool       `json:"can_late_submit" csv:"can_late_submit" description:"Whether inspections can be submitted after an occurrence is due"`
+	SiteID          string     `json:"site_id" csv:"site_id" gorm:"size:41" description:"ID of the site of the schedule"`
+	TemplateID      string     `json:"template_id" csv:"template_id" gorm:"size:100" description:"ID of the template of the schedule"`
+	CreatorUserID   string     `json:"creator_user_id" csv:"creator_user_id" gorm:"size:37" description:"ID of the user who created the schedule"`
+	AssetID         string     `json:"asset_id" csv:"asset_id" gorm:"size:37" description:"ID of the asset of the schedule"`
 }
 
 // ScheduleFeed is a representation of the schedules feed
@@ -139,7 +139,7 @@ func (f *ScheduleFeed) Export(ctx context.Context, apiClient *httpapi.Client, ex
 
 		l.With(
 			"estimated_remaining", resp.Metadata.RemainingRecords,
-			"duration_ms", apiClient.Duration.Milliseconds(),
+			"duration_ms", apiClient.Duration().Milliseconds(),
 			"export_duration_ms", exporter.GetDuration().Milliseconds(),
 		).Info("export batch complete")
 		return nil
@@ -15,13 +15,13 @@ import (
 
 // ScheduleAssignee represents a row from the schedule_assignees feed
 type ScheduleAssignee struct {
-	ID             string    `json:"id" csv:"id" gorm:"primarykey;size:100"`
-	ScheduleID     string    `json:"schedule_id" csv:"schedule_id" gorm:"size:45"`
-	AssigneeID     string    `json:"assignee_id" csv:"assignee_id" gorm:"size:37"`
-	OrganisationID string    `json:"organisation_id" csv:"organisation_id" gorm:"size:37"`
-	Type           string    `json:"type" csv:"type" gorm:"size:10"`
-	Name           string    `json:"name" csv:"name"`
-	ExportedAt     time.Time `json:"exported_at" csv:"exported_at" gorm:"autoUpdateTime"`
+	ID             string    `json:"id" csv:"id" gorm:"primarykey;size:100" description:"ID of the assignment"`
+	ScheduleID     string    `json:"schedule_id" csv:"schedule_id" gorm:"size:45" description:"ID of the schedule"`
+	AssigneeID     string    `json:"assignee_id" csv:"assignee_id" gorm:"size:37" description:"ID of the user or group assigned"`
+	OrganisationID string    `json:"organisation_id" csv:"organisation_id" gorm:"size:37" description:"ID of the organisation the row belongs to"`
+	Type           string    `json:"type" csv:"type" gorm:"size:10" description:"Type of the assignee, user or group"`
+	Name           string    `json:"name" csv:"name" description:"Name of the user or group assigned"`
+	ExportedAt     time.Time `json:"exported_at" csv:"exported_at" gorm:"autoUpdateTime" description:"Time the row was last written by the exporter"`
 }
 
 // ScheduleAssigneeFeed is a representation of the schedule_assignees feed
@@ -114,7 +114,7 @@ func (f *ScheduleAssigneeFeed) Export(ctx context.Context, apiClient *httpapi.Cl
 
 		l.With(
 			"estimated_remaining", resp.Metadata.RemainingRecords,
-			"duration_ms", apiClient.Duration.Milliseconds(),
+			"duration_ms", apiClient.Duration().Milliseconds(),
 			"export_duration_ms", exporter.GetDuration().Milliseconds(),
 		).Info("export batch complete")
 		return nil
@@ -16,21 +16,21 @@ import (
 
 // ScheduleOccurrence represents a row from the schedule_occurrences feed
 type ScheduleOccurrence struct {
-	ID               string     `json:"id" csv:"id" gorm:"primarykey;size:128"`
-	ScheduleID       string     `json:"schedule_id" csv:"schedule_id" gorm:"size:45"`
-	OccurrenceID     string     `json:"occurrence_id" csv:"occurrence_id" gorm:"size:30"`
-	TemplateID       string     `json:"template_id" csv:"template_id" gorm:"size:100"`
-	OrganisationID   string     `json:"organisation_id" csv:"organisation_id" gorm:"size:37"`
-	StartTime        *time.Time `json:"start_time" csv:"start_time"`
-	DueTime          *time.Time `json:"due_time" csv:"due_time"`
-	MissTime         *time.Time `json:"miss_time" csv:"miss_time"`
-	OccurrenceStatus string     `json:"occurrence_status" csv:"occurrence_status" gorm:"size:20"`
-	AuditID          *string    `json:"audit_id" csv:"audit_id" gorm:"size:100"`
-	CompletedAt      *time.Time `json:"completed_at" csv:"completed_at"`
-	ExportedAt       time.Time  `json:"exported_at" csv:"exported_at" gorm:"autoUpdateTime"`
-	UserID           string     `json:"user_id" csv:"user_id" gorm:"size:37"`
-	AssigneeStatus   string     `json:"assignee_status" csv:"assignee_status" gorm:"size:20"`
-	Note             string     `json:"note" csv:"note" gorm:"size:255"`
+	ID               string     `json:"id" csv:"id" gorm:"primarykey;size:128" description:"ID of the row"`
+	ScheduleID       string     `json:"schedule_id" csv:"schedule_id" gorm:"size:45" description:"ID of the schedule"`
+	OccurrenceID     string     `json:"occurrence_id" csv:"occurrence_id" gorm:"size:30" description:"ID of the occurrence"`
+	TemplateID       string     `json:"template_id" csv:"template_id" gorm:"size:100" description:"ID of the template of the occurrence"`
+	OrganisationID   string     `json:"organisation_id" csv:"organisation_id" gorm:"size:37" description:"ID of the organisation the row belongs to"`
+	StartTime        *time.Time `json:"start_time" csv:"start_time" description:"Time the occurrence starts"`
+	DueTime          *time.Time `json:"due_time" csv:"due_time" description:"Time the occurrence is due"`
+	MissTime         *time.Time `json:"miss_time" csv:"miss_time" description:"Time the occurrence is missed"`
+	OccurrenceStatus string     `json:"occurrence_status" csv:"occurrence_status" gorm:"size:20" description:"Status of the occurrence"`
+	AuditID          *string    `json:"audit_id" csv:"audit_id" gorm:"size:100" description:"ID of the inspection completing the occurrence"`
+	CompletedAt      *time.Time `json:"completed_at" csv:"completed_at" description:"Time the occurrence was completed"`
+	ExportedAt       time.Time  `json:"exported_at" csv:"exported_at" gorm:"autoUpdateTime" description:"Time the row was last written by the exporter"`
+	UserID           string     `json:"user_id" csv:"user_id" gorm:"size:37" description:"ID of the user assigned to the occurrence"`
+	AssigneeStatus   string     `json:"assignee_status" csv:"assignee_status" gorm:"size:20" description:"Status of the occurrence for the user"`
+	Note             string     `json:"note" csv:"note" gorm:"size:255" description:"Note about the occurrence"`
 }
 
 // ScheduleOccurrenceFeed is a representation of the schedule_occurrences feed
@@ -141,7 +141,7 @@ func (f *ScheduleOccurrenceFeed) Export(ctx context.Context, apiClient *httpapi.
 
 		l.With(
 			"estimated_remaining", resp.Metadata.RemainingRecords,
-			"duration_ms", apiClient.Duration.Milliseconds(),
+			"duration_ms", apiClient.Duration().Milliseconds(),
 			"export_duration_ms", exporter.GetDuration().Milliseconds(),
 		).Info("export batch complete")
 		return nil
@@ -18,30 +18,30 @@ import (
 
 // SheqsyActivity represents a user in sheqsy
 type SheqsyActivity struct {
-	ActivityUID             string    `json:"activityUId" csv:"activity_uid" gorm:"primarykey;column:activity_uid;size:36"`
-	ActivityID              int       `json:"activityId" csv:"activity_id" gorm:"column:activity_id"`
-	ExternalID              *string   `json:"externalId" csv:"external_id" gorm:"column:external_id"`
-	Email                   string    `json:"email" csv:"email" gorm:"column:email"`
-	PhoneNumber             string    `json:"phoneNumber" csv:"phone_number" gorm:"column:phone_number"`
-	ActivityName            string    `json:"activityName" csv:"activity_name" gorm:"column:activity_name"`
-	StartDateTimeUTC        time.Time `json:"startDateTimeUTC" csv:"start_date_time_utc" gorm:"column:start_date_time_utc"`
-	FinishDateTimeUTC       time.Time `json:"finishDateTimeUTC" csv:"finish_date_time_utc" gorm:"column:finish_date_time_utc"`
-	ActivityType            string    `json:"activityType" csv:"activity_type" gorm:"column:activity_type"`
-	EmployeeName            string    `json:"employeeName" csv:"employee_name" gorm:"column:employee_name"`
-	EmployeeSurname         string    `json:"employeeSurname" csv:"employee_surname" gorm:"column:employee_surname"`
-	StartLatitude           float64   `json:"startLatitude" csv:"start_latitude" gorm:"column:start_latitude"`
-	StartLongitude          float64   `json:"startLongitude" csv:"start_longitude" gorm:"column:start_longitude"`
-	StartAddress            string    `json:"startAddress" csv:"start_address" gorm:"column:start_address"`
-	FinishLatitude          float64   `json:"finishLatitude" csv:"finish_latitude" gorm:"column:finish_latitude"`
-	FinishLongitude         float64   `json:"finishLongitude" csv:"finish_longitude" gorm:"column:finish_longitude"`
-	FinishAddress           string    `json:"finishAddress" csv:"finish_address" gorm:"column:finish_address"`
-	TimeSpentSec            int       `json:"timeSpentSec" csv:"time_spent_sec" gorm:"column:time_spent_sec"`
-	Version                 int       `json:"version" csv:"version" gorm:"column:version"`
-	TimeEnrouteSec          int       `json:"timeEnrouteSec" csv:"time_enroute_sec" gorm:"column:time_enroute_sec"`
-	DistanceTravelledMeters int       `json:"distanceTravelledMeters" csv:"distance_travelled_meters" gorm:"column:distance_travelled_meters"`
-	ShiftID                 *int      `json:"shiftId" csv:"shift_id" gorm:"column:shift_id"`
-	Departments             string    `json:"departments" csv:"departments" gorm:"type:string;column:departments"`
-	ExportedAt              time.Time `json:"exported_at" csv:"exported_at" gorm:"autoUpdateTime"`
+	ActivityUID             string    `json:"activityUId" csv:"activity_uid" gorm:"primarykey;column:activity_uid;size:36" description:"Unique ID of the activity"`
+	ActivityID              int       `json:"activityId" csv:"activity_id" gorm:"column:activity_id" description:"ID of the activity"`
+	ExternalID              *string   `json:"externalId" csv:"external_id" gorm:"column:external_id" description:"ID of the activity in an external system"`
+	Email                   string    `json:"email" csv:"email" gorm:"column:email" description:"Email address of the employee"`
+	PhoneNumber             string    `json:"phoneNumber" csv:"phone_number" gorm:"column:phone_number" description:"Phone number of the employee"`
+	ActivityName            string    `json:"activityName" csv:"activity_name" gorm:"column:activity_name" description:"Name of the activity"`
+	StartDateTimeUTC        time.Time `json:"startDateTimeUTC" csv:"start_date_time_utc" gorm:"column:start_date_time_utc" description:"Time the activity started, in UTC"`
+	FinishDateTimeUTC       time.Time `json:"finishDateTimeUTC" csv:"finish_date_time_utc" gorm:"column:finish_date_time_utc" description:"Time the activity finished, in UTC"`
+	ActivityType            string    `json:"activityType" csv:"activity_type" gorm:"column:activity_type" description:"Type of the activity"`
+	EmployeeName            string    `json:"employeeName" csv:"employee_name" gorm:"column:employee_name" description:"First name of the employee"`
+	EmployeeSurname         string    `json:"employeeSurname" csv:"employee_surname" gorm:"column:employee_surname" description:"Last name of the employee"`
+	StartLatitude           float64   `json:"startLatitude" csv:"start_latitude" gorm:"column:start_latitude" description:"Latitude the activity started at"`
+	StartLongitude          float64   `json:"startLongitude" csv:"start_longitude" gorm:"column:start_longitude" description:"Longitude the activity started at"`
+	StartAddress            string    `json:"startAddress" csv:"start_address" gorm:"column:start_address" description:"Address the activity started at"`
+	FinishLatitude          float64   `json:"finishLatitude" csv:"finish_latitude" gorm:"column:finish_latitude" description:"Latitude the activity finished at"`
+	FinishLongitude         float64   `json:"finishLongitude" csv:"finish_longitude" gorm:"column:finish_longitude" description:"Longitude the activity finished at"`
+	FinishAddress           string    `json:"finishAddress" csv:"finish_address" gorm:"column:finish_address" description:"Address the activity finished at"`
+	TimeSpentSec            int       `json:"timeSpentSec" csv:"time_spent_sec" gorm:"column:time_spent_sec" description:"Time spent on the activity, in seconds"`
+	Version                 int       `json:"version" csv:"version" gorm:"column:version" description:"Version of the activity, incremented with each change"`
+	TimeEnrouteSec          int       `json:"timeEnrouteSec" csv:"time_enroute_sec" gorm:"column:time_enroute_sec" description:"Time spent travelling to the activity, in seconds"`
+	DistanceTravelledMeters int       `json:"distanceTravelledMeters" csv:"distance_travelled_meters" gorm:"column:distance_travelled_meters" description:"Distance travelled to the activity, in meters"`
+	ShiftID                 *int      `json:"shiftId" csv:"shift_id" gorm:"column:shift_id" description:"ID of the shift the activity is part of"`
+	Departments             string    `json:"departments" csv:"departments" gorm:"type:string;column:departments" description:"Names of the departments of the employee"`
+	ExportedAt              time.Time `json:"exported_at" csv:"exported_at" gorm:"autoUpdateTime" description:"Time the row was last written by the exporter"`
 }
 
 // SheqsyActivityFeed is a representation of the users feed
@@ -207,7 +207,7 @@ func (f *SheqsyActivityFeed) Export(ctx context.Context, apiClient *httpapi.Clie
 
 		log.With(
 			"estimated_remaining", 0,
-			"duration_ms", apiClient.Duration.Milliseconds(),
+			"duration_ms", apiClient.Duration().Milliseconds(),
 			"export_duration_ms", exporter.GetDuration().Milliseconds(),
 		).Info("export batch complete")
 	}
@@ -15,13 +15,13 @@ import (
 
 // SheqsyDepartment represents a user in sheqsy
 type SheqsyDepartment struct {
-	DepartmentUID string    `json:"departmentUId" csv:"department_uid" gorm:"primarykey;column:department_uid;size:36"`
-	DepartmentID  int       `json:"departmentId" csv:"department_id" gorm:"column:department_id"`
-	Name          string    `json:"name" csv:"name" gorm:"column:name"`
-	ExternalName  string    `json:"external_name" csv:"external_name" gorm:"column:external_name"`
-	Number        string    `json:"number" csv:"number" gorm:"column:number"`
-	Manager       string    `json:"manager" csv:"manager" gorm:"column:manager"`
-	ExportedAt    time.Time `json:"exported_at" csv:"exported_at" gorm:"autoUpdateTime"`
+	DepartmentUID string    `json:"departmentUId" csv:"department_uid" gorm:"primarykey;column:department_uid;size:36" description:"Unique ID of the department"`
+	DepartmentID  int       `json:"departmentId" csv:"department_id" gorm:"column:department_id" description:"ID of the department"`
+	Name          string    `json:"name" csv:"name" gorm:"column:name" description:"Name of the department"`
+	ExternalName  string    `json:"external_name" csv:"external_name" gorm:"column:external_name" description:"Name of the department in an external system"`
+	Number        string    `json:"number" csv:"number" gorm:"column:number" description:"Number of the department"`
+	Manager       string    `json:"manager" csv:"manager" gorm:"column:manager" description:"Manager of the department"`
+	ExportedAt    time.Time `json:"exported_at" csv:"exported_at" gorm:"autoUpdateTime" description:"Time the row was last written by the exporter"`
 }
 
 // SheqsyDepartmentFeed is a representation of the users feed
@@ -114,7 +114,7 @@ func (f *SheqsyDepartmentFeed) Export(ctx context.Context, apiClient *httpapi.Cl
 
 	log.With(
 		"estimated_remaining", 0,
-		"duration_ms", apiClient.Duration.Milliseconds(),
+		"duration_ms", apiClient.Duration().Milliseconds(),
 		"export_duration_ms", exporter.GetDuration().Milliseconds(),
 	).Info("export batch complete")
 
@@ -15,11 +15,11 @@ import (
 
 // SheqsyDepartmentEmployee represents a user in sheqsy
 type SheqsyDepartmentEmployee struct {
-	EmployeeUID   string    `json:"employeeUId" csv:"employee_uid" gorm:"primaryKey;column:employee_uid;size:36"`
-	DepartmentUID string    `json:"departmentUId" csv:"department_uid" gorm:"primaryKey;column:department_uid;size:36"`
-	EmployeeID    int       `json:"employeeId" csv:"employee_id" gorm:"column:employee_id"`
-	DepartmentID  int       `json:"departmentId" csv:"department_id" gorm:"column:department_id"`
-	ExportedAt    time.Time `json:"exported_at" csv:"exported_at" gorm:"autoUpdateTime"`
+	EmployeeUID   string    `json:"employeeUId" csv:"employee_uid" gorm:"primaryKey;column:employee_uid;size:36" description:"Unique ID of the employee"`
+	DepartmentUID string    `json:"departmentUId" csv:"department_uid" gorm:"primaryKey;column:department_uid;size:36" description:"Unique ID of the department"`
+	EmployeeID    int       `json:"employeeId" csv:"employee_id" gorm:"column:employee_id" description:"ID of the employee"`
+	DepartmentID  int       `json:"departmentId" csv:"department_id" gorm:"column:department_id" description:"ID of the department"`
+	ExportedAt    time.Time `json:"exported_at" csv:"exported_at" gorm:"autoUpdateTime" description:"Time the row was last written by the exporter"`
 }
 
 // SheqsyDepartmentEmployeeFeed is a representation of the users feed
@@ -130,7 +130,7 @@ func (f *SheqsyDepartmentEmployeeFeed) Export(ctx context.Context, apiClient *ht
 
 	log.With(
 		"estimated_remaining", 0,
-		"duration_ms", apiClient.Duration.Milliseconds(),
+		"duration_ms", apiClient.Duration().Milliseconds(),
 		"export_duration_ms", exporter.GetDuration().Milliseconds(),
 	).Info("export batch complete")
 
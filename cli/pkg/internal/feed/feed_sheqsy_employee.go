@@ -18,19 +18,19 @@ import (
 
 // SheqsyEmployee represents a user in sheqsy
 type SheqsyEmployee struct {
-	EmployeeUID             string    `json:"employeeUId" csv:"employee_uid" gorm:"primarykey;column:employee_uid;size:36"`
-	EmployeeID              int       `json:"employeeId" csv:"employee_id" gorm:"column:employee_id"`
-	ExternalID              *string   `json:"externalId" csv:"external_id" gorm:"column:external_id"`
-	FirstName               string    `json:"firstName" csv:"first_name" gorm:"column:first_name"`
-	LastName                string    `json:"lastName" csv:"last_name" gorm:"column:last_name"`
-	Email                   string    `json:"email" csv:"email" gorm:"column:email"`
-	AcceptedActivitiesCount int       `json:"acceptedActivitiesCount" csv:"accepted_activities_count" gorm:"column:accepted_activities_count"`
-	PendingActivitiesCount  int       `json:"pendingActivitiesCount" csv:"pending_activities_count" gorm:"column:pending_activities_count"`
-	IsInPanic               bool      `json:"isInPanic" csv:"is_in_panic" gorm:"column:is_in_panic"`
-	Status                  string    `json:"status" csv:"status" gorm:"column:status"`
-	LastActivityDateTimeUTC string    `json:"lastActivityDateTimeUTC" csv:"last_activity_date_time_utc" gorm:"column:last_activity_date_time_utc"`
-	Departments             string    `json:"departments" csv:"departments" gorm:"column:departments"`
-	ExportedAt              time.Time `json:"exported_at" csv:"exported_at" gorm:"autoUpdateTime"`
+	EmployeeUID             string    `json:"employeeUId" csv:"employee_uid" gorm:"primarykey;column:employee_uid;size:36" description:"Unique ID of the employee"`
+	EmployeeID              int       `json:"employeeId" csv:"employee_id" gorm:"column:employee_id" description:"ID of the employee"`
+	ExternalID              *string   `json:"externalId" csv:"external_id" gorm:"column:external_id" description:"ID of the employee in an external system"`
+	FirstName               string    `json:"firstName" csv:"first_name" gorm:"column:first_name" description:"First name of the employee"`
+	LastName                string    `json:"lastName" csv:"last_name" gorm:"column:last_name" description:"Last name of the employee"`
+	Email                   string    `json:"email" csv:"email" gorm:"column:email" description:"Email address of the employee"`
+	AcceptedActivitiesCount int       `json:"acceptedActivitiesCount" csv:"accepted_activities_count" gorm:"column:accepted_activities_count" description:"Number of activities the employee accepted"`
+	PendingActivitiesCount  int       `json:"pendingActivitiesCount" csv:"pending_activities_count" gorm:"column:pending_activities_count" description:"Number of activities pending for the employee"`
+	IsInPanic               bool      `json:"isInPanic" csv:"is_in_panic" gorm:"column:is_in_panic" description:"Whether the employee raised a panic alarm"`
+	Status                  string    `json:"status" csv:"status" gorm:"column:status" description:"Status of the employee"`
+	LastActivityDateTimeUTC string    `json:"lastActivityDateTimeUTC" csv:"last_activity_date_time_utc" gorm:"column:last_activity_date_time_utc" description:"Time of the last activity of the employee, in UTC"`
+	Departments             string    `json:"departments" csv:"departments" gorm:"column:departments" description:"Names of the departments of the employee"`
+	ExportedAt              time.Time `json:"exported_at" csv:"exported_at" gorm:"autoUpdateTime" description:"Time the row was last written by the exporter"`
 }
 
 // SheqsyEmployeeFeed is a representation of the users feed
@@ -162,7 +162,7 @@ func (f *SheqsyEmployeeFeed) Export(ctx context.Context, apiClient *httpapi.Clie
 
 	log.With(
 		"estimated_remaining", 0,
-		"duration_ms", apiClient.Duration.Milliseconds(),
+		"duration_ms", apiClient.Duration().Milliseconds(),
 		"export_duration_ms", exporter.GetDuration().Milliseconds(),
 	).Info("export batch complete")
 
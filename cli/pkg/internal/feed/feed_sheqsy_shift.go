@@ -18,18 +18,18 @@ import (
 
 // SheqsyShift represents a user in sheqsy
 type SheqsyShift struct {
-	ShiftID               int       `json:"shiftId" csv:"shift_id" gorm:"primarykey;column:shift_id;"`
-	EmployeeName          string    `json:"employeeName" csv:"employee_name" gorm:"column:employee_name"`
-	EmployeeSurname       string    `json:"employeeSurname" csv:"employee_surname" gorm:"column:employee_surname"`
-	Email                 string    `json:"email" csv:"email" gorm:"column:email"`
-	PhoneNumber           string    `json:"phoneNumber" csv:"phone_number" gorm:"column:phone_number"`
-	StartDateTimeUTC      time.Time `json:"startDateTimeUTC" csv:"start_date_time_utc" gorm:"column:start_date_time_utc"`
-	FinishDateTimeUTC     time.Time `json:"finishDateTimeUTC" csv:"finish_date_time_utc" gorm:"column:finish_date_time_utc"`
-	LastReportedAddress   string    `json:"lastReportedAddress" csv:"last_reported_address" gorm:"column:last_reported_address"`
-	LastReportedLatitude  float64   `json:"lastReportedLatitude" csv:"last_reported_latitude" gorm:"column:last_reported_latitude"`
-	LastReportedLongitude float64   `json:"lastReportedLongitude" csv:"last_reported_longitude" gorm:"column:last_reported_longitude"`
-	Version               int       `json:"version" csv:"version" gorm:"column:version"`
-	Departments           string    `json:"departments" csv:"departments" gorm:"column:departments"`
+	ShiftID               int       `json:"shiftId" csv:"shift_id" gorm:"primarykey;column:shift_id;" description:"ID of the shift"`
+	EmployeeName          string    `json:"employeeName" csv:"employee_name" gorm:"column:employee_name" description:"First name of the employee"`
+	EmployeeSurname       string    `json:"employeeSurname" csv:"employee_surname" gorm:"column:employee_surname" description:"Last name of the employee"`
+	Email                 string    `json:"email" csv:"email" gorm:"column:email" description:"Email address of the employee"`
+	PhoneNumber           string    `json:"phoneNumber" csv:"phone_number" gorm:"column:phone_number" description:"Phone number of the employee"`
+	StartDateTimeUTC      time.Time `json:"startDateTimeUTC" csv:"start_date_time_utc" gorm:"column:start_date_time_utc" description:"Time the shift started, in UTC"`
+	FinishDateTimeUTC     time.Time `json:"finishDateTimeUTC" csv:"finish_date_time_utc" gorm:"column:finish_date_time_utc" description:"Time the shift finished, in UTC"`
+	LastReportedAddress   string    `json:"lastReportedAddress" csv:"last_reported_address" gorm:"column:last_reported_address" description:"Last address reported during the shift"`
+	LastReportedLatitude  float64   `json:"lastReportedLatitude" csv:"last_reported_latitude" gorm:"column:last_reported_latitude" description:"Last latitude reported during the shift"`
+	LastReportedLongitude float64   `json:"lastReportedLongitude" csv:"last_reported_longitude" gorm:"column:last_reported_longitude" description:"Last longitude reported during the shift"`
+	Version               int       `json:"version" csv:"version" gorm:"column:version" description:"Version of the shift, incremented with each change"`
+	Departments           string    `json:"departments" csv:"departments" gorm:"column:departments" description:"Names of the departments of the employee"`
 }
 
 // SheqsyShiftFeed is a representation of the users feed
@@ -184,7 +184,7 @@ func (f *SheqsyShiftFeed) Export(ctx context.Context, apiClient *httpapi.Client,
 
 		log.With(
 			"estimated_remaining", 0,
-			"duration_ms", apiClient.Duration.Milliseconds(),
+			"duration_ms", apiClient.Duration().Milliseconds(),
 			"export_duration_ms", exporter.GetDuration().Milliseconds(),
 		).Info("export batch complete")
 	}
@@ -15,21 +15,24 @@ import (
 
 // Site represents a row from the sites feed
 type Site struct {
-	ID             string    `json:"id" csv:"site_id" gorm:"primarykey;column:site_id;size:41"`
-	Name           string    `json:"name" csv:"name"`
-	CreatorID      string    `json:"creator_id" csv:"creator_id" gorm:"size:37"`
-	OrganisationID string    `json:"organisation_id" csv:"organisation_id" gorm:"size:37"`
-	ExportedAt     time.Time `json:"exported_at" csv:"exported_at" gorm:"autoUpdateTime"`
-	Deleted        bool      `json:"deleted" csv:"deleted" gorm:"deleted"`
-	SiteUUID       string    `json:"site_uuid" csv:"site_uuid" gorm:"size:36"`
-	MetaLabel      string    `json:"meta_label" csv:"meta_label" gorm:"size:36"`
-	ParentID       string    `json:"parent_id" csv:"parent_id" gorm:"size:41"`
+	ID             string     `json:"id" csv:"site_id" gorm:"primarykey;column:site_id;size:41" description:"ID of the site"`
+	Name           string     `json:"name" csv:"name" description:"Name of the site"`
+	CreatorID      string     `json:"creator_id" csv:"creator_id" gorm:"size:37" description:"ID of the user who created the site"`
+	OrganisationID string     `json:"organisation_id" csv:"organisation_id" gorm:"size:37" description:"ID of the organisation the row belongs to"`
+	ExportedAt     time.Time  `json:"exported_at" csv:"exported_at" gorm:"autoUpdateTime" description:"Time the row was last written by the exporter"`
+	Deleted        bool       `json:"deleted" csv:"deleted" gorm:"deleted" description:"Whether the site was deleted"`
+	SiteUUID       string     `json:"site_uuid" csv:"site_uuid" gorm:"size:36" description:"UUID of the site"`
+	MetaLabel      string     `json:"meta_label" csv:"meta_label" gorm:"size:36" description:"Level of the site in the site hierarchy, such as area or region"`
+	ParentID       string     `json:"parent_id" csv:"parent_id" gorm:"size:41" description:"ID of the parent of the site in the site hierarchy"`
+	DeletedAt      *time.Time `json:"deleted_at" csv:"deleted_at" description:"Time the site was deleted, empty when it was not"`
 }
 
 // SiteFeed is a representation of the sites feed
 type SiteFeed struct {
 	IncludeDeleted       bool
 	IncludeFullHierarchy bool
+	DeletionMode         DeletionMode
+	DeletedAfter         time.Time
 }
 
 // Name is the name of the feed
@@ -116,11 +119,11 @@ func (f *SiteFeed) Export(ctx context.Context, apiClient *httpapi.Client, export
 			}
 		}
 
-		status.UpdateStatus(f.Name(), resp.Metadata.RemainingRecords, apiClient.Duration.Milliseconds())
+		status.UpdateStatus(f.Name(), resp.Metadata.RemainingRecords, apiClient.Duration().Milliseconds())
 
 		l.With(
 			"estimated_remaining", resp.Metadata.RemainingRecords,
-			"duration_ms", apiClient.Duration.Milliseconds(),
+			"duration_ms", apiClient.Duration().Milliseconds(),
 			"export_duration_ms", exporter.GetDuration().Milliseconds(),
 		).Info("export batch complete")
 		return nil
@@ -138,5 +141,11 @@ func (f *SiteFeed) Export(ctx context.Context, apiClient *httpapi.Client, export
 	if err := DrainFeed(ctx, apiClient, req, drainFn); err != nil {
 		return events.WrapEventError(err, fmt.Sprintf("feed %q", f.Name()))
 	}
+
+	// Process Deleted Sites, the sites are fetched in full by every export
+	if err := processDeletedRows(ctx, apiClient, exporter, f, siteDeletionEvent, f.DeletionMode, deletedRowsPageSize, f.DeletedAfter, true); err != nil && events.IsBlockingError(err) {
+		return events.WrapEventError(err, "process deleted sites")
+	}
+
 	return exporter.FinaliseExport(f, &[]*Site{})
 }
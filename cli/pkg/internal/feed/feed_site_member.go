@@ -15,9 +15,9 @@ import (
 
 // SiteMember represents a row from the site members feed
 type SiteMember struct {
-	SiteID     string    `json:"site_id" csv:"site_id" gorm:"primarykey;column:site_id;size:41"`
-	MemberID   string    `json:"member_id" csv:"member_id" gorm:"primarykey;column:member_id;size:37"`
-	ExportedAt time.Time `json:"exported_at" csv:"exported_at" gorm:"autoUpdateTime"`
+	SiteID     string    `json:"site_id" csv:"site_id" gorm:"primarykey;column:site_id;size:41" description:"ID of the site"`
+	MemberID   string    `json:"member_id" csv:"member_id" gorm:"primarykey;column:member_id;size:37" description:"ID of the user member of the site"`
+	ExportedAt time.Time `json:"exported_at" csv:"exported_at" gorm:"autoUpdateTime" description:"Time the row was last written by the exporter"`
 }
 
 // SiteMemberFeed is a representation of the sites feed
@@ -103,7 +103,7 @@ func (f *SiteMemberFeed) Export(ctx context.Context, apiClient *httpapi.Client,
 
 		l.With(
 			"estimated_remaining", resp.Metadata.RemainingRecords,
-			"duration_ms", apiClient.Duration.Milliseconds(),
+			"duration_ms", apiClient.Duration().Milliseconds(),
 			"export_duration_ms", exporter.GetDuration().Milliseconds(),
 		).Info("export batch complete")
 		return nil
@@ -30,6 +30,8 @@ type ExportStatusItemStage string
 
 const StageApi ExportStatusItemStage = "API_DOWNLOAD"
 const StageCsv ExportStatusItemStage = "CSV_EXPORT"
+const StageParquet ExportStatusItemStage = "PARQUET_EXPORT"
+const StageJSONL ExportStatusItemStage = "JSONL_EXPORT"
 
 type ExportStatusItem struct {
 	Name               string
@@ -15,24 +15,27 @@ import (
 
 // Template represents a row from the templates feed
 type Template struct {
-	ID             string    `json:"id" csv:"template_id" gorm:"primarykey;column:template_id;size:100"`
-	Archived       bool      `json:"archived" csv:"archived"`
-	Name           string    `json:"name" csv:"name"`
-	Description    string    `json:"description" csv:"description"`
-	OrganisationID string    `json:"organisation_id" csv:"organisation_id" gorm:"index:idx_tml_modified_at;size:37"`
-	OwnerName      string    `json:"owner_name" csv:"owner_name"`
-	OwnerID        string    `json:"owner_id" csv:"owner_id" gorm:"size:37"`
-	AuthorName     string    `json:"author_name" csv:"author_name"`
-	AuthorID       string    `json:"author_id" csv:"author_id" gorm:"size:37"`
-	CreatedAt      time.Time `json:"created_at" csv:"created_at"`
-	ModifiedAt     time.Time `json:"modified_at" csv:"modified_at" gorm:"index:idx_tml_modified_at,sort:desc"`
-	ExportedAt     time.Time `json:"exported_at" csv:"exported_at" gorm:"index:idx_tml_modified_at;autoUpdateTime"`
+	ID             string     `json:"id" csv:"template_id" gorm:"primarykey;column:template_id;size:100" description:"ID of the template"`
+	Archived       bool       `json:"archived" csv:"archived" description:"Whether the template is archived"`
+	Name           string     `json:"name" csv:"name" description:"Name of the template"`
+	Description    string     `json:"description" csv:"description" description:"Description of the template"`
+	OrganisationID string     `json:"organisation_id" csv:"organisation_id" gorm:"index:idx_tml_modified_at;size:37" description:"ID of the organisation the row belongs to"`
+	OwnerName      string     `json:"owner_name" csv:"owner_name" description:"Name of the owner of the template"`
+	OwnerID        string     `json:"owner_id" csv:"owner_id" gorm:"size:37" description:"ID of the user owning the template"`
+	AuthorName     string     `json:"author_name" csv:"author_name" description:"Name of the last user who modified the template"`
+	AuthorID       string     `json:"author_id" csv:"author_id" gorm:"size:37" description:"ID of the last user who modified the template"`
+	CreatedAt      time.Time  `json:"created_at" csv:"created_at" description:"Time the template was created"`
+	ModifiedAt     time.Time  `json:"modified_at" csv:"modified_at" gorm:"index:idx_tml_modified_at,sort:desc" description:"Time the template was last modified"`
+	ExportedAt     time.Time  `json:"exported_at" csv:"exported_at" gorm:"index:idx_tml_modified_at;autoUpdateTime" description:"Time the row was last written by the exporter"`
+	Deleted        bool       `json:"deleted" csv:"deleted" description:"Whether the template was deleted"`
+	DeletedAt      *time.Time `json:"deleted_at" csv:"deleted_at" description:"Time the template was deleted, empty when it was not"`
 }
 
 // TemplateFeed is a representation of the templates feed
 type TemplateFeed struct {
 	ModifiedAfter time.Time
 	Incremental   bool
+	DeletionMode  DeletionMode
 }
 
 // Name is the name of the feed
@@ -125,7 +128,7 @@ func (f *TemplateFeed) Export(ctx context.Context, apiClient *httpapi.Client, ex
 
 		l.With(
 			"estimated_remaining", resp.Metadata.RemainingRecords,
-			"duration_ms", apiClient.Duration.Milliseconds(),
+			"duration_ms", apiClient.Duration().Milliseconds(),
 			"export_duration_ms", exporter.GetDuration().Milliseconds(),
 		).Info("export batch complete")
 		return nil
@@ -150,5 +153,11 @@ func (f *TemplateFeed) Export(ctx context.Context, apiClient *httpapi.Client, ex
 	if err := DrainFeed(ctx, apiClient, req, drainFn); err != nil {
 		return events.WrapEventError(err, fmt.Sprintf("feed %q", f.Name()))
 	}
+
+	// Process Deleted Templates
+	if err := processDeletedRows(ctx, apiClient, exporter, f, templateDeletionEvent, f.DeletionMode, deletedRowsPageSize, f.ModifiedAfter, !f.Incremental); err != nil && events.IsBlockingError(err) {
+		return events.WrapEventError(err, "process deleted templates")
+	}
+
 	return exporter.FinaliseExport(f, &[]*Template{})
 }
@@ -15,12 +15,12 @@ import (
 
 // TemplatePermission represents a row from the template_permissions feed
 type TemplatePermission struct {
-	ID             string `json:"id" csv:"permission_id" gorm:"primarykey;column:permission_id;size:375"`
-	TemplateID     string `json:"template_id" csv:"template_id" gorm:"size:100"`
-	Permission     string `json:"permission" csv:"permission" gorm:"size:10"`
-	AssigneeID     string `json:"assignee_id" csv:"assignee_id" gorm:"size:256"`
-	AssigneeType   string `json:"assignee_type" csv:"assignee_type" gorm:"size:10"`
-	OrganisationID string `json:"organisation_id" csv:"organisation_id" gorm:"size:37"`
+	ID             string `json:"id" csv:"permission_id" gorm:"primarykey;column:permission_id;size:375" description:"ID of the permission"`
+	TemplateID     string `json:"template_id" csv:"template_id" gorm:"size:100" description:"ID of the template"`
+	Permission     string `json:"permission" csv:"permission" gorm:"size:10" description:"Permission granted, such as view, edit or owner"`
+	AssigneeID     string `json:"assignee_id" csv:"assignee_id" gorm:"size:256" description:"ID of the user or group assigned"`
+	AssigneeType   string `json:"assignee_type" csv:"assignee_type" gorm:"size:10" description:"Type of the assignee, user or group"`
+	OrganisationID string `json:"organisation_id" csv:"organisation_id" gorm:"size:37" description:"ID of the organisation the row belongs to"`
 }
 
 // TemplatePermissionFeed is a representation of the template_permissions feed
@@ -113,7 +113,7 @@ func (f *TemplatePermissionFeed) Export(ctx context.Context, apiClient *httpapi.
 
 		l.With(
 			"estimated_remaining", resp.Metadata.RemainingRecords,
-			"duration_ms", apiClient.Duration.Milliseconds(),
+			"duration_ms", apiClient.Duration().Milliseconds(),
 			"export_duration_ms", exporter.GetDuration().Milliseconds(),
 		).Info("export batch complete")
 
@@ -13,21 +13,21 @@ import (
 
 // TrainingCourseProgress represents a row for the feed
 type TrainingCourseProgress struct {
-	OpenedAt         string  `json:"opened_at" csv:"opened_at"`
-	CompletedAt      string  `json:"completed_at" csv:"completed_at"`
-	TotalLessons     int32   `json:"total_lessons" csv:"total_lessons"`
-	CompletedLessons int32   `json:"completed_lessons" csv:"completed_lessons"`
-	CourseID         string  `json:"course_id" csv:"course_id" gorm:"primarykey;column:course_id;size:64"`
-	CourseExternalID string  `json:"course_external_id" csv:"course_external_id" gorm:"size:256"`
-	CourseTitle      string  `json:"course_title" csv:"course_title"`
-	UserEmail        string  `json:"user_email" csv:"user_email" gorm:"size:256"`
-	UserFirstName    string  `json:"user_first_name" csv:"user_first_name"`
-	UserLastName     string  `json:"user_last_name" csv:"user_last_name"`
-	UserID           string  `json:"user_id" csv:"user_id" gorm:"primarykey;column:user_id;size:37"`
-	UserExternalID   string  `json:"user_external_id" csv:"user_external_id"`
-	ProgressPercent  float32 `json:"progress_percent" csv:"progress_percent"`
-	Score            int32   `json:"score" csv:"score"`
-	DueAt            string  `json:"due_at" csv:"due_at"`
+	OpenedAt         string  `json:"opened_at" csv:"opened_at" description:"Time the user opened the course"`
+	CompletedAt      string  `json:"completed_at" csv:"completed_at" description:"Time the user completed the course"`
+	TotalLessons     int32   `json:"total_lessons" csv:"total_lessons" description:"Number of lessons of the course"`
+	CompletedLessons int32   `json:"completed_lessons" csv:"completed_lessons" description:"Number of lessons the user completed"`
+	CourseID         string  `json:"course_id" csv:"course_id" gorm:"primarykey;column:course_id;size:64" description:"ID of the course"`
+	CourseExternalID string  `json:"course_external_id" csv:"course_external_id" gorm:"size:256" description:"ID of the course in an external system"`
+	CourseTitle      string  `json:"course_title" csv:"course_title" description:"Title of the course"`
+	UserEmail        string  `json:"user_email" csv:"user_email" gorm:"size:256" description:"Email address of the user"`
+	UserFirstName    string  `json:"user_first_name" csv:"user_first_name" description:"First name of the user"`
+	UserLastName     string  `json:"user_last_name" csv:"user_last_name" description:"Last name of the user"`
+	UserID           string  `json:"user_id" csv:"user_id" gorm:"primarykey;column:user_id;size:37" description:"ID of the user"`
+	UserExternalID   string  `json:"user_external_id" csv:"user_external_id" description:"ID of the user in an external system"`
+	ProgressPercent  float32 `json:"progress_percent" csv:"progress_percent" description:"Progress of the user through the course, as a percentage"`
+	Score            int32   `json:"score" csv:"score" description:"Score of the user in the course"`
+	DueAt            string  `json:"due_at" csv:"due_at" description:"Time the course is due"`
 }
 
 // TrainingCourseProgressFeed is a representation of the feed
@@ -126,11 +126,11 @@ func (f *TrainingCourseProgressFeed) Export(ctx context.Context, apiClient *http
 		}
 
 		// note: this feed api doesn't return remaining items
-		status.IncrementStatus(f.Name(), int64(numRows), apiClient.Duration.Milliseconds())
+		status.IncrementStatus(f.Name(), int64(numRows), apiClient.Duration().Milliseconds())
 
 		l.With(
 			"downloaded", status.ReadCounter(f.Name()),
-			"duration_ms", apiClient.Duration.Milliseconds(),
+			"duration_ms", apiClient.Duration().Milliseconds(),
 			"export_duration_ms", exporter.GetDuration().Milliseconds(),
 		).Info("export batch complete")
 		return nil
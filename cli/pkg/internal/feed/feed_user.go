@@ -15,16 +15,16 @@ import (
 
 // User represents a row from the users feed
 type User struct {
-	ID             string     `json:"id" csv:"user_id" gorm:"primarykey;column:user_id;size:37"`
-	OrganisationID string     `json:"organisation_id" csv:"organisation_id" gorm:"size:37"`
-	Email          string     `json:"email" csv:"email" gorm:"size:256"`
-	Firstname      string     `json:"firstname" csv:"firstname"`
-	Lastname       string     `json:"lastname" csv:"lastname"`
-	Active         bool       `json:"active" csv:"active"`
-	LastSeenAt     *time.Time `json:"last_seen_at" csv:"last_seen_at"`
-	ExportedAt     time.Time  `json:"exported_at" csv:"exported_at" gorm:"autoUpdateTime"`
-	SeatType       string     `json:"seat_type" csv:"seat_type" gorm:"seat_type"`
-	CreatedAt      *time.Time `json:"created_at" csv:"created_at" gorm:"autoCreateTime"`
+	ID             string     `json:"id" csv:"user_id" gorm:"primarykey;column:user_id;size:37" description:"ID of the user"`
+	OrganisationID string     `json:"organisation_id" csv:"organisation_id" gorm:"size:37" description:"ID of the organisation the row belongs to"`
+	Email          string     `json:"email" csv:"email" gorm:"size:256" description:"Email address of the user"`
+	Firstname      string     `json:"firstname" csv:"firstname" description:"First name of the user"`
+	Lastname       string     `json:"lastname" csv:"lastname" description:"Last name of the user"`
+	Active         bool       `json:"active" csv:"active" description:"Whether the user is active"`
+	LastSeenAt     *time.Time `json:"last_seen_at" csv:"last_seen_at" description:"Time the user was last seen"`
+	ExportedAt     time.Time  `json:"exported_at" csv:"exported_at" gorm:"autoUpdateTime" description:"Time the row was last written by the exporter"`
+	SeatType       string     `json:"seat_type" csv:"seat_type" gorm:"seat_type" description:"Type of the seat of the user"`
+	CreatedAt      *time.Time `json:"created_at" csv:"created_at" gorm:"autoCreateTime" description:"Time the user was created"`
 }
 
 // UserFeed is a representation of the users feed
@@ -119,7 +119,7 @@ func (f *UserFeed) Export(ctx context.Context, apiClient *httpapi.Client, export
 
 		l.With(
 			"estimated_remaining", resp.Metadata.RemainingRecords,
-			"duration_ms", apiClient.Duration.Milliseconds(),
+			"duration_ms", apiClient.Duration().Milliseconds(),
 			"export_duration_ms", exporter.GetDuration().Milliseconds(),
 		).Info("export batch complete")
 		return nil
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"io"
 	"strings"
 
 	"github.com/SafetyCulture/safetyculture-exporter/pkg/httpapi"
@@ -11,39 +12,59 @@ import (
 
 // GetMedia fetches the media object from SafetyCulture.
 func GetMedia(ctx context.Context, apiClient *httpapi.Client, request *GetMediaRequest) (*GetMediaResponse, error) {
-	baseURL := strings.TrimPrefix(request.URL, apiClient.BaseURL)
+	stream, err := OpenMedia(ctx, apiClient, request)
+	if err != nil || stream == nil {
+		return nil, err
+	}
 
-	// The mediaURL will be in the following format:
-	// https://api.eu.safetyculture.com/audits/audit_xxx/media/4c83fcf2-180b-4d3e-958f-389f7ac49777
-	// The string that is after the word "media/" is the ID of it.
-	mediaIDURL := strings.Split(request.URL, "/")
-	mediaID := mediaIDURL[len(mediaIDURL)-1]
+	defer stream.Body.Close()
+
+	buf := new(bytes.Buffer)
+	buf.ReadFrom(stream.Body)
+
+	resp := &GetMediaResponse{
+		ContentType: stream.ContentType,
+		Body:        buf.Bytes(),
+		MediaID:     stream.MediaID,
+	}
+	return resp, nil
+}
+
+// OpenMedia starts fetching the media object from SafetyCulture, the body is streamed from the response and has
+// to be closed. nil is returned when there is no media
+func OpenMedia(ctx context.Context, apiClient *httpapi.Client, request *GetMediaRequest) (*MediaStream, error) {
+	baseURL := strings.TrimPrefix(request.URL, apiClient.BaseURL)
 
 	httpRes, err := httpapi.ExecuteRawGet(ctx, apiClient, baseURL)
 	if err != nil {
 		return nil, err
 	}
 
-	defer httpRes.Body.Close()
-
 	if httpRes.StatusCode == 204 {
+		httpRes.Body.Close()
 		return nil, nil
 	}
 
 	contentType := httpRes.Header.Get("Content-Type")
 	if contentType == "" {
+		httpRes.Body.Close()
 		return nil, fmt.Errorf("failed to get content-type of media")
 	}
 
-	buf := new(bytes.Buffer)
-	buf.ReadFrom(httpRes.Body)
-
-	resp := &GetMediaResponse{
+	return &MediaStream{
 		ContentType: contentType,
-		Body:        buf.Bytes(),
-		MediaID:     mediaID,
-	}
-	return resp, nil
+		Body:        httpRes.Body,
+		MediaID:     mediaIDFromURL(request.URL),
+	}, nil
+}
+
+// mediaIDFromURL returns the ID of the media of a URL
+func mediaIDFromURL(mediaURL string) string {
+	// The mediaURL will be in the following format:
+	// https://api.eu.safetyculture.com/audits/audit_xxx/media/4c83fcf2-180b-4d3e-958f-389f7ac49777
+	// The string that is after the word "media/" is the ID of it.
+	mediaIDURL := strings.Split(mediaURL, "/")
+	return mediaIDURL[len(mediaIDURL)-1]
 }
 
 // GetMediaRequest has all the data needed to make a request to get a media
@@ -58,3 +79,10 @@ type GetMediaResponse struct {
 	Body        []byte
 	MediaID     string
 }
+
+// MediaStream is a media being fetched, its body is read from the response
+type MediaStream struct {
+	ContentType string
+	Body        io.ReadCloser
+	MediaID     string
+}
@@ -0,0 +1,133 @@
+package feed
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/SafetyCulture/safetyculture-exporter/pkg/internal/events"
+	"github.com/SafetyCulture/safetyculture-exporter/pkg/logger"
+	"go.uber.org/zap"
+)
+
+const defaultMediaRetryWait = time.Second
+
+// MediaDownloader downloads the media of the feeds through a pool of workers shared by all the feeds of an export.
+// A media failing to download is retried, then reported and skipped so that the rows are still exported
+type MediaDownloader struct {
+	slots     chan struct{}
+	retries   int
+	retryWait time.Duration
+	onError   func(err error)
+	failed    atomic.Int64
+	logger    *zap.SugaredLogger
+}
+
+// NewMediaDownloader creates a pool of concurrency workers, maxGoRoutines when not set. A download is attempted
+// retries more times before onError is called with its error
+func NewMediaDownloader(concurrency int, retries int, onError func(err error)) *MediaDownloader {
+	if concurrency <= 0 {
+		concurrency = maxGoRoutines
+	}
+	if retries < 0 {
+		retries = 0
+	}
+
+	return &MediaDownloader{
+		slots:     make(chan struct{}, concurrency),
+		retries:   retries,
+		retryWait: defaultMediaRetryWait,
+		onError:   onError,
+		logger:    logger.GetLogger(),
+	}
+}
+
+// NewBatch starts a group of downloads a feed waits for before writing its rows. A nil downloader uses a pool of
+// its own, without retries
+func (d *MediaDownloader) NewBatch(ctx context.Context) *MediaBatch {
+	if d == nil {
+		d = NewMediaDownloader(0, 0, nil)
+	}
+	return &MediaBatch{downloader: d, ctx: ctx}
+}
+
+// MediaBatch is a group of media downloads
+type MediaBatch struct {
+	downloader *MediaDownloader
+	ctx        context.Context
+	wg         sync.WaitGroup
+	errMu      sync.Mutex
+	errs       []error
+}
+
+// Go queues the download of a media, blocking while every worker of the pool is busy. false is returned once the
+// export is cancelled, the download isn't queued
+func (b *MediaBatch) Go(mediaURL string, download func(ctx context.Context) error) bool {
+	if b.ctx.Err() != nil {
+		return false
+	}
+
+	select {
+	case <-b.ctx.Done():
+		return false
+	case b.downloader.slots <- struct{}{}:
+	}
+
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+		defer func() { <-b.downloader.slots }()
+
+		if err := b.downloader.download(b.ctx, mediaURL, download); err != nil {
+			b.errMu.Lock()
+			b.errs = append(b.errs, err)
+			b.errMu.Unlock()
+		}
+	}()
+	return true
+}
+
+// Wait waits for the downloads of the batch and returns the errors of the media that couldn't be downloaded
+func (b *MediaBatch) Wait() []error {
+	b.wg.Wait()
+
+	b.errMu.Lock()
+	defer b.errMu.Unlock()
+	return b.errs
+}
+
+// Failed returns the number of media that couldn't be downloaded
+func (d *MediaDownloader) Failed() int64 {
+	return d.failed.Load()
+}
+
+// download runs a download until it succeeds, its retries are exhausted or the export is cancelled
+func (d *MediaDownloader) download(ctx context.Context, mediaURL string, download func(ctx context.Context) error) error {
+	l := d.logger.With("url", mediaURL)
+
+	var err error
+	for attempt := 0; attempt <= d.retries; attempt++ {
+		if attempt > 0 {
+			l.With("attempt", attempt, "error", err).Info("retrying media download")
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(d.retryWait * time.Duration(attempt)):
+			}
+		}
+
+		if err = download(ctx); err == nil || ctx.Err() != nil {
+			return nil
+		}
+	}
+
+	err = events.NewEventErrorWithMessage(err, events.ErrorSeverityWarning, events.ErrorSubSystemAPI, false, fmt.Sprintf("download media %s", mediaURL))
+	l.With("error", err).Warn("unable to download media")
+	d.failed.Add(1)
+	if d.onError != nil {
+		d.onError(err)
+	}
+	return err
+}
@@ -0,0 +1,120 @@
+package feed
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMediaDownloader_should_limit_concurrency(t *testing.T) {
+	downloader := NewMediaDownloader(2, 0, nil)
+
+	var running, maxRunning atomic.Int32
+	batch := downloader.NewBatch(context.Background())
+	for i := 0; i < 10; i++ {
+		require.True(t, batch.Go("media", func(ctx context.Context) error {
+			n := running.Add(1)
+			defer running.Add(-1)
+			for {
+				m := maxRunning.Load()
+				if n <= m || maxRunning.CompareAndSwap(m, n) {
+					break
+				}
+			}
+			time.Sleep(5 * time.Millisecond)
+			return nil
+		}))
+	}
+
+	assert.Empty(t, batch.Wait())
+	assert.EqualValues(t, 2, maxRunning.Load())
+}
+
+func TestMediaDownloader_should_retry_failed_downloads(t *testing.T) {
+	downloader := NewMediaDownloader(1, 2, nil)
+	downloader.retryWait = time.Millisecond
+
+	var attempts atomic.Int32
+	batch := downloader.NewBatch(context.Background())
+	batch.Go("media", func(ctx context.Context) error {
+		if attempts.Add(1) < 3 {
+			return errors.New("connection reset")
+		}
+		return nil
+	})
+
+	assert.Empty(t, batch.Wait())
+	assert.EqualValues(t, 3, attempts.Load())
+	assert.EqualValues(t, 0, downloader.Failed())
+}
+
+func TestMediaDownloader_should_report_failed_downloads(t *testing.T) {
+	var mu sync.Mutex
+	var reported []error
+	downloader := NewMediaDownloader(1, 1, func(err error) {
+		mu.Lock()
+		reported = append(reported, err)
+		mu.Unlock()
+	})
+	downloader.retryWait = time.Millisecond
+
+	// the workers are released by failed downloads, the later ones still run
+	var succeeded atomic.Int32
+	batch := downloader.NewBatch(context.Background())
+	for i := 0; i < 3; i++ {
+		batch.Go("https://api/media/bad", func(ctx context.Context) error {
+			return errors.New("not found")
+		})
+	}
+	batch.Go("https://api/media/good", func(ctx context.Context) error {
+		succeeded.Add(1)
+		return nil
+	})
+
+	errs := batch.Wait()
+	require.Len(t, errs, 3)
+	assert.Contains(t, errs[0].Error(), "https://api/media/bad")
+	assert.Len(t, reported, 3)
+	assert.EqualValues(t, 3, downloader.Failed())
+	assert.EqualValues(t, 1, succeeded.Load())
+}
+
+func TestMediaDownloader_should_stop_when_cancelled(t *testing.T) {
+	downloader := NewMediaDownloader(1, 3, nil)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	batch := downloader.NewBatch(ctx)
+	require.True(t, batch.Go("media", func(ctx context.Context) error {
+		cancel()
+		return ctx.Err()
+	}))
+
+	assert.Empty(t, batch.Wait())
+	assert.False(t, batch.Go("media", func(ctx context.Context) error {
+		t.Fatal("the download shouldn't run once cancelled")
+		return nil
+	}))
+	assert.EqualValues(t, 0, downloader.Failed())
+}
+
+func TestMediaDownloader_Nil(t *testing.T) {
+	var downloader *MediaDownloader
+
+	var downloads atomic.Int32
+	batch := downloader.NewBatch(context.Background())
+	for i := 0; i < 3; i++ {
+		batch.Go("media", func(ctx context.Context) error {
+			downloads.Add(1)
+			return nil
+		})
+	}
+
+	assert.Empty(t, batch.Wait())
+	assert.EqualValues(t, 3, downloads.Load())
+}
@@ -0,0 +1,343 @@
+package feed
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/SafetyCulture/safetyculture-exporter/pkg/httpapi"
+	"github.com/SafetyCulture/safetyculture-exporter/pkg/internal/events"
+	"github.com/SafetyCulture/safetyculture-exporter/pkg/internal/metrics"
+	"github.com/SafetyCulture/safetyculture-exporter/pkg/logger"
+	"gorm.io/gorm/clause"
+)
+
+// MediaStoreMode is how the downloaded media are laid out in the media path
+type MediaStoreMode string
+
+const (
+	// MediaStorePerAudit writes the media to <media_path>/<audit_id>/<media_id>.<ext>
+	MediaStorePerAudit MediaStoreMode = "per_audit"
+	// MediaStoreContent names the media by the SHA-256 of their content, so that a file referenced by several audits
+	// is stored once. The media are recorded in the media table
+	MediaStoreContent MediaStoreMode = "content"
+)
+
+// ParseMediaStoreMode validates a media store mode, an empty mode stores the media per audit
+func ParseMediaStoreMode(mode string) (MediaStoreMode, error) {
+	switch MediaStoreMode(mode) {
+	case "", MediaStorePerAudit:
+		return MediaStorePerAudit, nil
+	case MediaStoreContent:
+		return MediaStoreContent, nil
+	}
+	return "", fmt.Errorf("invalid media store %q, expected %q or %q", mode, MediaStorePerAudit, MediaStoreContent)
+}
+
+// mediaTempDir holds the media being downloaded, until their hash is known
+const mediaTempDir = ".tmp"
+
+var mediaExtensionRegexp = regexp.MustCompile(`^[a-z0-9.-]+`)
+
+// Media is the record of a media kept in the content-addressed store. The media of the actions and issues are
+// recorded under the folder of their task, such as actions/<action_id>, in place of the audit
+type Media struct {
+	AuditID     string `json:"audit_id" csv:"audit_id" gorm:"primarykey;size:100"`
+	ItemID      string `json:"item_id" csv:"item_id" gorm:"primarykey;size:100"`
+	MediaID     string `json:"media_id" csv:"media_id" gorm:"primarykey;size:100;index:idx_media_media_id"`
+	Hash        string `json:"hash" csv:"hash" gorm:"size:64"`
+	Size        int64  `json:"size" csv:"size"`
+	ContentType string `json:"content_type" csv:"content_type" gorm:"size:100"`
+	Path        string `json:"path" csv:"path" gorm:"size:256"`
+}
+
+// TableName returns the name of the table holding the media
+func (Media) TableName() string {
+	return "media"
+}
+
+// MediaFeed is the media table, written by the content-addressed store while the inspection items are exported
+type MediaFeed struct{}
+
+// Name is the name of the feed
+func (f *MediaFeed) Name() string {
+	return "media"
+}
+
+// HasRemainingInformation returns false, the media are recorded as they are downloaded
+func (f *MediaFeed) HasRemainingInformation() bool {
+	return false
+}
+
+// Model returns the model of the feed row
+func (f *MediaFeed) Model() interface{} {
+	return Media{}
+}
+
+// RowsModel returns the model of feed rows
+func (f *MediaFeed) RowsModel() interface{} {
+	return &[]*Media{}
+}
+
+// PrimaryKey returns the primary key(s)
+func (f *MediaFeed) PrimaryKey() []string {
+	return []string{"audit_id", "item_id", "media_id"}
+}
+
+// Columns returns the columns of the row
+func (f *MediaFeed) Columns() []string {
+	return []string{"hash", "size", "content_type", "path"}
+}
+
+// Order returns the ordering when retrieving an export
+func (f *MediaFeed) Order() string {
+	return "audit_id, item_id, media_id"
+}
+
+// CreateSchema creates the schema of the feed for the supplied exporter
+func (f *MediaFeed) CreateSchema(exporter Exporter) error {
+	return exporter.CreateSchema(f, f.RowsModel())
+}
+
+// Export writes out the media table recorded by the store, nothing is fetched from the API
+func (f *MediaFeed) Export(_ context.Context, _ *httpapi.Client, exporter Exporter, _ string) error {
+	return exporter.FinaliseExport(f, f.RowsModel())
+}
+
+// MediaStore is implemented by the exporters able to keep the media in a content-addressed store
+type MediaStore interface {
+	// InitMediaStore creates the media table
+	InitMediaStore() error
+	// HasStoredMedia returns whether the media is already in the store. A media stored for another item is
+	// recorded for this one as well
+	HasStoredMedia(auditID, itemID, mediaID string) (bool, error)
+	// StoreMedia streams the body of the media into the store and records it
+	StoreMedia(media *Media, body io.Reader) error
+}
+
+// InitMediaStore creates the media table
+func (e *SQLExporter) InitMediaStore() error {
+	return e.migrateMedia()
+}
+
+// HasStoredMedia returns whether the media is already in the store. The records whose file was removed are ignored,
+// so that the media is downloaded again, unless the file was copied to the object storage
+func (e *SQLExporter) HasStoredMedia(auditID, itemID, mediaID string) (bool, error) {
+	if err := e.migrateMedia(); err != nil {
+		return false, err
+	}
+
+	var stored []Media
+	if result := e.DB.Where("media_id = ?", mediaID).Find(&stored); result.Error != nil {
+		return false, events.NewEventErrorWithMessage(result.Error, events.ErrorSeverityError, events.ErrorSubSystemDB, false, "unable to read the media")
+	}
+
+	for _, media := range stored {
+		ok, err := e.hasMediaFile(media.Path)
+		if err != nil {
+			return false, err
+		}
+		if !ok {
+			continue
+		}
+		if media.AuditID == auditID && media.ItemID == itemID {
+			return true, nil
+		}
+
+		media.AuditID = auditID
+		media.ItemID = itemID
+		return true, e.saveMedia(&media)
+	}
+	return false, nil
+}
+
+// StoreMedia streams the body of the media to a temporary file while hashing it, and moves it to
+// <media_path>/<hash prefix>/<hash>.<ext> unless a media with the same content is already stored
+func (e *SQLExporter) StoreMedia(media *Media, body io.Reader) error {
+	if err := e.migrateMedia(); err != nil {
+		return err
+	}
+
+	tempDir := filepath.Join(e.ExportMediaPath, mediaTempDir)
+	if err := os.MkdirAll(tempDir, os.ModePerm); err != nil {
+		return events.NewEventErrorWithMessage(err, events.ErrorSeverityError, events.ErrorSubSystemFileOperations, false, fmt.Sprintf("create directory %s", tempDir))
+	}
+
+	file, err := os.CreateTemp(tempDir, "media-*")
+	if err != nil {
+		return events.NewEventErrorWithMessage(err, events.ErrorSeverityError, events.ErrorSubSystemFileOperations, false, "create temporary media file")
+	}
+	// removing the file is a no-op once it is moved to the store
+	defer os.Remove(file.Name())
+
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(file, hash), body)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return events.NewEventErrorWithMessage(err, events.ErrorSeverityError, events.ErrorSubSystemFileOperations, false, "write media to file")
+	}
+
+	media.Hash = hex.EncodeToString(hash.Sum(nil))
+	media.Size = size
+	media.Path = mediaStorePath(media.Hash, media.ContentType)
+
+	storePath := filepath.Join(e.ExportMediaPath, filepath.FromSlash(media.Path))
+	if _, err := os.Stat(storePath); errors.Is(err, os.ErrNotExist) {
+		if err := os.MkdirAll(filepath.Dir(storePath), os.ModePerm); err != nil {
+			return events.NewEventErrorWithMessage(err, events.ErrorSeverityError, events.ErrorSubSystemFileOperations, false, fmt.Sprintf("create directory %s", filepath.Dir(storePath)))
+		}
+		if err := os.Rename(file.Name(), storePath); err != nil {
+			return events.NewEventErrorWithMessage(err, events.ErrorSeverityError, events.ErrorSubSystemFileOperations, false, fmt.Sprintf("move media to %s", storePath))
+		}
+		if err := e.uploadFile(storePath); err != nil {
+			return err
+		}
+	}
+
+	return e.saveMedia(media)
+}
+
+// hasMediaFile returns whether the file of a stored media exists locally or in the object storage
+func (e *SQLExporter) hasMediaFile(mediaPath string) (bool, error) {
+	localPath := filepath.Join(e.ExportMediaPath, filepath.FromSlash(mediaPath))
+	if _, err := os.Stat(localPath); err == nil {
+		return true, nil
+	}
+
+	exists, err := e.Mirror.Exists(context.Background(), localPath)
+	if err != nil {
+		return false, events.NewEventErrorWithMessage(err, events.ErrorSeverityError, events.ErrorSubSystemFileOperations, false, "read media from object storage")
+	}
+	return exists, nil
+}
+
+func (e *SQLExporter) saveMedia(media *Media) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	result := e.DB.Clauses(clause.OnConflict{UpdateAll: true}).Create(media)
+	if result.Error != nil {
+		return events.NewEventErrorWithMessage(result.Error, events.ErrorSeverityError, events.ErrorSubSystemDB, false, "unable to save media")
+	}
+	return nil
+}
+
+func (e *SQLExporter) migrateMedia() error {
+	e.mediaOnce.Do(func() {
+		if e.AutoMigrate {
+			e.mediaErr = e.DB.AutoMigrate(&Media{})
+		}
+	})
+
+	if e.mediaErr != nil {
+		return events.NewEventErrorWithMessage(e.mediaErr, events.ErrorSeverityError, events.ErrorSubSystemDB, false, "unable to create media table")
+	}
+	return nil
+}
+
+// mediaStorePath returns the path of a media in the store, relative to the media path. The files are spread in
+// directories named after the first characters of their hash
+func mediaStorePath(hash string, contentType string) string {
+	return fmt.Sprintf("%s/%s.%s", hash[:2], hash, mediaExtension(contentType))
+}
+
+// mediaExtension returns the extension of the files of a content type, such as jpeg for image/jpeg or svg for
+// image/svg+xml
+func mediaExtension(contentType string) string {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = contentType
+	}
+
+	_, subtype, _ := strings.Cut(strings.ToLower(mediaType), "/")
+	if ext := mediaExtensionRegexp.FindString(subtype); ext != "" {
+		return ext
+	}
+	return "bin"
+}
+
+// fetchAndStoreMedia streams a media into the content-addressed store, unless it is already stored
+func fetchAndStoreMedia(ctx context.Context, apiClient *httpapi.Client, store MediaStore, auditID, itemID, mediaURL string) error {
+	stored, err := store.HasStoredMedia(auditID, itemID, mediaIDFromURL(mediaURL))
+	if err != nil || stored {
+		return err
+	}
+
+	stream, err := OpenMedia(ctx, apiClient, &GetMediaRequest{URL: mediaURL, AuditID: auditID})
+	if err != nil {
+		return err
+	}
+
+	// If the response is empty, then ignore this media object
+	if stream == nil {
+		return nil
+	}
+	defer stream.Body.Close()
+
+	media := &Media{
+		AuditID:     auditID,
+		ItemID:      itemID,
+		MediaID:     stream.MediaID,
+		ContentType: stream.ContentType,
+	}
+	if err := store.StoreMedia(media, stream.Body); err != nil {
+		return err
+	}
+
+	GetExporterStatus().IncrementStatus("media", 1, 0)
+	metrics.IncMediaDownloaded()
+	return nil
+}
+
+// initMediaStore sets up the content-addressed store of the media when configured
+func (e *ExporterFeedClient) initMediaStore(exporter Exporter) error {
+	mode, err := ParseMediaStoreMode(e.configuration.ExportMediaStore)
+	if err != nil {
+		return err
+	}
+
+	e.mediaStore = nil
+	if !e.configuration.ExportMedia || mode != MediaStoreContent {
+		return nil
+	}
+
+	store, ok := exporter.(MediaStore)
+	if !ok {
+		logger.GetLogger().Warn("the exporter doesn't support the content-addressed media store, the media are stored per audit")
+		return nil
+	}
+	if err := store.InitMediaStore(); err != nil {
+		return err
+	}
+
+	e.mediaStore = store
+	return nil
+}
+
+// exportMediaTable writes out the media table recorded by the content-addressed store
+func (e *ExporterFeedClient) exportMediaTable(ctx context.Context, exporter Exporter) {
+	if e.mediaStore == nil {
+		return
+	}
+
+	// the streaming CSV exporter writes the files as rows are exported, the media are only kept in its index
+	if _, streaming := exporter.(*StreamingCSVExporter); streaming {
+		logger.GetLogger().Info("the media table is kept in the CSV index database")
+		return
+	}
+
+	feed := &MediaFeed{}
+	if err := feed.Export(ctx, nil, exporter, ""); err != nil {
+		e.addError(events.WrapEventError(err, "write media table"))
+	}
+}
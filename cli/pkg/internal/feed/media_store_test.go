@@ -0,0 +1,26 @@
+package feed
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMediaExtension(t *testing.T) {
+	tests := map[string]struct {
+		contentType string
+		expected    string
+	}{
+		"image":      {contentType: "image/jpeg", expected: "jpeg"},
+		"parameters": {contentType: "image/png; charset=binary", expected: "png"},
+		"suffix":     {contentType: "image/svg+xml", expected: "svg"},
+		"vendor":     {contentType: "application/vnd.ms-excel", expected: "vnd.ms-excel"},
+		"invalid":    {contentType: "unknown", expected: "bin"},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, mediaExtension(tt.contentType))
+		})
+	}
+}
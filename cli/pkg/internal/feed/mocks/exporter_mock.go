@@ -32,6 +32,27 @@ func (_m *Exporter) CreateSchema(_a0 feed.Feed, rows interface{}) error {
 	return r0
 }
 
+// DeleteDeletedRows provides a mock function with given fields: _a0, deletedAt, query, args
+func (_m *Exporter) DeleteDeletedRows(_a0 feed.Feed, deletedAt time.Time, query string, args ...interface{}) error {
+	var _ca []interface{}
+	_ca = append(_ca, _a0, deletedAt, query)
+	_ca = append(_ca, args...)
+	ret := _m.Called(_ca...)
+
+	if len(ret) == 0 {
+		panic("no return value specified for DeleteDeletedRows")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(feed.Feed, time.Time, string, ...interface{}) error); ok {
+		r0 = rf(_a0, deletedAt, query, args...)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // DeleteRowsIfExist provides a mock function with given fields: _a0, query, args
 func (_m *Exporter) DeleteRowsIfExist(_a0 feed.Feed, query string, args ...interface{}) error {
 	var _ca []interface{}
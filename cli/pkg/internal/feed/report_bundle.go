@@ -0,0 +1,288 @@
+package feed
+
+import (
+	"archive/zip"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/MickStanciu/go-fn/fn"
+	"gorm.io/gorm"
+)
+
+// reportBundleFolder is the folder of the export path the archives are written to
+const reportBundleFolder = "bundles"
+
+// reportBundleGroups are the fields the reports can be grouped by into archives
+var reportBundleGroups = map[string]func(inspection *Inspection, siteNames map[string]string) string{
+	"template": func(i *Inspection, _ map[string]string) string { return i.TemplateName },
+	"site": func(i *Inspection, siteNames map[string]string) string {
+		return fn.GetOrElse(siteNames[i.SiteID], i.SiteID, nonEmpty)
+	},
+	"month": func(i *Inspection, _ map[string]string) string {
+		if i.DateStarted.IsZero() {
+			return ""
+		}
+		return i.DateStarted.Format("2006-01")
+	},
+}
+
+func nonEmpty(s string) bool {
+	return s != ""
+}
+
+// reportBundle records the archive the reports of an inspection were bundled into, so that the archives the
+// inspection left are rebuilt as well
+type reportBundle struct {
+	AuditID string `gorm:"primarykey;column:audit_id"`
+	Bundle  string `gorm:"column:bundle"`
+}
+
+// bundledReport is a report written to an archive
+type bundledReport struct {
+	inspection *Inspection
+	site       string
+	path       string
+}
+
+// validateBundleBy returns an error if the reports can't be grouped by one of the fields
+func validateBundleBy(bundleBy []string) error {
+	for _, group := range bundleBy {
+		if _, ok := reportBundleGroups[group]; !ok {
+			return fmt.Errorf("reports can't be bundled by %q, template, site and month are the only valid options", group)
+		}
+	}
+	return nil
+}
+
+// bundleName returns the name of the archive of the reports of an inspection, such as Schrute-Farms_2024-03
+func (e *ReportExporter) bundleName(inspection *Inspection, siteNames map[string]string) string {
+	var parts []string
+	for _, group := range e.BundleBy {
+		value := strings.TrimSpace(sanitizeName(reportBundleGroups[group](inspection, siteNames)))
+		if strings.Trim(value, ".") == "" {
+			value = reportPathEmptyValue
+		}
+		parts = append(parts, value)
+	}
+	return strings.Join(parts, "_")
+}
+
+// bundleReports groups the reports into ZIP archives with an index.csv listing their inspections. Only the archives
+// with reports saved by this run, or whose inspections changed of archive, are rebuilt
+func (e *ReportExporter) bundleReports(saved map[string]bool) error {
+	if len(e.BundleBy) == 0 {
+		return nil
+	}
+
+	e.dbMu.Lock()
+	defer e.dbMu.Unlock()
+
+	if err := e.DB.AutoMigrate(&reportBundle{}); err != nil {
+		return err
+	}
+
+	siteNames := map[string]string{}
+	if e.DB.Migrator().HasTable(&Site{}) {
+		var sites []*Site
+		if err := e.DB.Select("site_id", "name").Find(&sites).Error; err != nil {
+			return err
+		}
+		for _, site := range sites {
+			siteNames[site.ID] = site.Name
+		}
+	}
+
+	var reports []*reportExport
+	if err := e.DB.Where("pdf = 1 OR word = 1").Find(&reports).Error; err != nil {
+		return err
+	}
+	paths := map[string][]string{}
+	for _, r := range reports {
+		if r.PDF == 1 && r.PDFPath != "" {
+			paths[r.AuditID] = append(paths[r.AuditID], r.PDFPath)
+		}
+		if r.WORD == 1 && r.WORDPath != "" {
+			paths[r.AuditID] = append(paths[r.AuditID], r.WORDPath)
+		}
+	}
+
+	var previous []*reportBundle
+	if err := e.DB.Find(&previous).Error; err != nil {
+		return err
+	}
+	previousBundles := map[string]string{}
+	for _, b := range previous {
+		previousBundles[b.AuditID] = b.Bundle
+	}
+
+	var inspections []*Inspection
+	err := e.DB.
+		Select("audit_id", "name", "site_id", "template_name", "date_started", "score_percentage").
+		Order("date_started, audit_id").
+		Find(&inspections).Error
+	if err != nil {
+		return err
+	}
+
+	bundles := map[string][]bundledReport{}
+	rebuild := map[string]bool{}
+	var current []*reportBundle
+	for _, inspection := range inspections {
+		if len(paths[inspection.ID]) == 0 {
+			continue
+		}
+
+		name := e.bundleName(inspection, siteNames)
+		for _, p := range paths[inspection.ID] {
+			bundles[name] = append(bundles[name], bundledReport{inspection: inspection, site: siteNames[inspection.SiteID], path: p})
+		}
+		current = append(current, &reportBundle{AuditID: inspection.ID, Bundle: name})
+
+		if saved[inspection.ID] || previousBundles[inspection.ID] != name {
+			rebuild[name] = true
+		}
+		if prev, ok := previousBundles[inspection.ID]; ok && prev != name {
+			rebuild[prev] = true
+		}
+		delete(previousBundles, inspection.ID)
+	}
+
+	// the archives of the inspections without report anymore are rebuilt without them
+	for _, prev := range previousBundles {
+		rebuild[prev] = true
+	}
+
+	dir := filepath.Join(e.ExportPath, reportBundleFolder)
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return err
+	}
+	for name := range bundles {
+		if _, err := os.Stat(filepath.Join(dir, name+".zip")); err != nil {
+			rebuild[name] = true
+		}
+	}
+
+	var names []string
+	for name := range rebuild {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		filePath := filepath.Join(dir, name+".zip")
+		if len(bundles[name]) == 0 {
+			if err := os.Remove(filePath); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			continue
+		}
+
+		if err := e.writeBundle(filePath, bundles[name]); err != nil {
+			return fmt.Errorf("bundle %s: %w", name, err)
+		}
+		if err := e.uploadFile(filePath); err != nil {
+			return err
+		}
+		e.Logger.Infof("Bundled %d reports into %s", len(bundles[name]), filePath)
+	}
+
+	return e.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Session(&gorm.Session{AllowGlobalUpdate: true}).Delete(&reportBundle{}).Error; err != nil {
+			return err
+		}
+		if len(current) == 0 {
+			return nil
+		}
+		// Column count + buffer to account for primary keys
+		return tx.CreateInBatches(current, e.ParameterLimit()/(2+4)).Error
+	})
+}
+
+// writeBundle writes an archive of reports and their index, replacing the previous one once complete
+func (e *ReportExporter) writeBundle(filePath string, reports []bundledReport) error {
+	tmp, err := os.CreateTemp(filepath.Dir(filePath), filepath.Base(filePath)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	// the reports removed from the export path are left out
+	reports = fn.Filter(reports, func(r bundledReport) bool {
+		_, sErr := os.Stat(r.path)
+		return sErr == nil
+	})
+
+	archive := zip.NewWriter(tmp)
+	index, err := archive.Create("index.csv")
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+
+	w := csv.NewWriter(index)
+	rows := [][]string{{"audit_id", "title", "site", "date", "score", "file_name"}}
+	for _, r := range reports {
+		date := ""
+		if !r.inspection.DateStarted.IsZero() {
+			date = r.inspection.DateStarted.Format("2006-01-02")
+		}
+		rows = append(rows, []string{
+			r.inspection.ID,
+			r.inspection.Name,
+			fn.GetOrElse(r.site, r.inspection.SiteID, nonEmpty),
+			date,
+			strconv.FormatFloat(float64(r.inspection.ScorePercentage), 'f', -1, 32),
+			e.bundleEntryName(r.path),
+		})
+	}
+	if err := w.WriteAll(rows); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	for _, r := range reports {
+		if err := addBundleFile(archive, r.path, e.bundleEntryName(r.path)); err != nil {
+			tmp.Close()
+			return err
+		}
+	}
+
+	if err := archive.Close(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filePath)
+}
+
+// bundleEntryName returns the name of a report in an archive, its path relative to the export path which is unique
+func (e *ReportExporter) bundleEntryName(filePath string) string {
+	name, err := filepath.Rel(e.ExportPath, filePath)
+	if err != nil || !filepath.IsLocal(name) {
+		name = filepath.Base(filePath)
+	}
+	return filepath.ToSlash(name)
+}
+
+// addBundleFile adds a report to an archive
+func addBundleFile(archive *zip.Writer, filePath string, name string) error {
+	in, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := archive.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, in)
+	return err
+}
@@ -0,0 +1,43 @@
+package feed
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReportExporter_bundleName(t *testing.T) {
+	inspection := &Inspection{
+		ID:           "audit_1",
+		TemplateName: "Daily: checks",
+		SiteID:       "site_1",
+		DateStarted:  time.Date(2024, 3, 4, 10, 0, 0, 0, time.UTC),
+	}
+
+	tests := map[string]struct {
+		bundleBy  []string
+		siteNames map[string]string
+		expected  string
+	}{
+		"template":          {bundleBy: []string{"template"}, expected: "Daily--checks"},
+		"site and month":    {bundleBy: []string{"site", "month"}, siteNames: map[string]string{"site_1": "North / Yard"}, expected: "North-Yard_2024-03"},
+		"site without name": {bundleBy: []string{"site"}, expected: "site_1"},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			e := &ReportExporter{BundleBy: tt.bundleBy}
+			assert.Equal(t, tt.expected, e.bundleName(inspection, tt.siteNames))
+		})
+	}
+
+	e := &ReportExporter{BundleBy: []string{"month"}}
+	assert.Equal(t, "unknown", e.bundleName(&Inspection{ID: "audit_2"}, nil))
+}
+
+func TestValidateBundleBy(t *testing.T) {
+	assert.NoError(t, validateBundleBy(nil))
+	assert.NoError(t, validateBundleBy([]string{"template", "site", "month"}))
+	assert.Error(t, validateBundleBy([]string{"site", "week"}))
+}
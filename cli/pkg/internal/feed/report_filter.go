@@ -0,0 +1,110 @@
+package feed
+
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ReportFilter limits the inspections the reports are generated for. The zero value generates the reports of every
+// inspection
+type ReportFilter struct {
+	SiteIDs     []string
+	TemplateIDs []string
+	// DateFrom and DateTo limit the date the inspections started, DateTo excluded
+	DateFrom time.Time
+	DateTo   time.Time
+	// ScoreMin and ScoreMax limit the score percentage of the inspections, ignored when 0
+	ScoreMin float64
+	ScoreMax float64
+	AuditIDs []string
+}
+
+// IsEmpty returns true if the filter doesn't exclude any inspection
+func (f *ReportFilter) IsEmpty() bool {
+	return len(f.SiteIDs) == 0 &&
+		len(f.TemplateIDs) == 0 &&
+		f.DateFrom.IsZero() &&
+		f.DateTo.IsZero() &&
+		f.ScoreMin == 0 &&
+		f.ScoreMax == 0 &&
+		len(f.AuditIDs) == 0
+}
+
+// validate returns an error if the filter can't match any inspection
+func (f *ReportFilter) validate() error {
+	if !f.DateTo.IsZero() && !f.DateTo.After(f.DateFrom) {
+		return fmt.Errorf("invalid report date range, date_to %s must be after date_from %s",
+			f.DateTo.Format(time.RFC3339), f.DateFrom.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// apply adds the conditions of the filter to a query of the inspections. The audit IDs aren't part of the query,
+// there can be more of them than the parameters a statement accepts
+func (f *ReportFilter) apply(tx *gorm.DB) *gorm.DB {
+	if len(f.SiteIDs) != 0 {
+		tx = tx.Where("site_id IN ?", f.SiteIDs)
+	}
+	if len(f.TemplateIDs) != 0 {
+		tx = tx.Where("template_id IN ?", f.TemplateIDs)
+	}
+	if !f.DateFrom.IsZero() {
+		tx = tx.Where("date_started >= ?", f.DateFrom)
+	}
+	if !f.DateTo.IsZero() {
+		tx = tx.Where("date_started < ?", f.DateTo)
+	}
+	if f.ScoreMin != 0 {
+		tx = tx.Where("score_percentage >= ?", f.ScoreMin)
+	}
+	if f.ScoreMax != 0 {
+		tx = tx.Where("score_percentage <= ?", f.ScoreMax)
+	}
+	return tx
+}
+
+// queuedInspections returns the IDs of the inspections the reports are generated for, nil when they all are
+func (e *ReportExporter) queuedInspections(format *reportExportFormat) (map[string]bool, error) {
+	if !e.RetryFailed && e.Filter.IsEmpty() {
+		return nil, nil
+	}
+
+	// only the inspections with a failed report are queued when retrying
+	var failed map[string]bool
+	if e.RetryFailed {
+		statuses, err := e.reportStatuses(format)
+		if err != nil {
+			return nil, err
+		}
+
+		failed = map[string]bool{}
+		for _, s := range statuses {
+			if s.State == ReportStateFailed {
+				failed[s.AuditID] = true
+			}
+		}
+	}
+
+	var auditIDs map[string]bool
+	if len(e.Filter.AuditIDs) != 0 {
+		auditIDs = map[string]bool{}
+		for _, id := range e.Filter.AuditIDs {
+			auditIDs[id] = true
+		}
+	}
+
+	var ids []string
+	if err := e.Filter.apply(e.DB.Model(&Inspection{})).Pluck("audit_id", &ids).Error; err != nil {
+		return nil, err
+	}
+
+	queued := map[string]bool{}
+	for _, id := range ids {
+		if (failed == nil || failed[id]) && (auditIDs == nil || auditIDs[id]) {
+			queued[id] = true
+		}
+	}
+	return queued, nil
+}
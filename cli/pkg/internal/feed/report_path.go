@@ -0,0 +1,280 @@
+package feed
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// reportFilenameConventions are the path templates of the filename conventions
+var reportFilenameConventions = map[string]string{
+	"INSPECTION_TITLE": "{title}.{ext}",
+	"INSPECTION_ID":    "{audit_id}.{ext}",
+}
+
+const (
+	// reportPathMaxLength keeps the fully qualified file names below the 260 characters allowed on Windows,
+	// accounting for the extension appended to the file name
+	reportPathMaxLength = 250
+	// reportPathDefaultDateLayout is the layout of the dates without one, such as {date}
+	reportPathDefaultDateLayout = "2006-01-02"
+	// reportPathEmptyValue replaces the fields without value, so that the folders of the layout are kept
+	reportPathEmptyValue = "unknown"
+)
+
+// reportPathFields are the fields of an inspection a report path template can refer to
+var reportPathFields = map[string]func(inspection *Inspection, r *reportPathValues) string{
+	"audit_id":        func(i *Inspection, _ *reportPathValues) string { return i.ID },
+	"title":           func(i *Inspection, _ *reportPathValues) string { return i.Name },
+	"template_id":     func(i *Inspection, _ *reportPathValues) string { return i.TemplateID },
+	"template_name":   func(i *Inspection, _ *reportPathValues) string { return i.TemplateName },
+	"site_id":         func(i *Inspection, _ *reportPathValues) string { return i.SiteID },
+	"site_name":       func(i *Inspection, r *reportPathValues) string { return r.siteNames[i.SiteID] },
+	"owner_name":      func(i *Inspection, _ *reportPathValues) string { return i.OwnerName },
+	"author_name":     func(i *Inspection, _ *reportPathValues) string { return i.AuthorName },
+	"organisation_id": func(i *Inspection, _ *reportPathValues) string { return i.OrganisationID },
+	"document_no":     func(i *Inspection, _ *reportPathValues) string { return i.DocumentNo },
+	"format":          func(_ *Inspection, r *reportPathValues) string { return r.format },
+	"ext":             func(_ *Inspection, r *reportPathValues) string { return getFileExtension(r.format) },
+}
+
+// reportPathDates are the dates of an inspection a report path template can refer to, formatted with the layout
+// following the name such as {date:2006-01}
+var reportPathDates = map[string]func(inspection *Inspection) *time.Time{
+	"date":           func(i *Inspection) *time.Time { return &i.DateStarted },
+	"date_completed": func(i *Inspection) *time.Time { return i.DateCompleted },
+	"modified_at":    func(i *Inspection) *time.Time { return &i.ModifiedAt },
+	"conducted_on":   func(i *Inspection) *time.Time { return i.ConductedOn },
+}
+
+type reportPathValues struct {
+	format    string
+	siteNames map[string]string
+}
+
+// reportPathSegment is either a literal text of the template or a field
+type reportPathSegment struct {
+	literal string
+	field   string
+	layout  string
+}
+
+// reportPathTemplate is the path of the reports relative to the export path, such as
+// {template_name}/{site_name}/{date:2006-01}/{title}_{audit_id}.{ext}
+type reportPathTemplate struct {
+	segments []reportPathSegment
+}
+
+// parseReportPathTemplate parses a path template, or the template of a filename convention
+func parseReportPathTemplate(tmpl string) (*reportPathTemplate, error) {
+	if convention, ok := reportFilenameConventions[tmpl]; ok {
+		tmpl = convention
+	}
+
+	t := &reportPathTemplate{}
+	for rest := tmpl; rest != ""; {
+		start := strings.Index(rest, "{")
+		if start == -1 {
+			t.segments = append(t.segments, reportPathSegment{literal: rest})
+			break
+		}
+		if start > 0 {
+			t.segments = append(t.segments, reportPathSegment{literal: rest[:start]})
+		}
+
+		end := strings.Index(rest[start:], "}")
+		if end == -1 {
+			return nil, fmt.Errorf("report path template %q: unclosed {", tmpl)
+		}
+
+		field, layout, hasLayout := strings.Cut(rest[start+1:start+end], ":")
+		if _, ok := reportPathDates[field]; ok {
+			if !hasLayout {
+				layout = reportPathDefaultDateLayout
+			}
+		} else if _, ok := reportPathFields[field]; !ok || hasLayout {
+			return nil, fmt.Errorf("report path template %q: unknown field {%s}", tmpl, rest[start+1:start+end])
+		}
+
+		t.segments = append(t.segments, reportPathSegment{field: field, layout: layout})
+		rest = rest[start+end+1:]
+	}
+
+	if len(t.segments) == 0 {
+		return nil, fmt.Errorf("report path template is empty")
+	}
+	return t, nil
+}
+
+// usesField returns true if the template refers to the field
+func (t *reportPathTemplate) usesField(field string) bool {
+	for _, s := range t.segments {
+		if s.field == field {
+			return true
+		}
+	}
+	return false
+}
+
+// render returns the path of the report of an inspection, slash separated. The values of the fields are sanitized
+// so that they can't add folders to the layout
+func (t *reportPathTemplate) render(inspection *Inspection, values *reportPathValues) (string, error) {
+	var sb strings.Builder
+	for _, s := range t.segments {
+		if s.field == "" {
+			sb.WriteString(s.literal)
+			continue
+		}
+
+		value := ""
+		if date, ok := reportPathDates[s.field]; ok {
+			if d := date(inspection); d != nil && !d.IsZero() {
+				value = d.Format(s.layout)
+			}
+		} else {
+			value = reportPathFields[s.field](inspection, values)
+		}
+
+		// a value made of dots only would walk the folders
+		value = strings.TrimSpace(sanitizeName(value))
+		if strings.Trim(value, ".") == "" {
+			value = ""
+		}
+		if value == "" && s.field == "title" {
+			value = inspection.ID
+		}
+		if value == "" {
+			value = reportPathEmptyValue
+		}
+		sb.WriteString(value)
+	}
+
+	p := path.Clean(strings.ReplaceAll(sb.String(), "\\", "/"))
+	if !filepath.IsLocal(filepath.FromSlash(p)) {
+		return "", fmt.Errorf("report path %q is outside of the export path", p)
+	}
+	return p, nil
+}
+
+// reportPaths resolves the file of each report. A path shared by several inspections belongs to the inspection
+// created first, the others have their ID appended. This keeps the file of an inspection the same across runs
+type reportPaths struct {
+	exportPath string
+	template   *reportPathTemplate
+	siteNames  map[string]string
+	owners     map[string]string
+}
+
+// reportPathTemplate returns the path template of the reports, the one of the filename convention when not set
+func (e *ReportExporter) reportPathTemplate() (*reportPathTemplate, error) {
+	if e.PathTemplate != "" {
+		return parseReportPathTemplate(e.PathTemplate)
+	}
+	return parseReportPathTemplate(e.Filename)
+}
+
+// usesSiteNames returns true if the reports are named or bundled after their site, the sites have to be exported
+// along the inspections
+func (e *ReportExporter) usesSiteNames() bool {
+	for _, group := range e.BundleBy {
+		if group == "site" {
+			return true
+		}
+	}
+
+	template, err := e.reportPathTemplate()
+	return err == nil && template.usesField("site_name")
+}
+
+// newReportPaths resolves the owner of the path of every inspection of the exporter
+func (e *ReportExporter) newReportPaths(formats []string) (*reportPaths, error) {
+	template, err := e.reportPathTemplate()
+	if err != nil {
+		return nil, err
+	}
+
+	p := &reportPaths{
+		exportPath: e.ExportPath,
+		template:   template,
+		siteNames:  map[string]string{},
+		owners:     map[string]string{},
+	}
+
+	if template.usesField("site_name") && e.DB.Migrator().HasTable(&Site{}) {
+		var sites []*Site
+		if err := e.DB.Select("site_id", "name").Find(&sites).Error; err != nil {
+			return nil, err
+		}
+		for _, site := range sites {
+			p.siteNames[site.ID] = site.Name
+		}
+	}
+
+	rows, err := e.DB.Model(&Inspection{}).Order("created_at, audit_id").Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		inspection := &Inspection{}
+		if err := e.DB.ScanRows(rows, inspection); err != nil {
+			return nil, err
+		}
+
+		if err := p.add(inspection, formats); err != nil {
+			return nil, err
+		}
+	}
+	return p, rows.Err()
+}
+
+// add records the inspection as the owner of the files of its reports not owned yet. The files are compared once
+// truncated, the paths differing past the maximum length are the same file
+func (p *reportPaths) add(inspection *Inspection, formats []string) error {
+	for _, format := range formats {
+		rel, err := p.template.render(inspection, &reportPathValues{format: format, siteNames: p.siteNames})
+		if err != nil {
+			return err
+		}
+		if file := p.file(rel, ""); p.owners[file] == "" {
+			p.owners[file] = inspection.ID
+		}
+	}
+	return nil
+}
+
+// path returns the file of the report of an inspection in the given format
+func (p *reportPaths) path(inspection *Inspection, format string) (string, error) {
+	rel, err := p.template.render(inspection, &reportPathValues{format: format, siteNames: p.siteNames})
+	if err != nil {
+		return "", err
+	}
+
+	file := p.file(rel, "")
+	if owner, ok := p.owners[file]; ok && owner != inspection.ID {
+		return p.file(rel, fmt.Sprintf(" (%s)", inspection.ID)), nil
+	}
+	return file, nil
+}
+
+// file returns the file of a report path with the suffix appended to its name. The name is truncated for the fully
+// qualified file name to fit on Windows, which can't be longer than 260 characters
+func (p *reportPaths) file(rel string, suffix string) string {
+	dir, base := path.Split(rel)
+	ext := path.Ext(base)
+	name := strings.TrimSuffix(base, ext)
+
+	dirPath := filepath.Join(p.exportPath, filepath.FromSlash(dir))
+	if maxLength := reportPathMaxLength - len(dirPath) - 1 - len(suffix); maxLength > 0 && len(name) > maxLength {
+		// the name is cut before a rune, not in the middle of one
+		for maxLength > 0 && !utf8.RuneStart(name[maxLength]) {
+			maxLength--
+		}
+		name = name[:maxLength]
+	}
+	return filepath.Join(dirPath, name+suffix+ext)
+}
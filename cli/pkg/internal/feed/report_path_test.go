@@ -0,0 +1,94 @@
+package feed
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReportPathTemplate_render(t *testing.T) {
+	completed := time.Date(2024, 3, 5, 10, 0, 0, 0, time.UTC)
+	inspection := &Inspection{
+		ID:            "audit_1",
+		Name:          "Site walk / North",
+		TemplateName:  "Daily: checks",
+		SiteID:        "site_1",
+		DateStarted:   time.Date(2024, 3, 4, 10, 0, 0, 0, time.UTC),
+		DateCompleted: &completed,
+	}
+	values := &reportPathValues{format: "WORD", siteNames: map[string]string{"site_1": "Head office"}}
+
+	tests := map[string]struct {
+		template string
+		expected string
+	}{
+		"title convention": {template: "INSPECTION_TITLE", expected: "Site-walk-North.docx"},
+		"id convention":    {template: "INSPECTION_ID", expected: "audit_1.docx"},
+		"folders":          {template: "{template_name}/{site_name}/{date:2006-01}/{title}_{audit_id}.{ext}", expected: "Daily--checks/Head-office/2024-03/Site-walk-North_audit_1.docx"},
+		"default layout":   {template: "{date_completed}/{format}.{ext}", expected: "2024-03-05/WORD.docx"},
+		"empty values":     {template: "{owner_name}/{conducted_on}/{audit_id}.{ext}", expected: "unknown/unknown/audit_1.docx"},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			tmpl, err := parseReportPathTemplate(tt.template)
+			require.NoError(t, err)
+
+			rel, err := tmpl.render(inspection, values)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, rel)
+		})
+	}
+}
+
+func TestReportPathTemplate_render_should_keep_reports_in_export_path(t *testing.T) {
+	tmpl, err := parseReportPathTemplate("{title}/{audit_id}.{ext}")
+	require.NoError(t, err)
+
+	rel, err := tmpl.render(&Inspection{ID: "audit_1", Name: ".."}, &reportPathValues{format: "PDF"})
+	require.NoError(t, err)
+	assert.Equal(t, "audit_1/audit_1.pdf", rel)
+
+	tmpl, err = parseReportPathTemplate("../{audit_id}.{ext}")
+	require.NoError(t, err)
+
+	_, err = tmpl.render(&Inspection{ID: "audit_1"}, &reportPathValues{format: "PDF"})
+	assert.Error(t, err)
+}
+
+func TestReportPaths_path_should_suffix_names_colliding_once_truncated(t *testing.T) {
+	tmpl, err := parseReportPathTemplate("INSPECTION_TITLE")
+	require.NoError(t, err)
+
+	exportPath := filepath.Join("exports", "reports")
+	paths := &reportPaths{exportPath: exportPath, template: tmpl, siteNames: map[string]string{}, owners: map[string]string{}}
+
+	// the titles only differ past the maximum length of the file names
+	title := strings.Repeat("a", reportPathMaxLength)
+	first := &Inspection{ID: "audit_1", Name: title + "first"}
+	second := &Inspection{ID: "audit_2", Name: title + "second"}
+	require.NoError(t, paths.add(first, []string{"PDF"}))
+	require.NoError(t, paths.add(second, []string{"PDF"}))
+
+	firstPath, err := paths.path(first, "PDF")
+	require.NoError(t, err)
+	secondPath, err := paths.path(second, "PDF")
+	require.NoError(t, err)
+
+	assert.NotEqual(t, firstPath, secondPath)
+	assert.True(t, strings.HasSuffix(secondPath, " (audit_2).pdf"), secondPath)
+	for _, p := range []string{firstPath, secondPath} {
+		assert.LessOrEqual(t, len(strings.TrimSuffix(p, ".pdf")), reportPathMaxLength, p)
+	}
+}
+
+func TestParseReportPathTemplate_should_reject_invalid_templates(t *testing.T) {
+	for _, tmpl := range []string{"", "{title", "{unknown}.{ext}", "{title:2006}.{ext}"} {
+		_, err := parseReportPathTemplate(tmpl)
+		assert.Error(t, err, tmpl)
+	}
+}
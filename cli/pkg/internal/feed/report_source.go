@@ -0,0 +1,69 @@
+package feed
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/SafetyCulture/safetyculture-exporter/pkg/internal/util"
+	"gorm.io/gorm"
+)
+
+// reportSourceAuditIDsBatchSize is the number of audit IDs the inspections are read by from the SQL database, below
+// the parameters a statement accepts on every dialect
+const reportSourceAuditIDsBatchSize = 500
+
+// copySourceFeed replaces the rows of a feed in reports.db with the ones of the SQL database the reports are
+// generated from, the rows matching any of the queries when given
+func (e *ReportExporter) copySourceFeed(ctx context.Context, f Feed, queries ...func(tx *gorm.DB) *gorm.DB) error {
+	if !e.Source.DB.Migrator().HasTable(f.Name()) {
+		return fmt.Errorf("no %s table in the SQL database, export the data to SQL before generating the reports", f.Name())
+	}
+
+	if err := e.InitFeed(f, &InitFeedOptions{Truncate: true}); err != nil {
+		return fmt.Errorf("init feed: %w", err)
+	}
+
+	if len(queries) == 0 {
+		queries = append(queries, func(tx *gorm.DB) *gorm.DB { return tx })
+	}
+
+	// Calculate the size of the batch we can insert into the DB at once. Column count + buffer to account for primary keys
+	batchSize := e.ParameterLimit() / (len(f.Columns()) + 4)
+	var read int64
+	for _, query := range queries {
+		rows := f.RowsModel()
+		res := query(e.Source.DB.Table(f.Name())).FindInBatches(rows, batchSize, func(_ *gorm.DB, _ int) error {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			return e.WriteRows(f, rows)
+		})
+		if res.Error != nil {
+			return fmt.Errorf("copy %s: %w", f.Name(), res.Error)
+		}
+		read += res.RowsAffected
+	}
+
+	e.Logger.Infof("Read %d %s from the SQL database", read, f.Name())
+	return nil
+}
+
+// copySourceInspections copies the inspections of the SQL database matching the filter of the reports. The audit IDs
+// are read in batches, there can be more of them than the parameters a statement accepts
+func (e *ReportExporter) copySourceInspections(ctx context.Context, f Feed) error {
+	filter := func(tx *gorm.DB) *gorm.DB {
+		return e.Filter.apply(tx.Where("deleted = ?", false))
+	}
+	if len(e.Filter.AuditIDs) == 0 {
+		return e.copySourceFeed(ctx, f, filter)
+	}
+
+	var queries []func(tx *gorm.DB) *gorm.DB
+	_ = util.SplitSliceInBatch(reportSourceAuditIDsBatchSize, e.Filter.AuditIDs, func(batch []string) error {
+		queries = append(queries, func(tx *gorm.DB) *gorm.DB {
+			return filter(tx).Where("audit_id IN ?", batch)
+		})
+		return nil
+	})
+	return e.copySourceFeed(ctx, f, queries...)
+}
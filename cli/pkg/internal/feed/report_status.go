@@ -0,0 +1,117 @@
+package feed
+
+import (
+	"fmt"
+	"time"
+)
+
+// ReportState is the state of the reports of an inspection
+type ReportState string
+
+const (
+	// ReportStatePending is the state of the inspections whose reports are yet to be generated, because they are new
+	// or were modified since
+	ReportStatePending ReportState = "PENDING"
+	// ReportStateFailed is the state of the inspections with a report that failed to generate
+	ReportStateFailed ReportState = "FAILED"
+	// ReportStateSucceeded is the state of the inspections whose reports are all saved
+	ReportStateSucceeded ReportState = "SUCCEEDED"
+)
+
+// ReportStatus is the state of the reports of an inspection, as recorded in reports.db
+type ReportStatus struct {
+	AuditID       string
+	Name          string
+	State         ReportState
+	Attempts      int
+	LastAttemptAt *time.Time
+	// Paths are the files the reports were saved to
+	Paths []string
+	// Errors are the errors the reports failed with, prefixed with their format
+	Errors []string
+}
+
+// reportState returns the state of the reports of an inspection in the formats exported, r is nil when the
+// reports of the inspection were never generated
+func reportState(r *reportExport, inspection *Inspection, format *reportExportFormat) ReportState {
+	if r == nil || !r.AuditModifiedAt.Equal(inspection.ModifiedAt) {
+		return ReportStatePending
+	}
+
+	if (format.PDF && r.PDF == -1) || (format.WORD && r.WORD == -1) {
+		return ReportStateFailed
+	}
+
+	if (format.PDF && r.PDF != 1) || (format.WORD && r.WORD != 1) {
+		return ReportStatePending
+	}
+	return ReportStateSucceeded
+}
+
+// ListReportStatuses returns the state of the reports of the inspections exported by the previous runs
+func (e *ReportExporter) ListReportStatuses() ([]ReportStatus, error) {
+	format, err := e.getFormats()
+	if err != nil {
+		return nil, err
+	}
+	return e.reportStatuses(format)
+}
+
+// reportStatuses returns the state of the reports of every inspection in the formats exported
+func (e *ReportExporter) reportStatuses(format *reportExportFormat) ([]ReportStatus, error) {
+	e.dbMu.Lock()
+	defer e.dbMu.Unlock()
+
+	if !e.DB.Migrator().HasTable(&Inspection{}) {
+		return nil, fmt.Errorf("no reports exported to %s", e.ExportPath)
+	}
+
+	reports := map[string]*reportExport{}
+	if e.DB.Migrator().HasTable(&reportExport{}) {
+		var rows []*reportExport
+		if err := e.DB.Find(&rows).Error; err != nil {
+			return nil, err
+		}
+		for _, r := range rows {
+			reports[r.AuditID] = r
+		}
+	}
+
+	var inspections []*Inspection
+	if err := e.DB.Select("audit_id", "name", "modified_at").Order("modified_at ASC, audit_id").Find(&inspections).Error; err != nil {
+		return nil, err
+	}
+
+	statuses := make([]ReportStatus, 0, len(inspections))
+	for _, inspection := range inspections {
+		r := reports[inspection.ID]
+		status := ReportStatus{
+			AuditID: inspection.ID,
+			Name:    inspection.Name,
+			State:   reportState(r, inspection, format),
+		}
+
+		if r != nil {
+			status.Attempts = r.Attempts
+			status.LastAttemptAt = r.LastAttemptAt
+			for _, f := range []struct {
+				name    string
+				enabled bool
+				path    string
+				err     string
+			}{
+				{"PDF", format.PDF, r.PDFPath, r.PDFError},
+				{"WORD", format.WORD, r.WORDPath, r.WORDError},
+			} {
+				if f.enabled && f.path != "" {
+					status.Paths = append(status.Paths, f.path)
+				}
+				if f.enabled && f.err != "" {
+					status.Errors = append(status.Errors, fmt.Sprintf("%s: %s", f.name, f.err))
+				}
+			}
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
@@ -0,0 +1,57 @@
+package feed
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReportState(t *testing.T) {
+	modifiedAt := time.Date(2024, 3, 4, 10, 0, 0, 0, time.UTC)
+	inspection := &Inspection{ID: "audit_1", ModifiedAt: modifiedAt}
+	pdf := &reportExportFormat{PDF: true}
+	both := &reportExportFormat{PDF: true, WORD: true}
+
+	tests := map[string]struct {
+		report   *reportExport
+		format   *reportExportFormat
+		expected ReportState
+	}{
+		"never generated": {
+			format:   pdf,
+			expected: ReportStatePending,
+		},
+		"saved": {
+			report:   &reportExport{AuditModifiedAt: modifiedAt, PDF: 1},
+			format:   pdf,
+			expected: ReportStateSucceeded,
+		},
+		"modified since saved": {
+			report:   &reportExport{AuditModifiedAt: modifiedAt.Add(-time.Hour), PDF: 1},
+			format:   pdf,
+			expected: ReportStatePending,
+		},
+		"failed": {
+			report:   &reportExport{AuditModifiedAt: modifiedAt, PDF: 1, WORD: -1},
+			format:   both,
+			expected: ReportStateFailed,
+		},
+		"failed in a format not exported": {
+			report:   &reportExport{AuditModifiedAt: modifiedAt, PDF: 1, WORD: -1},
+			format:   pdf,
+			expected: ReportStateSucceeded,
+		},
+		"format not generated yet": {
+			report:   &reportExport{AuditModifiedAt: modifiedAt, PDF: 1},
+			format:   both,
+			expected: ReportStatePending,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, reportState(tt.report, inspection, tt.format))
+		})
+	}
+}
@@ -0,0 +1,378 @@
+package feed
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
+	"gorm.io/driver/sqlserver"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+	gormschema "gorm.io/gorm/schema"
+)
+
+// SchemaFormat is the format the schemas of the feeds are written in
+type SchemaFormat string
+
+const (
+	// SchemaFormatTable writes a table of the columns of each feed
+	SchemaFormatTable SchemaFormat = "table"
+	// SchemaFormatDDL writes the statements creating the tables of the feeds
+	SchemaFormatDDL SchemaFormat = "ddl"
+	// SchemaFormatJSONSchema writes a JSON Schema document defining the rows of each feed
+	SchemaFormatJSONSchema SchemaFormat = "jsonschema"
+	// SchemaFormatDBT writes a dbt sources.yml declaring the tables of the feeds
+	SchemaFormatDBT SchemaFormat = "dbt"
+)
+
+// dbtSourceName is the name of the dbt source the tables of the feeds are declared in
+const dbtSourceName = "safetyculture"
+
+// ParseSchemaFormat validates a schema format, an empty format is the table format
+func ParseSchemaFormat(format string) (SchemaFormat, error) {
+	switch SchemaFormat(format) {
+	case "", SchemaFormatTable:
+		return SchemaFormatTable, nil
+	case SchemaFormatDDL, SchemaFormatJSONSchema, SchemaFormatDBT:
+		return SchemaFormat(format), nil
+	}
+	return "", fmt.Errorf("invalid schema format %q, expected %q, %q, %q or %q", format, SchemaFormatTable, SchemaFormatDDL, SchemaFormatJSONSchema, SchemaFormatDBT)
+}
+
+// WriteSchemas writes the schemas of the feeds in the format. The DDL statements and the dbt data types are written
+// for the dialect
+func (e *ExporterFeedClient) WriteSchemas(w io.Writer, format SchemaFormat, dialect string) error {
+	if format == SchemaFormatTable {
+		exporter, err := NewSchemaExporter(w)
+		if err != nil {
+			return err
+		}
+		return e.PrintSchemas(exporter)
+	}
+
+	db, err := newDryRunDB(dialect)
+	if err != nil {
+		return err
+	}
+
+	if format == SchemaFormatDDL {
+		return writeDDL(w, db, e.schemaFeeds())
+	}
+
+	tables, err := newSchemaDescriptions(db, e.schemaFeeds())
+	if err != nil {
+		return err
+	}
+
+	switch format {
+	case SchemaFormatJSONSchema:
+		return writeJSONSchema(w, tables)
+	case SchemaFormatDBT:
+		return writeDBTSources(w, tables)
+	}
+	return fmt.Errorf("invalid schema format %q", format)
+}
+
+// newDryRunDB opens a database of the dialect that builds the statements without running them, no connection is made
+func newDryRunDB(dialect string) (*gorm.DB, error) {
+	var dialector gorm.Dialector
+	switch dialect {
+	case "mysql":
+		dialector = mysql.New(mysql.Config{SkipInitializeWithVersion: true})
+	case "postgres":
+		dialector = postgres.New(postgres.Config{})
+	case "sqlserver":
+		dialector = sqlserver.Open("")
+	case "sqlite":
+		dialector = sqlite.Open("file::memory:")
+	default:
+		return nil, fmt.Errorf("invalid database dialect %s", dialect)
+	}
+
+	return gorm.Open(dialector, &gorm.Config{
+		DryRun:               true,
+		DisableAutomaticPing: true,
+		Logger:               gormlogger.Discard,
+	})
+}
+
+// writeDDL writes the statements creating the tables of the feeds
+func writeDDL(w io.Writer, db *gorm.DB, feeds []Feed) error {
+	exporter := &SQLExporter{DB: db}
+	for _, feed := range feeds {
+		statements, err := exporter.dryRun(func(tx *gorm.DB) error {
+			return tx.Table(feed.Name()).Migrator().CreateTable(feed.Model())
+		})
+		if err != nil {
+			return fmt.Errorf("create table %s: %w", feed.Name(), err)
+		}
+
+		fmt.Fprintf(w, "-- %s\n", feed.Name())
+		for _, statement := range statements {
+			fmt.Fprintf(w, "%s;\n", statement)
+		}
+		fmt.Fprintln(w)
+	}
+	return nil
+}
+
+// schemaDescription describes the table of a feed
+type schemaDescription struct {
+	name        string
+	description string
+	primaryKey  []string
+	columns     []schemaColumnDescription
+}
+
+// schemaColumnDescription describes a column of the table of a feed
+type schemaColumnDescription struct {
+	name string
+	// fileName is the name of the column in the file exports, the keys of the JSONL rows
+	fileName    string
+	description string
+	dataType    string
+	goType      reflect.Type
+	// references is the table the column holds the primary key of, if any
+	references string
+}
+
+func newSchemaDescriptions(db *gorm.DB, feeds []Feed) ([]schemaDescription, error) {
+	// the tables identified by a single column are referenced by the columns of the same name
+	referenced := map[string]string{}
+	for _, feed := range feeds {
+		if _, ok := feed.(*historyFeed); ok {
+			continue
+		}
+		pk := feed.PrimaryKey()
+		if len(pk) != 1 || pk[0] == "id" {
+			continue
+		}
+		if _, ok := referenced[pk[0]]; !ok {
+			referenced[pk[0]] = feed.Name()
+		}
+	}
+
+	var tables []schemaDescription
+	for _, feed := range feeds {
+		stmt := &gorm.Statement{DB: db, Table: feed.Name()}
+		if err := stmt.Parse(feed.Model()); err != nil {
+			return nil, fmt.Errorf("parse model of %s: %w", feed.Name(), err)
+		}
+
+		table := schemaDescription{
+			name:        feed.Name(),
+			description: fmt.Sprintf("Rows of the %s feed", feed.Name()),
+			primaryKey:  feed.PrimaryKey(),
+		}
+		if h, ok := feed.(*historyFeed); ok {
+			table.description = fmt.Sprintf("Versions of the rows of the %s table, each valid from valid_from until valid_to", h.Feed.Name())
+		}
+
+		for _, field := range stmt.Schema.Fields {
+			if field.DBName == "" {
+				continue
+			}
+
+			column := schemaColumnDescription{
+				name:        field.DBName,
+				fileName:    fileColumnName(field.StructField),
+				description: columnDescription(field),
+				dataType:    db.Dialector.DataTypeOf(field),
+				goType:      field.StructField.Type,
+			}
+			if target, ok := referenced[field.DBName]; ok && target != feed.Name() && target+historyTableSuffix != feed.Name() {
+				column.references = target
+			}
+			table.columns = append(table.columns, column)
+		}
+		tables = append(tables, table)
+	}
+	return tables, nil
+}
+
+// commonColumnDescriptions describe the columns shared by the feeds
+var commonColumnDescriptions = map[string]string{
+	"organisation_id": "ID of the organisation the row belongs to",
+	"exported_at":     "Time the row was last written by the exporter",
+	"created_at":      "Time the record was created in SafetyCulture",
+	"modified_at":     "Time the record was last modified in SafetyCulture",
+	"deleted":         "Whether the record was deleted in SafetyCulture",
+	"deleted_at":      "Time the record was deleted in SafetyCulture",
+	"history_id":      "ID of the version of the row",
+	"valid_from":      "Time the version of the row is valid from",
+	"valid_to":        "Time the version of the row was replaced, empty for the current version",
+	"row_hash":        "Hash of the row the version was created from",
+}
+
+// columnDescription returns the description tag of the field of a column, the columns added by the exporter are
+// described from their name
+func columnDescription(field *gormschema.Field) string {
+	if description := field.Tag.Get("description"); description != "" {
+		return description
+	}
+
+	column := field.DBName
+	if description, ok := commonColumnDescriptions[column]; ok {
+		return description
+	}
+	if name, ok := strings.CutSuffix(column, utcColumnSuffix); ok {
+		return fmt.Sprintf("UTC value of %s", name)
+	}
+	if name, ok := strings.CutSuffix(column, "_id"); ok {
+		return fmt.Sprintf("ID of the %s", strings.ReplaceAll(name, "_", " "))
+	}
+
+	description := strings.ReplaceAll(column, "_", " ")
+	return strings.ToUpper(description[:1]) + description[1:]
+}
+
+// writeJSONSchema writes a JSON Schema document with a definition of the rows of each feed, as written by the JSONL
+// exporter
+func writeJSONSchema(w io.Writer, tables []schemaDescription) error {
+	defs := map[string]interface{}{}
+	for _, table := range tables {
+		properties := map[string]interface{}{}
+		var required []string
+		for _, column := range table.columns {
+			property := jsonSchemaType(column.goType)
+			property["description"] = column.description
+			properties[column.fileName] = property
+
+			for _, pk := range table.primaryKey {
+				if pk == column.name {
+					required = append(required, column.fileName)
+				}
+			}
+		}
+
+		defs[table.name] = map[string]interface{}{
+			"title":                table.name,
+			"description":          table.description,
+			"type":                 "object",
+			"properties":           properties,
+			"required":             required,
+			"additionalProperties": false,
+		}
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(map[string]interface{}{
+		"$schema": "https://json-schema.org/draft/2020-12/schema",
+		"title":   "SafetyCulture exporter feeds",
+		"$defs":   defs,
+	})
+}
+
+// jsonSchemaType returns the JSON Schema type of a field, pointers are nullable
+func jsonSchemaType(t reflect.Type) map[string]interface{} {
+	nullable := t.Kind() == reflect.Ptr
+	if nullable {
+		t = t.Elem()
+	}
+
+	schema := map[string]interface{}{}
+	var jsonType string
+	switch {
+	case t == timeType:
+		jsonType = "string"
+		schema["format"] = "date-time"
+	case t.Kind() == reflect.String:
+		jsonType = "string"
+	case t.Kind() == reflect.Bool:
+		jsonType = "boolean"
+	case t.Kind() >= reflect.Int && t.Kind() <= reflect.Uint64:
+		jsonType = "integer"
+	case t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64:
+		jsonType = "number"
+	default:
+		return schema
+	}
+
+	if nullable {
+		schema["type"] = []string{jsonType, "null"}
+	} else {
+		schema["type"] = jsonType
+	}
+	return schema
+}
+
+type dbtSources struct {
+	Version int         `yaml:"version"`
+	Sources []dbtSource `yaml:"sources"`
+}
+
+type dbtSource struct {
+	Name        string     `yaml:"name"`
+	Description string     `yaml:"description"`
+	Tables      []dbtTable `yaml:"tables"`
+}
+
+type dbtTable struct {
+	Name        string                 `yaml:"name"`
+	Description string                 `yaml:"description"`
+	Meta        map[string]interface{} `yaml:"meta,omitempty"`
+	Columns     []dbtColumn            `yaml:"columns"`
+}
+
+type dbtColumn struct {
+	Name        string                 `yaml:"name"`
+	Description string                 `yaml:"description"`
+	DataType    string                 `yaml:"data_type,omitempty"`
+	Meta        map[string]interface{} `yaml:"meta,omitempty"`
+	Tests       []interface{}          `yaml:"tests,omitempty"`
+}
+
+type dbtReference struct {
+	Table  string `yaml:"table"`
+	Column string `yaml:"column"`
+}
+
+// writeDBTSources writes a dbt sources.yml declaring the tables of the feeds and their primary keys. The tables the
+// columns reference are declared in the meta of the columns rather than as relationships tests, the filters of the
+// export and the deletions leave rows referencing rows that were not exported
+func writeDBTSources(w io.Writer, tables []schemaDescription) error {
+	source := dbtSource{
+		Name:        dbtSourceName,
+		Description: "Data exported from SafetyCulture by the SafetyCulture exporter",
+	}
+
+	for _, table := range tables {
+		t := dbtTable{
+			Name:        table.name,
+			Description: table.description,
+			Meta:        map[string]interface{}{"primary_key": table.primaryKey},
+		}
+
+		for _, column := range table.columns {
+			c := dbtColumn{
+				Name:        column.name,
+				Description: column.description,
+				DataType:    column.dataType,
+			}
+			if len(table.primaryKey) == 1 && table.primaryKey[0] == column.name {
+				c.Tests = append(c.Tests, "not_null", "unique")
+			}
+			if column.references != "" {
+				c.Meta = map[string]interface{}{
+					"references": dbtReference{Table: column.references, Column: column.name},
+				}
+			}
+			t.Columns = append(t.Columns, c)
+		}
+		source.Tables = append(source.Tables, t)
+	}
+
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(dbtSources{Version: 2, Sources: []dbtSource{source}}); err != nil {
+		return err
+	}
+	return enc.Close()
+}
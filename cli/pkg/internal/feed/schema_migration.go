@@ -0,0 +1,474 @@
+package feed
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/SafetyCulture/safetyculture-exporter/pkg/internal/events"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	gormlogger "gorm.io/gorm/logger"
+	gormschema "gorm.io/gorm/schema"
+)
+
+// SchemaMigration is the record of a schema migration applied to the database
+type SchemaMigration struct {
+	Version    string    `json:"version" gorm:"primarykey;size:64"`
+	AppliedAt  time.Time `json:"applied_at" gorm:"index"`
+	Statements int       `json:"statements"`
+}
+
+// TableName returns the name of the table holding the applied schema migrations
+func (SchemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// SchemaMigrationPlan is the DDL bringing the database schema up to date with the tables of the exporter
+type SchemaMigrationPlan struct {
+	Dialect string
+	// Version identifies the schema of the exporter, it changes with the tables and columns of the feeds
+	Version string
+	// CurrentVersion is the version of the last migration applied to the database, empty when there is none
+	CurrentVersion string
+	// Statements are the DDL statements to run, in order
+	Statements []string
+	// Destructive are the statements of Statements dropping columns or changing their type, they may lose data
+	Destructive []string
+	// Record is the statement recording the migration in the schema_migrations table
+	Record string
+}
+
+// Script returns the statements of the migration followed by its record, to be run by a database administrator
+func (p *SchemaMigrationPlan) Script() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "-- safetyculture-exporter schema migration\n")
+	fmt.Fprintf(&sb, "-- dialect: %s\n", p.Dialect)
+	fmt.Fprintf(&sb, "-- version: %s\n", p.Version)
+	if p.CurrentVersion != "" {
+		fmt.Fprintf(&sb, "-- current version: %s\n", p.CurrentVersion)
+	}
+	if len(p.Destructive) != 0 {
+		fmt.Fprintf(&sb, "-- destructive statements: %d, review them before running the migration\n", len(p.Destructive))
+	}
+	sb.WriteString("\n")
+
+	destructive := map[string]bool{}
+	for _, statement := range p.Destructive {
+		destructive[statement] = true
+	}
+	for _, statement := range append(append([]string{}, p.Statements...), p.Record) {
+		if destructive[statement] {
+			sb.WriteString("-- destructive, data may be lost\n")
+		}
+		sb.WriteString(statement)
+		sb.WriteString(";\n")
+	}
+	return sb.String()
+}
+
+// UpToDate returns whether the schema of the database is the schema of the exporter
+func (p *SchemaMigrationPlan) UpToDate() bool {
+	return p.CurrentVersion == p.Version && len(p.Statements) == 0
+}
+
+// migration returns the record of the migration
+func (p *SchemaMigrationPlan) migration() *SchemaMigration {
+	return &SchemaMigration{
+		Version:    p.Version,
+		AppliedAt:  time.Now().UTC(),
+		Statements: len(p.Statements),
+	}
+}
+
+// record stores the migration, a migration repairing a database already at its version replaces the existing record
+func (p *SchemaMigrationPlan) record(tx *gorm.DB) error {
+	return tx.Clauses(clause.OnConflict{UpdateAll: true}).Create(p.migration()).Error
+}
+
+// schemaTable is a table created by the exporter
+type schemaTable struct {
+	name  string
+	model interface{}
+}
+
+// schemaFeeds returns the feeds the exporter writes to, with the columns and the history tables added by the
+// configuration
+func (e *ExporterFeedClient) schemaFeeds() []Feed {
+	history := map[string]bool{}
+	for _, table := range e.configuration.ExportHistoryTables {
+		history[table] = true
+	}
+
+	var feeds []Feed
+	for _, feed := range append(e.GetFeeds(), e.GetSheqsyFeeds()...) {
+		if e.configuration.ExportTimeZoneKeepUTC {
+			feed = newTimeZoneFeed(feed, true)
+		}
+		feeds = append(feeds, feed)
+
+		if history[feed.Name()] {
+			feeds = append(feeds, newHistoryFeed(feed))
+		}
+	}
+	return feeds
+}
+
+// schemaTables returns the tables the exporter writes to, including the tables keeping the state of the exports
+func (e *ExporterFeedClient) schemaTables() []schemaTable {
+	var tables []schemaTable
+	for _, feed := range e.schemaFeeds() {
+		tables = append(tables, schemaTable{name: feed.Name(), model: feed.Model()})
+	}
+
+	for _, model := range []interface{ TableName() string }{ExportState{}, ExportRun{}, ExportRunFeed{}, Media{}} {
+		tables = append(tables, schemaTable{name: model.TableName(), model: model})
+	}
+	return tables
+}
+
+// PlanSchemaMigration returns the DDL bringing the schema of the database up to date, without running it
+func (e *ExporterFeedClient) PlanSchemaMigration(exporter *SQLExporter) (*SchemaMigrationPlan, error) {
+	return exporter.planSchemaMigration(e.schemaTables())
+}
+
+// ApplySchemaMigration brings the schema of the database up to date and records the migration
+func (e *ExporterFeedClient) ApplySchemaMigration(exporter *SQLExporter) (*SchemaMigrationPlan, error) {
+	plan, err := exporter.planSchemaMigration(e.schemaTables())
+	if err != nil {
+		return nil, err
+	}
+	if plan.UpToDate() {
+		return plan, nil
+	}
+
+	if err := exporter.applySchemaMigration(plan); err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+// checkSchemaVersion fails when the database is managed with schema migrations and its schema is out of date
+func (e *ExporterFeedClient) checkSchemaVersion(exporter Exporter) error {
+	sqlExporter, ok := exporter.(*SQLExporter)
+	if !ok || sqlExporter.AutoMigrate || !sqlExporter.DB.Migrator().HasTable(&SchemaMigration{}) {
+		return nil
+	}
+
+	plan, err := e.PlanSchemaMigration(sqlExporter)
+	if err != nil {
+		return err
+	}
+	if !plan.UpToDate() {
+		return fmt.Errorf("the database schema is out of date (version %q, expected %q), run the migrate apply command", plan.CurrentVersion, plan.Version)
+	}
+	return nil
+}
+
+// ListSchemaMigrations returns the schema migrations applied to the database, the most recent first
+func (e *SQLExporter) ListSchemaMigrations() ([]SchemaMigration, error) {
+	var migrations []SchemaMigration
+	if !e.DB.Migrator().HasTable(&SchemaMigration{}) {
+		return migrations, nil
+	}
+
+	result := e.DB.Order("applied_at DESC").Find(&migrations)
+	if result.Error != nil {
+		return nil, events.NewEventErrorWithMessage(result.Error, events.ErrorSeverityError, events.ErrorSubSystemDB, false, "unable to list schema migrations")
+	}
+	return migrations, nil
+}
+
+func (e *SQLExporter) planSchemaMigration(tables []schemaTable) (*SchemaMigrationPlan, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	plan := &SchemaMigrationPlan{Dialect: e.DB.Dialector.Name()}
+
+	// the version is the DDL creating every table from scratch, it is the same for every database of a dialect
+	var creates []string
+	for _, table := range tables {
+		statements, err := e.dryRun(func(tx *gorm.DB) error {
+			return tx.Table(table.name).Migrator().CreateTable(table.model)
+		})
+		if err != nil {
+			return nil, events.NewEventErrorWithMessage(err, events.ErrorSeverityError, events.ErrorSubSystemDB, false, "unable to plan schema migration")
+		}
+		creates = append(creates, statements...)
+	}
+	sort.Strings(creates)
+	sum := sha256.Sum256([]byte(strings.Join(creates, ";\n")))
+	plan.Version = hex.EncodeToString(sum[:])[:16]
+
+	migrator := e.DB.Migrator()
+	if migrator.HasTable(&SchemaMigration{}) {
+		var last SchemaMigration
+		result := e.DB.Order("applied_at DESC").Limit(1).Find(&last)
+		if result.Error != nil {
+			return nil, events.NewEventErrorWithMessage(result.Error, events.ErrorSeverityError, events.ErrorSubSystemDB, false, "unable to read schema migrations")
+		}
+		plan.CurrentVersion = last.Version
+	} else {
+		tables = append(tables, schemaTable{name: SchemaMigration{}.TableName(), model: SchemaMigration{}})
+	}
+
+	for _, table := range tables {
+		statements, destructive, err := e.planTableMigration(table)
+		if err != nil {
+			return nil, events.NewEventErrorWithMessage(err, events.ErrorSeverityError, events.ErrorSubSystemDB, false, "unable to plan schema migration")
+		}
+		plan.Statements = append(plan.Statements, statements...)
+		plan.Destructive = append(plan.Destructive, destructive...)
+	}
+
+	record, err := e.dryRun(plan.record)
+	if err == nil && len(record) == 0 {
+		err = fmt.Errorf("no statement recording migration %s", plan.Version)
+	}
+	if err != nil {
+		return nil, events.NewEventErrorWithMessage(err, events.ErrorSeverityError, events.ErrorSubSystemDB, false, "unable to plan schema migration")
+	}
+	plan.Record = record[0]
+	return plan, nil
+}
+
+// planTableMigration returns the DDL creating the table, or adding the columns and indexes it is missing. The columns
+// whose type changed are altered and the columns the table no longer has are dropped, these statements are returned
+// as destructive as well
+func (e *SQLExporter) planTableMigration(table schemaTable) ([]string, []string, error) {
+	migrator := e.DB.Table(table.name).Migrator()
+	if !migrator.HasTable(table.name) {
+		statements, err := e.dryRun(func(tx *gorm.DB) error {
+			return tx.Table(table.name).Migrator().CreateTable(table.model)
+		})
+		return statements, nil, err
+	}
+
+	stmt := &gorm.Statement{DB: e.DB, Table: table.name}
+	if err := stmt.Parse(table.model); err != nil {
+		return nil, nil, err
+	}
+
+	columnTypes, err := migrator.ColumnTypes(table.model)
+	if err != nil {
+		return nil, nil, err
+	}
+	existing := map[string]gorm.ColumnType{}
+	for _, columnType := range columnTypes {
+		existing[strings.ToLower(columnType.Name())] = columnType
+	}
+
+	var added, changed []*gormschema.Field
+	for _, column := range stmt.Schema.DBNames {
+		field := stmt.Schema.FieldsByDBName[column]
+		columnType, ok := existing[strings.ToLower(column)]
+		switch {
+		case !ok:
+			added = append(added, field)
+		case e.columnTypeChanged(field, columnType):
+			changed = append(changed, field)
+		}
+		delete(existing, strings.ToLower(column))
+	}
+
+	var dropped []string
+	for _, columnType := range existing {
+		dropped = append(dropped, columnType.Name())
+	}
+	sort.Strings(dropped)
+
+	var indexes, rebuiltIndexes []string
+	for _, index := range stmt.Schema.ParseIndexes() {
+		switch {
+		case !migrator.HasIndex(table.model, index.Name):
+			indexes = append(indexes, index.Name)
+		case e.DB.Dialector.Name() == "sqlite" && indexHasField(index, changed):
+			// the columns are rebuilt on SQLite, their indexes are dropped and created again
+			rebuiltIndexes = append(rebuiltIndexes, index.Name)
+		}
+	}
+	indexes = append(indexes, rebuiltIndexes...)
+	sort.Strings(indexes)
+
+	additive, err := e.dryRun(func(tx *gorm.DB) error {
+		m := tx.Table(table.name).Migrator()
+		for _, field := range added {
+			if err := m.AddColumn(table.model, field.DBName); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	destructive, err := e.dryRun(func(tx *gorm.DB) error {
+		for _, index := range rebuiltIndexes {
+			if err := tx.Table(table.name).Migrator().DropIndex(table.model, index); err != nil {
+				return err
+			}
+		}
+		for _, field := range changed {
+			if err := e.alterColumn(tx, table, field); err != nil {
+				return err
+			}
+		}
+		for _, column := range dropped {
+			if err := tx.Exec("ALTER TABLE ? DROP COLUMN ?", clause.Table{Name: table.name}, clause.Column{Name: column}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	created, err := e.dryRun(func(tx *gorm.DB) error {
+		m := tx.Table(table.name).Migrator()
+		for _, index := range indexes {
+			if err := m.CreateIndex(table.model, index); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	statements := append(append(additive, destructive...), created...)
+	return statements, destructive, nil
+}
+
+// columnTypeChanged returns whether the type of the column in the database is not the type of the field, its size
+// included. Primary keys are left as they are, like the auto migrations do
+func (e *SQLExporter) columnTypeChanged(field *gormschema.Field, columnType gorm.ColumnType) bool {
+	if field.PrimaryKey {
+		return false
+	}
+
+	migrator := e.DB.Migrator()
+	dataType := strings.ToLower(e.DB.Dialector.DataTypeOf(field))
+	realType := strings.ToLower(columnType.DatabaseTypeName())
+	if !strings.HasPrefix(dataType, realType) {
+		sameType := false
+		for _, alias := range migrator.GetTypeAliases(realType) {
+			if strings.HasPrefix(dataType, alias) {
+				sameType = true
+				break
+			}
+		}
+		if !sameType {
+			return true
+		}
+	}
+
+	length, ok := columnType.Length()
+	return ok && length > 0 && field.Size > 0 && length != int64(field.Size)
+}
+
+// alterColumn changes the type of the column to the type of the field. SQLite can't alter a column, the column is
+// renamed and its values are copied to a new column before it is dropped
+func (e *SQLExporter) alterColumn(tx *gorm.DB, table schemaTable, field *gormschema.Field) error {
+	tableName, column := clause.Table{Name: table.name}, clause.Column{Name: field.DBName}
+	dataType := clause.Expr{SQL: tx.Dialector.DataTypeOf(field)}
+
+	switch tx.Dialector.Name() {
+	case "mysql":
+		return tx.Exec("ALTER TABLE ? MODIFY COLUMN ? ?", tableName, column, tx.Migrator().FullDataTypeOf(field)).Error
+	case "postgres":
+		return tx.Exec("ALTER TABLE ? ALTER COLUMN ? TYPE ? USING ?::?", tableName, column, dataType, column, dataType).Error
+	case "sqlserver":
+		return tx.Exec("ALTER TABLE ? ALTER COLUMN ? ? NULL", tableName, column, dataType).Error
+	default:
+		previous := clause.Column{Name: field.DBName + replacedTableSuffix}
+		if err := tx.Exec("ALTER TABLE ? RENAME COLUMN ? TO ?", tableName, column, previous).Error; err != nil {
+			return err
+		}
+		if err := tx.Table(table.name).Migrator().AddColumn(table.model, field.DBName); err != nil {
+			return err
+		}
+		if err := tx.Exec("UPDATE ? SET ? = ?", tableName, column, previous).Error; err != nil {
+			return err
+		}
+		return tx.Exec("ALTER TABLE ? DROP COLUMN ?", tableName, previous).Error
+	}
+}
+
+// indexHasField returns whether one of the fields is a field of the index
+func indexHasField(index gormschema.Index, fields []*gormschema.Field) bool {
+	for _, option := range index.Fields {
+		for _, field := range fields {
+			if option.Field.DBName == field.DBName {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (e *SQLExporter) applySchemaMigration(plan *SchemaMigrationPlan) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.Logger.With(
+		"schema_version", plan.Version,
+		"statements", len(plan.Statements),
+	).Info("applying schema migration")
+
+	apply := func(tx *gorm.DB) error {
+		for _, statement := range plan.Statements {
+			if err := tx.Exec(statement).Error; err != nil {
+				return fmt.Errorf("%s: %w", statement, err)
+			}
+		}
+		return plan.record(tx)
+	}
+
+	var err error
+	switch e.DB.Dialector.Name() {
+	case "mysql":
+		// MySQL commits DDL statements implicitly, they can't be grouped in a transaction
+		err = apply(e.DB)
+	default:
+		err = e.DB.Transaction(apply)
+	}
+	if err != nil {
+		return events.NewEventErrorWithMessage(err, events.ErrorSeverityError, events.ErrorSubSystemDB, true, "unable to apply schema migration")
+	}
+	return nil
+}
+
+// dryRun returns the statements fn would run against the database, without running them
+func (e *SQLExporter) dryRun(fn func(tx *gorm.DB) error) ([]string, error) {
+	recorder := &statementRecorder{}
+	err := fn(e.DB.Session(&gorm.Session{DryRun: true, Logger: recorder}))
+	return recorder.statements, err
+}
+
+// statementRecorder is a gorm logger recording the statements instead of logging them
+type statementRecorder struct {
+	statements []string
+}
+
+func (r *statementRecorder) LogMode(gormlogger.LogLevel) gormlogger.Interface {
+	return r
+}
+
+func (r *statementRecorder) Info(context.Context, string, ...interface{}) {}
+
+func (r *statementRecorder) Warn(context.Context, string, ...interface{}) {}
+
+func (r *statementRecorder) Error(context.Context, string, ...interface{}) {}
+
+func (r *statementRecorder) Trace(_ context.Context, _ time.Time, fc func() (string, int64), _ error) {
+	if statement, _ := fc(); statement != "" {
+		r.statements = append(r.statements, statement)
+	}
+}
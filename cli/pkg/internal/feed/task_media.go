@@ -0,0 +1,80 @@
+package feed
+
+import (
+	"context"
+	"path"
+	"strings"
+
+	"github.com/SafetyCulture/safetyculture-exporter/pkg/httpapi"
+	"github.com/SafetyCulture/safetyculture-exporter/pkg/logger"
+)
+
+// taskMedia is a media referenced by a row of the action and issue feeds
+type taskMedia struct {
+	// folder is the folder of the media below the media path, such as actions/<action_id>
+	folder string
+	url    string
+}
+
+// taskMediaFolder returns the folder of the media of an action or an issue, named after the feed and the task:
+// <media_path>/actions/<action_id> or <media_path>/issues/<issue_id>
+func taskMediaFolder(feedName string, taskID string) string {
+	return path.Join(feedName, taskID)
+}
+
+// splitMediaReferences returns the URLs of a media_hypertext_reference column, one per line
+func splitMediaReferences(mediaHypertextReference string) []string {
+	var urls []string
+	for _, mediaURL := range strings.Split(mediaHypertextReference, "\n") {
+		if mediaURL = strings.TrimSpace(mediaURL); mediaURL != "" {
+			urls = append(urls, mediaURL)
+		}
+	}
+	return urls
+}
+
+// taskMediaIDs returns the IDs of the media referenced by a row, one per line, unless the feed already returned them
+func taskMediaIDs(mediaIDs string, mediaHypertextReference string) string {
+	if mediaIDs != "" {
+		return mediaIDs
+	}
+
+	var ids []string
+	for _, mediaURL := range splitMediaReferences(mediaHypertextReference) {
+		ids = append(ids, mediaIDFromURL(mediaURL))
+	}
+	return strings.Join(ids, "\n")
+}
+
+// collectTaskMedia returns the media referenced by a row, downloaded to the folder of its task
+func collectTaskMedia(feedName string, taskID string, mediaHypertextReference string) []taskMedia {
+	var media []taskMedia
+	for _, mediaURL := range splitMediaReferences(mediaHypertextReference) {
+		media = append(media, taskMedia{folder: taskMediaFolder(feedName, taskID), url: mediaURL})
+	}
+	return media
+}
+
+// writeTaskMedia downloads the media of a batch of rows through the pool of the downloader, into the content-addressed
+// store when set or through the exporter
+func writeTaskMedia(ctx context.Context, downloader *MediaDownloader, apiClient *httpapi.Client, exporter Exporter, store MediaStore, media []taskMedia) {
+	batch := downloader.NewBatch(ctx)
+	for _, m := range media {
+		m := m
+		if !batch.Go(m.url, func(c context.Context) error {
+			return fetchTaskMedia(c, apiClient, exporter, store, m)
+		}) {
+			logger.GetLogger().Infof(" ... canceling media downloads ")
+			break
+		}
+	}
+	batch.Wait()
+}
+
+// fetchTaskMedia downloads a media of a task. The store records it under the folder of the task, in place of an audit
+func fetchTaskMedia(ctx context.Context, apiClient *httpapi.Client, exporter Exporter, store MediaStore, m taskMedia) error {
+	if store != nil {
+		return fetchAndStoreMedia(ctx, apiClient, store, m.folder, "", m.url)
+	}
+	return fetchAndWriteMedia(ctx, apiClient, exporter, m.folder, m.url)
+}
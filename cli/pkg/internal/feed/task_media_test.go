@@ -0,0 +1,34 @@
+package feed
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTaskMediaIDs(t *testing.T) {
+	tests := map[string]struct {
+		mediaIDs  string
+		reference string
+		expected  string
+	}{
+		"empty":    {expected: ""},
+		"returned": {mediaIDs: "abc", reference: "https://api/audits/audit_1/media/12345", expected: "abc"},
+		"single":   {reference: "https://api/audits/audit_1/media/12345", expected: "12345"},
+		"multiple": {reference: "https://api/media/12345\n\nhttps://api/media/67890\n", expected: "12345\n67890"},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, taskMediaIDs(tt.mediaIDs, tt.reference))
+		})
+	}
+}
+
+func TestCollectTaskMedia(t *testing.T) {
+	media := collectTaskMedia("actions", "action_1", "https://api/media/12345\nhttps://api/media/67890")
+	assert.Equal(t, []taskMedia{
+		{folder: "actions/action_1", url: "https://api/media/12345"},
+		{folder: "actions/action_1", url: "https://api/media/67890"},
+	}, media)
+}
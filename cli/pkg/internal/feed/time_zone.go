@@ -0,0 +1,343 @@
+package feed
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	gormschema "gorm.io/gorm/schema"
+)
+
+// utcColumnSuffix is appended to the name of a timestamp column to name the column keeping its UTC value
+const utcColumnSuffix = "_utc"
+
+var (
+	timeType    = reflect.TypeOf(time.Time{})
+	timePtrType = reflect.TypeOf(&time.Time{})
+)
+
+// timeZoneExporter converts the timestamps of the rows to a time zone before they are written.
+// When keepUTC is set the rows are extended with a <column>_utc column holding the UTC value of each timestamp.
+//
+// How the time zone ends up in the database depends on its column type:
+//   - SQLite, CSV and the other file exports write the timestamps with their offset
+//   - SQL Server keeps the offset in its datetimeoffset columns
+//   - MySQL datetime columns have no time zone and the driver writes every timestamp in UTC, the local date and time
+//     are written instead, see wallClock
+//   - PostgreSQL timestamptz columns only keep the instant, they are displayed in the TimeZone of the session reading
+//     them, which should be set to the same time zone
+type timeZoneExporter struct {
+	Exporter
+	location *time.Location
+	keepUTC  bool
+	// wallClock is set when the database has no time zone for its timestamps, the local date and time are written
+	// as if they were in UTC
+	wallClock bool
+
+	mu    sync.Mutex
+	feeds map[string]*timeZoneFeed
+}
+
+// newTimeZoneExporter wraps the exporter so that it writes timestamps in the named IANA time zone, target being the
+// exporter the rows end up in. The exporter is returned as is when the timestamps are written in UTC with no UTC copy
+func newTimeZoneExporter(exporter Exporter, target Exporter, timeZone string, keepUTC bool) (Exporter, error) {
+	if timeZone == "" {
+		timeZone = "UTC"
+	}
+	if timeZone == "UTC" && !keepUTC {
+		return exporter, nil
+	}
+
+	location, err := time.LoadLocation(timeZone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid time zone %q: %w", timeZone, err)
+	}
+
+	wallClock := false
+	if sqlExporter, ok := target.(*SQLExporter); ok {
+		wallClock = sqlExporter.DB.Dialector.Name() == "mysql"
+	}
+
+	return &timeZoneExporter{
+		Exporter:  exporter,
+		location:  location,
+		keepUTC:   keepUTC,
+		wallClock: wallClock,
+		feeds:     map[string]*timeZoneFeed{},
+	}, nil
+}
+
+// InitFeed initialises the feed with the columns of the converted rows
+func (e *timeZoneExporter) InitFeed(feed Feed, opts *InitFeedOptions) error {
+	return e.Exporter.InitFeed(e.feed(feed), opts)
+}
+
+// CreateSchema creates the schema of the converted rows
+func (e *timeZoneExporter) CreateSchema(feed Feed, rows interface{}) error {
+	f := e.feed(feed)
+	return e.Exporter.CreateSchema(f, f.convert(rows, e.localTime))
+}
+
+// WriteRows converts the timestamps of the rows before writing them
+func (e *timeZoneExporter) WriteRows(feed Feed, rows interface{}) error {
+	f := e.feed(feed)
+	return e.Exporter.WriteRows(f, f.convert(rows, e.localTime))
+}
+
+// UpdateRows converts the timestamps being updated
+func (e *timeZoneExporter) UpdateRows(feed Feed, primaryKeys []string, element map[string]interface{}) (int64, error) {
+	converted := make(map[string]interface{}, len(element))
+	for column, value := range element {
+		switch v := value.(type) {
+		case time.Time:
+			converted[column] = e.localTime(v)
+		case *time.Time:
+			if v != nil {
+				t := e.localTime(*v)
+				converted[column] = &t
+			} else {
+				converted[column] = v
+			}
+		default:
+			converted[column] = value
+		}
+	}
+	return e.Exporter.UpdateRows(feed, primaryKeys, converted)
+}
+
+// DeleteDeletedRows converts the time the rows were deleted at
+func (e *timeZoneExporter) DeleteDeletedRows(feed Feed, deletedAt time.Time, query string, args ...interface{}) error {
+	return e.Exporter.DeleteDeletedRows(feed, e.localTime(deletedAt), query, args...)
+}
+
+// FinaliseExport finalises the export of the converted rows
+func (e *timeZoneExporter) FinaliseExport(feed Feed, rows interface{}) error {
+	f := e.feed(feed)
+	return e.Exporter.FinaliseExport(f, f.convert(rows, e.localTime))
+}
+
+// LastModifiedAt returns the latest stored modified at date for the feed, as an instant
+func (e *timeZoneExporter) LastModifiedAt(feed Feed, modifiedAfter time.Time, orgID string) (time.Time, error) {
+	if !e.wallClock {
+		return e.Exporter.LastModifiedAt(feed, modifiedAfter, orgID)
+	}
+
+	t, err := e.Exporter.LastModifiedAt(feed, e.localTime(modifiedAfter), orgID)
+	return e.instant(t), err
+}
+
+// LastRecord returns the latest stored record the feed, as an instant
+func (e *timeZoneExporter) LastRecord(feed Feed, fallbackTime time.Time, orgID string, sortColumn string) time.Time {
+	if !e.wallClock {
+		return e.Exporter.LastRecord(feed, fallbackTime, orgID, sortColumn)
+	}
+
+	return e.instant(e.Exporter.LastRecord(feed, e.localTime(fallbackTime), orgID, sortColumn))
+}
+
+// localTime converts a timestamp to the time zone, zero timestamps are kept as is
+func (e *timeZoneExporter) localTime(t time.Time) time.Time {
+	if t.IsZero() {
+		return t
+	}
+
+	local := t.In(e.location)
+	if e.wallClock {
+		return time.Date(local.Year(), local.Month(), local.Day(), local.Hour(), local.Minute(), local.Second(), local.Nanosecond(), time.UTC)
+	}
+	return local
+}
+
+// instant returns the timestamp a local date and time read back from the database stand for
+func (e *timeZoneExporter) instant(t time.Time) time.Time {
+	if t.IsZero() || !e.wallClock {
+		return t
+	}
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), e.location)
+}
+
+func (e *timeZoneExporter) feed(feed Feed) *timeZoneFeed {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if f, ok := e.feeds[feed.Name()]; ok {
+		return f
+	}
+	f := newTimeZoneFeed(feed, e.keepUTC)
+	e.feeds[feed.Name()] = f
+	return f
+}
+
+// timeZoneFeed is a feed whose model holds the UTC copies of the timestamps, when they are kept
+type timeZoneFeed struct {
+	Feed
+	model   reflect.Type
+	columns []string
+	// fieldIndex maps the fields of the feed model to the fields of the converted model
+	fieldIndex []int
+	// utcIndex maps the timestamp fields of the feed model to the fields keeping their UTC value, -1 when there is none
+	utcIndex []int
+	// autoTime flags the timestamp fields set by the exporter when the row is written, such as exported_at
+	autoTime []bool
+}
+
+func newTimeZoneFeed(feed Feed, keepUTC bool) *timeZoneFeed {
+	modelType := reflect.TypeOf(feed.Model())
+	f := &timeZoneFeed{
+		Feed:       feed,
+		model:      modelType,
+		columns:    feed.Columns(),
+		fieldIndex: make([]int, modelType.NumField()),
+		utcIndex:   make([]int, modelType.NumField()),
+		autoTime:   make([]bool, modelType.NumField()),
+	}
+
+	upserted := map[string]bool{}
+	for _, column := range f.columns {
+		upserted[column] = true
+	}
+
+	var fields []reflect.StructField
+	for i := 0; i < modelType.NumField(); i++ {
+		field := modelType.Field(i)
+		f.fieldIndex[i] = len(fields)
+		f.utcIndex[i] = -1
+		fields = append(fields, field)
+
+		settings := gormschema.ParseTagSetting(field.Tag.Get("gorm"), ";")
+		_, autoCreate := settings["AUTOCREATETIME"]
+		_, autoUpdate := settings["AUTOUPDATETIME"]
+		f.autoTime[i] = autoCreate || autoUpdate
+
+		if !keepUTC || (field.Type != timeType && field.Type != timePtrType) {
+			continue
+		}
+
+		column := utcColumnName(field)
+		f.utcIndex[i] = len(fields)
+		// the UTC value is updated along with the column it keeps, the columns left out of the upsert such as
+		// deleted_at keep theirs
+		if upserted[columnName(field)] {
+			f.columns = append(f.columns, column)
+		}
+		fields = append(fields, reflect.StructField{
+			Name: field.Name + "UTC",
+			Type: field.Type,
+			Tag: reflect.StructTag(fmt.Sprintf(`json:"%s" csv:"%s" gorm:"column:%s"`,
+				tagName(field, "json")+utcColumnSuffix,
+				tagName(field, "csv")+utcColumnSuffix,
+				column,
+			)),
+		})
+	}
+
+	if len(fields) != modelType.NumField() {
+		f.model = reflect.StructOf(fields)
+	}
+	return f
+}
+
+// Model returns the model of the converted rows
+func (f *timeZoneFeed) Model() interface{} {
+	return reflect.New(f.model).Elem().Interface()
+}
+
+// RowsModel returns the model of the converted rows
+func (f *timeZoneFeed) RowsModel() interface{} {
+	return reflect.New(reflect.SliceOf(reflect.PointerTo(f.model))).Interface()
+}
+
+// Columns returns the columns of the converted rows
+func (f *timeZoneFeed) Columns() []string {
+	return f.columns
+}
+
+// convert returns a copy of the rows, a pointer to a slice of feed models, with the timestamps converted by localTime
+func (f *timeZoneFeed) convert(rows interface{}, localTime func(time.Time) time.Time) interface{} {
+	values := reflect.Indirect(reflect.ValueOf(rows))
+	if values.Kind() != reflect.Slice {
+		return rows
+	}
+
+	now := time.Now()
+	converted := reflect.MakeSlice(reflect.SliceOf(reflect.PointerTo(f.model)), 0, values.Len())
+	for i := 0; i < values.Len(); i++ {
+		row := reflect.Indirect(values.Index(i))
+		if !row.IsValid() || row.Type() != reflect.TypeOf(f.Feed.Model()) {
+			return rows
+		}
+
+		out := reflect.New(f.model).Elem()
+		for j := 0; j < row.NumField(); j++ {
+			value := row.Field(j)
+			target := out.Field(f.fieldIndex[j])
+
+			// set the timestamps the database would set, so that they are converted as well
+			if f.autoTime[j] && value.IsZero() {
+				if value.Type() == timeType {
+					value = reflect.ValueOf(now)
+				} else if value.Type() == timePtrType {
+					value = reflect.ValueOf(&now)
+				}
+			}
+
+			switch value.Type() {
+			case timeType:
+				t := value.Interface().(time.Time)
+				target.Set(reflect.ValueOf(localTime(t)))
+				if f.utcIndex[j] != -1 {
+					out.Field(f.utcIndex[j]).Set(reflect.ValueOf(inLocation(t, time.UTC)))
+				}
+			case timePtrType:
+				if value.IsNil() {
+					continue
+				}
+				t := *value.Interface().(*time.Time)
+				local, utc := localTime(t), inLocation(t, time.UTC)
+				target.Set(reflect.ValueOf(&local))
+				if f.utcIndex[j] != -1 {
+					out.Field(f.utcIndex[j]).Set(reflect.ValueOf(&utc))
+				}
+			default:
+				target.Set(value)
+			}
+		}
+		converted = reflect.Append(converted, out.Addr())
+	}
+
+	ptr := reflect.New(converted.Type())
+	ptr.Elem().Set(converted)
+	return ptr.Interface()
+}
+
+// inLocation converts a timestamp to the location, zero timestamps are kept as is
+func inLocation(t time.Time, location *time.Location) time.Time {
+	if t.IsZero() {
+		return t
+	}
+	return t.In(location)
+}
+
+// utcColumnName returns the name of the column keeping the UTC value of the timestamp field
+func utcColumnName(field reflect.StructField) string {
+	return columnName(field) + utcColumnSuffix
+}
+
+// columnName returns the name of the column of the field
+func columnName(field reflect.StructField) string {
+	column := gormschema.ParseTagSetting(field.Tag.Get("gorm"), ";")["COLUMN"]
+	if column == "" {
+		column = (gormschema.NamingStrategy{}).ColumnName("", field.Name)
+	}
+	return column
+}
+
+func tagName(field reflect.StructField, key string) string {
+	name := strings.Split(field.Tag.Get(key), ",")[0]
+	if name == "" || name == "-" {
+		return field.Name
+	}
+	return name
+}
@@ -0,0 +1,86 @@
+package feed
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// rowsRecorder is an exporter keeping the timestamps written to a column, the last one being its latest record
+type rowsRecorder struct {
+	Exporter
+	field      string
+	timestamps []time.Time
+}
+
+func (r *rowsRecorder) WriteRows(_ Feed, rows interface{}) error {
+	values := reflect.ValueOf(rows).Elem()
+	for i := 0; i < values.Len(); i++ {
+		t := reflect.Indirect(values.Index(i)).FieldByName(r.field).Interface().(*time.Time)
+		r.timestamps = append(r.timestamps, *t)
+	}
+	return nil
+}
+
+func (r *rowsRecorder) LastRecord(_ Feed, fallbackTime time.Time, _ string, _ string) time.Time {
+	if len(r.timestamps) == 0 {
+		return fallbackTime
+	}
+	return r.timestamps[len(r.timestamps)-1]
+}
+
+// offlineSQLExporter returns an exporter of the dialect that never connects to its database
+func offlineSQLExporter(t *testing.T, dialector gorm.Dialector) *SQLExporter {
+	db, err := gorm.Open(dialector, &gorm.Config{DisableAutomaticPing: true})
+	require.NoError(t, err)
+	return &SQLExporter{DB: db}
+}
+
+func TestTimeZoneExporter_should_write_timestamps_per_dialect(t *testing.T) {
+	lastSeenAt := time.Date(2020, 10, 29, 12, 28, 42, 0, time.UTC)
+
+	tests := map[string]struct {
+		target   Exporter
+		expected string
+	}{
+		"file": {
+			target:   &rowsRecorder{},
+			expected: "2020-10-29T23:28:42+11:00",
+		},
+		"mysql": {
+			target: offlineSQLExporter(t, mysql.New(mysql.Config{
+				DSN:                       "user:password@tcp(127.0.0.1:1)/db?parseTime=true",
+				SkipInitializeWithVersion: true,
+			})),
+			// datetime columns have no time zone, the local date and time are written
+			expected: "2020-10-29T23:28:42Z",
+		},
+		"postgres": {
+			target:   offlineSQLExporter(t, postgres.New(postgres.Config{DSN: "host=127.0.0.1 port=1"})),
+			expected: "2020-10-29T23:28:42+11:00",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			recorder := &rowsRecorder{field: "LastSeenAt"}
+			exporter, err := newTimeZoneExporter(recorder, tt.target, "Australia/Sydney", true)
+			require.NoError(t, err)
+
+			userFeed := &UserFeed{}
+			require.NoError(t, exporter.WriteRows(userFeed, &[]*User{{ID: "user_1", LastSeenAt: &lastSeenAt}}))
+
+			require.Len(t, recorder.timestamps, 1)
+			assert.Equal(t, tt.expected, recorder.timestamps[0].Format(time.RFC3339))
+
+			// the timestamps read back stand for the same instant
+			assert.True(t, lastSeenAt.Equal(exporter.LastRecord(userFeed, time.Time{}, "", "last_seen_at")))
+		})
+	}
+}
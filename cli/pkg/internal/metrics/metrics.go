@@ -0,0 +1,154 @@
+package metrics
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "safetyculture_exporter"
+
+var (
+	registry = prometheus.NewRegistry()
+
+	rowsExported = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rows_exported_total",
+		Help:      "Number of rows exported per feed.",
+	}, []string{"feed"})
+
+	apiRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "api_request_duration_seconds",
+		Help:      "Latency of the API requests.",
+		Buckets:   prometheus.ExponentialBuckets(0.05, 2, 12),
+	}, []string{"endpoint", "code"})
+
+	apiRequestRetries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "api_request_retries_total",
+		Help:      "Number of API requests retried.",
+	}, []string{"endpoint"})
+
+	dbWriteDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "db_write_duration_seconds",
+		Help:      "Duration of the batches written to the database per feed.",
+		Buckets:   prometheus.ExponentialBuckets(0.001, 2, 15),
+	}, []string{"feed"})
+
+	mediaDownloaded = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "media_downloaded_total",
+		Help:      "Number of media files downloaded.",
+	})
+
+	reports = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "reports_total",
+		Help:      "Number of inspection reports generated per format and result.",
+	}, []string{"format", "result"})
+
+	lastSuccessfulRun = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "last_successful_run_timestamp_seconds",
+		Help:      "Unix timestamp of the last export that completed without errors.",
+	})
+)
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		rowsExported,
+		apiRequestDuration,
+		apiRequestRetries,
+		dbWriteDuration,
+		mediaDownloaded,
+		reports,
+		lastSuccessfulRun,
+	)
+}
+
+// Handler returns the HTTP handler serving the metrics
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
+
+// AddRowsExported counts the rows exported for a feed
+func AddRowsExported(feed string, rows int) {
+	rowsExported.WithLabelValues(feed).Add(float64(rows))
+}
+
+// ObserveAPIRequest records the latency of an API request, statusCode is 0 when no response was received
+func ObserveAPIRequest(rawURL string, statusCode int, duration time.Duration) {
+	code := "error"
+	if statusCode != 0 {
+		code = strconv.Itoa(statusCode)
+	}
+	apiRequestDuration.WithLabelValues(endpoint(rawURL), code).Observe(duration.Seconds())
+}
+
+// IncAPIRetry counts an API request being retried
+func IncAPIRetry(rawURL string) {
+	apiRequestRetries.WithLabelValues(endpoint(rawURL)).Inc()
+}
+
+// ObserveDBWrite records the duration of a batch written to the database
+func ObserveDBWrite(feed string, duration time.Duration) {
+	dbWriteDuration.WithLabelValues(feed).Observe(duration.Seconds())
+}
+
+// IncMediaDownloaded counts a media file downloaded
+func IncMediaDownloaded() {
+	mediaDownloaded.Inc()
+}
+
+// ObserveReport counts a report generated, or failed to generate when err is not nil
+func ObserveReport(format string, err error) {
+	result := "success"
+	if err != nil {
+		result = "failure"
+	}
+	reports.WithLabelValues(format, result).Inc()
+}
+
+// SetLastSuccessfulRun records the time of the last export completed without errors
+func SetLastSuccessfulRun(t time.Time) {
+	lastSuccessfulRun.Set(float64(t.Unix()))
+}
+
+// endpoint returns the first segments of the URL path, with the segments holding IDs replaced,
+// to keep the number of label values bounded
+func endpoint(rawURL string) string {
+	path := rawURL
+	if u, err := url.Parse(rawURL); err == nil {
+		path = u.Path
+	}
+
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	if len(segments) > 3 {
+		segments = segments[:3]
+	}
+	for i, s := range segments {
+		if strings.IndexFunc(s, unicode.IsDigit) != -1 && !isVersion(s) {
+			segments[i] = ":id"
+		}
+	}
+	return "/" + strings.Join(segments, "/")
+}
+
+// isVersion reports whether a path segment is an API version such as v1
+func isVersion(s string) bool {
+	if len(s) < 2 || s[0] != 'v' {
+		return false
+	}
+	return strings.IndexFunc(s[1:], func(r rune) bool { return !unicode.IsDigit(r) }) == -1
+}
@@ -0,0 +1,60 @@
+package metrics
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEndpoint(t *testing.T) {
+	tests := map[string]string{
+		"/feed/users":                                "/feed/users",
+		"/feed/users?next_page_token=abc123":         "/feed/users",
+		"http://localhost:9999/feed/inspections":     "/feed/inspections",
+		"accounts/user/v1/user:WhoAmI":               "/accounts/user/v1",
+		"/audits/audit_123/media/media_456":          "/audits/:id/media",
+		"/inspections/v1/inspections/audit_1/report": "/inspections/v1/inspections",
+		"/SheqsyIntegrationApi/api/v3/companies/42":  "/SheqsyIntegrationApi/api/v3",
+	}
+
+	for url, expected := range tests {
+		t.Run(url, func(t *testing.T) {
+			assert.Equal(t, expected, endpoint(url))
+		})
+	}
+}
+
+func TestHandler_should_serve_recorded_metrics(t *testing.T) {
+	AddRowsExported("users", 4)
+	ObserveAPIRequest("/feed/users", http.StatusOK, 120*time.Millisecond)
+	IncAPIRetry("/feed/users")
+	ObserveDBWrite("users", 5*time.Millisecond)
+	IncMediaDownloaded()
+	ObserveReport("PDF", nil)
+	ObserveReport("WORD", fmt.Errorf("failed"))
+	SetLastSuccessfulRun(time.Unix(1700000000, 0))
+
+	rec := httptest.NewRecorder()
+	Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	body := rec.Body.String()
+	for _, expected := range []string{
+		`safetyculture_exporter_rows_exported_total{feed="users"} 4`,
+		`safetyculture_exporter_api_request_duration_seconds_count{code="200",endpoint="/feed/users"} 1`,
+		`safetyculture_exporter_api_request_retries_total{endpoint="/feed/users"} 1`,
+		`safetyculture_exporter_db_write_duration_seconds_count{feed="users"} 1`,
+		`safetyculture_exporter_media_downloaded_total 1`,
+		`safetyculture_exporter_reports_total{format="PDF",result="success"} 1`,
+		`safetyculture_exporter_reports_total{format="WORD",result="failure"} 1`,
+		`safetyculture_exporter_last_successful_run_timestamp_seconds 1.7e+09`,
+	} {
+		assert.True(t, strings.Contains(body, expected), "missing %s", expected)
+	}
+}
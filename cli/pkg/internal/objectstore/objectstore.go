@@ -0,0 +1,308 @@
+// Package objectstore copies the exported files to an S3-compatible object storage
+package objectstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// defaultRegion is used when no region is configured, S3-compatible storages such as MinIO accept any region
+const defaultRegion = "us-east-1"
+
+// partSize is the size of the parts of the multipart uploads, smaller files are uploaded in a single request
+const partSize = 16 * 1024 * 1024
+
+// Bucket is a bucket of an S3-compatible object storage, the objects are written under a prefix
+type Bucket struct {
+	client   *s3.Client
+	uploader *manager.Uploader
+	name     string
+	prefix   string
+}
+
+// Open opens the bucket of a URL such as s3://bucket/prefix. The credentials and the region are read from the
+// standard AWS environment variables and shared configuration files. The query parameters are:
+//   - endpoint: the URL of an S3-compatible storage, such as http://localhost:9000 for a local MinIO. The bucket is
+//     addressed in the path of the requests
+//   - region: the region of the bucket
+//   - path_style: true to address the bucket in the path of the requests
+func Open(ctx context.Context, rawURL string) (*Bucket, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("parse object storage URL: %w", err)
+	}
+	if u.Scheme != "s3" || u.Host == "" {
+		return nil, fmt.Errorf("invalid object storage URL %q, expected s3://bucket/prefix", rawURL)
+	}
+
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("load object storage credentials: %w", err)
+	}
+
+	query := u.Query()
+	if region := query.Get("region"); region != "" {
+		cfg.Region = region
+	}
+	if cfg.Region == "" {
+		cfg.Region = defaultRegion
+	}
+
+	endpoint := query.Get("endpoint")
+	client := s3.NewFromConfig(cfg, func(o *s3.Options) {
+		if endpoint != "" {
+			o.BaseEndpoint = aws.String(endpoint)
+		}
+		o.UsePathStyle = endpoint != "" || query.Get("path_style") == "true"
+	})
+
+	return &Bucket{
+		client: client,
+		uploader: manager.NewUploader(client, func(u *manager.Uploader) {
+			u.PartSize = partSize
+		}),
+		name:   u.Host,
+		prefix: strings.Trim(u.Path, "/"),
+	}, nil
+}
+
+// Name returns the name of the bucket
+func (b *Bucket) Name() string {
+	return b.name
+}
+
+// key returns the key of an object, below the prefix of the bucket
+func (b *Bucket) key(name string) string {
+	return path.Join(b.prefix, name)
+}
+
+// Upload uploads a local file, in parts when it is large. The parts are checked with their SHA-256 checksum
+func (b *Bucket) Upload(ctx context.Context, localPath string, name string) error {
+	file, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = b.uploader.Upload(ctx, &s3.PutObjectInput{
+		Bucket:            aws.String(b.name),
+		Key:               aws.String(b.key(name)),
+		Body:              file,
+		ChecksumAlgorithm: types.ChecksumAlgorithmSha256,
+	})
+	if err != nil {
+		return fmt.Errorf("upload %s to bucket %s: %w", name, b.name, err)
+	}
+	return nil
+}
+
+// Exists returns whether an object exists
+func (b *Bucket) Exists(ctx context.Context, name string) (bool, error) {
+	_, err := b.client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(b.name),
+		Key:    aws.String(b.key(name)),
+	})
+
+	var notFound *types.NotFound
+	switch {
+	case errors.As(err, &notFound):
+		return false, nil
+	case err != nil:
+		return false, fmt.Errorf("read %s from bucket %s: %w", name, b.name, err)
+	}
+	return true, nil
+}
+
+// List returns the names of the objects starting with a prefix, sorted
+func (b *Bucket) List(ctx context.Context, prefix string) ([]string, error) {
+	var names []string
+	keyPrefix := prefix
+	if b.prefix != "" {
+		keyPrefix = b.prefix + "/" + prefix
+	}
+
+	paginator := s3.NewListObjectsV2Paginator(b.client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(b.name),
+		Prefix: aws.String(keyPrefix),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("list %s in bucket %s: %w", prefix, b.name, err)
+		}
+		for _, object := range page.Contents {
+			names = append(names, strings.TrimPrefix(aws.ToString(object.Key), b.prefix+"/"))
+		}
+	}
+
+	sort.Strings(names)
+	return names, nil
+}
+
+// Delete deletes an object
+func (b *Bucket) Delete(ctx context.Context, name string) error {
+	_, err := b.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(b.name),
+		Key:    aws.String(b.key(name)),
+	})
+	if err != nil {
+		return fmt.Errorf("delete %s from bucket %s: %w", name, b.name, err)
+	}
+	return nil
+}
+
+// Mirror copies the files of local directories to a bucket, each file is written under its path relative to the
+// directory. A nil Mirror copies nothing, so that the exporters can use it whether or not a bucket is configured
+type Mirror struct {
+	bucket *Bucket
+	mu     sync.Mutex
+	roots  []mirrorRoot
+}
+
+type mirrorRoot struct {
+	dir    string
+	prefix string
+}
+
+// NewMirror creates a mirror of local directories into the bucket
+func NewMirror(bucket *Bucket) *Mirror {
+	return &Mirror{bucket: bucket}
+}
+
+// AddDir mirrors the files of a local directory under a prefix of the bucket. The files are mirrored under the most
+// specific directory they belong to
+func (m *Mirror) AddDir(dir string, prefix string) {
+	if m == nil {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		abs = filepath.Clean(dir)
+	}
+	m.roots = append(m.roots, mirrorRoot{dir: abs, prefix: strings.Trim(prefix, "/")})
+	sort.SliceStable(m.roots, func(i, j int) bool {
+		return len(m.roots[i].dir) > len(m.roots[j].dir)
+	})
+}
+
+// name returns the name of the object of a local file
+func (m *Mirror) name(localPath string) (string, error) {
+	abs, err := filepath.Abs(localPath)
+	if err != nil {
+		return "", err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, root := range m.roots {
+		rel, err := filepath.Rel(root.dir, abs)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		if rel == "." {
+			return root.prefix, nil
+		}
+		return path.Join(root.prefix, filepath.ToSlash(rel)), nil
+	}
+	return "", fmt.Errorf("%s is outside of the mirrored directories", localPath)
+}
+
+// Upload copies a local file to the bucket
+func (m *Mirror) Upload(ctx context.Context, localPath string) error {
+	if m == nil {
+		return nil
+	}
+
+	name, err := m.name(localPath)
+	if err != nil {
+		return err
+	}
+	return m.bucket.Upload(ctx, localPath, name)
+}
+
+// Exists returns whether a local file was copied to the bucket
+func (m *Mirror) Exists(ctx context.Context, localPath string) (bool, error) {
+	if m == nil {
+		return false, nil
+	}
+
+	name, err := m.name(localPath)
+	if err != nil {
+		return false, err
+	}
+	return m.bucket.Exists(ctx, name)
+}
+
+// Sync copies the local files of a directory matching the patterns, such as users*.csv, and deletes the objects of
+// the directory matching the patterns that no longer exist locally
+func (m *Mirror) Sync(ctx context.Context, dir string, patterns ...string) error {
+	if m == nil {
+		return nil
+	}
+
+	dirName, err := m.name(dir)
+	if err != nil {
+		return err
+	}
+	if dirName != "" {
+		dirName += "/"
+	}
+
+	uploaded := map[string]bool{}
+	for _, pattern := range patterns {
+		files, err := filepath.Glob(filepath.Join(dir, pattern))
+		if err != nil {
+			return err
+		}
+
+		for _, file := range files {
+			if err := m.Upload(ctx, file); err != nil {
+				return err
+			}
+			uploaded[dirName+filepath.Base(file)] = true
+		}
+	}
+
+	objects, err := m.bucket.List(ctx, dirName)
+	if err != nil {
+		return err
+	}
+	for _, object := range objects {
+		base := strings.TrimPrefix(object, dirName)
+		if strings.Contains(base, "/") || uploaded[object] || !matchAny(patterns, base) {
+			continue
+		}
+		if err := m.bucket.Delete(ctx, object); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func matchAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
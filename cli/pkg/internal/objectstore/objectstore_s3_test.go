@@ -0,0 +1,70 @@
+//go:build s3
+// +build s3
+
+package objectstore
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// getTestingBucket opens the bucket of TEST_OBJECT_STORAGE_URL, such as a local MinIO, and creates it if needed
+func getTestingBucket(t *testing.T) *Bucket {
+	bucket, err := Open(context.Background(), os.Getenv("TEST_OBJECT_STORAGE_URL"))
+	require.NoError(t, err)
+
+	_, err = bucket.client.CreateBucket(context.Background(), &s3.CreateBucketInput{Bucket: aws.String(bucket.name)})
+	var owned *types.BucketAlreadyOwnedByYou
+	if err != nil && !errors.As(err, &owned) {
+		require.NoError(t, err)
+	}
+	return bucket
+}
+
+func TestIntegrationBucket_Upload_Multipart(t *testing.T) {
+	bucket := getTestingBucket(t)
+	bucket.uploader.PartSize = manager.MinUploadPartSize
+
+	content := make([]byte, manager.MinUploadPartSize*2+1)
+	file := filepath.Join(t.TempDir(), "inspection_items.csv")
+	require.NoError(t, os.WriteFile(file, content, 0666))
+
+	require.NoError(t, bucket.Upload(context.Background(), file, "inspection_items.csv"))
+	defer bucket.Delete(context.Background(), "inspection_items.csv")
+
+	exists, err := bucket.Exists(context.Background(), "inspection_items.csv")
+	require.NoError(t, err)
+	assert.True(t, exists)
+}
+
+func TestIntegrationMirror_Sync(t *testing.T) {
+	bucket := getTestingBucket(t)
+	exportDir := t.TempDir()
+	mirror := NewMirror(bucket)
+	mirror.AddDir(exportDir, "mirror")
+
+	for _, name := range []string{"users.csv", "users-2.csv"} {
+		require.NoError(t, os.WriteFile(filepath.Join(exportDir, name), []byte(name), 0666))
+	}
+	require.NoError(t, mirror.Sync(context.Background(), exportDir, "users.csv", "users-*.csv"))
+
+	require.NoError(t, os.Remove(filepath.Join(exportDir, "users-2.csv")))
+	require.NoError(t, mirror.Sync(context.Background(), exportDir, "users.csv", "users-*.csv"))
+
+	names, err := bucket.List(context.Background(), "mirror/")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"mirror/users.csv"}, names)
+
+	require.NoError(t, os.Remove(filepath.Join(exportDir, "users.csv")))
+	require.NoError(t, mirror.Sync(context.Background(), exportDir, "users.csv", "users-*.csv"))
+}
@@ -0,0 +1,130 @@
+package objectstore
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/SafetyCulture/safetyculture-exporter/pkg/internal/objectstore/objectstoretest"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setCredentials(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "test")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "test")
+	t.Setenv("AWS_REGION", "")
+}
+
+func TestOpen_InvalidURL(t *testing.T) {
+	setCredentials(t)
+
+	for _, rawURL := range []string{"bucket/prefix", "file:///tmp", "s3:///prefix"} {
+		_, err := Open(context.Background(), rawURL)
+		assert.Error(t, err, rawURL)
+	}
+}
+
+func TestBucket_Upload(t *testing.T) {
+	setCredentials(t)
+	server := objectstoretest.NewServer()
+	defer server.Close()
+
+	bucket, err := Open(context.Background(), server.BucketURL("exports", "sc/data"))
+	require.NoError(t, err)
+
+	file := filepath.Join(t.TempDir(), "users.csv")
+	require.NoError(t, os.WriteFile(file, []byte("user_id\n1\n"), 0666))
+
+	require.NoError(t, bucket.Upload(context.Background(), file, "users.csv"))
+	body, ok := server.Object("exports", "sc/data/users.csv")
+	assert.True(t, ok)
+	assert.Equal(t, "user_id\n1\n", string(body))
+
+	exists, err := bucket.Exists(context.Background(), "users.csv")
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	exists, err = bucket.Exists(context.Background(), "sites.csv")
+	require.NoError(t, err)
+	assert.False(t, exists)
+}
+
+func TestBucket_Upload_Multipart(t *testing.T) {
+	setCredentials(t)
+	server := objectstoretest.NewServer()
+	defer server.Close()
+
+	bucket, err := Open(context.Background(), server.BucketURL("exports", ""))
+	require.NoError(t, err)
+	bucket.uploader.PartSize = manager.MinUploadPartSize
+
+	content := bytes.Repeat([]byte("0123456789"), int(manager.MinUploadPartSize)/10*2+1)
+	file := filepath.Join(t.TempDir(), "inspection_items.csv")
+	require.NoError(t, os.WriteFile(file, content, 0666))
+
+	require.NoError(t, bucket.Upload(context.Background(), file, "inspection_items.csv"))
+	assert.Equal(t, 3, server.Parts)
+
+	body, ok := server.Object("exports", "inspection_items.csv")
+	assert.True(t, ok)
+	assert.Equal(t, content, body)
+}
+
+func TestMirror_Sync(t *testing.T) {
+	setCredentials(t)
+	server := objectstoretest.NewServer()
+	defer server.Close()
+
+	bucket, err := Open(context.Background(), server.BucketURL("exports", "sc"))
+	require.NoError(t, err)
+
+	exportDir := t.TempDir()
+	mediaDir := filepath.Join(exportDir, "media")
+	mirror := NewMirror(bucket)
+	mirror.AddDir(exportDir, "")
+	mirror.AddDir(mediaDir, "media")
+
+	write := func(name string) string {
+		file := filepath.Join(exportDir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(file), os.ModePerm))
+		require.NoError(t, os.WriteFile(file, []byte(name), 0666))
+		return file
+	}
+
+	write("users.csv")
+	write("users-2.csv")
+	write("sites.csv")
+	require.NoError(t, mirror.Sync(context.Background(), exportDir, "users*.csv"))
+	assert.Equal(t, []string{"sc/users-2.csv", "sc/users.csv"}, server.Keys("exports"))
+
+	// the rollover file no longer exists locally
+	require.NoError(t, os.Remove(filepath.Join(exportDir, "users-2.csv")))
+	require.NoError(t, mirror.Sync(context.Background(), exportDir, "users*.csv"))
+	require.NoError(t, mirror.Sync(context.Background(), exportDir, "sites*.csv"))
+	assert.Equal(t, []string{"sc/sites.csv", "sc/users.csv"}, server.Keys("exports"))
+
+	media := write("media/audit_1/media_1.jpeg")
+	require.NoError(t, mirror.Upload(context.Background(), media))
+	exists, err := mirror.Exists(context.Background(), media)
+	require.NoError(t, err)
+	assert.True(t, exists)
+	assert.Contains(t, server.Keys("exports"), "sc/media/audit_1/media_1.jpeg")
+
+	assert.Error(t, mirror.Upload(context.Background(), filepath.Join(t.TempDir(), "outside.csv")))
+}
+
+func TestMirror_Nil(t *testing.T) {
+	var mirror *Mirror
+	mirror.AddDir("export", "")
+
+	assert.NoError(t, mirror.Upload(context.Background(), "export/users.csv"))
+	assert.NoError(t, mirror.Sync(context.Background(), "export", "users*.csv"))
+
+	exists, err := mirror.Exists(context.Background(), "export/users.csv")
+	assert.NoError(t, err)
+	assert.False(t, exists)
+}
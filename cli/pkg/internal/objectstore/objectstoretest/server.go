@@ -0,0 +1,336 @@
+// Package objectstoretest provides an in-memory S3-compatible object storage for tests
+package objectstoretest
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Server is an S3-compatible object storage keeping the objects in memory. The buckets are addressed in the path of
+// the requests and are created on first use. The SHA-256 checksums sent with the objects and their parts are verified
+type Server struct {
+	*httptest.Server
+
+	mu      sync.Mutex
+	objects map[string][]byte
+	uploads map[string]map[int][]byte
+	// Parts counts the parts uploaded through multipart uploads
+	Parts int
+}
+
+// NewServer starts an object storage, it is closed with Close
+func NewServer() *Server {
+	s := &Server{
+		objects: map[string][]byte{},
+		uploads: map[string]map[int][]byte{},
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+// BucketURL returns the URL of a bucket prefix, such as s3://bucket/prefix?endpoint=http://127.0.0.1:1234
+func (s *Server) BucketURL(bucket string, prefix string) string {
+	return fmt.Sprintf("s3://%s/%s?endpoint=%s", bucket, prefix, s.Server.URL)
+}
+
+// Object returns the content of an object
+func (s *Server) Object(bucket string, key string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	body, ok := s.objects[bucket+"/"+key]
+	return body, ok
+}
+
+// Keys returns the keys of the objects of a bucket, sorted
+func (s *Server) Keys(bucket string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var keys []string
+	for key := range s.objects {
+		if name, ok := strings.CutPrefix(key, bucket+"/"); ok {
+			keys = append(keys, name)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	query := r.URL.Query()
+
+	switch {
+	case r.Method == http.MethodGet && key == "":
+		s.list(w, bucket, query.Get("prefix"))
+	case r.Method == http.MethodPost && query.Has("uploads"):
+		s.createUpload(w, bucket, key)
+	case r.Method == http.MethodPut && query.Has("uploadId"):
+		s.uploadPart(w, r, query.Get("uploadId"), query.Get("partNumber"))
+	case r.Method == http.MethodPost && query.Has("uploadId"):
+		s.completeUpload(w, bucket, key, query.Get("uploadId"))
+	case r.Method == http.MethodPut:
+		s.put(w, r, bucket, key)
+	case r.Method == http.MethodHead:
+		s.head(w, bucket, key)
+	case r.Method == http.MethodGet:
+		s.get(w, bucket, key)
+	case r.Method == http.MethodDelete:
+		s.mu.Lock()
+		delete(s.objects, bucket+"/"+key)
+		s.mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(w, http.StatusNotImplemented, "NotImplemented")
+	}
+}
+
+func (s *Server) put(w http.ResponseWriter, r *http.Request, bucket string, key string) {
+	body, ok := readBody(w, r)
+	if !ok {
+		return
+	}
+
+	s.mu.Lock()
+	s.objects[bucket+"/"+key] = body
+	s.mu.Unlock()
+
+	w.Header().Set("ETag", etag(body))
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *Server) head(w http.ResponseWriter, bucket string, key string) {
+	body, ok := s.Object(bucket, key)
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.Header().Set("ETag", etag(body))
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *Server) get(w http.ResponseWriter, bucket string, key string) {
+	body, ok := s.Object(bucket, key)
+	if !ok {
+		writeError(w, http.StatusNotFound, "NoSuchKey")
+		return
+	}
+	w.Header().Set("ETag", etag(body))
+	_, _ = w.Write(body)
+}
+
+type listBucketResult struct {
+	XMLName     xml.Name `xml:"ListBucketResult"`
+	Name        string   `xml:"Name"`
+	Prefix      string   `xml:"Prefix"`
+	KeyCount    int      `xml:"KeyCount"`
+	IsTruncated bool     `xml:"IsTruncated"`
+	Contents    []struct {
+		Key  string `xml:"Key"`
+		Size int    `xml:"Size"`
+	} `xml:"Contents"`
+}
+
+func (s *Server) list(w http.ResponseWriter, bucket string, prefix string) {
+	res := listBucketResult{Name: bucket, Prefix: prefix}
+	for _, key := range s.Keys(bucket) {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		body, _ := s.Object(bucket, key)
+		res.Contents = append(res.Contents, struct {
+			Key  string `xml:"Key"`
+			Size int    `xml:"Size"`
+		}{Key: key, Size: len(body)})
+	}
+	res.KeyCount = len(res.Contents)
+	writeXML(w, res)
+}
+
+type initiateMultipartUploadResult struct {
+	XMLName  xml.Name `xml:"InitiateMultipartUploadResult"`
+	Bucket   string   `xml:"Bucket"`
+	Key      string   `xml:"Key"`
+	UploadID string   `xml:"UploadId"`
+}
+
+func (s *Server) createUpload(w http.ResponseWriter, bucket string, key string) {
+	s.mu.Lock()
+	uploadID := fmt.Sprintf("upload-%d", len(s.uploads)+1)
+	s.uploads[uploadID] = map[int][]byte{}
+	s.mu.Unlock()
+
+	writeXML(w, initiateMultipartUploadResult{Bucket: bucket, Key: key, UploadID: uploadID})
+}
+
+func (s *Server) uploadPart(w http.ResponseWriter, r *http.Request, uploadID string, partNumber string) {
+	number, err := strconv.Atoi(partNumber)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "InvalidArgument")
+		return
+	}
+
+	body, ok := readBody(w, r)
+	if !ok {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	parts, ok := s.uploads[uploadID]
+	if !ok {
+		writeError(w, http.StatusNotFound, "NoSuchUpload")
+		return
+	}
+	parts[number] = body
+	s.Parts++
+
+	w.Header().Set("ETag", etag(body))
+	w.WriteHeader(http.StatusOK)
+}
+
+type completeMultipartUploadResult struct {
+	XMLName xml.Name `xml:"CompleteMultipartUploadResult"`
+	Bucket  string   `xml:"Bucket"`
+	Key     string   `xml:"Key"`
+	ETag    string   `xml:"ETag"`
+}
+
+func (s *Server) completeUpload(w http.ResponseWriter, bucket string, key string, uploadID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	parts, ok := s.uploads[uploadID]
+	if !ok {
+		writeError(w, http.StatusNotFound, "NoSuchUpload")
+		return
+	}
+
+	var numbers []int
+	for number := range parts {
+		numbers = append(numbers, number)
+	}
+	sort.Ints(numbers)
+
+	var body []byte
+	for _, number := range numbers {
+		body = append(body, parts[number]...)
+	}
+	s.objects[bucket+"/"+key] = body
+	delete(s.uploads, uploadID)
+
+	writeXML(w, completeMultipartUploadResult{Bucket: bucket, Key: key, ETag: etag(body)})
+}
+
+// readBody reads the body of a request, decoding the aws-chunked encoding, and verifies its SHA-256 checksum when
+// sent in a header or a trailer
+func readBody(w http.ResponseWriter, r *http.Request) ([]byte, bool) {
+	raw, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "IncompleteBody")
+		return nil, false
+	}
+
+	body := raw
+	checksum := r.Header.Get("X-Amz-Checksum-Sha256")
+	if strings.Contains(r.Header.Get("Content-Encoding"), "aws-chunked") || r.Header.Get("X-Amz-Decoded-Content-Length") != "" {
+		var trailers map[string]string
+		body, trailers, err = decodeChunked(raw)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "IncompleteBody")
+			return nil, false
+		}
+		if trailer, ok := trailers["x-amz-checksum-sha256"]; ok {
+			checksum = trailer
+		}
+	}
+
+	if checksum != "" {
+		sum := sha256.Sum256(body)
+		if base64.StdEncoding.EncodeToString(sum[:]) != checksum {
+			writeError(w, http.StatusBadRequest, "BadDigest")
+			return nil, false
+		}
+	}
+	return body, true
+}
+
+// decodeChunked decodes a body sent with the aws-chunked content encoding
+func decodeChunked(raw []byte) ([]byte, map[string]string, error) {
+	reader := bufio.NewReader(bytes.NewReader(raw))
+	var body []byte
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return nil, nil, err
+		}
+		sizeHex, _, _ := strings.Cut(strings.TrimSpace(line), ";")
+		size, err := strconv.ParseInt(sizeHex, 16, 64)
+		if err != nil {
+			return nil, nil, err
+		}
+		if size == 0 {
+			break
+		}
+
+		chunk := make([]byte, size)
+		if _, err := io.ReadFull(reader, chunk); err != nil {
+			return nil, nil, err
+		}
+		body = append(body, chunk...)
+		if _, err := reader.ReadString('\n'); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	trailers := map[string]string{}
+	for {
+		line, err := reader.ReadString('\n')
+		line = strings.TrimSpace(line)
+		if name, value, ok := strings.Cut(line, ":"); ok {
+			trailers[strings.ToLower(name)] = value
+		}
+		if err != nil {
+			break
+		}
+	}
+	return body, trailers, nil
+}
+
+func etag(body []byte) string {
+	sum := md5.Sum(body)
+	return `"` + hex.EncodeToString(sum[:]) + `"`
+}
+
+func writeXML(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(http.StatusOK)
+	_ = xml.NewEncoder(w).Encode(v)
+}
+
+type errorResponse struct {
+	XMLName xml.Name `xml:"Error"`
+	Code    string   `xml:"Code"`
+}
+
+func writeError(w http.ResponseWriter, status int, code string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	_ = xml.NewEncoder(w).Encode(errorResponse{Code: code})
+}
@@ -0,0 +1,574 @@
+// Package mockapi serves a fake SafetyCulture and SHEQSY API from a directory of fixtures, so that
+// the exporters can be run end to end without access to a real organisation.
+//
+// The fixture directory is laid out as follows, every file being optional:
+//
+//	whoami.json                 the response of the WhoAmI endpoint
+//	feeds/<feed>.json           the rows of a feed, served in pages by /feed/<feed>
+//	activity_log.json           the events listed by the activity log
+//	audits/<audit_id>.json      the inspections returned by /audits/<audit_id>
+//	media/<media_id>.<ext>      the media files, the extension sets the content type
+//	reports/report.<ext>        the generated reports, report.pdf and report.docx
+//	sheqsy/company.json         the SHEQSY company
+//	sheqsy/departments.json     the SHEQSY departments
+//	sheqsy/employees.json       the SHEQSY employees
+//	sheqsy/activities.json      the SHEQSY activities, served in pages by version
+//	sheqsy/shifts.json          the SHEQSY shifts, served in pages by version
+//
+// The placeholder {base_url} in the fixtures is replaced with the URL of the server, so that media links
+// point back to it.
+package mockapi
+
+import (
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tidwall/gjson"
+)
+
+// DefaultPageSize is the number of records returned per page when the request doesn't set a limit
+const DefaultPageSize = 100
+
+const baseURLPlaceholder = "{base_url}"
+
+//go:embed seed
+var seed embed.FS
+
+// Seed returns the fixtures shipped with the exporter
+func Seed() fs.FS {
+	fixtures, err := fs.Sub(seed, "seed")
+	if err != nil {
+		panic(err)
+	}
+	return fixtures
+}
+
+// Server is an http.Handler mimicking the SafetyCulture and SHEQSY APIs
+type Server struct {
+	// PageSize is the number of records returned per page when the request doesn't set a limit
+	PageSize int
+	// ReportPendingPolls is the number of times a report is reported as in progress before it succeeds
+	ReportPendingPolls int
+
+	fixtures fs.FS
+	mux      *http.ServeMux
+
+	mu      sync.Mutex
+	reports map[string]*reportJob
+}
+
+type reportJob struct {
+	auditID string
+	format  string
+	polls   int
+}
+
+// NewServer creates a server serving the given fixtures
+func NewServer(fixtures fs.FS) *Server {
+	s := &Server{
+		PageSize: DefaultPageSize,
+		fixtures: fixtures,
+		mux:      http.NewServeMux(),
+		reports:  map[string]*reportJob{},
+	}
+
+	s.mux.HandleFunc("GET /accounts/user/v1/user:WhoAmI", s.handleWhoAmI)
+	s.mux.HandleFunc("GET /feed/{feed}", s.handleFeed)
+	s.mux.HandleFunc("GET /accounts/history/v2/feed/{feed}", s.handleFeed)
+	s.mux.HandleFunc("GET /training/v1/feed/{feed}", s.handleFeed)
+	s.mux.HandleFunc("POST /accounts/history/v1/activity_log/list", s.handleActivityLog)
+	s.mux.HandleFunc("GET /templates/v1/templates/search", s.handleTemplateSearch)
+	s.mux.HandleFunc("GET /audits/search", s.handleInspectionSearch)
+	s.mux.HandleFunc("GET /audits/{audit_id}", s.handleInspection)
+	s.mux.HandleFunc("GET /audits/{audit_id}/media/{media_id}", s.handleMedia)
+	s.mux.HandleFunc("POST /audits/{audit_id}/report", s.handleInitiateReport)
+	s.mux.HandleFunc("GET /audits/{audit_id}/report/{message_id}", s.handleReportCompletion)
+	s.mux.HandleFunc("GET /reports/{message_id}", s.handleReportDownload)
+	s.mux.HandleFunc("GET /SheqsyIntegrationApi/api/v3/companies/{company_id}", s.handleFixture("sheqsy/company.json"))
+	s.mux.HandleFunc("GET /SheqsyIntegrationApi/api/v3/companies/{company_id}/departments", s.handleFixture("sheqsy/departments.json"))
+	s.mux.HandleFunc("GET /SheqsyIntegrationApi/api/v3/companies/{company_id}/employees", s.handleFixture("sheqsy/employees.json"))
+	s.mux.HandleFunc("GET /SheqsyIntegrationApi/api/v3/companies/{company_id}/activities/history", s.handleSheqsyHistory("sheqsy/activities.json"))
+	s.mux.HandleFunc("GET /SheqsyIntegrationApi/api/v3/companies/{company_id}/shifts/history", s.handleSheqsyHistory("sheqsy/shifts.json"))
+
+	return s
+}
+
+// ServeHTTP serves the API requests
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+func (s *Server) handleWhoAmI(w http.ResponseWriter, r *http.Request) {
+	body, err := s.readFixture(r, "whoami.json")
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if body == nil {
+		body = []byte(`{"user_id":"user_1","organisation_id":"role_123","firstname":"Mock","lastname":"User"}`)
+	}
+	writeRaw(w, "application/json", body)
+}
+
+func (s *Server) handleFixture(name string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, err := s.readFixture(r, name)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		if body == nil {
+			writeError(w, http.StatusNotFound, fmt.Errorf("no fixture %s", name))
+			return
+		}
+		writeRaw(w, "application/json", body)
+	}
+}
+
+// handleFeed serves a page of a feed. The page starts at the offset held by next_page_token, and
+// next_page links to the following page with the same filters, as the real feeds do
+func (s *Server) handleFeed(w http.ResponseWriter, r *http.Request) {
+	rows, err := s.readRows(r, path.Join("feeds", r.PathValue("feed")+".json"))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	query := r.URL.Query()
+	if modifiedAfter := query.Get("modified_after"); modifiedAfter != "" {
+		after, err := time.Parse(time.RFC3339Nano, modifiedAfter)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid modified_after: %w", err))
+			return
+		}
+		rows = filterRows(rows, func(row json.RawMessage) bool {
+			return gjson.GetBytes(row, "modified_at").Time().After(after)
+		})
+	}
+
+	offset, err := parseOffset(query.Get("next_page_token"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	limit := s.limit(query.Get("limit"))
+
+	page, next := paginate(rows, offset, limit)
+
+	var nextPage *string
+	if next != 0 {
+		query.Set("next_page_token", strconv.Itoa(next))
+		link := r.URL.Path + "?" + query.Encode()
+		nextPage = &link
+	}
+
+	writeJSON(w, map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"next_page":         nextPage,
+			"remaining_records": len(rows) - offset - len(page),
+		},
+		"data": page,
+	})
+}
+
+func (s *Server) handleActivityLog(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		PageSize  int    `json:"page_size"`
+		PageToken string `json:"page_token"`
+		Filters   struct {
+			Timeframe struct {
+				From time.Time `json:"from"`
+			} `json:"timeframe"`
+			EventTypes []string `json:"event_types"`
+		} `json:"filters"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	activities, err := s.readRows(r, "activity_log.json")
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	activities = filterRows(activities, func(row json.RawMessage) bool {
+		if !gjson.GetBytes(row, "event_at").Time().After(req.Filters.Timeframe.From) {
+			return false
+		}
+		if len(req.Filters.EventTypes) == 0 {
+			return true
+		}
+		eventType := gjson.GetBytes(row, "type").String()
+		for _, t := range req.Filters.EventTypes {
+			if t == eventType {
+				return true
+			}
+		}
+		return false
+	})
+
+	offset, err := parseOffset(req.PageToken)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	limit := req.PageSize
+	if limit <= 0 {
+		limit = s.PageSize
+	}
+
+	page, next := paginate(activities, offset, limit)
+	writeJSON(w, map[string]interface{}{
+		"activities":      page,
+		"next_page_token": token(next),
+	})
+}
+
+func (s *Server) handleTemplateSearch(w http.ResponseWriter, r *http.Request) {
+	rows, err := s.readRows(r, "feeds/templates.json")
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	query := r.URL.Query()
+	offset, err := parseOffset(query.Get("page_token"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	page, next := paginate(rows, offset, s.limit(query.Get("page_size")))
+	items := make([]map[string]interface{}, 0, len(page))
+	for _, row := range page {
+		items = append(items, map[string]interface{}{
+			"id":          gjson.GetBytes(row, "id").String(),
+			"name":        gjson.GetBytes(row, "name").String(),
+			"modified_at": gjson.GetBytes(row, "modified_at").String(),
+		})
+	}
+
+	writeJSON(w, map[string]interface{}{
+		"next_page_token": token(next),
+		"items":           items,
+	})
+}
+
+// handleInspectionSearch lists the inspections modified after modified_after, oldest first
+func (s *Server) handleInspectionSearch(w http.ResponseWriter, r *http.Request) {
+	rows, err := s.readRows(r, "feeds/inspections.json")
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	query := r.URL.Query()
+	if modifiedAfter := query.Get("modified_after"); modifiedAfter != "" {
+		after, err := time.Parse(time.RFC3339Nano, modifiedAfter)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid modified_after: %w", err))
+			return
+		}
+		rows = filterRows(rows, func(row json.RawMessage) bool {
+			return gjson.GetBytes(row, "modified_at").Time().After(after)
+		})
+	}
+	sort.SliceStable(rows, func(i, j int) bool {
+		return gjson.GetBytes(rows[i], "modified_at").Time().Before(gjson.GetBytes(rows[j], "modified_at").Time())
+	})
+
+	page, _ := paginate(rows, 0, s.limit(query.Get("limit")))
+	audits := make([]map[string]interface{}, 0, len(page))
+	for _, row := range page {
+		audits = append(audits, map[string]interface{}{
+			"audit_id":    gjson.GetBytes(row, "id").String(),
+			"modified_at": gjson.GetBytes(row, "modified_at").String(),
+		})
+	}
+
+	writeJSON(w, map[string]interface{}{
+		"count":  len(audits),
+		"total":  len(rows),
+		"audits": audits,
+	})
+}
+
+// handleInspection serves the inspection fixture, or one built from the inspections feed when there is none
+func (s *Server) handleInspection(w http.ResponseWriter, r *http.Request) {
+	auditID := r.PathValue("audit_id")
+	body, err := s.readFixture(r, path.Join("audits", auditID+".json"))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if body != nil {
+		writeRaw(w, "application/json", body)
+		return
+	}
+
+	rows, err := s.readRows(r, "feeds/inspections.json")
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	for _, row := range rows {
+		if gjson.GetBytes(row, "id").String() != auditID {
+			continue
+		}
+		writeJSON(w, map[string]interface{}{
+			"audit_id":    auditID,
+			"template_id": gjson.GetBytes(row, "template_id").String(),
+			"archived":    gjson.GetBytes(row, "archived").Bool(),
+			"created_at":  gjson.GetBytes(row, "created_at").String(),
+			"modified_at": gjson.GetBytes(row, "modified_at").String(),
+			"audit_data": map[string]interface{}{
+				"name":        gjson.GetBytes(row, "name").String(),
+				"score":       gjson.GetBytes(row, "score").Float(),
+				"total_score": gjson.GetBytes(row, "max_score").Float(),
+			},
+		})
+		return
+	}
+	writeError(w, http.StatusNotFound, fmt.Errorf("inspection %s not found", auditID))
+}
+
+// handleMedia serves the media file, with no content when there is no fixture for it
+func (s *Server) handleMedia(w http.ResponseWriter, r *http.Request) {
+	matches, err := fs.Glob(s.fixtures, path.Join("media", r.PathValue("media_id")+".*"))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if len(matches) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	body, err := fs.ReadFile(s.fixtures, matches[0])
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	contentType := mime.TypeByExtension(path.Ext(matches[0]))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	writeRaw(w, contentType, body)
+}
+
+func (s *Server) handleInitiateReport(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Format string `json:"format"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if req.Format != "PDF" && req.Format != "WORD" {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("unsupported format %q", req.Format))
+		return
+	}
+
+	s.mu.Lock()
+	messageID := fmt.Sprintf("message_%d", len(s.reports)+1)
+	s.reports[messageID] = &reportJob{auditID: r.PathValue("audit_id"), format: req.Format}
+	s.mu.Unlock()
+
+	writeJSON(w, map[string]string{"messageId": messageID})
+}
+
+func (s *Server) handleReportCompletion(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	job, ok := s.reports[r.PathValue("message_id")]
+	if ok {
+		job.polls++
+	}
+	s.mu.Unlock()
+
+	if !ok || job.auditID != r.PathValue("audit_id") {
+		writeError(w, http.StatusNotFound, fmt.Errorf("report %s not found", r.PathValue("message_id")))
+		return
+	}
+
+	if job.polls <= s.ReportPendingPolls {
+		writeJSON(w, map[string]string{"status": "IN_PROGRESS"})
+		return
+	}
+	writeJSON(w, map[string]string{
+		"status": "SUCCESS",
+		"url":    baseURL(r) + "/reports/" + url.PathEscape(r.PathValue("message_id")),
+	})
+}
+
+func (s *Server) handleReportDownload(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	job, ok := s.reports[r.PathValue("message_id")]
+	s.mu.Unlock()
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Errorf("report %s not found", r.PathValue("message_id")))
+		return
+	}
+
+	name, contentType := "reports/report.pdf", "application/pdf"
+	if job.format == "WORD" {
+		name, contentType = "reports/report.docx", "application/vnd.openxmlformats-officedocument.wordprocessingml.document"
+	}
+
+	body, err := fs.ReadFile(s.fixtures, name)
+	if err != nil {
+		writeError(w, http.StatusNotFound, fmt.Errorf("no fixture %s", name))
+		return
+	}
+	writeRaw(w, contentType, body)
+}
+
+// handleSheqsyHistory serves a page of a SHEQSY history. ver is the position of the first item, starting at 1
+func (s *Server) handleSheqsyHistory(name string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		items, err := s.readRows(r, name)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		version := 1
+		if ver := r.URL.Query().Get("ver"); ver != "" {
+			if version, err = strconv.Atoi(ver); err != nil || version < 1 {
+				writeError(w, http.StatusBadRequest, fmt.Errorf("invalid ver %q", ver))
+				return
+			}
+		}
+
+		page, next := paginate(items, version-1, s.PageSize)
+		writeJSON(w, map[string]interface{}{
+			"data":         page,
+			"lastVersion":  version + len(page),
+			"hasMoreItems": next != 0,
+			"itemsLeft":    len(items) - (version - 1) - len(page),
+		})
+	}
+}
+
+// readFixture reads a fixture, nil is returned when it doesn't exist
+func (s *Server) readFixture(r *http.Request, name string) ([]byte, error) {
+	body, err := fs.ReadFile(s.fixtures, name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return []byte(strings.ReplaceAll(string(body), baseURLPlaceholder, baseURL(r))), nil
+}
+
+// readRows reads a fixture holding an array of records, a missing fixture has no records
+func (s *Server) readRows(r *http.Request, name string) ([]json.RawMessage, error) {
+	body, err := s.readFixture(r, name)
+	if err != nil || body == nil {
+		return nil, err
+	}
+
+	var rows []json.RawMessage
+	if err := json.Unmarshal(body, &rows); err != nil {
+		return nil, fmt.Errorf("parse fixture %s: %w", name, err)
+	}
+	return rows, nil
+}
+
+func (s *Server) limit(value string) int {
+	if limit, err := strconv.Atoi(value); err == nil && limit > 0 {
+		return limit
+	}
+	return s.PageSize
+}
+
+// paginate returns the records of the page starting at offset, and the offset of the next page or 0 if it's the last one
+func paginate(rows []json.RawMessage, offset int, limit int) ([]json.RawMessage, int) {
+	if offset > len(rows) {
+		offset = len(rows)
+	}
+	end := offset + limit
+	if end >= len(rows) {
+		return nonNil(rows[offset:]), 0
+	}
+	return rows[offset:end], end
+}
+
+func nonNil(rows []json.RawMessage) []json.RawMessage {
+	if rows == nil {
+		return []json.RawMessage{}
+	}
+	return rows
+}
+
+func filterRows(rows []json.RawMessage, keep func(json.RawMessage) bool) []json.RawMessage {
+	var filtered []json.RawMessage
+	for _, row := range rows {
+		if keep(row) {
+			filtered = append(filtered, row)
+		}
+	}
+	return filtered
+}
+
+func parseOffset(value string) (int, error) {
+	if value == "" {
+		return 0, nil
+	}
+	offset, err := strconv.Atoi(value)
+	if err != nil || offset < 0 {
+		return 0, fmt.Errorf("invalid page token %q", value)
+	}
+	return offset, nil
+}
+
+func token(offset int) string {
+	if offset == 0 {
+		return ""
+	}
+	return strconv.Itoa(offset)
+}
+
+func baseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeRaw(w, "application/json", body)
+}
+
+func writeRaw(w http.ResponseWriter, contentType string, body []byte) {
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(body)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	body, _ := json.Marshal(map[string]string{"error": err.Error()})
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, _ = w.Write(body)
+}
@@ -0,0 +1,142 @@
+package mockapi_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+
+	"github.com/SafetyCulture/safetyculture-exporter/pkg/mockapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type feedResponse struct {
+	Metadata struct {
+		NextPage         *string `json:"next_page"`
+		RemainingRecords int     `json:"remaining_records"`
+	} `json:"metadata"`
+	Data []map[string]interface{} `json:"data"`
+}
+
+func serve(t *testing.T, srv http.Handler, method string, url string, body string, v interface{}) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, url, bytes.NewBufferString(body))
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, req)
+	if v != nil {
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), v))
+	}
+	return rec
+}
+
+func TestServer_should_paginate_feeds(t *testing.T) {
+	srv := mockapi.NewServer(mockapi.Seed())
+
+	var page feedResponse
+	serve(t, srv, http.MethodGet, "/feed/users?limit=3", "", &page)
+	require.Len(t, page.Data, 3)
+	assert.Equal(t, "user_1", page.Data[0]["id"])
+	assert.Equal(t, 1, page.Metadata.RemainingRecords)
+	require.NotNil(t, page.Metadata.NextPage)
+	assert.Equal(t, "/feed/users?limit=3&next_page_token=3", *page.Metadata.NextPage)
+
+	serve(t, srv, http.MethodGet, *page.Metadata.NextPage, "", &page)
+	require.Len(t, page.Data, 1)
+	assert.Equal(t, "user_4", page.Data[0]["id"])
+	assert.Equal(t, 0, page.Metadata.RemainingRecords)
+	assert.Nil(t, page.Metadata.NextPage)
+}
+
+func TestServer_should_filter_feeds_modified_after(t *testing.T) {
+	srv := mockapi.NewServer(fstest.MapFS{
+		"feeds/sites.json": {Data: []byte(`[
+			{"site_id": "site_1", "modified_at": "2023-01-01T00:00:00Z"},
+			{"site_id": "site_2", "modified_at": "2023-02-01T00:00:00Z"}
+		]`)},
+	})
+
+	var page feedResponse
+	serve(t, srv, http.MethodGet, "/feed/sites?modified_after=2023-01-15T00:00:00Z", "", &page)
+	require.Len(t, page.Data, 1)
+	assert.Equal(t, "site_2", page.Data[0]["site_id"])
+
+	serve(t, srv, http.MethodGet, "/feed/unknown", "", &page)
+	assert.Empty(t, page.Data)
+	assert.Nil(t, page.Metadata.NextPage)
+}
+
+func TestServer_should_filter_activity_log(t *testing.T) {
+	srv := mockapi.NewServer(mockapi.Seed())
+
+	var resp struct {
+		Activities    []map[string]interface{} `json:"activities"`
+		NextPageToken string                   `json:"next_page_token"`
+	}
+	serve(t, srv, http.MethodPost, "/accounts/history/v1/activity_log/list", `{"page_size": 10, "filters": {"event_types": ["action.actions_deleted"]}}`, &resp)
+	require.Len(t, resp.Activities, 1)
+	assert.Equal(t, "action.actions_deleted", resp.Activities[0]["type"])
+	assert.Empty(t, resp.NextPageToken)
+}
+
+func TestServer_should_page_sheqsy_history_by_version(t *testing.T) {
+	srv := mockapi.NewServer(mockapi.Seed())
+	srv.PageSize = 30
+
+	var resp struct {
+		Data         []map[string]interface{} `json:"data"`
+		LastVersion  int                      `json:"lastVersion"`
+		HasMoreItems bool                     `json:"hasMoreItems"`
+		ItemsLeft    int                      `json:"itemsLeft"`
+	}
+	serve(t, srv, http.MethodGet, "/SheqsyIntegrationApi/api/v3/companies/company_1/shifts/history?ver=1", "", &resp)
+	assert.Len(t, resp.Data, 30)
+	assert.Equal(t, 31, resp.LastVersion)
+	assert.True(t, resp.HasMoreItems)
+	assert.Equal(t, 7, resp.ItemsLeft)
+
+	serve(t, srv, http.MethodGet, "/SheqsyIntegrationApi/api/v3/companies/company_1/shifts/history?ver=31", "", &resp)
+	assert.Len(t, resp.Data, 7)
+	assert.False(t, resp.HasMoreItems)
+	assert.Equal(t, 0, resp.ItemsLeft)
+}
+
+func TestServer_should_serve_media(t *testing.T) {
+	srv := mockapi.NewServer(mockapi.Seed())
+
+	rec := serve(t, srv, http.MethodGet, "/audits/audit_1/media/12345", "", nil)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "image/jpeg", rec.Header().Get("Content-Type"))
+
+	rec = serve(t, srv, http.MethodGet, "/audits/audit_1/media/unknown", "", nil)
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+}
+
+func TestServer_should_generate_reports(t *testing.T) {
+	srv := mockapi.NewServer(mockapi.Seed())
+	srv.ReportPendingPolls = 1
+
+	var initiated struct {
+		MessageID string `json:"messageId"`
+	}
+	serve(t, srv, http.MethodPost, "/audits/audit_1/report", `{"format": "PDF"}`, &initiated)
+	require.NotEmpty(t, initiated.MessageID)
+
+	var completion struct {
+		Status string `json:"status"`
+		URL    string `json:"url"`
+	}
+	serve(t, srv, http.MethodGet, "/audits/audit_1/report/"+initiated.MessageID, "", &completion)
+	assert.Equal(t, "IN_PROGRESS", completion.Status)
+
+	serve(t, srv, http.MethodGet, "/audits/audit_1/report/"+initiated.MessageID, "", &completion)
+	assert.Equal(t, "SUCCESS", completion.Status)
+	assert.Equal(t, "http://example.com/reports/"+initiated.MessageID, completion.URL)
+
+	rec := serve(t, srv, http.MethodGet, completion.URL, "", nil)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/pdf", rec.Header().Get("Content-Type"))
+	assert.Contains(t, rec.Body.String(), "%PDF")
+}